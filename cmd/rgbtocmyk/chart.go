@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/davesmith10/RGBtoCMYK/internal/chart"
+	"github.com/davesmith10/RGBtoCMYK/internal/color"
+	"github.com/davesmith10/RGBtoCMYK/internal/jpeg"
+	"github.com/davesmith10/RGBtoCMYK/internal/tiff"
+	"github.com/spf13/cobra"
+)
+
+var chartCmd = &cobra.Command{
+	Use:   "chart",
+	Short: "Generate a printable CMYK test chart and its CGATS reference file",
+	RunE:  runChart,
+}
+
+func init() {
+	chartCmd.Flags().StringP("output", "o", "", "Output chart image (.tif for lossless, otherwise JPEG)")
+	chartCmd.Flags().String("cgats", "", "Output CGATS reference file (default: output name with .txt)")
+	chartCmd.Flags().String("set", "standard", "Patch set (standard, basic)")
+	chartCmd.Flags().String("page", "a4", "Page size (a4, a3, letter, tabloid or WIDTHxHEIGHT in mm)")
+	chartCmd.Flags().Float64("patch-size", 6, "Patch size (mm)")
+	chartCmd.Flags().Float64("margin", 10, "Page margin (mm)")
+	chartCmd.Flags().Float64("dpi", 300, "Output resolution (pixels per inch)")
+	chartCmd.Flags().String("order", "visual", "Patch order (visual, random)")
+	chartCmd.Flags().Int64("seed", 1, "Random order seed")
+	chartCmd.Flags().String("icc", "", "ICC profile to embed in JPEG output (path or built-in name)")
+	chartCmd.MarkFlagRequired("output")
+	rootCmd.AddCommand(chartCmd)
+}
+
+func runChart(cmd *cobra.Command, args []string) error {
+	outputPath, _ := cmd.Flags().GetString("output")
+	cgatsPath, _ := cmd.Flags().GetString("cgats")
+	set, _ := cmd.Flags().GetString("set")
+	pageStr, _ := cmd.Flags().GetString("page")
+	patchSize, _ := cmd.Flags().GetFloat64("patch-size")
+	margin, _ := cmd.Flags().GetFloat64("margin")
+	dpi, _ := cmd.Flags().GetFloat64("dpi")
+	order, _ := cmd.Flags().GetString("order")
+	seed, _ := cmd.Flags().GetInt64("seed")
+	iccPath, _ := cmd.Flags().GetString("icc")
+
+	pageW, pageH, err := chart.ParsePageSize(pageStr)
+	if err != nil {
+		return err
+	}
+
+	patches, err := chart.GeneratePatches(set)
+	if err != nil {
+		return err
+	}
+	patches, err = chart.OrderPatches(patches, order, seed)
+	if err != nil {
+		return err
+	}
+
+	var icc []byte
+	if iccPath != "" {
+		icc, err = color.ResolveProfile(iccPath)
+		if err != nil {
+			return profileError(fmt.Errorf("loading ICC profile: %w", err))
+		}
+	}
+
+	title := fmt.Sprintf("RGBtoCMYK %s %s", set, order)
+	pages, samples, err := chart.Render(patches, chart.Layout{
+		PageWidth:  pageW,
+		PageHeight: pageH,
+		PatchSize:  patchSize,
+		Margin:     margin,
+		DPI:        dpi,
+		Title:      title,
+	})
+	if err != nil {
+		return err
+	}
+
+	ext := filepath.Ext(outputPath)
+	base := strings.TrimSuffix(outputPath, ext)
+	lossless := strings.EqualFold(ext, ".tif") || strings.EqualFold(ext, ".tiff")
+	run.lap("render")
+
+	report := &chartReport{Set: set, Order: order, Profile: describeProfile(iccPath, icc)}
+	for i, pg := range pages {
+		path := outputPath
+		if len(pages) > 1 {
+			path = fmt.Sprintf("%s-p%d%s", base, i+1, ext)
+		}
+
+		var data []byte
+		if lossless {
+			data, err = tiff.EncodeCMYK(pg.Pixels, pg.Width, pg.Height, dpi)
+		} else {
+			// Every plate at full quality: a measurement target must not
+			// lose its colour plates to the usual CMY reduction.
+			data, err = jpeg.EncodeCMYK(pg.Pixels, pg.Width, pg.Height, icc, jpeg.EncoderOptions{
+				ChannelQuality: [4]int{100, 100, 100, 100},
+				Density:        jpeg.Density{X: dpi, Y: dpi},
+			})
+		}
+		if err != nil {
+			return encodeError(fmt.Errorf("encoding page %d: %w", i+1, err))
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return outputError(fmt.Errorf("writing chart: %w", err))
+		}
+		report.Pages = append(report.Pages, fileReport{Path: path, Bytes: len(data), Width: pg.Width, Height: pg.Height})
+		if !jsonOutput() {
+			fmt.Printf("Page %d: %s (%dx%d, %d bytes)\n", i+1, path, pg.Width, pg.Height, len(data))
+		}
+	}
+
+	if cgatsPath == "" {
+		cgatsPath = base + ".txt"
+	}
+	f, err := os.Create(cgatsPath)
+	if err != nil {
+		return outputError(fmt.Errorf("writing CGATS: %w", err))
+	}
+	err = chart.WriteCGATS(f, samples, title, time.Now())
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return outputError(fmt.Errorf("writing CGATS: %w", err))
+	}
+	run.lap("write")
+
+	report.Patches, report.CGATS = len(samples), cgatsPath
+	if reportJSON(report) {
+		return nil
+	}
+
+	fmt.Printf("Patches: %d (%s set, %s order)\n", len(samples), set, order)
+	fmt.Printf("CGATS:   %s\n", cgatsPath)
+	return nil
+}
+
+// chartReport is the JSON form of the chart output.
+type chartReport struct {
+	Pages   []fileReport   `json:"pages"`
+	CGATS   string         `json:"cgats"`
+	Patches int            `json:"patches"`
+	Set     string         `json:"set"`
+	Order   string         `json:"order"`
+	Profile *profileReport `json:"profile"`
+}
@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/davesmith10/RGBtoCMYK/internal/black"
 	"github.com/davesmith10/RGBtoCMYK/internal/color"
+	"github.com/davesmith10/RGBtoCMYK/internal/fidelity"
+	"github.com/davesmith10/RGBtoCMYK/internal/jpeg"
 	"github.com/davesmith10/RGBtoCMYK/internal/pipeline"
 	"github.com/spf13/cobra"
 )
@@ -12,75 +15,370 @@ import (
 var convertCmd = &cobra.Command{
 	Use:   "convert",
 	Short: "Convert an RGB JPEG to CMYK JPEG",
-	RunE:  runConvert,
+	Long: `Separates an RGB, YCbCr or grayscale JPEG into CMYK through an ICC
+profile and encodes it as a CMYK JPEG.
+
+EXIF, XMP and IPTC metadata is copied, with the dimensions, colour space,
+colour mode and resolution rewritten for the output. Embedded thumbnails in
+EXIF (IFD1) and the Photoshop resources are removed, not regenerated, since
+they would show the RGB original.`,
+	RunE: runConvert,
 }
 
 func init() {
 	convertCmd.Flags().StringP("input", "i", "", "Input RGB JPEG file")
-	convertCmd.Flags().StringP("output", "o", "", "Output CMYK JPEG file")
-	convertCmd.Flags().String("profile", "", "CMYK ICC profile path")
+	convertCmd.Flags().StringArrayP("output", "o", nil, "Output CMYK JPEG file (repeat, one per --profile)")
+	convertCmd.Flags().StringArray("profile", []string{color.DefaultCMYKProfile}, "CMYK ICC profile path or built-in name (repeat for several outputs)")
 	convertCmd.Flags().String("src-profile", "", "Source RGB ICC profile override")
-	convertCmd.Flags().Int("quality", 85, "JPEG quality (1-100)")
+	convertCmd.Flags().String("assume-profile", "", "Source profile for untagged input, skipping EXIF/XMP hints")
+	convertCmd.Flags().IntSlice("quality", []int{85}, "JPEG quality (1-100), one value or one per --profile")
 	convertCmd.Flags().Int("cmy-reduction", 15, "Quality reduction for CMY channels vs K")
-	convertCmd.Flags().String("intent", "perceptual", "Rendering intent (perceptual, relative, saturation, absolute)")
+	addQuantFlags(convertCmd)
+	addScanFlags(convertCmd)
+	addTargetFlags(convertCmd)
+	addConventionFlags(convertCmd)
+	convertCmd.Flags().StringSlice("intent", []string{"perceptual"}, "Rendering intent (perceptual, relative, saturation, absolute), one value or one per --profile")
+	convertCmd.Flags().String("black", "off", "Pure-black handling (off, auto, k-only, rich)")
+	convertCmd.Flags().String("rich-black", "60/40/40/100", "Rich-black recipe C/M/Y/K in percent")
+	convertCmd.Flags().Int("black-min-area", black.DefaultOptions().MinArea, "Smallest black region in pixels filled rich in auto mode")
+	convertCmd.Flags().Int("black-min-width", black.DefaultOptions().MinWidth, "Thinnest black region in pixels filled rich in auto mode")
+	convertCmd.Flags().Uint8("black-tolerance", black.DefaultOptions().Tolerance, "Largest RGB value counted as pure black, and largest channel spread of a neutral edge pixel")
+	convertCmd.Flags().Int("black-edge", black.DefaultOptions().Edge, "Width in pixels of the neutral anti-aliased edge around K-only black also made K-only (0 disables)")
+	convertCmd.Flags().Float64("dpi", 0, "Print resolution in pixels per inch (default: the input's JFIF or EXIF resolution)")
+	convertCmd.Flags().Bool("strip-metadata", false, "Do not copy EXIF, XMP and IPTC metadata from the input")
+	convertCmd.Flags().Bool("strip-gps", false, "Remove GPS location data from the copied metadata")
+	convertCmd.Flags().Bool("inks", false, "Print an ink coverage report and usage estimate for the result")
+	addInkFlags(convertCmd)
 	convertCmd.MarkFlagRequired("input")
 	convertCmd.MarkFlagRequired("output")
-	convertCmd.MarkFlagRequired("profile")
 	rootCmd.AddCommand(convertCmd)
 }
 
 func runConvert(cmd *cobra.Command, args []string) error {
 	inputPath, _ := cmd.Flags().GetString("input")
-	outputPath, _ := cmd.Flags().GetString("output")
-	profilePath, _ := cmd.Flags().GetString("profile")
+	outputPaths, _ := cmd.Flags().GetStringArray("output")
+	profilePaths, _ := cmd.Flags().GetStringArray("profile")
 	srcProfilePath, _ := cmd.Flags().GetString("src-profile")
-	quality, _ := cmd.Flags().GetInt("quality")
+	assumePath, _ := cmd.Flags().GetString("assume-profile")
+	qualities, _ := cmd.Flags().GetIntSlice("quality")
 	cmyReduction, _ := cmd.Flags().GetInt("cmy-reduction")
-	intentStr, _ := cmd.Flags().GetString("intent")
+	intentStrs, _ := cmd.Flags().GetStringSlice("intent")
+	showInks, _ := cmd.Flags().GetBool("inks")
+	dpi, _ := cmd.Flags().GetFloat64("dpi")
+	stripMetadata, _ := cmd.Flags().GetBool("strip-metadata")
+	stripGPS, _ := cmd.Flags().GetBool("strip-gps")
+	blackMode, _ := cmd.Flags().GetString("black")
+	richBlack, _ := cmd.Flags().GetString("rich-black")
+	blackMinArea, _ := cmd.Flags().GetInt("black-min-area")
+	blackMinWidth, _ := cmd.Flags().GetInt("black-min-width")
+	blackTolerance, _ := cmd.Flags().GetUint8("black-tolerance")
+	blackEdge, _ := cmd.Flags().GetInt("black-edge")
+
+	n := len(profilePaths)
+	if len(outputPaths) != n {
+		return fmt.Errorf("got %d --output paths for %d --profile values", len(outputPaths), n)
+	}
+	if len(qualities) != 1 && len(qualities) != n {
+		return fmt.Errorf("--quality needs one value or one per --profile, got %d", len(qualities))
+	}
+	if len(intentStrs) != 1 && len(intentStrs) != n {
+		return fmt.Errorf("--intent needs one value or one per --profile, got %d", len(intentStrs))
+	}
 
-	intent, err := color.ParseIntent(intentStr)
+	channelQuality, baseTables, err := quantOptions(cmd)
+	if err != nil {
+		return err
+	}
+	progressive, scans, err := scanOptions(cmd)
+	if err != nil {
+		return err
+	}
+	targetSize, target, err := targetOptions(cmd)
+	if err != nil {
+		return err
+	}
+	convention, ycck, err := conventionOptions(cmd)
 	if err != nil {
 		return err
 	}
 
-	inputData, err := os.ReadFile(inputPath)
+	tacLimit, rates, err := inkOptions(cmd)
 	if err != nil {
-		return fmt.Errorf("reading input: %w", err)
+		return err
+	}
+
+	blackOpts := black.DefaultOptions()
+	if blackOpts.Mode, err = black.ParseMode(blackMode); err != nil {
+		return err
+	}
+	if blackOpts.Recipe, err = black.ParseRecipe(richBlack); err != nil {
+		return err
+	}
+	blackOpts.MinArea = blackMinArea
+	blackOpts.MinWidth = blackMinWidth
+	blackOpts.Tolerance = blackTolerance
+	blackOpts.Edge = blackEdge
+	if blackEdge < 0 {
+		return fmt.Errorf("--black-edge must not be negative")
 	}
 
-	dstProfile, err := color.LoadProfile(profilePath)
+	inputData, err := os.ReadFile(inputPath)
+	if err != nil {
+		return inputError(fmt.Errorf("reading input: %w", err))
+	}
+	info, err := jpeg.GetInfo(inputData)
 	if err != nil {
-		return fmt.Errorf("loading CMYK profile: %w", err)
+		return inputError(fmt.Errorf("reading input: %w", err))
 	}
+	run.lap("read")
 
 	var srcProfile []byte
 	if srcProfilePath != "" {
-		srcProfile, err = color.LoadProfile(srcProfilePath)
+		srcProfile, err = color.ResolveProfile(srcProfilePath)
 		if err != nil {
-			return fmt.Errorf("loading source profile: %w", err)
+			return profileError(fmt.Errorf("loading source profile: %w", err))
 		}
 	}
 
-	opts := pipeline.Options{
-		SrcProfileOverride: srcProfile,
-		DstProfile:         dstProfile,
-		Quality:            quality,
-		CMYReduction:       cmyReduction,
-		Intent:             intent,
+	var assumeProfile []byte
+	if assumePath != "" {
+		assumeProfile, err = color.ResolveProfile(assumePath)
+		if err != nil {
+			return profileError(fmt.Errorf("loading assumed profile: %w", err))
+		}
 	}
 
-	result, err := pipeline.Run(inputData, opts)
-	if err != nil {
-		return fmt.Errorf("conversion: %w", err)
+	opts := make([]pipeline.Options, n)
+	for t := range opts {
+		dstProfile, err := color.ResolveProfile(profilePaths[t])
+		if err != nil {
+			return profileError(fmt.Errorf("loading CMYK profile %s: %w", profilePaths[t], err))
+		}
+		intent, err := color.ParseIntent(intentStrs[min(t, len(intentStrs)-1)])
+		if err != nil {
+			return err
+		}
+		opts[t] = pipeline.Options{
+			SrcProfileOverride: srcProfile,
+			AssumeProfile:      assumeProfile,
+			DstProfile:         dstProfile,
+			Quality:            qualities[min(t, len(qualities)-1)],
+			CMYReduction:       cmyReduction,
+			ChannelQuality:     channelQuality,
+			BaseTables:         baseTables,
+			Intent:             intent,
+			Black:              blackOpts,
+			Progressive:        progressive,
+			Scans:              scans,
+			TargetSize:         targetSize,
+			Target:             target,
+			Convention:         convention,
+			YCCK:               ycck,
+			StripMetadata:      stripMetadata,
+			StripGPS:           stripGPS,
+			DPI:                dpi,
+			Software:           software(),
+		}
 	}
 
-	if err := os.WriteFile(outputPath, result.Data, 0644); err != nil {
-		return fmt.Errorf("writing output: %w", err)
+	var results []*pipeline.Result
+	if n == 1 {
+		result, err := pipeline.Run(inputData, opts[0])
+		if err != nil {
+			return fmt.Errorf("conversion: %w", err)
+		}
+		results = []*pipeline.Result{result}
+	} else {
+		results, err = pipeline.RunAll(inputData, opts, true)
+		if err != nil {
+			return fmt.Errorf("conversion: %w", err)
+		}
+	}
+	run.lap("convert")
+
+	for t, result := range results {
+		if err := os.WriteFile(outputPaths[t], result.Data, 0644); err != nil {
+			return outputError(fmt.Errorf("writing output: %w", err))
+		}
+	}
+	run.lap("write")
+
+	first := results[0]
+	for _, w := range first.Warnings {
+		warn("%s", w)
+	}
+	for t, result := range results {
+		dest := ""
+		if n > 1 {
+			dest = profilePaths[t]
+		}
+		intentWarning(dest, opts[t].Intent, result.Intent)
+	}
+	var reports []inkReport
+	if showInks {
+		for _, result := range results {
+			w, h := printSizeMM(result.Density, result.SrcWidth, result.SrcHeight)
+			report, err := analyzeInks(result.CMYK, result.SrcWidth, result.SrcHeight, tacLimit, rates, w, h)
+			if err != nil {
+				return err
+			}
+			reports = append(reports, report)
+		}
+	}
+	if reportJSON(newConvertReport(inputPath, inputData, info, profilePaths, outputPaths, opts, results, reports)) {
+		return nil
 	}
 
-	fmt.Printf("Converted %dx%d RGB → CMYK\n", result.SrcWidth, result.SrcHeight)
+	fmt.Printf("Converted %dx%d RGB → CMYK\n", first.SrcWidth, first.SrcHeight)
 	fmt.Printf("Input:  %s (%d bytes)\n", inputPath, len(inputData))
-	fmt.Printf("Output: %s (%d bytes)\n", outputPath, len(result.Data))
+	fmt.Printf("Source: %s\n", first.SrcReason)
+	if first.Density.Known() {
+		w, h := first.Density.PrintSize(first.SrcWidth, first.SrcHeight)
+		fmt.Printf("Resolution: %s (%s), %.2f x %.2f in\n", first.Density, first.DensityFrom, w, h)
+	}
+	for t, result := range results {
+		if n > 1 {
+			fmt.Printf("\n[%s]\n", profilePaths[t])
+		}
+		fmt.Printf("Output: %s (%d bytes)\n", outputPaths[t], len(result.Data))
+		switch {
+		case target.Value > 0:
+			printScoreTarget(result.Quality, result.CMYReduction, target, result.Fidelity)
+		case targetSize > 0:
+			printTargetSettings(result.Quality, result.CMYReduction, len(result.Data), targetSize)
+		}
+
+		if blackOpts.Mode != black.Off {
+			b := result.Black
+			fmt.Printf("Black:  %d K-only pixels, %d rich-black pixels", b.KOnlyPixels, b.RichPixels)
+			if blackOpts.Mode == black.Auto {
+				fmt.Printf(" (%d of %d regions rich)", b.RichRegions, b.Regions)
+			}
+			if b.EdgePixels > 0 {
+				fmt.Printf(", %d edge pixels K-only", b.EdgePixels)
+			}
+			fmt.Println()
+		}
+
+		if showInks {
+			fmt.Println()
+			printInkReport(os.Stdout, reports[t])
+		}
+	}
 
+	if n > 1 {
+		printComparison(profilePaths, results)
+	}
 	return nil
 }
+
+// printComparison writes the fan-out summary table.
+func printComparison(profiles []string, results []*pipeline.Result) {
+	fmt.Printf("\n%-24s %12s %12s %10s\n", "Profile", "Size", "Out of gamut", "Mean ΔE")
+	for t, r := range results {
+		if r.Gamut == nil {
+			fmt.Printf("%-24s %12d %12s %10s\n", profiles[t], len(r.Data), "—", "—")
+			continue
+		}
+		fmt.Printf("%-24s %12d %11.1f%% %10.2f\n", profiles[t], len(r.Data), r.Gamut.OutOfGamut, r.Gamut.MeanDeltaE)
+	}
+}
+
+// convertReport is the JSON form of the convert output.
+type convertReport struct {
+	Input           fileReport      `json:"input"`
+	ColorSpace      string          `json:"color_space"`
+	EmbeddedProfile *profileReport  `json:"embedded_profile"`
+	SourceProfile   *profileReport  `json:"source_profile"`
+	Resolution      *densityReport  `json:"resolution"`
+	Outputs         []convertOutput `json:"outputs"`
+}
+
+type convertOutput struct {
+	fileReport
+	Profile         *profileReport  `json:"profile"`
+	Intent          string          `json:"intent"`
+	RequestedIntent string          `json:"requested_intent"`
+	Quality         int             `json:"quality"`
+	CMYReduction    int             `json:"cmy_reduction"`
+	Black           *blackReport    `json:"black,omitempty"`
+	Gamut           *gamutReport    `json:"gamut,omitempty"`
+	Fidelity        *fidelityReport `json:"fidelity,omitempty"`
+	Inks            *inkReport      `json:"inks,omitempty"`
+}
+
+type densityReport struct {
+	XDPI float64 `json:"x_dpi"`
+	YDPI float64 `json:"y_dpi"`
+	From string  `json:"from"`
+}
+
+type blackReport struct {
+	KOnlyPixels int `json:"k_only_pixels"`
+	RichPixels  int `json:"rich_pixels"`
+	Regions     int `json:"regions"`
+	RichRegions int `json:"rich_regions"`
+	EdgePixels  int `json:"edge_pixels"`
+}
+
+type gamutReport struct {
+	OutOfGamut float64 `json:"out_of_gamut_percent"`
+	MeanDeltaE float64 `json:"mean_delta_e"`
+}
+
+type fidelityReport struct {
+	SSIM         [4]float64 `json:"ssim"`
+	PSNR         [4]float64 `json:"psnr_db"`
+	WeightedSSIM float64    `json:"weighted_ssim"`
+	WeightedPSNR float64    `json:"weighted_psnr_db"`
+}
+
+func newFidelityReport(s *fidelity.Scores) *fidelityReport {
+	if s == nil {
+		return nil
+	}
+	return &fidelityReport{SSIM: s.SSIM, PSNR: s.PSNR, WeightedSSIM: s.WeightedSSIM, WeightedPSNR: s.WeightedPSNR}
+}
+
+func newDensityReport(d jpeg.Density, from string) *densityReport {
+	if !d.Known() {
+		return nil
+	}
+	return &densityReport{XDPI: d.X, YDPI: d.Y, From: from}
+}
+
+func newConvertReport(inputPath string, inputData []byte, info *jpeg.ImageInfo, profilePaths, outputPaths []string,
+	opts []pipeline.Options, results []*pipeline.Result, inkReports []inkReport) *convertReport {
+	first := results[0]
+	r := &convertReport{
+		Input:           fileReport{Path: inputPath, Bytes: len(inputData), Width: first.SrcWidth, Height: first.SrcHeight},
+		ColorSpace:      info.ColorSpace,
+		EmbeddedProfile: describeProfile("", info.ICC),
+		SourceProfile:   describeProfile("", first.SrcICC),
+		Resolution:      newDensityReport(first.Density, first.DensityFrom),
+	}
+	r.SourceProfile.Reason = first.SrcReason
+	for t, result := range results {
+		out := convertOutput{
+			fileReport:      fileReport{Path: outputPaths[t], Bytes: len(result.Data), Width: result.SrcWidth, Height: result.SrcHeight},
+			Profile:         describeProfile(profilePaths[t], opts[t].DstProfile),
+			Intent:          color.IntentName(result.Intent),
+			RequestedIntent: color.IntentName(opts[t].Intent),
+			Quality:         result.Quality,
+			CMYReduction:    result.CMYReduction,
+			Fidelity:        newFidelityReport(result.Fidelity),
+		}
+		if opts[t].Black.Mode != black.Off {
+			b := result.Black
+			out.Black = &blackReport{KOnlyPixels: b.KOnlyPixels, RichPixels: b.RichPixels, Regions: b.Regions, RichRegions: b.RichRegions, EdgePixels: b.EdgePixels}
+		}
+		if g := result.Gamut; g != nil {
+			out.Gamut = &gamutReport{OutOfGamut: g.OutOfGamut, MeanDeltaE: g.MeanDeltaE}
+		}
+		if inkReports != nil {
+			out.Inks = &inkReports[t]
+		}
+		r.Outputs = append(r.Outputs, out)
+	}
+	return r
+}
@@ -3,7 +3,10 @@ package main
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 
+	"github.com/davesmith10/RGBtoCMYK/internal/fidelity"
 	"github.com/davesmith10/RGBtoCMYK/internal/jpeg"
 	"github.com/spf13/cobra"
 )
@@ -22,6 +25,11 @@ func init() {
 	encodeCmd.Flags().Int("height", 0, "Image height")
 	encodeCmd.Flags().Int("quality", 85, "JPEG quality (1-100)")
 	encodeCmd.Flags().Int("cmy-reduction", 15, "Quality reduction for CMY channels")
+	encodeCmd.Flags().Float64("dpi", 0, "Print resolution in pixels per inch to record in the file")
+	addQuantFlags(encodeCmd)
+	addScanFlags(encodeCmd)
+	addConventionFlags(encodeCmd)
+	addTargetFlags(encodeCmd)
 	encodeCmd.MarkFlagRequired("input")
 	encodeCmd.MarkFlagRequired("output")
 	encodeCmd.MarkFlagRequired("width")
@@ -37,37 +45,282 @@ func runEncode(cmd *cobra.Command, args []string) error {
 	height, _ := cmd.Flags().GetInt("height")
 	quality, _ := cmd.Flags().GetInt("quality")
 	cmyReduction, _ := cmd.Flags().GetInt("cmy-reduction")
+	dpi, _ := cmd.Flags().GetFloat64("dpi")
+	channelQuality, baseTables, err := quantOptions(cmd)
+	if err != nil {
+		return err
+	}
+	progressive, scans, err := scanOptions(cmd)
+	if err != nil {
+		return err
+	}
+	targetSize, target, err := targetOptions(cmd)
+	if err != nil {
+		return err
+	}
+	convention, ycck, err := conventionOptions(cmd)
+	if err != nil {
+		return err
+	}
 
 	pixels, err := os.ReadFile(inputPath)
 	if err != nil {
-		return fmt.Errorf("reading input: %w", err)
+		return inputError(fmt.Errorf("reading input: %w", err))
 	}
 
 	expected := width * height * 4
 	if len(pixels) != expected {
-		return fmt.Errorf("expected %d bytes for %dx%d CMYK, got %d", expected, width, height, len(pixels))
+		return inputError(fmt.Errorf("expected %d bytes for %dx%d CMYK, got %d", expected, width, height, len(pixels)))
 	}
 
 	var icc []byte
 	if iccPath != "" {
 		icc, err = os.ReadFile(iccPath)
 		if err != nil {
-			return fmt.Errorf("reading ICC profile: %w", err)
+			return profileError(fmt.Errorf("reading ICC profile: %w", err))
 		}
 	}
+	run.lap("read")
 
-	encoded, err := jpeg.EncodeCMYK(pixels, width, height, icc, jpeg.EncoderOptions{
-		Quality:      quality,
-		CMYReduction: cmyReduction,
-	})
+	encOpts := jpeg.EncoderOptions{
+		Quality:        quality,
+		CMYReduction:   cmyReduction,
+		ChannelQuality: channelQuality,
+		BaseTables:     baseTables,
+		Progressive:    progressive,
+		Scans:          scans,
+		Convention:     convention,
+		YCCK:           ycck,
+		Density:        jpeg.Density{X: dpi, Y: dpi},
+	}
+	var encoded []byte
+	var scores *fidelity.Scores
+	switch {
+	case target.Value > 0:
+		encoded, encOpts, scores, err = fidelity.EncodeToTarget(pixels, width, height, icc, encOpts, target)
+	case targetSize > 0:
+		encoded, encOpts, err = jpeg.EncodeCMYKToSize(pixels, width, height, icc, encOpts, targetSize)
+	default:
+		encoded, err = jpeg.EncodeCMYK(pixels, width, height, icc, encOpts)
+	}
 	if err != nil {
-		return fmt.Errorf("encoding: %w", err)
+		return encodeError(fmt.Errorf("encoding: %w", err))
 	}
+	run.lap("encode")
 
 	if err := os.WriteFile(outputPath, encoded, 0644); err != nil {
-		return fmt.Errorf("writing output: %w", err)
+		return outputError(fmt.Errorf("writing output: %w", err))
+	}
+	run.lap("write")
+
+	if reportJSON(&encodeReport{
+		Input:        fileReport{Path: inputPath, Bytes: len(pixels)},
+		Output:       fileReport{Path: outputPath, Bytes: len(encoded), Width: width, Height: height},
+		Profile:      describeProfile(iccPath, icc),
+		Quality:      encOpts.Quality,
+		CMYReduction: encOpts.CMYReduction,
+		Qualities:    encOpts.Qualities(),
+		Convention:   convention.String(),
+		YCCK:         ycck,
+		Progressive:  progressive,
+		Fidelity:     newFidelityReport(scores),
+	}) {
+		return nil
 	}
 
 	fmt.Printf("Encoded %dx%d CMYK → %s (%d bytes)\n", width, height, outputPath, len(encoded))
+	switch {
+	case target.Value > 0:
+		printScoreTarget(encOpts.Quality, encOpts.CMYReduction, target, scores)
+	case targetSize > 0:
+		printTargetSettings(encOpts.Quality, encOpts.CMYReduction, len(encoded), targetSize)
+	}
 	return nil
 }
+
+// encodeReport is the JSON form of the encode output.
+type encodeReport struct {
+	Input        fileReport      `json:"input"`
+	Output       fileReport      `json:"output"`
+	Profile      *profileReport  `json:"profile"`
+	Quality      int             `json:"quality"`
+	CMYReduction int             `json:"cmy_reduction"`
+	Qualities    [4]int          `json:"channel_quality"`
+	Convention   string          `json:"convention"`
+	YCCK         bool            `json:"ycck"`
+	Progressive  bool            `json:"progressive"`
+	Fidelity     *fidelityReport `json:"fidelity,omitempty"`
+}
+
+// addConventionFlags registers --cmyk-convention and --ycck.
+func addConventionFlags(cmd *cobra.Command) {
+	cmd.Flags().String("cmyk-convention", "adobe-inverted", "CMYK sample storage: adobe-inverted (Photoshop, APP14 marker) or plain (no marker)")
+	cmd.Flags().Bool("ycck", false, "Store C, M, Y as YCbCr (Adobe transform 2) for smaller files; implies adobe-inverted")
+}
+
+// conventionOptions reads the flags added by addConventionFlags.
+func conventionOptions(cmd *cobra.Command) (jpeg.Convention, bool, error) {
+	name, _ := cmd.Flags().GetString("cmyk-convention")
+	convention, err := jpeg.ParseConvention(name)
+	if err != nil {
+		return 0, false, err
+	}
+	ycck, _ := cmd.Flags().GetBool("ycck")
+	if ycck && convention != jpeg.AdobeInverted {
+		return 0, false, fmt.Errorf("--ycck needs --cmyk-convention adobe-inverted")
+	}
+	return convention, ycck, nil
+}
+
+// addTargetFlags registers --target-size, --target-ssim and --target-psnr.
+func addTargetFlags(cmd *cobra.Command) {
+	cmd.Flags().String("target-size", "", "Largest output size, e.g. 8MB; searches --quality and --cmy-reduction")
+	cmd.Flags().Float64("target-ssim", 0, "Smallest output whose K-weighted SSIM against the separation reaches this (0-1)")
+	cmd.Flags().Float64("target-psnr", 0, "Smallest output whose K-weighted PSNR against the separation reaches this (dB)")
+}
+
+// targetOptions reads the flags added by addTargetFlags. At most one target
+// may be set; size is 0 and target.Value is 0 when unset.
+func targetOptions(cmd *cobra.Command) (size int, target fidelity.Target, err error) {
+	set := 0
+	for _, name := range []string{"target-size", "target-ssim", "target-psnr"} {
+		if cmd.Flags().Changed(name) {
+			set++
+		}
+	}
+	if set > 1 {
+		return 0, target, fmt.Errorf("--target-size, --target-ssim and --target-psnr are mutually exclusive")
+	}
+
+	if s, _ := cmd.Flags().GetString("target-size"); s != "" {
+		if size, err = parseByteSize(s); err != nil {
+			return 0, target, fmt.Errorf("--target-size: %w", err)
+		}
+	}
+	if v, _ := cmd.Flags().GetFloat64("target-ssim"); v != 0 {
+		if v <= 0 || v > 1 {
+			return 0, target, fmt.Errorf("--target-ssim must be in (0, 1], got %g", v)
+		}
+		target = fidelity.Target{Metric: fidelity.SSIM, Value: v}
+	}
+	if v, _ := cmd.Flags().GetFloat64("target-psnr"); v != 0 {
+		if v < 0 {
+			return 0, target, fmt.Errorf("--target-psnr must be positive, got %g", v)
+		}
+		target = fidelity.Target{Metric: fidelity.PSNR, Value: v}
+	}
+	return size, target, nil
+}
+
+// parseByteSize parses a size such as 500000, 800KB or 8MB. KB, MB and GB
+// are decimal (1000-based) as upload limits usually are; KiB, MiB and GiB
+// are binary.
+func parseByteSize(s string) (int, error) {
+	units := []struct {
+		suffix string
+		scale  float64
+	}{
+		{"KIB", 1 << 10}, {"MIB", 1 << 20}, {"GIB", 1 << 30},
+		{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"K", 1e3}, {"M", 1e6}, {"G", 1e9}, {"B", 1},
+	}
+	num, scale := strings.ToUpper(strings.TrimSpace(s)), 1.0
+	for _, u := range units {
+		if strings.HasSuffix(num, u.suffix) {
+			num, scale = strings.TrimSpace(strings.TrimSuffix(num, u.suffix)), u.scale
+			break
+		}
+	}
+	v, err := strconv.ParseFloat(num, 64)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int(v * scale), nil
+}
+
+// printScoreTarget reports the settings a --target-ssim or --target-psnr
+// search chose, with the scores they reached.
+func printScoreTarget(quality, cmyReduction int, target fidelity.Target, s *fidelity.Scores) {
+	fmt.Printf("Target: quality %d, CMY reduction %d (%s %.4g, target %g)\n",
+		quality, cmyReduction, target.Metric, s.Score(target.Metric), target.Value)
+	printScores(s)
+}
+
+// printScores writes per-plate fidelity scores.
+func printScores(s *fidelity.Scores) {
+	fmt.Printf("%-8s %8s %10s\n", "Plate", "SSIM", "PSNR")
+	for p, name := range []string{"C", "M", "Y", "K"} {
+		fmt.Printf("%-8s %8.4f %7.2f dB\n", name, s.SSIM[p], s.PSNR[p])
+	}
+	fmt.Printf("%-8s %8.4f %7.2f dB\n", "Weighted", s.WeightedSSIM, s.WeightedPSNR)
+}
+
+// printTargetSettings reports the settings a --target-size search chose.
+func printTargetSettings(quality, cmyReduction, size, target int) {
+	fmt.Printf("Target: quality %d, CMY reduction %d (%d of %d bytes)\n", quality, cmyReduction, size, target)
+}
+
+// addQuantFlags registers the per-channel quality and quantization table
+// flags.
+func addQuantFlags(cmd *cobra.Command) {
+	for _, ch := range []string{"c", "m", "y", "k"} {
+		cmd.Flags().Int("quality-"+ch, 0, "Quality for the "+strings.ToUpper(ch)+" channel (overrides --quality and --cmy-reduction)")
+	}
+	cmd.Flags().String("quant-table", "annex-k", "Base quantization table: "+strings.Join(jpeg.BaseTableNames(), ", "))
+	cmd.Flags().String("qtables", "", "Base quantization table file in cjpeg -qtables format (one to four tables, for C, M, Y, K)")
+}
+
+// quantOptions reads the flags added by addQuantFlags.
+func quantOptions(cmd *cobra.Command) (channelQuality [4]int, bases [][64]int, err error) {
+	for c, ch := range []string{"c", "m", "y", "k"} {
+		q, _ := cmd.Flags().GetInt("quality-" + ch)
+		if q < 0 || q > 100 {
+			return channelQuality, nil, fmt.Errorf("--quality-%s must be 1-100, got %d", ch, q)
+		}
+		channelQuality[c] = q
+	}
+	path, _ := cmd.Flags().GetString("qtables")
+	if path != "" {
+		if cmd.Flags().Changed("quant-table") {
+			return channelQuality, nil, fmt.Errorf("--qtables and --quant-table are mutually exclusive")
+		}
+		text, err := os.ReadFile(path)
+		if err != nil {
+			return channelQuality, nil, fmt.Errorf("reading quantization tables: %w", err)
+		}
+		if bases, err = jpeg.ParseQuantTables(string(text)); err != nil {
+			return channelQuality, nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return channelQuality, bases, nil
+	}
+	name, _ := cmd.Flags().GetString("quant-table")
+	base, err := jpeg.BaseTable(name)
+	if err != nil {
+		return channelQuality, nil, err
+	}
+	return channelQuality, [][64]int{base}, nil
+}
+
+// addScanFlags registers --progressive and --scans.
+func addScanFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("progressive", false, "Write a progressive JPEG (K-first scan script unless --scans is given)")
+	cmd.Flags().String("scans", "", "Progressive scan script file in cjpeg -scans format (implies --progressive)")
+}
+
+// scanOptions reads --progressive and --scans; scans is nil for the default
+// script.
+func scanOptions(cmd *cobra.Command) (progressive bool, scans []jpeg.Scan, err error) {
+	progressive, _ = cmd.Flags().GetBool("progressive")
+	path, _ := cmd.Flags().GetString("scans")
+	if path == "" {
+		return progressive, nil, nil
+	}
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return false, nil, fmt.Errorf("reading scan script: %w", err)
+	}
+	scans, err = jpeg.ParseScanScript(string(text))
+	if err != nil {
+		return false, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return true, scans, nil
+}
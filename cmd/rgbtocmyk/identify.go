@@ -3,40 +3,85 @@ package main
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/davesmith10/RGBtoCMYK/internal/color"
 	"github.com/davesmith10/RGBtoCMYK/internal/jpeg"
+	"github.com/davesmith10/RGBtoCMYK/internal/meta"
+	"github.com/davesmith10/RGBtoCMYK/internal/pipeline"
 	"github.com/spf13/cobra"
 )
 
 var identifyCmd = &cobra.Command{
-	Use:   "identify [file]",
+	Use:   "identify [flags] file...",
 	Short: "Inspect image and ICC profile info",
-	Args:  cobra.ExactArgs(1),
-	RunE:  runIdentify,
+	Long: `Prints the metadata and encoding structure of a JPEG. Given several
+files, globs or directories (with -r), or any of --csv and the --only
+filters, prints one line per file instead.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runIdentify,
 }
 
 func init() {
+	identifyCmd.Flags().Bool("tables", false, "Print each component's quantization table")
+	identifyCmd.Flags().BoolP("recursive", "r", false, "Look for .jpg and .jpeg files in directories and their subdirectories")
+	identifyCmd.Flags().Bool("csv", false, "Write the file list as CSV")
+	identifyCmd.Flags().Bool("only-untagged", false, "List only files without an ICC profile")
+	identifyCmd.Flags().Bool("only-rgb", false, "List only RGB and YCbCr files")
+	identifyCmd.Flags().Bool("only-cmyk", false, "List only CMYK and YCCK files")
 	rootCmd.AddCommand(identifyCmd)
 }
 
 func runIdentify(cmd *cobra.Command, args []string) error {
+	showTables, _ := cmd.Flags().GetBool("tables")
+	if listMode(cmd, args) {
+		if showTables {
+			return fmt.Errorf("--tables needs a single file")
+		}
+		return runIdentifyList(cmd, args)
+	}
+
 	path := args[0]
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return fmt.Errorf("reading %s: %w", path, err)
+		return inputError(fmt.Errorf("reading %s: %w", path, err))
 	}
 
 	info, err := jpeg.GetInfo(data)
 	if err != nil {
-		return fmt.Errorf("parsing %s: %w", path, err)
+		return inputError(fmt.Errorf("parsing %s: %w", path, err))
+	}
+	run.lap("read")
+	if reportJSON(newImageReport(path, data, info)) {
+		return nil
 	}
 
 	fmt.Printf("File:       %s\n", path)
 	fmt.Printf("Dimensions: %d x %d\n", info.Width, info.Height)
 	fmt.Printf("Components: %d\n", info.NumComponents)
 	fmt.Printf("Color space: %s\n", info.ColorSpace)
+	if info.ColorSpace == "CMYK" || info.ColorSpace == "YCCK" {
+		fmt.Printf("Convention: %s\n", info.Convention)
+	}
+	fmt.Printf("Coding:     %s, %d-bit\n", info.Coding(), info.Precision)
+	if info.RestartInterval > 0 {
+		fmt.Printf("Restart interval: %d MCUs\n", info.RestartInterval)
+	}
+	if info.JFIFVersion != "" {
+		fmt.Printf("JFIF:       %s\n", info.JFIFVersion)
+	}
+	if info.AdobeTransform >= 0 {
+		fmt.Printf("Adobe APP14: transform %d (%s)\n", info.AdobeTransform, adobeTransformName(info.AdobeTransform))
+	}
+	fmt.Printf("EXIF:       %s\n", yesNo(info.EXIF))
 	fmt.Printf("File size:  %d bytes (%.1f MB)\n", len(data), float64(len(data))/(1024*1024))
+	if density, from := pipeline.SourceDensity(info.JFIF, info.APP1); density.Known() {
+		w, h := density.PrintSize(info.Width, info.Height)
+		fmt.Printf("Resolution: %s (%s)\n", density, from)
+		fmt.Printf("Print size: %.2f x %.2f in (%.1f x %.1f mm)\n", w, h, w*25.4, h*25.4)
+	} else {
+		fmt.Println("Resolution: unknown")
+	}
 
 	if info.ICC != nil {
 		pi, err := color.ParseProfileInfo(info.ICC)
@@ -44,6 +89,9 @@ func runIdentify(cmd *cobra.Command, args []string) error {
 			fmt.Printf("ICC profile: present (%d bytes) but invalid: %v\n", len(info.ICC), err)
 		} else {
 			fmt.Printf("ICC profile: %d bytes\n", len(info.ICC))
+			if pi.Description != "" {
+				fmt.Printf("  Description: %s\n", pi.Description)
+			}
 			fmt.Printf("  Version:     %s\n", pi.Version)
 			fmt.Printf("  Color space: %s\n", color.ColorSpaceName(pi.ColorSpace))
 			fmt.Printf("  PCS:         %s\n", color.ColorSpaceName(pi.PCS))
@@ -53,5 +101,212 @@ func runIdentify(cmd *cobra.Command, args []string) error {
 		fmt.Println("ICC profile: none")
 	}
 
+	printComponents(info.Components, showTables)
+	printSegments(info.Segments, len(data))
+
+	for _, seg := range info.APP1 {
+		if p, ok := meta.ReadProvenance(meta.XMPPacket(seg)); ok {
+			printProvenance(p)
+			break
+		}
+	}
 	return nil
 }
+
+// imageReport is the JSON form of the identify output.
+type imageReport struct {
+	fileReport
+	NumComponents   int               `json:"num_components"`
+	ColorSpace      string            `json:"color_space"`
+	Convention      string            `json:"convention,omitempty"`
+	Coding          string            `json:"coding"`
+	Progressive     bool              `json:"progressive"`
+	Arithmetic      bool              `json:"arithmetic"`
+	Precision       int               `json:"precision"`
+	RestartInterval int               `json:"restart_interval"`
+	JFIFVersion     string            `json:"jfif_version,omitempty"`
+	AdobeTransform  *int              `json:"adobe_transform"`
+	EXIF            bool              `json:"exif"`
+	Resolution      *densityReport    `json:"resolution"`
+	PrintWidthMM    float64           `json:"print_width_mm,omitempty"`
+	PrintHeightMM   float64           `json:"print_height_mm,omitempty"`
+	Profile         *profileReport    `json:"icc_profile"`
+	Components      []componentReport `json:"components"`
+	Markers         []markerReport    `json:"markers"`
+	Provenance      *provenanceReport `json:"provenance,omitempty"`
+}
+
+type componentReport struct {
+	ID           int        `json:"id"`
+	HSamp        int        `json:"h_samp"`
+	VSamp        int        `json:"v_samp"`
+	Quality      int        `json:"quality"`
+	QualityBase  string     `json:"quality_base"`
+	QualityExact bool       `json:"quality_exact"`
+	Transposed   bool       `json:"quality_transposed"`
+	Quantization [64]uint16 `json:"quantization"`
+}
+
+type markerReport struct {
+	Offset   int    `json:"offset"`
+	Marker   string `json:"marker"`
+	Length   int    `json:"length"`
+	Ident    string `json:"ident,omitempty"`
+	ScanData int    `json:"scan_bytes,omitempty"`
+}
+
+type provenanceReport struct {
+	Software            string `json:"software"`
+	When                string `json:"when"`
+	SourceDigest        string `json:"source_digest"`
+	SourceProfile       string `json:"source_profile"`
+	SourceProfileDigest string `json:"source_profile_digest"`
+	DestProfile         string `json:"dest_profile"`
+	DestProfileDigest   string `json:"dest_profile_digest"`
+	Intent              string `json:"intent"`
+	BPC                 bool   `json:"black_point_compensation"`
+	Black               string `json:"black"`
+	Quality             int    `json:"quality"`
+	CMYReduction        int    `json:"cmy_reduction"`
+	Qualities           [4]int `json:"channel_quality"`
+}
+
+func newImageReport(path string, data []byte, info *jpeg.ImageInfo) *imageReport {
+	r := &imageReport{
+		fileReport:      fileReport{Path: path, Bytes: len(data), Width: info.Width, Height: info.Height},
+		NumComponents:   info.NumComponents,
+		ColorSpace:      info.ColorSpace,
+		Coding:          info.Coding(),
+		Progressive:     info.Progressive,
+		Arithmetic:      info.Arithmetic,
+		Precision:       info.Precision,
+		RestartInterval: info.RestartInterval,
+		JFIFVersion:     info.JFIFVersion,
+		EXIF:            info.EXIF,
+		Profile:         describeProfile("", info.ICC),
+		Components:      []componentReport{},
+		Markers:         []markerReport{},
+	}
+	if info.ColorSpace == "CMYK" || info.ColorSpace == "YCCK" {
+		r.Convention = info.Convention.String()
+	}
+	if info.AdobeTransform >= 0 {
+		t := info.AdobeTransform
+		r.AdobeTransform = &t
+	}
+	density, from := pipeline.SourceDensity(info.JFIF, info.APP1)
+	if r.Resolution = newDensityReport(density, from); r.Resolution != nil {
+		w, h := density.PrintSize(info.Width, info.Height)
+		r.PrintWidthMM, r.PrintHeightMM = w*25.4, h*25.4
+	}
+	for _, ci := range info.Components {
+		q := ci.Quality
+		r.Components = append(r.Components, componentReport{
+			ID: ci.ID, HSamp: ci.HSamp, VSamp: ci.VSamp,
+			Quality: q.Quality, QualityBase: q.Base, QualityExact: q.Exact, Transposed: q.Transposed,
+			Quantization: ci.Quant,
+		})
+	}
+	for _, s := range info.Segments {
+		r.Markers = append(r.Markers, markerReport{Offset: s.Offset, Marker: s.Name(), Length: s.Length, Ident: s.Ident, ScanData: s.Entropy})
+	}
+	for _, seg := range info.APP1 {
+		if p, ok := meta.ReadProvenance(meta.XMPPacket(seg)); ok {
+			r.Provenance = &provenanceReport{
+				Software: p.Software, When: p.When.Format(time.RFC3339),
+				SourceDigest: p.SourceDigest, SourceProfile: p.SourceProfile, SourceProfileDigest: p.SourceProfileDigest,
+				DestProfile: p.DestProfile, DestProfileDigest: p.DestProfileDigest,
+				Intent: p.Intent, BPC: p.BPC, Black: p.Black,
+				Quality: p.Quality, CMYReduction: p.CMYReduction, Qualities: p.Qualities,
+			}
+			break
+		}
+	}
+	return r
+}
+
+// adobeTransformName names an Adobe APP14 transform code.
+func adobeTransformName(t int) string {
+	switch t {
+	case 0:
+		return "none, RGB or CMYK"
+	case 1:
+		return "YCbCr"
+	case 2:
+		return "YCCK"
+	}
+	return "unknown"
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// componentName shows a component identifier as a letter when it is one,
+// as libjpeg writes C, M, Y and K for CMYK.
+func componentName(id int) string {
+	if id >= 'A' && id <= 'Z' || id >= 'a' && id <= 'z' {
+		return string(rune(id))
+	}
+	return fmt.Sprint(id)
+}
+
+// printComponents lists sampling factors and estimated quality per
+// component, with the quantization tables if showTables is set.
+func printComponents(comps []jpeg.ComponentInfo, showTables bool) {
+	fmt.Println("Components:")
+	fmt.Printf("  %-3s %-4s %-9s %s\n", "#", "ID", "Sampling", "Quality")
+	for c, ci := range comps {
+		fmt.Printf("  %-3d %-4s %-9s %s\n", c+1, componentName(ci.ID), fmt.Sprintf("%dx%d", ci.HSamp, ci.VSamp), ci.Quality)
+		if showTables {
+			for row := 0; row < 8; row++ {
+				fmt.Print("     ")
+				for _, v := range ci.Quant[row*8 : row*8+8] {
+					fmt.Printf(" %4d", v)
+				}
+				fmt.Println()
+			}
+		}
+	}
+}
+
+// printSegments lists the file's markers with their offsets and sizes.
+func printSegments(segs []jpeg.Segment, size int) {
+	fmt.Println("Markers:")
+	fmt.Printf("  %-10s %-7s %8s\n", "Offset", "Marker", "Length")
+	end := 0
+	for _, s := range segs {
+		line := fmt.Sprintf("  %-10d %-7s %8d", s.Offset, s.Name(), s.Length)
+		switch {
+		case s.Ident != "":
+			line += "  " + s.Ident
+		case s.Entropy > 0:
+			line += fmt.Sprintf("  + %d bytes of scan data", s.Entropy)
+		}
+		fmt.Println(line)
+		end = s.Offset + 2 + s.Length + s.Entropy
+	}
+	if end < size && len(segs) > 0 && segs[len(segs)-1].Name() == "EOI" {
+		fmt.Printf("  %d bytes after EOI\n", size-end)
+	}
+}
+
+// printProvenance prints the conversion record written by convert.
+func printProvenance(p *meta.Provenance) {
+	bpc := "off"
+	if p.BPC {
+		bpc = "on"
+	}
+	q := p.Qualities
+	fmt.Println("Conversion history:")
+	fmt.Printf("  Converted:   %s by %s\n", p.When.Format(time.RFC3339), p.Software)
+	fmt.Printf("  Source file: %s\n", p.SourceDigest)
+	fmt.Printf("  Source:      %s (%s)\n", p.SourceProfile, p.SourceProfileDigest)
+	fmt.Printf("  Destination: %s (%s)\n", p.DestProfile, p.DestProfileDigest)
+	fmt.Printf("  Intent:      %s, black point compensation %s\n", p.Intent, bpc)
+	fmt.Printf("  Black:       %s\n", p.Black)
+	fmt.Printf("  Quality:     %d, CMY reduction %d (C/M/Y/K %d/%d/%d/%d)\n", p.Quality, p.CMYReduction, q[0], q[1], q[2], q[3])
+}
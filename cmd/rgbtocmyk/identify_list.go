@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/davesmith10/RGBtoCMYK/internal/jpeg"
+	"github.com/spf13/cobra"
+)
+
+// listMode reports whether identify prints one line per file rather than
+// the full report of a single file.
+func listMode(cmd *cobra.Command, args []string) bool {
+	for _, name := range []string{"recursive", "csv", "only-untagged", "only-rgb", "only-cmyk"} {
+		if v, _ := cmd.Flags().GetBool(name); v {
+			return true
+		}
+	}
+	if len(args) > 1 || isGlob(args[0]) {
+		return true
+	}
+	fi, err := os.Stat(args[0])
+	return err == nil && fi.IsDir()
+}
+
+// isGlob reports whether arg is a pattern for filepath.Glob, left
+// unexpanded by the shell or given quoted.
+func isGlob(arg string) bool {
+	if _, err := os.Lstat(arg); err == nil {
+		return false
+	}
+	return strings.ContainsAny(arg, "*?[")
+}
+
+// identifyFilter selects files for the list.
+type identifyFilter struct {
+	untagged, rgb, cmyk bool
+}
+
+func (f identifyFilter) active() bool { return f.untagged || f.rgb || f.cmyk }
+
+// match reports whether r passes every filter that is set.
+func (f identifyFilter) match(r *imageReport) bool {
+	if f.untagged && r.Profile != nil {
+		return false
+	}
+	if f.rgb && r.ColorSpace != "RGB" && r.ColorSpace != "YCbCr" {
+		return false
+	}
+	if f.cmyk && r.ColorSpace != "CMYK" && r.ColorSpace != "YCCK" {
+		return false
+	}
+	return true
+}
+
+// identifyListReport is the JSON form of the identify output for a list of
+// files. Files holds the files that passed the filters.
+type identifyListReport struct {
+	Files   []*imageReport `json:"files"`
+	Errors  []fileError    `json:"errors"`
+	Scanned int            `json:"scanned"`
+}
+
+type fileError struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+func runIdentifyList(cmd *cobra.Command, args []string) error {
+	recursive, _ := cmd.Flags().GetBool("recursive")
+	asCSV, _ := cmd.Flags().GetBool("csv")
+	var filter identifyFilter
+	filter.untagged, _ = cmd.Flags().GetBool("only-untagged")
+	filter.rgb, _ = cmd.Flags().GetBool("only-rgb")
+	filter.cmyk, _ = cmd.Flags().GetBool("only-cmyk")
+
+	paths, unreadable, err := expandPaths(args, recursive)
+	if err != nil {
+		return err
+	}
+	// Unreadable files are listed on stderr; usage would bury them.
+	cmd.SilenceUsage = true
+
+	report := &identifyListReport{Files: []*imageReport{}, Errors: []fileError{}, Scanned: len(paths)}
+	var firstErr error
+	for _, path := range paths {
+		var r *imageReport
+		err := unreadable[path]
+		if err == nil {
+			r, err = identifyFile(path)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			report.Errors = append(report.Errors, fileError{Path: path, Error: err.Error()})
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if filter.match(r) {
+			report.Files = append(report.Files, r)
+		}
+	}
+	run.lap("read")
+
+	switch {
+	case reportJSON(report):
+	case asCSV:
+		if err := writeIdentifyCSV(os.Stdout, report.Files); err != nil {
+			return outputError(err)
+		}
+	default:
+		printIdentifyTable(report.Files)
+		if filter.active() {
+			fmt.Printf("\n%d of %d files listed\n", len(report.Files), len(paths)-len(report.Errors))
+		}
+	}
+
+	if len(report.Errors) > 0 {
+		// The exit code is that of the first failure.
+		return &exitError{exitCode(firstErr), fmt.Errorf("%d of %d files could not be read", len(report.Errors), len(paths))}
+	}
+	return nil
+}
+
+// expandPaths turns identify's arguments into files: globs are expanded,
+// and directories are walked for .jpg and .jpeg files if recursive is set.
+// Paths that do not exist are kept, to fail when they are read.
+// Subdirectories that cannot be read are kept too, with their error in
+// unreadable, and the walk goes on.
+func expandPaths(args []string, recursive bool) (paths []string, unreadable map[string]error, err error) {
+	unreadable = map[string]error{}
+	for _, arg := range args {
+		matches := []string{arg}
+		if isGlob(arg) {
+			var err error
+			if matches, err = filepath.Glob(arg); err != nil {
+				return nil, nil, fmt.Errorf("bad pattern %q: %w", arg, err)
+			}
+			if len(matches) == 0 {
+				return nil, nil, inputError(fmt.Errorf("no files match %s", arg))
+			}
+		}
+		for _, m := range matches {
+			fi, err := os.Stat(m)
+			if err != nil || !fi.IsDir() {
+				paths = append(paths, m)
+				continue
+			}
+			if !recursive {
+				return nil, nil, inputError(fmt.Errorf("%s is a directory (use -r to look inside)", m))
+			}
+			filepath.WalkDir(m, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					paths = append(paths, path)
+					unreadable[path] = inputError(fmt.Errorf("reading %s: %w", path, err))
+					if d != nil && d.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if !d.IsDir() && isJPEGName(d.Name()) {
+					paths = append(paths, path)
+				}
+				return nil
+			})
+		}
+	}
+	return paths, unreadable, nil
+}
+
+func isJPEGName(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".jpg" || ext == ".jpeg"
+}
+
+// identifyFile reads path and describes it.
+func identifyFile(path string) (*imageReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, inputError(fmt.Errorf("reading %s: %w", path, err))
+	}
+	info, err := jpeg.GetInfo(data)
+	if err != nil {
+		return nil, inputError(fmt.Errorf("parsing %s: %w", path, err))
+	}
+	return newImageReport(path, data, info), nil
+}
+
+// listProfile names the ICC profile of a listed file.
+func listProfile(r *imageReport) string {
+	switch {
+	case r.Profile == nil:
+		return "none"
+	case r.Profile.Invalid != "":
+		return "invalid"
+	case r.Profile.Description == "":
+		return "(no description)"
+	}
+	return r.Profile.Description
+}
+
+// listDPI shows a resolution as "300", or "300x150" if it differs by axis.
+func listDPI(d *densityReport) string {
+	if d.XDPI == d.YDPI {
+		return strconv.FormatFloat(d.XDPI, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(d.XDPI, 'f', -1, 64) + "x" + strconv.FormatFloat(d.YDPI, 'f', -1, 64)
+}
+
+// listQuality sums up the estimated quality of the components: one value
+// if they agree, else one per component, e.g. "70/70/70/85". "~" marks an
+// estimate that matched no table exactly.
+func listQuality(comps []componentReport) string {
+	var parts []string
+	same := true
+	for _, c := range comps {
+		q := strconv.Itoa(c.Quality)
+		if !c.QualityExact {
+			q = "~" + q
+		}
+		parts = append(parts, q)
+		same = same && q == parts[0]
+	}
+	if len(parts) > 0 && same {
+		return parts[0]
+	}
+	return strings.Join(parts, "/")
+}
+
+// printIdentifyTable writes one aligned line per file.
+func printIdentifyTable(files []*imageReport) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "File\tDimensions\tColor\tICC profile\tDPI\tPrint size\tQuality")
+	for _, r := range files {
+		dpi, size := "—", "—"
+		if r.Resolution != nil {
+			dpi = listDPI(r.Resolution)
+			size = fmt.Sprintf("%.1f x %.1f mm", r.PrintWidthMM, r.PrintHeightMM)
+		}
+		fmt.Fprintf(w, "%s\t%d x %d\t%s\t%s\t%s\t%s\t%s\n", r.Path, r.Width, r.Height, r.ColorSpace,
+			listProfile(r), dpi, size, listQuality(r.Components))
+	}
+	w.Flush()
+}
+
+// writeIdentifyCSV writes the file list as CSV with a header row. Unknown
+// resolutions leave their fields empty.
+func writeIdentifyCSV(out io.Writer, files []*imageReport) error {
+	w := csv.NewWriter(out)
+	w.Write([]string{"path", "bytes", "width", "height", "color_space", "coding", "icc_profile",
+		"x_dpi", "y_dpi", "print_width_mm", "print_height_mm", "quality"})
+	for _, r := range files {
+		var xdpi, ydpi, pw, ph string
+		if d := r.Resolution; d != nil {
+			xdpi = strconv.FormatFloat(d.XDPI, 'f', -1, 64)
+			ydpi = strconv.FormatFloat(d.YDPI, 'f', -1, 64)
+			pw = strconv.FormatFloat(r.PrintWidthMM, 'f', 1, 64)
+			ph = strconv.FormatFloat(r.PrintHeightMM, 'f', 1, 64)
+		}
+		profile := ""
+		if r.Profile != nil {
+			profile = listProfile(r)
+		}
+		w.Write([]string{r.Path, strconv.Itoa(r.Bytes), strconv.Itoa(r.Width), strconv.Itoa(r.Height),
+			r.ColorSpace, r.Coding, profile, xdpi, ydpi, pw, ph, listQuality(r.Components)})
+	}
+	w.Flush()
+	return w.Error()
+}
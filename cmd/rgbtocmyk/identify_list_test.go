@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"image"
+	stdjpeg "image/jpeg"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/davesmith10/RGBtoCMYK/internal/color"
+	"github.com/davesmith10/RGBtoCMYK/internal/jpeg"
+)
+
+// writeRGB writes an untagged 8×8 RGB JPEG at quality 90.
+func writeRGB(t *testing.T, path string) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := stdjpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 8, 8)), &stdjpeg.Options{Quality: 90}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeCMYK writes a 16×8 CMYK JPEG with the default CMYK profile at 300
+// dpi, quality 80 with CMY at 65.
+func writeCMYK(t *testing.T, path string) {
+	t.Helper()
+	icc, _ := color.BuiltinProfile(color.DefaultCMYKProfile)
+	data, err := jpeg.EncodeCMYK(make([]byte, 16*8*4), 16, 8, icc, jpeg.EncoderOptions{
+		Quality: 80, CMYReduction: 15, Density: jpeg.Density{X: 300, Y: 300},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExpandPaths(t *testing.T) {
+	dir := t.TempDir()
+	writeRGB(t, filepath.Join(dir, "a.jpg"))
+	writeRGB(t, filepath.Join(dir, "b.JPEG"))
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0o644)
+	os.Mkdir(filepath.Join(dir, "sub"), 0o755)
+	writeRGB(t, filepath.Join(dir, "sub", "c.jpeg"))
+	os.WriteFile(filepath.Join(dir, "sub", "d.png"), []byte("x"), 0o644)
+
+	tests := []struct {
+		name      string
+		args      []string
+		recursive bool
+		want      []string // relative to dir
+	}{
+		{"glob", []string{filepath.Join(dir, "*.jpg")}, false, []string{"a.jpg"}},
+		{"files", []string{filepath.Join(dir, "b.JPEG"), filepath.Join(dir, "missing.jpg")}, false, []string{"b.JPEG", "missing.jpg"}},
+		{"walk", []string{dir}, true, []string{"a.jpg", "b.JPEG", "sub/c.jpeg"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths, _, err := expandPaths(tt.args, tt.recursive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, p := range paths {
+				rel, _ := filepath.Rel(dir, p)
+				got = append(got, filepath.ToSlash(rel))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	for _, args := range [][]string{{filepath.Join(dir, "*.tif")}, {dir}} {
+		_, _, err := expandPaths(args, false)
+		if err == nil || exitCode(err) != exitInput {
+			t.Errorf("%v: got %v (exit %d), want an input error", args, err, exitCode(err))
+		}
+	}
+}
+
+func TestExpandPathsUnreadableDir(t *testing.T) {
+	dir := t.TempDir()
+	for _, sub := range []string{"a", "b", "c"} {
+		os.Mkdir(filepath.Join(dir, sub), 0o755)
+		writeRGB(t, filepath.Join(dir, sub, "x.jpg"))
+	}
+	locked := filepath.Join(dir, "b")
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(locked, 0o755)
+	if _, err := os.ReadDir(locked); err == nil {
+		t.Skip("directory permissions are not enforced (running as root?)")
+	}
+
+	paths, unreadable, err := expandPaths([]string{dir}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "a", "x.jpg"), locked, filepath.Join(dir, "c", "x.jpg")}
+	if !slices.Equal(paths, want) {
+		t.Errorf("got %v, want %v", paths, want)
+	}
+	if err := unreadable[locked]; err == nil || exitCode(err) != exitInput || len(unreadable) != 1 {
+		t.Errorf("unreadable = %v, want an input error for %s", unreadable, locked)
+	}
+}
+
+func TestIdentifyFilter(t *testing.T) {
+	rgb := &imageReport{ColorSpace: "YCbCr"}
+	cmyk := &imageReport{ColorSpace: "YCCK", Profile: &profileReport{Description: "coated"}}
+	tests := []struct {
+		filter    identifyFilter
+		rgb, cmyk bool
+	}{
+		{identifyFilter{}, true, true},
+		{identifyFilter{untagged: true}, true, false},
+		{identifyFilter{rgb: true}, true, false},
+		{identifyFilter{cmyk: true}, false, true},
+		{identifyFilter{untagged: true, cmyk: true}, false, false},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.match(rgb); got != tt.rgb {
+			t.Errorf("%+v matched RGB: %v", tt.filter, got)
+		}
+		if got := tt.filter.match(cmyk); got != tt.cmyk {
+			t.Errorf("%+v matched CMYK: %v", tt.filter, got)
+		}
+	}
+}
+
+func TestListQuality(t *testing.T) {
+	tests := []struct {
+		comps []componentReport
+		want  string
+	}{
+		{nil, ""},
+		{[]componentReport{{Quality: 85, QualityExact: true}, {Quality: 85, QualityExact: true}}, "85"},
+		{[]componentReport{{Quality: 70, QualityExact: true}, {Quality: 70, QualityExact: true}, {Quality: 85, QualityExact: true}}, "70/70/85"},
+		{[]componentReport{{Quality: 83}, {Quality: 83}}, "~83"},
+		{[]componentReport{{Quality: 83}, {Quality: 83, QualityExact: true}}, "~83/83"},
+	}
+	for _, tt := range tests {
+		if got := listQuality(tt.comps); got != tt.want {
+			t.Errorf("listQuality(%+v) = %q, want %q", tt.comps, got, tt.want)
+		}
+	}
+}
+
+func TestIdentifyCSV(t *testing.T) {
+	dir := t.TempDir()
+	rgbPath, cmykPath := filepath.Join(dir, "rgb.jpg"), filepath.Join(dir, "cmyk.jpg")
+	writeRGB(t, rgbPath)
+	writeCMYK(t, cmykPath)
+	var files []*imageReport
+	for _, p := range []string{rgbPath, cmykPath} {
+		r, err := identifyFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, r)
+	}
+
+	var buf bytes.Buffer
+	if err := writeIdentifyCSV(&buf, files); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"path", "bytes", "width", "height", "color_space", "coding", "icc_profile", "x_dpi", "y_dpi", "print_width_mm", "print_height_mm", "quality"},
+		{rgbPath, "", "8", "8", "YCbCr", "baseline", "", "", "", "", "", "90"},
+		{cmykPath, "", "16", "8", "CMYK", "baseline", listProfile(files[1]), "300", "300", "1.4", "0.7", "65/65/65/80"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("got %d rows, want %d:\n%s", len(rows), len(want), buf.String())
+	}
+	for i, row := range rows {
+		if i > 0 {
+			want[i][1] = row[1] // file sizes depend on the encoder
+		}
+		if !slices.Equal(row, want[i]) {
+			t.Errorf("row %d = %q, want %q", i, row, want[i])
+		}
+	}
+	if files[1].Profile == nil || listProfile(files[1]) == "none" {
+		t.Errorf("CMYK profile not reported: %+v", files[1].Profile)
+	}
+}
+
+func TestIdentifyListExitCode(t *testing.T) {
+	dir := t.TempDir()
+	good, bad := filepath.Join(dir, "good.jpg"), filepath.Join(dir, "bad.jpg")
+	writeRGB(t, good)
+	os.WriteFile(bad, []byte("not a JPEG"), 0o644)
+
+	stdout := os.Stdout
+	os.Stdout, _ = os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	defer func() { os.Stdout = stdout }()
+	err := runIdentifyList(identifyCmd, []string{good, bad, filepath.Join(dir, "missing.jpg")})
+	if err == nil || exitCode(err) != exitInput || !strings.Contains(err.Error(), "2 of 3 files") {
+		t.Errorf("got %v (exit %d), want 2 of 3 files failing with exit %d", err, exitCode(err), exitInput)
+	}
+}
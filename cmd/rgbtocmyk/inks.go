@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/davesmith10/RGBtoCMYK/internal/inks"
+	"github.com/davesmith10/RGBtoCMYK/internal/jpeg"
+	"github.com/davesmith10/RGBtoCMYK/internal/pipeline"
+	"github.com/spf13/cobra"
+)
+
+var inksCmd = &cobra.Command{
+	Use:   "inks [file]",
+	Short: "Report ink coverage and estimate ink usage of a CMYK JPEG",
+	Args:  cobra.ExactArgs(1),
+	// --json is a deprecated alias of --format json.
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if asJSON, _ := cmd.Flags().GetBool("json"); asJSON && !jsonOutput() {
+			outputFormat = "json"
+			return rootCmd.PersistentPreRunE(cmd, args)
+		}
+		return nil
+	},
+	RunE: runInks,
+}
+
+func init() {
+	addInkFlags(inksCmd)
+	inksCmd.Flags().Float64("width", 0, "Printed width in mm (height follows the aspect ratio if omitted; default from the file's resolution)")
+	inksCmd.Flags().Float64("height", 0, "Printed height in mm (width follows the aspect ratio if omitted)")
+	inksCmd.Flags().Bool("json", false, "Same as --format json")
+	inksCmd.Flags().MarkDeprecated("json", "use --format json")
+	rootCmd.AddCommand(inksCmd)
+}
+
+// addInkFlags adds the coverage threshold and ink consumption flags shared
+// by inks and convert --inks.
+func addInkFlags(cmd *cobra.Command) {
+	cmd.Flags().Float64("tac-limit", 300, "Report the area whose total coverage exceeds this percentage")
+	cmd.Flags().String("rates", "1.2,1.2,1.2,1.4", "Ink consumption C,M,Y,K in g/m² at 100% coverage")
+}
+
+// inkOptions reads the flags added by addInkFlags.
+func inkOptions(cmd *cobra.Command) (tacLimit float64, rates [4]float64, err error) {
+	tacLimit, _ = cmd.Flags().GetFloat64("tac-limit")
+	ratesStr, _ := cmd.Flags().GetString("rates")
+	rates, err = inks.ParseRates(ratesStr)
+	return tacLimit, rates, err
+}
+
+// inkReport is the JSON form of the inks output.
+type inkReport struct {
+	*inks.Report
+	PrintWidthMM  float64      `json:"print_width_mm,omitempty"`
+	PrintHeightMM float64      `json:"print_height_mm,omitempty"`
+	Usage         []inks.Usage `json:"usage,omitempty"`
+	TotalGrams    float64      `json:"total_grams,omitempty"`
+}
+
+func runInks(cmd *cobra.Command, args []string) error {
+	path := args[0]
+	width, _ := cmd.Flags().GetFloat64("width")
+	height, _ := cmd.Flags().GetFloat64("height")
+
+	tacLimit, rates, err := inkOptions(cmd)
+	if err != nil {
+		return err
+	}
+	if width < 0 || height < 0 {
+		return fmt.Errorf("print size must be positive")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return inputError(fmt.Errorf("reading %s: %w", path, err))
+	}
+	decoded, err := jpeg.DecodeCMYK(data)
+	if err != nil {
+		return inputError(fmt.Errorf("decoding %s: %w", path, err))
+	}
+	run.lap("read")
+
+	switch {
+	case width > 0 && height == 0:
+		height = width * float64(decoded.Height) / float64(decoded.Width)
+	case height > 0 && width == 0:
+		width = height * float64(decoded.Width) / float64(decoded.Height)
+	case width == 0 && height == 0:
+		density, _ := pipeline.SourceDensity(decoded.JFIF, decoded.APP1)
+		width, height = printSizeMM(density, decoded.Width, decoded.Height)
+	}
+	out, err := analyzeInks(decoded.Pixels, decoded.Width, decoded.Height, tacLimit, rates, width, height)
+	if err != nil {
+		return err
+	}
+
+	run.lap("analyze")
+	if reportJSON(out) {
+		return nil
+	}
+	fmt.Printf("File: %s\n", path)
+	printInkReport(os.Stdout, out)
+	return nil
+}
+
+// printSizeMM returns the printed size in mm of a width×height image at
+// density, or zeros if the density is unknown.
+func printSizeMM(density jpeg.Density, width, height int) (w, h float64) {
+	w, h = density.PrintSize(width, height)
+	return w * 25.4, h * 25.4
+}
+
+// analyzeInks reports the coverage of CMYK pixels and, when the printed size
+// in mm is known, estimates the ink they use.
+func analyzeInks(pixels []byte, width, height int, tacLimit float64, rates [4]float64, widthMM, heightMM float64) (inkReport, error) {
+	report, err := inks.Analyze(pixels, width, height, tacLimit)
+	if err != nil {
+		return inkReport{}, err
+	}
+	out := inkReport{Report: report}
+	if widthMM > 0 && heightMM > 0 {
+		out.PrintWidthMM, out.PrintHeightMM = widthMM, heightMM
+		usage := report.Estimate(widthMM, heightMM, rates)
+		out.Usage = usage[:]
+		for _, u := range usage {
+			out.TotalGrams += u.Grams
+		}
+	}
+	return out, nil
+}
+
+// printInkReport writes the text form of an ink report.
+func printInkReport(w io.Writer, r inkReport) {
+	fmt.Fprintf(w, "Size: %d x %d pixels\n\n", r.Width, r.Height)
+	fmt.Fprintf(w, "%-8s %8s  %s\n", "Plate", "Average", "Histogram (0-10% ... 90-100%)")
+	for _, p := range r.Plates {
+		var bars strings.Builder
+		for _, share := range p.Histogram {
+			bars.WriteString(fmt.Sprintf(" %5.1f", share))
+		}
+		fmt.Fprintf(w, "%-8s %7.1f%% %s\n", p.Name, p.Average, bars.String())
+	}
+	fmt.Fprintf(w, "\nTAC max: %.1f%%\n", r.TACMax)
+	fmt.Fprintf(w, "TAC p99: %.1f%%\n", r.TACP99)
+	fmt.Fprintf(w, "Above %.0f%%: %.2f%% of area\n", r.Threshold, r.AboveThreshold)
+
+	if r.Usage == nil {
+		return
+	}
+	fmt.Fprintf(w, "\nInk estimate for %.0f x %.0f mm:\n", r.PrintWidthMM, r.PrintHeightMM)
+	for _, u := range r.Usage {
+		fmt.Fprintf(w, "  %-8s %8.3f g  (%.2f g/m²)\n", u.Name, u.Grams, u.Rate)
+	}
+	fmt.Fprintf(w, "  %-8s %8.3f g\n", "total", r.TotalGrams)
+}
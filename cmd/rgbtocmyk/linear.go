@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/davesmith10/RGBtoCMYK/internal/color"
+	"github.com/davesmith10/RGBtoCMYK/internal/hdr"
+	"github.com/davesmith10/RGBtoCMYK/internal/jpeg"
+	"github.com/davesmith10/RGBtoCMYK/internal/tiff"
+	"github.com/spf13/cobra"
+)
+
+var linearCmd = &cobra.Command{
+	Use:   "linear",
+	Short: "Separate a linear-light float render (PFM or 32-bit TIFF) to CMYK",
+	RunE:  runLinear,
+}
+
+func init() {
+	linearCmd.Flags().StringP("input", "i", "", "Input PFM or 32-bit float TIFF")
+	linearCmd.Flags().StringP("output", "o", "", "Output file (.tif for TIFF, otherwise JPEG)")
+	linearCmd.Flags().String("profile", color.DefaultCMYKProfile, "CMYK ICC profile path or built-in name")
+	linearCmd.Flags().String("src-profile", "srgb-linear", "Source RGB ICC profile the float values are in")
+	linearCmd.Flags().Float64("exposure", 0, "Exposure adjustment in stops")
+	linearCmd.Flags().String("tonemap", "clip", "Tone map operator (clip, reinhard, filmic)")
+	linearCmd.Flags().String("intent", "perceptual", "Rendering intent")
+	linearCmd.Flags().Int("depth", 8, "CMYK output bits per sample (8, or 16 for TIFF)")
+	linearCmd.Flags().Int("quality", 85, "JPEG quality (1-100)")
+	linearCmd.Flags().Int("cmy-reduction", 15, "Quality reduction for CMY channels vs K")
+	linearCmd.Flags().Float64("dpi", 0, "Print resolution in pixels per inch (default: the input TIFF's resolution)")
+	linearCmd.MarkFlagRequired("input")
+	linearCmd.MarkFlagRequired("output")
+	rootCmd.AddCommand(linearCmd)
+}
+
+func runLinear(cmd *cobra.Command, args []string) error {
+	inputPath, _ := cmd.Flags().GetString("input")
+	outputPath, _ := cmd.Flags().GetString("output")
+	profilePath, _ := cmd.Flags().GetString("profile")
+	srcProfilePath, _ := cmd.Flags().GetString("src-profile")
+	exposure, _ := cmd.Flags().GetFloat64("exposure")
+	tonemapStr, _ := cmd.Flags().GetString("tonemap")
+	intentStr, _ := cmd.Flags().GetString("intent")
+	depth, _ := cmd.Flags().GetInt("depth")
+	quality, _ := cmd.Flags().GetInt("quality")
+	cmyReduction, _ := cmd.Flags().GetInt("cmy-reduction")
+	dpi, _ := cmd.Flags().GetFloat64("dpi")
+
+	intent, err := color.ParseIntent(intentStr)
+	if err != nil {
+		return err
+	}
+	op, err := hdr.ParseOperator(tonemapStr)
+	if err != nil {
+		return err
+	}
+	ext := filepath.Ext(outputPath)
+	asTIFF := strings.EqualFold(ext, ".tif") || strings.EqualFold(ext, ".tiff")
+	if depth != 8 && depth != 16 {
+		return fmt.Errorf("--depth must be 8 or 16, got %d", depth)
+	}
+	if depth == 16 && !asTIFF {
+		return fmt.Errorf("16-bit output needs a .tif output file")
+	}
+	if dpi < 0 {
+		return fmt.Errorf("--dpi must not be negative, got %g", dpi)
+	}
+
+	data, err := os.ReadFile(inputPath)
+	if err != nil {
+		return inputError(fmt.Errorf("reading input: %w", err))
+	}
+	img, err := hdr.Decode(data)
+	if err != nil {
+		return inputError(fmt.Errorf("decoding %s: %w", inputPath, err))
+	}
+	run.lap("read")
+	if dpi == 0 {
+		dpi = img.DPI
+	}
+
+	srcProfile, err := color.ResolveProfile(srcProfilePath)
+	if err != nil {
+		return profileError(fmt.Errorf("loading source profile: %w", err))
+	}
+	dstProfile, err := color.ResolveProfile(profilePath)
+	if err != nil {
+		return profileError(fmt.Errorf("loading CMYK profile: %w", err))
+	}
+
+	hdr.ToneMap(img.Pixels, exposure, op)
+
+	xform, err := color.NewFloatTransform(srcProfile, dstProfile, intent, depth)
+	if err != nil {
+		return profileError(err)
+	}
+	defer xform.Close()
+	intentWarning("", intent, xform.Intent())
+
+	var out []byte
+	if depth == 16 {
+		cmyk, err := xform.TransformFloat16(img.Pixels, img.Width, img.Height)
+		if err != nil {
+			return transformError(err)
+		}
+		run.lap("transform")
+		out, err = tiff.EncodeCMYK16(cmyk, img.Width, img.Height, dpi)
+		if err != nil {
+			return encodeError(fmt.Errorf("encode: %w", err))
+		}
+	} else {
+		cmyk, err := xform.TransformFloat(img.Pixels, img.Width, img.Height)
+		if err != nil {
+			return transformError(err)
+		}
+		run.lap("transform")
+		if asTIFF {
+			out, err = tiff.EncodeCMYK(cmyk, img.Width, img.Height, dpi)
+		} else {
+			out, err = jpeg.EncodeCMYK(cmyk, img.Width, img.Height, dstProfile, jpeg.EncoderOptions{
+				Quality:      quality,
+				CMYReduction: cmyReduction,
+				Density:      jpeg.Density{X: dpi, Y: dpi},
+			})
+		}
+		if err != nil {
+			return encodeError(fmt.Errorf("encode: %w", err))
+		}
+	}
+	run.lap("encode")
+
+	if err := os.WriteFile(outputPath, out, 0644); err != nil {
+		return outputError(fmt.Errorf("writing output: %w", err))
+	}
+	run.lap("write")
+
+	if reportJSON(&linearReport{
+		Input:           fileReport{Path: inputPath, Bytes: len(data), Width: img.Width, Height: img.Height},
+		SourceProfile:   describeProfile(srcProfilePath, srcProfile),
+		Profile:         describeProfile(profilePath, dstProfile),
+		Intent:          color.IntentName(xform.Intent()),
+		RequestedIntent: color.IntentName(intent),
+		ToneMap:         tonemapStr,
+		Exposure:        exposure,
+		Depth:           depth,
+		DPI:             dpi,
+		Output:          fileReport{Path: outputPath, Bytes: len(out), Width: img.Width, Height: img.Height},
+	}) {
+		return nil
+	}
+
+	fmt.Printf("Separated %dx%d float RGB → %d-bit CMYK\n", img.Width, img.Height, depth)
+	fmt.Printf("Tone map: %s, exposure %+.2f stops\n", tonemapStr, exposure)
+	if dpi > 0 {
+		w, h := jpeg.Density{X: dpi, Y: dpi}.PrintSize(img.Width, img.Height)
+		fmt.Printf("Resolution: %g dpi, %.2f x %.2f in\n", dpi, w, h)
+	}
+	fmt.Printf("Output: %s (%d bytes)\n", outputPath, len(out))
+	return nil
+}
+
+// linearReport is the JSON form of the linear output.
+type linearReport struct {
+	Input           fileReport     `json:"input"`
+	SourceProfile   *profileReport `json:"source_profile"`
+	Profile         *profileReport `json:"profile"`
+	Intent          string         `json:"intent"`
+	RequestedIntent string         `json:"requested_intent"`
+	ToneMap         string         `json:"tonemap"`
+	Exposure        float64        `json:"exposure"`
+	Depth           int            `json:"depth"`
+	DPI             float64        `json:"dpi,omitempty"`
+	Output          fileReport     `json:"output"`
+}
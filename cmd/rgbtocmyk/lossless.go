@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"strings"
+
+	"github.com/davesmith10/RGBtoCMYK/internal/jpeg"
+	"github.com/davesmith10/RGBtoCMYK/internal/pipeline"
+	"github.com/spf13/cobra"
+)
+
+var losslessCmd = &cobra.Command{
+	Use:   "lossless",
+	Short: "Rotate, flip, crop or rescan a CMYK JPEG without re-encoding it",
+	Long: `Transforms a CMYK JPEG on its DCT coefficients, as jpegtran does, so no
+generation loss is added. Huffman tables are always re-optimized. ICC, EXIF,
+XMP, IPTC and comment markers are kept; dimensions and orientation in the
+metadata follow the image, and when they change, embedded EXIF and
+Photoshop thumbnails are removed.
+
+Edges that would bring a partial 8x8 block to the top or left are trimmed,
+and a crop's top-left corner is moved up and left to a block boundary.`,
+	RunE: runLossless,
+}
+
+func init() {
+	losslessCmd.Flags().StringP("input", "i", "", "Input CMYK JPEG file")
+	losslessCmd.Flags().StringP("output", "o", "", "Output CMYK JPEG file")
+	losslessCmd.Flags().Int("rotate", 0, "Rotate clockwise by 90, 180 or 270 degrees")
+	losslessCmd.Flags().String("flip", "", "Mirror horizontal or vertical")
+	losslessCmd.Flags().Bool("transpose", false, "Mirror across the top-left to bottom-right diagonal")
+	losslessCmd.Flags().Bool("transverse", false, "Mirror across the top-right to bottom-left diagonal")
+	losslessCmd.Flags().Bool("auto-orient", false, "Turn the image upright by its EXIF orientation and reset the tag, before any other transform")
+	losslessCmd.Flags().String("crop", "", "Keep a WxH+X+Y region of the transformed image")
+	losslessCmd.Flags().Bool("baseline", false, "Write a baseline sequential JPEG (default: keep the input's mode)")
+	addScanFlags(losslessCmd)
+	losslessCmd.MarkFlagRequired("input")
+	losslessCmd.MarkFlagRequired("output")
+	rootCmd.AddCommand(losslessCmd)
+}
+
+func runLossless(cmd *cobra.Command, args []string) error {
+	inputPath, _ := cmd.Flags().GetString("input")
+	outputPath, _ := cmd.Flags().GetString("output")
+	autoOrient, _ := cmd.Flags().GetBool("auto-orient")
+	baseline, _ := cmd.Flags().GetBool("baseline")
+	cropSpec, _ := cmd.Flags().GetString("crop")
+
+	transform, err := transformOption(cmd)
+	if err != nil {
+		return err
+	}
+	var crop image.Rectangle
+	if cropSpec != "" {
+		if crop, err = parseCrop(cropSpec); err != nil {
+			return err
+		}
+	}
+	progressive, scans, err := scanOptions(cmd)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(inputPath)
+	if err != nil {
+		return inputError(fmt.Errorf("reading input: %w", err))
+	}
+	in, err := jpeg.GetInfo(data)
+	if err != nil {
+		return inputError(fmt.Errorf("reading input: %w", err))
+	}
+	run.lap("read")
+
+	r, err := pipeline.Lossless(data, pipeline.LosslessOptions{
+		AutoOrient:  autoOrient,
+		Transform:   transform,
+		Crop:        crop,
+		Progressive: progressive,
+		Baseline:    baseline,
+		Scans:       scans,
+	})
+	if err != nil {
+		return err
+	}
+	run.lap("transform")
+	if err := os.WriteFile(outputPath, r.Data, 0644); err != nil {
+		return outputError(fmt.Errorf("writing output: %w", err))
+	}
+	run.lap("write")
+
+	applied := make([]string, len(r.Applied))
+	for i, t := range r.Applied {
+		applied[i] = t.String()
+	}
+	report := &losslessReport{
+		Input:       fileReport{Path: inputPath, Bytes: len(data), Width: in.Width, Height: in.Height},
+		Output:      fileReport{Path: outputPath, Bytes: len(r.Data), Width: r.Width, Height: r.Height},
+		Orientation: r.Orientation,
+		Applied:     applied,
+		Progressive: r.Progressive,
+	}
+	if !r.Crop.Empty() {
+		report.Crop = fmt.Sprintf("%dx%d+%d+%d", r.Crop.Dx(), r.Crop.Dy(), r.Crop.Min.X, r.Crop.Min.Y)
+	}
+	if reportJSON(report) {
+		return nil
+	}
+
+	fmt.Printf("Input:  %s (%dx%d, %d bytes)\n", inputPath, in.Width, in.Height, len(data))
+	if autoOrient {
+		if r.Orientation == 0 {
+			fmt.Println("Orientation: none recorded")
+		} else {
+			fmt.Printf("Orientation: %d\n", r.Orientation)
+		}
+	}
+	if len(applied) > 0 {
+		fmt.Printf("Applied: %s\n", strings.Join(applied, ", "))
+	}
+	if report.Crop != "" {
+		fmt.Printf("Crop:   %s\n", report.Crop)
+	}
+	mode := "baseline"
+	if r.Progressive {
+		mode = "progressive"
+	}
+	fmt.Printf("Output: %s (%dx%d, %s, %d bytes)\n", outputPath, r.Width, r.Height, mode, len(r.Data))
+	return nil
+}
+
+// losslessReport is the JSON form of the lossless output.
+type losslessReport struct {
+	Input       fileReport `json:"input"`
+	Output      fileReport `json:"output"`
+	Orientation int        `json:"orientation,omitempty"`
+	Applied     []string   `json:"applied"`
+	Crop        string     `json:"crop,omitempty"`
+	Progressive bool       `json:"progressive"`
+}
+
+// transformOption reads --rotate, --flip, --transpose and --transverse, of
+// which at most one may be given.
+func transformOption(cmd *cobra.Command) (jpeg.Transform, error) {
+	var chosen []jpeg.Transform
+	if cmd.Flags().Changed("rotate") {
+		degrees, _ := cmd.Flags().GetInt("rotate")
+		switch degrees {
+		case 90:
+			chosen = append(chosen, jpeg.Rotate90)
+		case 180:
+			chosen = append(chosen, jpeg.Rotate180)
+		case 270:
+			chosen = append(chosen, jpeg.Rotate270)
+		default:
+			return 0, fmt.Errorf("--rotate must be 90, 180 or 270, got %d", degrees)
+		}
+	}
+	if flip, _ := cmd.Flags().GetString("flip"); flip != "" {
+		switch flip {
+		case "horizontal":
+			chosen = append(chosen, jpeg.FlipHorizontal)
+		case "vertical":
+			chosen = append(chosen, jpeg.FlipVertical)
+		default:
+			return 0, fmt.Errorf("--flip must be horizontal or vertical, got %q", flip)
+		}
+	}
+	if v, _ := cmd.Flags().GetBool("transpose"); v {
+		chosen = append(chosen, jpeg.Transpose)
+	}
+	if v, _ := cmd.Flags().GetBool("transverse"); v {
+		chosen = append(chosen, jpeg.Transverse)
+	}
+	switch len(chosen) {
+	case 0:
+		return jpeg.NoTransform, nil
+	case 1:
+		return chosen[0], nil
+	}
+	return 0, fmt.Errorf("only one of --rotate, --flip, --transpose and --transverse can be given")
+}
+
+// parseCrop parses a WxH+X+Y region.
+func parseCrop(s string) (image.Rectangle, error) {
+	var w, h, x, y int
+	var rest string
+	n, _ := fmt.Sscanf(s, "%dx%d+%d+%d%s", &w, &h, &x, &y, &rest)
+	if n != 4 || w <= 0 || h <= 0 || x < 0 || y < 0 {
+		return image.Rectangle{}, fmt.Errorf("invalid crop %q (want WxH+X+Y)", s)
+	}
+	return image.Rect(x, y, x+w, y+h), nil
+}
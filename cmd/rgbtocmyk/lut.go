@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/davesmith10/RGBtoCMYK/internal/color"
+	"github.com/davesmith10/RGBtoCMYK/internal/jpeg"
+	"github.com/davesmith10/RGBtoCMYK/internal/lut"
+	"github.com/davesmith10/RGBtoCMYK/internal/tiff"
+	"github.com/spf13/cobra"
+)
+
+var lutCmd = &cobra.Command{
+	Use:   "lut",
+	Short: "Export a separation as a 3D LUT, or separate images from one",
+}
+
+var lutExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Sample an RGB→CMYK separation onto a grid (.cube or .csv)",
+	Args:  cobra.NoArgs,
+	RunE:  runLutExport,
+}
+
+var lutApplyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Separate an RGB JPEG through a CMYK LUT",
+	Args:  cobra.NoArgs,
+	RunE:  runLutApply,
+}
+
+func init() {
+	lutExportCmd.Flags().StringP("output", "o", "", "Output LUT file (.csv for CSV, otherwise .cube)")
+	lutExportCmd.Flags().String("profile", color.DefaultCMYKProfile, "CMYK ICC profile path or built-in name")
+	lutExportCmd.Flags().String("src-profile", "srgb", "Source RGB ICC profile path or built-in name")
+	lutExportCmd.Flags().String("intent", "perceptual", "Rendering intent")
+	lutExportCmd.Flags().Int("size", 33, "Grid points per RGB axis")
+	lutExportCmd.Flags().Float64("tac", 0, "Total area coverage limit in percent (0 = the profile's own)")
+	lutExportCmd.Flags().String("title", "", "LUT title (default: describes the separation)")
+	lutExportCmd.MarkFlagRequired("output")
+
+	lutApplyCmd.Flags().StringP("input", "i", "", "Input RGB JPEG file")
+	lutApplyCmd.Flags().StringP("output", "o", "", "Output file (.tif for TIFF, otherwise JPEG)")
+	lutApplyCmd.Flags().String("lut", "", "CMYK LUT file (.cube or .csv)")
+	lutApplyCmd.Flags().String("profile", "", "CMYK ICC profile to embed in JPEG output (path or built-in name)")
+	lutApplyCmd.Flags().Int("quality", 85, "JPEG quality (1-100)")
+	lutApplyCmd.Flags().Int("cmy-reduction", 15, "Quality reduction for CMY channels vs K")
+	lutApplyCmd.MarkFlagRequired("input")
+	lutApplyCmd.MarkFlagRequired("output")
+	lutApplyCmd.MarkFlagRequired("lut")
+
+	lutCmd.AddCommand(lutExportCmd, lutApplyCmd)
+	rootCmd.AddCommand(lutCmd)
+}
+
+func runLutExport(cmd *cobra.Command, args []string) error {
+	outputPath, _ := cmd.Flags().GetString("output")
+	profilePath, _ := cmd.Flags().GetString("profile")
+	srcProfilePath, _ := cmd.Flags().GetString("src-profile")
+	intentStr, _ := cmd.Flags().GetString("intent")
+	size, _ := cmd.Flags().GetInt("size")
+	tac, _ := cmd.Flags().GetFloat64("tac")
+	title, _ := cmd.Flags().GetString("title")
+
+	intent, err := color.ParseIntent(intentStr)
+	if err != nil {
+		return err
+	}
+	if tac != 0 && (tac < 100 || tac > 400) {
+		return fmt.Errorf("--tac must be between 100 and 400, got %g", tac)
+	}
+	srcProfile, err := color.ResolveProfile(srcProfilePath)
+	if err != nil {
+		return profileError(fmt.Errorf("loading source profile: %w", err))
+	}
+	dstProfile, err := color.ResolveProfile(profilePath)
+	if err != nil {
+		return profileError(fmt.Errorf("loading CMYK profile: %w", err))
+	}
+
+	xform, err := color.NewFloatTransform(srcProfile, dstProfile, intent, 16)
+	if err != nil {
+		return profileError(err)
+	}
+	defer xform.Close()
+	intentWarning("", intent, xform.Intent())
+
+	table, err := lut.Sample(xform, size)
+	if err != nil {
+		return transformError(err)
+	}
+	limited := 0
+	if tac > 0 {
+		limited = table.LimitTAC(tac)
+	}
+
+	if title == "" {
+		title = fmt.Sprintf("%s to %s, %s", filepath.Base(srcProfilePath), filepath.Base(profilePath),
+			color.IntentName(xform.Intent()))
+	}
+	table.Title = title
+	table.Comments = []string{
+		"RGB to CMYK separation exported by rgbtocmyk",
+		"Source profile: " + srcProfilePath,
+		"Destination profile: " + profilePath,
+		"Intent: " + color.IntentName(xform.Intent()),
+		"Colour engine: " + color.Engine,
+	}
+	if tac > 0 {
+		table.Comments = append(table.Comments, fmt.Sprintf("TAC limit: %g%%", tac))
+	}
+
+	var buf bytes.Buffer
+	if strings.EqualFold(filepath.Ext(outputPath), ".csv") {
+		err = table.WriteCSV(&buf)
+	} else {
+		err = table.WriteCube(&buf)
+	}
+	if err != nil {
+		return encodeError(err)
+	}
+	run.lap("sample")
+	if err := os.WriteFile(outputPath, buf.Bytes(), 0644); err != nil {
+		return outputError(fmt.Errorf("writing LUT: %w", err))
+	}
+	run.lap("write")
+
+	if reportJSON(&lutExportReport{
+		SourceProfile:   describeProfile(srcProfilePath, srcProfile),
+		Profile:         describeProfile(profilePath, dstProfile),
+		Intent:          color.IntentName(xform.Intent()),
+		RequestedIntent: color.IntentName(intent),
+		Size:            size,
+		Title:           title,
+		TACLimit:        tac,
+		Limited:         limited,
+		Output:          fileReport{Path: outputPath, Bytes: buf.Len()},
+	}) {
+		return nil
+	}
+
+	fmt.Printf("Sampled %d³ grid: %s\n", size, title)
+	if tac > 0 {
+		fmt.Printf("TAC limit: %g%%, %d grid points reduced\n", tac, limited)
+	}
+	fmt.Printf("Output: %s (%d bytes)\n", outputPath, buf.Len())
+	return nil
+}
+
+func runLutApply(cmd *cobra.Command, args []string) error {
+	inputPath, _ := cmd.Flags().GetString("input")
+	outputPath, _ := cmd.Flags().GetString("output")
+	lutPath, _ := cmd.Flags().GetString("lut")
+	profilePath, _ := cmd.Flags().GetString("profile")
+	quality, _ := cmd.Flags().GetInt("quality")
+	cmyReduction, _ := cmd.Flags().GetInt("cmy-reduction")
+
+	lutData, err := os.ReadFile(lutPath)
+	if err != nil {
+		return profileError(fmt.Errorf("reading LUT: %w", err))
+	}
+	table, err := lut.Parse(lutData)
+	if err != nil {
+		return profileError(fmt.Errorf("parsing %s: %w", lutPath, err))
+	}
+	var dstProfile []byte
+	if profilePath != "" {
+		if dstProfile, err = color.ResolveProfile(profilePath); err != nil {
+			return profileError(fmt.Errorf("loading CMYK profile: %w", err))
+		}
+	}
+
+	inputData, err := os.ReadFile(inputPath)
+	if err != nil {
+		return inputError(fmt.Errorf("reading input: %w", err))
+	}
+	decoded, err := jpeg.DecodeRGB(inputData)
+	if err != nil {
+		return inputError(fmt.Errorf("decoding: %w", err))
+	}
+	run.lap("read")
+
+	cmyk, err := table.Apply(decoded.Pixels, decoded.Width, decoded.Height)
+	if err != nil {
+		return transformError(err)
+	}
+	run.lap("transform")
+
+	var out []byte
+	ext := filepath.Ext(outputPath)
+	if strings.EqualFold(ext, ".tif") || strings.EqualFold(ext, ".tiff") {
+		out, err = tiff.EncodeCMYK(cmyk, decoded.Width, decoded.Height, 0)
+	} else {
+		out, err = jpeg.EncodeCMYK(cmyk, decoded.Width, decoded.Height, dstProfile, jpeg.EncoderOptions{
+			Quality:      quality,
+			CMYReduction: cmyReduction,
+		})
+	}
+	if err != nil {
+		return encodeError(fmt.Errorf("encode: %w", err))
+	}
+	run.lap("encode")
+	if err := os.WriteFile(outputPath, out, 0644); err != nil {
+		return outputError(fmt.Errorf("writing output: %w", err))
+	}
+	run.lap("write")
+
+	if decoded.ICC != nil {
+		warn("the input's embedded profile is ignored; the LUT fixes the source colour space")
+	}
+	if dstProfile == nil && !strings.EqualFold(ext, ".tif") && !strings.EqualFold(ext, ".tiff") {
+		warn("no --profile given, output JPEG has no embedded ICC profile")
+	}
+	if reportJSON(&lutApplyReport{
+		Input:           fileReport{Path: inputPath, Bytes: len(inputData), Width: decoded.Width, Height: decoded.Height},
+		EmbeddedProfile: describeProfile("", decoded.ICC),
+		LUT:             lutReport{Path: lutPath, Size: table.Size, Title: table.Title},
+		Profile:         describeProfile(profilePath, dstProfile),
+		Output:          fileReport{Path: outputPath, Bytes: len(out), Width: decoded.Width, Height: decoded.Height},
+	}) {
+		return nil
+	}
+
+	fmt.Printf("Separated %dx%d through %d³ LUT", decoded.Width, decoded.Height, table.Size)
+	if table.Title != "" {
+		fmt.Printf(" %q", table.Title)
+	}
+	fmt.Println()
+	fmt.Printf("Output: %s (%d bytes)\n", outputPath, len(out))
+	return nil
+}
+
+// lutExportReport is the JSON form of the lut export output.
+type lutExportReport struct {
+	SourceProfile   *profileReport `json:"source_profile"`
+	Profile         *profileReport `json:"profile"`
+	Intent          string         `json:"intent"`
+	RequestedIntent string         `json:"requested_intent"`
+	Size            int            `json:"size"`
+	Title           string         `json:"title"`
+	TACLimit        float64        `json:"tac_limit,omitempty"`
+	Limited         int            `json:"limited_points,omitempty"`
+	Output          fileReport     `json:"output"`
+}
+
+// lutApplyReport is the JSON form of the lut apply output.
+type lutApplyReport struct {
+	Input           fileReport     `json:"input"`
+	EmbeddedProfile *profileReport `json:"embedded_profile"`
+	LUT             lutReport      `json:"lut"`
+	Profile         *profileReport `json:"profile"`
+	Output          fileReport     `json:"output"`
+}
+
+type lutReport struct {
+	Path  string `json:"path"`
+	Size  int    `json:"size"`
+	Title string `json:"title,omitempty"`
+}
@@ -4,17 +4,53 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/davesmith10/RGBtoCMYK/internal/color"
 	"github.com/spf13/cobra"
 )
 
+// version is set at build time with -ldflags "-X main.version=...".
+var version = "dev"
+
 var rootCmd = &cobra.Command{
-	Use:   "rgbtocmyk",
-	Short: "Convert RGB JPEG images to CMYK for professional printing",
+	Use:     "rgbtocmyk",
+	Short:   "Convert RGB JPEG images to CMYK for professional printing",
+	Version: version,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		switch outputFormat {
+		case "text":
+		case "json":
+			// The report carries the error; keep stdout a single document.
+			cmd.Root().SilenceErrors = true
+			cmd.Root().SilenceUsage = true
+			run.started = true
+		default:
+			return fmt.Errorf("--format must be text or json, got %q", outputFormat)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "format", "text", "Output format: text, or json for scripts")
+}
+
+// software names the tool, its version and colour engine in conversion
+// records.
+func software() string {
+	return fmt.Sprintf("rgbtocmyk %s (%s)", version, color.Engine)
 }
 
 func main() {
-	if err := rootCmd.Execute(); err != nil {
+	cmd, err := rootCmd.ExecuteC()
+	// Help and --version print text and run no command; a flag error
+	// stops before one runs but is still reported.
+	if jsonOutput() && (run.started || err != nil) {
+		if werr := run.finish(os.Stdout, cmd, err); werr != nil && err == nil {
+			err = outputError(werr)
+		}
+	}
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/davesmith10/RGBtoCMYK/internal/pipeline"
+	"github.com/spf13/cobra"
+)
+
+var optimizeCmd = &cobra.Command{
+	Use:   "optimize [flags] file.jpg...",
+	Short: "Recompress existing CMYK JPEGs with channel-aware quantization",
+	Long: `Re-encodes CMYK JPEGs, such as ImageMagick output, with the same
+channel-aware quantization and optimized Huffman coding as convert, without
+any colour transform. The embedded ICC profile, EXIF, XMP, IPTC, resolution
+and CMYK convention are kept. A file is never made larger: when the
+re-encode is not smaller, the original is kept.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runOptimize,
+}
+
+func init() {
+	optimizeCmd.Flags().StringP("output", "o", "", "Output file (one input only)")
+	optimizeCmd.Flags().String("out-dir", "", "Directory for the outputs, named as the inputs")
+	optimizeCmd.Flags().Bool("in-place", false, "Replace the inputs")
+	optimizeCmd.Flags().Int("quality", 85, "JPEG quality (1-100)")
+	optimizeCmd.Flags().Int("cmy-reduction", 15, "Quality reduction for CMY channels")
+	addQuantFlags(optimizeCmd)
+	addScanFlags(optimizeCmd)
+	rootCmd.AddCommand(optimizeCmd)
+}
+
+func runOptimize(cmd *cobra.Command, args []string) error {
+	outputPath, _ := cmd.Flags().GetString("output")
+	outDir, _ := cmd.Flags().GetString("out-dir")
+	inPlace, _ := cmd.Flags().GetBool("in-place")
+	quality, _ := cmd.Flags().GetInt("quality")
+	cmyReduction, _ := cmd.Flags().GetInt("cmy-reduction")
+
+	destinations := 0
+	for _, set := range []bool{outputPath != "", outDir != "", inPlace} {
+		if set {
+			destinations++
+		}
+	}
+	if destinations != 1 {
+		return fmt.Errorf("give exactly one of --output, --out-dir and --in-place")
+	}
+	if outputPath != "" && len(args) > 1 {
+		return fmt.Errorf("--output takes one input; use --out-dir or --in-place for %d", len(args))
+	}
+	if quality < 1 || quality > 100 {
+		return fmt.Errorf("--quality must be 1-100, got %d", quality)
+	}
+	channelQuality, baseTables, err := quantOptions(cmd)
+	if err != nil {
+		return err
+	}
+	progressive, scans, err := scanOptions(cmd)
+	if err != nil {
+		return err
+	}
+	opts := pipeline.OptimizeOptions{
+		Quality:        quality,
+		CMYReduction:   cmyReduction,
+		ChannelQuality: channelQuality,
+		BaseTables:     baseTables,
+		Progressive:    progressive,
+		Scans:          scans,
+	}
+
+	report := &optimizeReport{Files: []optimizedFile{}}
+	var firstErr error
+	for _, path := range args {
+		dest := outputPath
+		switch {
+		case inPlace:
+			dest = path
+		case outDir != "":
+			dest = filepath.Join(outDir, filepath.Base(path))
+		}
+		f, err := optimizeFile(path, dest, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			report.Failed++
+			if firstErr == nil {
+				firstErr = err
+			}
+			report.Files = append(report.Files, optimizedFile{Path: path, Error: err.Error()})
+			continue
+		}
+		report.Before += f.Before
+		report.After += f.After
+		report.Files = append(report.Files, *f)
+		if jsonOutput() {
+			continue
+		}
+		if f.Kept {
+			fmt.Printf("%s: %d bytes, kept (re-encode was %d bytes)\n", path, f.Before, f.Encoded)
+		} else {
+			fmt.Printf("%s: %d → %d bytes, saved %d (%.1f%%)\n",
+				path, f.Before, f.After, f.Before-f.After, percentSaved(f.Before, f.After))
+		}
+	}
+	run.lap("optimize")
+
+	if !reportJSON(report) && len(args) > 1 {
+		fmt.Printf("Total: %d → %d bytes, saved %d (%.1f%%) over %d files\n",
+			report.Before, report.After, report.Before-report.After, percentSaved(report.Before, report.After), len(args)-report.Failed)
+	}
+	if report.Failed > 0 {
+		// The exit code is that of the first failure.
+		return &exitError{exitCode(firstErr), fmt.Errorf("%d of %d files failed", report.Failed, len(args))}
+	}
+	return nil
+}
+
+// optimizeReport is the JSON form of the optimize output. Before and After
+// total the files that did not fail.
+type optimizeReport struct {
+	Files  []optimizedFile `json:"files"`
+	Before int             `json:"bytes_before"`
+	After  int             `json:"bytes_after"`
+	Failed int             `json:"failed"`
+}
+
+type optimizedFile struct {
+	Path    string `json:"path"`
+	Output  string `json:"output,omitempty"`
+	Before  int    `json:"bytes_before,omitempty"`
+	After   int    `json:"bytes_after,omitempty"`
+	Encoded int    `json:"bytes_encoded,omitempty"`
+	Kept    bool   `json:"kept"`
+	Error   string `json:"error,omitempty"`
+}
+
+// optimizeFile recompresses path to dest. dest may be path itself; it is
+// then only rewritten if the file shrinks, and through a temporary file so
+// an interrupted run cannot truncate it.
+func optimizeFile(path, dest string, opts pipeline.OptimizeOptions) (*optimizedFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, inputError(fmt.Errorf("reading input: %w", err))
+	}
+	r, err := pipeline.Optimize(data, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if !(r.Kept && dest == path) {
+		if err := writeFileAtomic(dest, r.Data); err != nil {
+			return nil, outputError(fmt.Errorf("writing output: %w", err))
+		}
+	}
+	return &optimizedFile{Path: path, Output: dest, Before: len(data), After: len(r.Data), Encoded: r.Encoded, Kept: r.Kept}, nil
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it into place, keeping the permissions of a file it replaces.
+func writeFileAtomic(path string, data []byte) error {
+	mode := os.FileMode(0644)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".optimize-*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Chmod(f.Name(), mode); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// percentSaved returns how much smaller after is than before, in percent.
+func percentSaved(before, after int) float64 {
+	if before == 0 {
+		return 0
+	}
+	return float64(before-after) / float64(before) * 100
+}
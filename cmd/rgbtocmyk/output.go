@@ -0,0 +1,220 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/davesmith10/RGBtoCMYK/internal/color"
+	"github.com/davesmith10/RGBtoCMYK/internal/pipeline"
+	"github.com/spf13/cobra"
+)
+
+// Exit codes. Scripts can tell a bad input file from a bad profile or a
+// failed write without parsing the message.
+const (
+	exitFailure   = 1 // invalid flags or arguments, or any other error
+	exitInput     = 2 // the input could not be read or decoded
+	exitProfile   = 3 // a profile could not be loaded or used
+	exitTransform = 4 // the colour transform failed
+	exitEncode    = 5 // the output could not be encoded
+	exitOutput    = 6 // the output could not be written
+)
+
+// exitKinds names the exit codes in JSON output.
+var exitKinds = map[int]string{
+	exitFailure:   "failure",
+	exitInput:     "input",
+	exitProfile:   "profile",
+	exitTransform: "transform",
+	exitEncode:    "encode",
+	exitOutput:    "output",
+}
+
+// exitError gives an error its exit code.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+
+func (e *exitError) Unwrap() error { return e.err }
+
+func inputError(err error) error     { return &exitError{exitInput, err} }
+func profileError(err error) error   { return &exitError{exitProfile, err} }
+func transformError(err error) error { return &exitError{exitTransform, err} }
+func encodeError(err error) error    { return &exitError{exitEncode, err} }
+func outputError(err error) error    { return &exitError{exitOutput, err} }
+
+// exitCode returns the exit code for the error a command returned: the
+// code given with exitError, or the one for the pipeline stage it came
+// from.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var ee *exitError
+	if errors.As(err, &ee) {
+		return ee.code
+	}
+	var se *pipeline.StageError
+	if errors.As(err, &se) {
+		switch se.Stage {
+		case pipeline.StageDecode:
+			return exitInput
+		case pipeline.StageProfile:
+			return exitProfile
+		case pipeline.StageTransform:
+			return exitTransform
+		case pipeline.StageEncode:
+			return exitEncode
+		case pipeline.StageOptions:
+			return exitFailure
+		}
+	}
+	return exitFailure
+}
+
+// outputFormat is the global --format flag, "text" or "json".
+var outputFormat = "text"
+
+func jsonOutput() bool { return outputFormat == "json" }
+
+// report is the document written with --format json. Result holds the
+// command's own fields; it is normally absent when the command failed, but
+// optimize reports the files it got through.
+type report struct {
+	Command  string             `json:"command"`
+	Version  string             `json:"version"`
+	OK       bool               `json:"ok"`
+	Error    *reportError       `json:"error,omitempty"`
+	Warnings []string           `json:"warnings"`
+	Timings  map[string]float64 `json:"timings_ms"`
+	Result   any                `json:"result,omitempty"`
+
+	start, last time.Time
+	started     bool // a command's run function was reached
+}
+
+type reportError struct {
+	Code    int    `json:"code"`
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// run is the report of the command being executed.
+var run = newReport()
+
+func newReport() *report {
+	now := time.Now()
+	return &report{Version: version, Warnings: []string{}, Timings: map[string]float64{}, start: now, last: now}
+}
+
+// lap records the time since the previous lap, or since the command
+// started, as the timing of step.
+func (r *report) lap(step string) {
+	now := time.Now()
+	r.Timings[step] += float64(now.Sub(r.last).Microseconds()) / 1000
+	r.last = now
+}
+
+// warn reports something the user should know that does not stop the
+// command: on stderr as text, in the warnings list as JSON.
+func warn(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	if jsonOutput() {
+		run.Warnings = append(run.Warnings, msg)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Warning: %s\n", msg)
+}
+
+// reportJSON stores a command's result for the JSON report and says
+// whether the output format is JSON, in which case the command prints no
+// text.
+func reportJSON(result any) bool {
+	if !jsonOutput() {
+		return false
+	}
+	run.Result = result
+	return true
+}
+
+// finish completes the report for cmd after it returned err and writes it
+// to w.
+func (r *report) finish(w io.Writer, cmd *cobra.Command, err error) error {
+	if cmd != nil {
+		r.Command = strings.TrimPrefix(cmd.CommandPath(), rootCmd.Name()+" ")
+	}
+	r.Timings["total"] = float64(time.Since(r.start).Microseconds()) / 1000
+	r.OK = err == nil
+	if err != nil {
+		code := exitCode(err)
+		r.Error = &reportError{Code: code, Kind: exitKinds[code], Message: err.Error()}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// fileReport describes a file read or written.
+type fileReport struct {
+	Path   string `json:"path"`
+	Bytes  int    `json:"bytes"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+}
+
+// profileReport describes an ICC profile. Name is the path or built-in
+// name it was given as; Reason says how a source profile was chosen.
+type profileReport struct {
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description"`
+	ColorSpace  string `json:"color_space"`
+	Class       string `json:"class"`
+	Version     string `json:"version"`
+	Bytes       int    `json:"bytes"`
+	SHA256      string `json:"sha256"`
+	Reason      string `json:"reason,omitempty"`
+	Invalid     string `json:"invalid,omitempty"`
+}
+
+// describeProfile fills a profileReport from profile data, nil for none.
+func describeProfile(name string, data []byte) *profileReport {
+	if data == nil {
+		return nil
+	}
+	sum := sha256.Sum256(data)
+	p := &profileReport{Name: name, Bytes: len(data), SHA256: hex.EncodeToString(sum[:])}
+	pi, err := color.ParseProfileInfo(data)
+	if err != nil {
+		p.Invalid = err.Error()
+		return p
+	}
+	p.Description = pi.Description
+	p.ColorSpace = color.ColorSpaceName(pi.ColorSpace)
+	p.Class = color.ProfileClassName(pi.Class)
+	p.Version = pi.Version
+	return p
+}
+
+// intentWarning warns when the profiles did not support the requested
+// rendering intent. dest names the destination when there are several.
+func intentWarning(dest string, requested, used int) {
+	if used == requested {
+		return
+	}
+	msg := fmt.Sprintf("rendering intent %s not supported by the profiles, used %s",
+		color.IntentName(requested), color.IntentName(used))
+	if dest != "" {
+		msg = dest + ": " + msg
+	}
+	warn("%s", msg)
+}
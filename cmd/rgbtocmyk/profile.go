@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/davesmith10/RGBtoCMYK/internal/color"
+	"github.com/spf13/cobra"
+)
+
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Work with ICC profiles",
+}
+
+var profileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List built-in profiles usable as --profile or --src-profile",
+	Args:  cobra.NoArgs,
+	RunE:  runProfileList,
+}
+
+func init() {
+	profileCmd.AddCommand(profileListCmd)
+	rootCmd.AddCommand(profileCmd)
+}
+
+func runProfileList(cmd *cobra.Command, args []string) error {
+	var profiles []*profileReport
+	for _, name := range color.BuiltinProfileNames() {
+		data, _ := color.BuiltinProfile(name)
+		pi, err := color.ParseProfileInfo(data)
+		if err != nil {
+			return profileError(fmt.Errorf("built-in profile %s: %w", name, err))
+		}
+		profiles = append(profiles, describeProfile(name, data))
+		if jsonOutput() {
+			continue
+		}
+		marker := ""
+		if name == color.DefaultCMYKProfile {
+			marker = " (default)"
+		}
+		fmt.Printf("%-18s %-5s %-7s %7d bytes%s\n", name, color.ColorSpaceName(pi.ColorSpace),
+			color.ProfileClassName(pi.Class), len(data), marker)
+	}
+	reportJSON(&profileListReport{Profiles: profiles, Default: color.DefaultCMYKProfile})
+	return nil
+}
+
+// profileListReport is the JSON form of the profile list output.
+type profileListReport struct {
+	Profiles []*profileReport `json:"profiles"`
+	Default  string           `json:"default"`
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/davesmith10/RGBtoCMYK/internal/color"
+	"github.com/davesmith10/RGBtoCMYK/internal/profile"
+	"github.com/spf13/cobra"
+)
+
+var profileBuildCmd = &cobra.Command{
+	Use:   "build",
+	Short: "Build a CMYK output profile from CGATS/IT8 measurement data",
+	RunE:  runProfileBuild,
+}
+
+func init() {
+	profileBuildCmd.Flags().StringP("input", "i", "", "CGATS/IT8 measurement file (CMYK + Lab or XYZ)")
+	profileBuildCmd.Flags().StringP("output", "o", "", "Output ICC profile")
+	profileBuildCmd.Flags().String("description", "", "Profile description (default: input file name)")
+	profileBuildCmd.Flags().String("copyright", "", "Profile copyright text")
+	profileBuildCmd.Flags().Float64("tac", 300, "Total area coverage limit (%)")
+	profileBuildCmd.Flags().Float64("max-k", 95, "Maximum black (%)")
+	profileBuildCmd.Flags().Float64("black-start", 20, "Gray component at which black generation starts (%)")
+	profileBuildCmd.Flags().Float64("gcr", 50, "Gray component replacement strength (0-100)")
+	profileBuildCmd.Flags().Int("grid", 33, "B2A grid points per Lab axis")
+	profileBuildCmd.MarkFlagRequired("input")
+	profileBuildCmd.MarkFlagRequired("output")
+	profileCmd.AddCommand(profileBuildCmd)
+}
+
+func runProfileBuild(cmd *cobra.Command, args []string) error {
+	inputPath, _ := cmd.Flags().GetString("input")
+	outputPath, _ := cmd.Flags().GetString("output")
+	description, _ := cmd.Flags().GetString("description")
+	copyright, _ := cmd.Flags().GetString("copyright")
+	tac, _ := cmd.Flags().GetFloat64("tac")
+	maxK, _ := cmd.Flags().GetFloat64("max-k")
+	blackStart, _ := cmd.Flags().GetFloat64("black-start")
+	gcr, _ := cmd.Flags().GetFloat64("gcr")
+	grid, _ := cmd.Flags().GetInt("grid")
+
+	data, err := os.ReadFile(inputPath)
+	if err != nil {
+		return inputError(fmt.Errorf("reading measurements: %w", err))
+	}
+
+	table, err := color.ParseIT8(data)
+	if err != nil {
+		return inputError(fmt.Errorf("parsing %s: %w", inputPath, err))
+	}
+	samples, err := profile.SamplesFromTable(table.Fields, table.Rows)
+	if err != nil {
+		return inputError(fmt.Errorf("parsing %s: %w", inputPath, err))
+	}
+	run.lap("read")
+
+	model, err := profile.FitMeasured(samples)
+	if err != nil {
+		return profileError(fmt.Errorf("fitting model: %w", err))
+	}
+	meanDE, maxDE := model.FitError()
+
+	if description == "" {
+		description = strings.TrimSuffix(filepath.Base(inputPath), filepath.Ext(inputPath))
+	}
+	icc, err := profile.BuildCMYK(model, profile.Options{
+		Description: description,
+		Copyright:   copyright,
+		B2AGrid:     grid,
+		Separation: profile.Separation{
+			TAC:        tac,
+			MaxK:       maxK,
+			BlackStart: blackStart,
+			GCR:        gcr,
+		},
+	})
+	if err != nil {
+		return profileError(fmt.Errorf("building profile: %w", err))
+	}
+	run.lap("build")
+
+	if err := os.WriteFile(outputPath, icc, 0644); err != nil {
+		return outputError(fmt.Errorf("writing profile: %w", err))
+	}
+	run.lap("write")
+
+	if reportJSON(&profileBuildReport{
+		Input:      fileReport{Path: inputPath, Bytes: len(data)},
+		Patches:    len(samples),
+		MeanDeltaE: meanDE,
+		MaxDeltaE:  maxDE,
+		TAC:        tac,
+		MaxK:       maxK,
+		BlackStart: blackStart,
+		GCR:        gcr,
+		Profile:    describeProfile(outputPath, icc),
+	}) {
+		return nil
+	}
+
+	fmt.Printf("Measurements: %s (%d patches)\n", inputPath, len(samples))
+	fmt.Printf("Model fit:    mean ΔE %.2f, max ΔE %.2f\n", meanDE, maxDE)
+	fmt.Printf("Separation:   TAC %.0f%%, max K %.0f%%, black start %.0f%%, GCR %.0f%%\n", tac, maxK, blackStart, gcr)
+	fmt.Printf("Profile:      %s (%d bytes)\n", outputPath, len(icc))
+	return nil
+}
+
+// profileBuildReport is the JSON form of the profile build output.
+type profileBuildReport struct {
+	Input      fileReport     `json:"input"`
+	Patches    int            `json:"patches"`
+	MeanDeltaE float64        `json:"fit_mean_delta_e"`
+	MaxDeltaE  float64        `json:"fit_max_delta_e"`
+	TAC        float64        `json:"tac"`
+	MaxK       float64        `json:"max_k"`
+	BlackStart float64        `json:"black_start"`
+	GCR        float64        `json:"gcr"`
+	Profile    *profileReport `json:"profile"`
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/davesmith10/RGBtoCMYK/internal/color"
 	"github.com/davesmith10/RGBtoCMYK/internal/jpeg"
+	"github.com/davesmith10/RGBtoCMYK/internal/pipeline"
 	"github.com/spf13/cobra"
 )
 
@@ -20,12 +21,12 @@ var transformCmd = &cobra.Command{
 func init() {
 	transformCmd.Flags().StringP("input", "i", "", "Input RGB JPEG file")
 	transformCmd.Flags().StringP("output", "o", "", "Output raw CMYK file")
-	transformCmd.Flags().String("profile", "", "CMYK ICC profile path")
+	transformCmd.Flags().String("profile", color.DefaultCMYKProfile, "CMYK ICC profile path or built-in name")
 	transformCmd.Flags().String("src-profile", "", "Source RGB ICC profile override")
+	transformCmd.Flags().String("assume-profile", "", "Source profile for untagged input, skipping EXIF/XMP hints")
 	transformCmd.Flags().String("intent", "perceptual", "Rendering intent")
 	transformCmd.MarkFlagRequired("input")
 	transformCmd.MarkFlagRequired("output")
-	transformCmd.MarkFlagRequired("profile")
 	rootCmd.AddCommand(transformCmd)
 }
 
@@ -40,6 +41,7 @@ func runTransform(cmd *cobra.Command, args []string) error {
 	outputPath, _ := cmd.Flags().GetString("output")
 	profilePath, _ := cmd.Flags().GetString("profile")
 	srcProfilePath, _ := cmd.Flags().GetString("src-profile")
+	assumePath, _ := cmd.Flags().GetString("assume-profile")
 	intentStr, _ := cmd.Flags().GetString("intent")
 
 	intent, err := color.ParseIntent(intentStr)
@@ -49,43 +51,53 @@ func runTransform(cmd *cobra.Command, args []string) error {
 
 	inputData, err := os.ReadFile(inputPath)
 	if err != nil {
-		return fmt.Errorf("reading input: %w", err)
+		return inputError(fmt.Errorf("reading input: %w", err))
 	}
 
 	decoded, err := jpeg.DecodeRGB(inputData)
 	if err != nil {
-		return fmt.Errorf("decoding: %w", err)
+		return inputError(fmt.Errorf("decoding: %w", err))
 	}
+	run.lap("read")
 
-	dstProfile, err := color.LoadProfile(profilePath)
+	dstProfile, err := color.ResolveProfile(profilePath)
 	if err != nil {
-		return err
+		return profileError(err)
 	}
 
-	srcICC := decoded.ICC
+	var srcOverride, assumeProfile []byte
 	if srcProfilePath != "" {
-		srcICC, err = color.LoadProfile(srcProfilePath)
+		srcOverride, err = color.ResolveProfile(srcProfilePath)
 		if err != nil {
-			return err
+			return profileError(err)
 		}
 	}
-	if srcICC == nil {
-		srcICC = color.EmbeddedSRGB
+	if assumePath != "" {
+		assumeProfile, err = color.ResolveProfile(assumePath)
+		if err != nil {
+			return profileError(err)
+		}
+	}
+	srcICC, srcReason := pipeline.SourceProfile(decoded, srcOverride, assumeProfile)
+	if ignored := pipeline.IgnoredProfile(decoded); ignored != "" && srcOverride == nil {
+		warn("%s", ignored)
 	}
 
 	xform, err := color.NewTransform(srcICC, dstProfile, intent)
 	if err != nil {
-		return err
+		return profileError(err)
 	}
 	defer xform.Close()
+	intentWarning("", intent, xform.Intent())
 
 	cmyk, err := xform.TransformPixels(decoded.Pixels, decoded.Width, decoded.Height)
 	if err != nil {
-		return err
+		return transformError(err)
 	}
+	run.lap("transform")
 
 	if err := os.WriteFile(outputPath, cmyk, 0644); err != nil {
-		return fmt.Errorf("writing raw CMYK: %w", err)
+		return outputError(fmt.Errorf("writing raw CMYK: %w", err))
 	}
 
 	// Write JSON sidecar
@@ -97,10 +109,39 @@ func runTransform(cmd *cobra.Command, args []string) error {
 	metaJSON, _ := json.MarshalIndent(meta, "", "  ")
 	metaPath := strings.TrimSuffix(outputPath, ".raw") + ".json"
 	if err := os.WriteFile(metaPath, metaJSON, 0644); err != nil {
-		return fmt.Errorf("writing sidecar: %w", err)
+		return outputError(fmt.Errorf("writing sidecar: %w", err))
+	}
+	run.lap("write")
+
+	source := describeProfile("", srcICC)
+	source.Reason = srcReason
+	if reportJSON(&transformReport{
+		Input:           fileReport{Path: inputPath, Bytes: len(inputData), Width: decoded.Width, Height: decoded.Height},
+		EmbeddedProfile: describeProfile("", decoded.ICC),
+		SourceProfile:   source,
+		Profile:         describeProfile(profilePath, dstProfile),
+		Intent:          color.IntentName(xform.Intent()),
+		RequestedIntent: color.IntentName(intent),
+		Output:          fileReport{Path: outputPath, Bytes: len(cmyk), Width: decoded.Width, Height: decoded.Height},
+		Sidecar:         metaPath,
+	}) {
+		return nil
 	}
 
 	fmt.Printf("Transformed %dx%d → raw CMYK (%d bytes)\n", decoded.Width, decoded.Height, len(cmyk))
+	fmt.Printf("Source: %s\n", srcReason)
 	fmt.Printf("Sidecar: %s\n", metaPath)
 	return nil
 }
+
+// transformReport is the JSON form of the transform output.
+type transformReport struct {
+	Input           fileReport     `json:"input"`
+	EmbeddedProfile *profileReport `json:"embedded_profile"`
+	SourceProfile   *profileReport `json:"source_profile"`
+	Profile         *profileReport `json:"profile"`
+	Intent          string         `json:"intent"`
+	RequestedIntent string         `json:"requested_intent"`
+	Output          fileReport     `json:"output"`
+	Sidecar         string         `json:"sidecar"`
+}
@@ -0,0 +1,325 @@
+// Package black rewrites the separation of pure-black areas in converted
+// images. The colour transform renders RGB black as a four-colour mix, which
+// muddies text and hairlines and varies with the profile; this package
+// replaces it with K-only for small features and a fixed rich-black recipe
+// for large solids.
+package black
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Mode selects how pure-black pixels are separated.
+type Mode int
+
+const (
+	// Off leaves the colour transform's output untouched.
+	Off Mode = iota
+	// Auto renders small features K-only and large solids rich.
+	Auto
+	// KOnly renders all pure black K-only.
+	KOnly
+	// Rich renders all pure black with the rich-black recipe.
+	Rich
+)
+
+// ParseMode converts a command-line name to a Mode.
+func ParseMode(s string) (Mode, error) {
+	switch s {
+	case "off":
+		return Off, nil
+	case "auto":
+		return Auto, nil
+	case "k-only":
+		return KOnly, nil
+	case "rich":
+		return Rich, nil
+	default:
+		return Off, fmt.Errorf("unknown black mode: %q", s)
+	}
+}
+
+// String returns the command-line name of m.
+func (m Mode) String() string {
+	switch m {
+	case Off:
+		return "off"
+	case Auto:
+		return "auto"
+	case KOnly:
+		return "k-only"
+	case Rich:
+		return "rich"
+	}
+	return fmt.Sprintf("Mode(%d)", int(m))
+}
+
+// Options controls black handling. The zero value disables it.
+type Options struct {
+	Mode      Mode
+	Tolerance uint8    // max RGB channel value still counted as black, and max channel spread of a neutral edge pixel
+	Edge      int      // width in pixels of the neutral fringe around K-only areas also made K-only
+	MinArea   int      // smallest region, in pixels, filled rich in Auto mode
+	MinWidth  int      // thinnest region, in pixels, filled rich in Auto mode
+	Recipe    [4]uint8 // rich-black CMYK, 0-255
+}
+
+// String describes the settings that apply in o's mode, e.g.
+// "rich 60/40/40/100".
+func (o Options) String() string {
+	recipe := make([]string, 4)
+	for i, v := range o.Recipe {
+		recipe[i] = strconv.Itoa(int(math.Round(float64(v) * 100 / 255)))
+	}
+	switch o.Mode {
+	case Rich:
+		return "rich " + strings.Join(recipe, "/")
+	case Auto:
+		return fmt.Sprintf("auto, rich %s from %d pixels and %d wide%s", strings.Join(recipe, "/"), o.MinArea, o.MinWidth, o.edgeString())
+	case KOnly:
+		return "k-only" + o.edgeString()
+	}
+	return o.Mode.String()
+}
+
+// edgeString describes the edge setting for String.
+func (o Options) edgeString() string {
+	if o.Edge <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(", %d-pixel edges", o.Edge)
+}
+
+// DefaultOptions returns settings tuned for 300 dpi rasterisations, with
+// black handling Off as in the command's default; set Mode to use them.
+// Regions need at least 64×64 pixels of area and a 12-pixel-wide core to be
+// treated as solids, solids use 60/40/40/100, and the 2-pixel anti-aliased
+// edges of K-only text go K-only with it.
+func DefaultOptions() Options {
+	return Options{
+		Mode:      Off,
+		Tolerance: 12,
+		Edge:      2,
+		MinArea:   64 * 64,
+		MinWidth:  12,
+		Recipe:    [4]uint8{153, 102, 102, 255},
+	}
+}
+
+// ParseRecipe parses a rich-black recipe such as "60/40/40/100" (percent,
+// separated by "/" or ",") into 0-255 CMYK values.
+func ParseRecipe(s string) ([4]uint8, error) {
+	var r [4]uint8
+	parts := strings.FieldsFunc(s, func(c rune) bool { return c == '/' || c == ',' })
+	if len(parts) != 4 {
+		return r, fmt.Errorf("rich black %q: expected C/M/Y/K percentages", s)
+	}
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil || v < 0 || v > 100 {
+			return r, fmt.Errorf("rich black %q: invalid percentage %q", s, p)
+		}
+		r[i] = uint8(v*255/100 + 0.5)
+	}
+	return r, nil
+}
+
+// Stats reports what Apply changed.
+type Stats struct {
+	Regions     int // connected black regions found
+	RichRegions int // regions filled with the rich-black recipe
+	KOnlyPixels int
+	RichPixels  int
+	EdgePixels  int // neutral edge pixels made K-only
+}
+
+// Apply rewrites the CMYK separation of pure-black pixels in place. rgb holds
+// the source pixels (3 bytes each) that produced cmyk (4 bytes each).
+func Apply(rgb, cmyk []byte, width, height int, opts Options) (Stats, error) {
+	var st Stats
+	n := width * height
+	if len(rgb) != n*3 || len(cmyk) != n*4 {
+		return st, fmt.Errorf("expected %d RGB and %d CMYK bytes, got %d and %d", n*3, n*4, len(rgb), len(cmyk))
+	}
+	if opts.Mode == Off {
+		return st, nil
+	}
+
+	mask := make([]bool, n)
+	for i := range mask {
+		r, g, b := rgb[3*i], rgb[3*i+1], rgb[3*i+2]
+		mask[i] = r <= opts.Tolerance && g <= opts.Tolerance && b <= opts.Tolerance
+	}
+
+	kOnly := [4]uint8{0, 0, 0, 255}
+	fill := func(i int, v [4]uint8) {
+		copy(cmyk[4*i:4*i+4], v[:])
+	}
+
+	// K-only pixels seed the edge pass.
+	var edgeSeeds []int32
+	if opts.Mode != Auto {
+		v := kOnly
+		if opts.Mode == Rich {
+			v = opts.Recipe
+		}
+		for i, black := range mask {
+			if black {
+				fill(i, v)
+				if opts.Mode == Rich {
+					st.RichPixels++
+				} else {
+					st.KOnlyPixels++
+					edgeSeeds = append(edgeSeeds, int32(i))
+				}
+			}
+		}
+	} else {
+		dist := chessboardDistance(mask, width, height)
+		for _, reg := range regions(mask, width, height) {
+			st.Regions++
+			solid := len(reg) >= opts.MinArea && 2*maxDistance(reg, dist) >= opts.MinWidth
+			v := kOnly
+			if solid {
+				v = opts.Recipe
+				st.RichRegions++
+				st.RichPixels += len(reg)
+			} else {
+				st.KOnlyPixels += len(reg)
+				edgeSeeds = append(edgeSeeds, reg...)
+			}
+			for _, i := range reg {
+				fill(int(i), v)
+			}
+		}
+	}
+
+	for _, i := range neutralEdges(rgb, mask, edgeSeeds, width, height, opts) {
+		r, g, b := int(rgb[3*i]), int(rgb[3*i+1]), int(rgb[3*i+2])
+		fill(int(i), [4]uint8{0, 0, 0, uint8(255 - (r+g+b+1)/3)})
+		st.EdgePixels++
+	}
+	return st, nil
+}
+
+// neutralEdges returns the pixels within opts.Edge steps of seeds, through
+// unmasked neutral pixels. These are the anti-aliased fringes of K-only
+// text: left to the colour transform they print as a four-colour halo
+// around the K-only core, so they go K-only at their own grey level.
+func neutralEdges(rgb []byte, mask []bool, seeds []int32, width, height int, opts Options) []int32 {
+	if opts.Edge <= 0 || len(seeds) == 0 {
+		return nil
+	}
+	neutral := func(i int) bool {
+		r, g, b := rgb[3*i], rgb[3*i+1], rgb[3*i+2]
+		return max(r, g, b)-min(r, g, b) <= opts.Tolerance
+	}
+	seen := make([]bool, len(mask))
+	var out []int32
+	frontier := seeds
+	for step := 0; step < opts.Edge && len(frontier) > 0; step++ {
+		var next []int32
+		for _, p := range frontier {
+			x, y := int(p)%width, int(p)/width
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := x+dx, y+dy
+					if nx < 0 || ny < 0 || nx >= width || ny >= height {
+						continue
+					}
+					j := ny*width + nx
+					if mask[j] || seen[j] || !neutral(j) {
+						continue
+					}
+					seen[j] = true
+					next = append(next, int32(j))
+				}
+			}
+		}
+		out = append(out, next...)
+		frontier = next
+	}
+	return out
+}
+
+// regions returns the 8-connected components of mask as pixel index lists.
+func regions(mask []bool, width, height int) [][]int32 {
+	seen := make([]bool, len(mask))
+	var out [][]int32
+	var stack []int32
+	for start, black := range mask {
+		if !black || seen[start] {
+			continue
+		}
+		var reg []int32
+		seen[start] = true
+		stack = append(stack[:0], int32(start))
+		for len(stack) > 0 {
+			i := int(stack[len(stack)-1])
+			stack = stack[:len(stack)-1]
+			reg = append(reg, int32(i))
+			x, y := i%width, i/width
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := x+dx, y+dy
+					if nx < 0 || ny < 0 || nx >= width || ny >= height {
+						continue
+					}
+					j := ny*width + nx
+					if mask[j] && !seen[j] {
+						seen[j] = true
+						stack = append(stack, int32(j))
+					}
+				}
+			}
+		}
+		out = append(out, reg)
+	}
+	return out
+}
+
+// chessboardDistance returns, for each masked pixel, the chessboard distance
+// to the nearest unmasked pixel or image edge, using the two-pass chamfer
+// algorithm.
+func chessboardDistance(mask []bool, width, height int) []int32 {
+	d := make([]int32, len(mask))
+	at := func(x, y int) int32 {
+		if x < 0 || y < 0 || x >= width || y >= height {
+			return 0
+		}
+		return d[y*width+x]
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*width + x
+			if !mask[i] {
+				continue
+			}
+			m := min(at(x-1, y), at(x-1, y-1), at(x, y-1), at(x+1, y-1))
+			d[i] = m + 1
+		}
+	}
+	for y := height - 1; y >= 0; y-- {
+		for x := width - 1; x >= 0; x-- {
+			i := y*width + x
+			if !mask[i] {
+				continue
+			}
+			m := min(at(x+1, y), at(x+1, y+1), at(x, y+1), at(x-1, y+1))
+			d[i] = min(d[i], m+1)
+		}
+	}
+	return d
+}
+
+// maxDistance returns the largest distance value within a region.
+func maxDistance(reg []int32, dist []int32) int {
+	var m int32
+	for _, i := range reg {
+		m = max(m, dist[i])
+	}
+	return int(m)
+}
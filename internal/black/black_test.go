@@ -0,0 +1,174 @@
+package black
+
+import "testing"
+
+// testImage returns a white 100x100 RGB image with a 40x40 black square at
+// (10,10) and a 1-pixel black hairline along row 80, converted to a muddy
+// four-colour black by a stand-in transform.
+func testImage() (rgb, cmyk []byte, w, h int) {
+	w, h = 100, 100
+	rgb = make([]byte, w*h*3)
+	cmyk = make([]byte, w*h*4)
+	for i := range rgb {
+		rgb[i] = 255
+	}
+	setBlack := func(x, y int) {
+		i := y*w + x
+		rgb[3*i], rgb[3*i+1], rgb[3*i+2] = 0, 0, 0
+		copy(cmyk[4*i:], []byte{190, 180, 170, 230})
+	}
+	for y := 10; y < 50; y++ {
+		for x := 10; x < 50; x++ {
+			setBlack(x, y)
+		}
+	}
+	for x := 5; x < 95; x++ {
+		setBlack(x, 80)
+	}
+	return rgb, cmyk, w, h
+}
+
+func pixel(cmyk []byte, w, x, y int) [4]uint8 {
+	i := 4 * (y*w + x)
+	return [4]uint8{cmyk[i], cmyk[i+1], cmyk[i+2], cmyk[i+3]}
+}
+
+func TestApplyAuto(t *testing.T) {
+	rgb, cmyk, w, h := testImage()
+	opts := DefaultOptions()
+	opts.Mode = Auto
+	opts.MinArea = 100
+	opts.MinWidth = 6
+
+	st, err := Apply(rgb, cmyk, w, h, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Regions != 2 || st.RichRegions != 1 {
+		t.Errorf("stats = %+v, want 2 regions, 1 rich", st)
+	}
+	if got := pixel(cmyk, w, 30, 30); got != opts.Recipe {
+		t.Errorf("solid = %v, want recipe %v", got, opts.Recipe)
+	}
+	if got := pixel(cmyk, w, 50, 80); got != [4]uint8{0, 0, 0, 255} {
+		t.Errorf("hairline = %v, want K only", got)
+	}
+	if got := pixel(cmyk, w, 70, 30); got != [4]uint8{} {
+		t.Errorf("paper = %v, want untouched", got)
+	}
+}
+
+func TestApplyEdges(t *testing.T) {
+	rgb, cmyk, w, h := testImage()
+	set := func(x, y int, r, g, b byte) {
+		i := y*w + x
+		rgb[3*i], rgb[3*i+1], rgb[3*i+2] = r, g, b
+		copy(cmyk[4*i:], []byte{90, 80, 70, 60})
+	}
+	// An anti-aliased fringe below the hairline, and a red pixel above it.
+	for x := 5; x < 95; x++ {
+		set(x, 81, 128, 128, 128)
+		set(x, 82, 200, 200, 200)
+		set(x, 83, 230, 230, 230)
+	}
+	set(50, 79, 200, 40, 40)
+
+	opts := DefaultOptions()
+	opts.Mode = KOnly
+	st, err := Apply(rgb, cmyk, w, h, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := pixel(cmyk, w, 50, 81); got != [4]uint8{0, 0, 0, 127} {
+		t.Errorf("first edge row = %v, want K-only 127", got)
+	}
+	if got := pixel(cmyk, w, 50, 82); got != [4]uint8{0, 0, 0, 55} {
+		t.Errorf("second edge row = %v, want K-only 55", got)
+	}
+	if got := pixel(cmyk, w, 50, 83); got != [4]uint8{90, 80, 70, 60} {
+		t.Errorf("third row = %v, want untouched beyond the edge width", got)
+	}
+	if got := pixel(cmyk, w, 50, 79); got != [4]uint8{90, 80, 70, 60} {
+		t.Errorf("coloured neighbour = %v, want untouched", got)
+	}
+	if st.EdgePixels == 0 {
+		t.Errorf("stats = %+v, want edge pixels", st)
+	}
+
+	// Rich solids keep their edges, and Edge 0 turns the pass off.
+	rgb, cmyk, w, h = testImage()
+	set(30, 50, 128, 128, 128)
+	opts.Mode, opts.MinArea, opts.MinWidth = Auto, 100, 6
+	if _, err := Apply(rgb, cmyk, w, h, opts); err != nil {
+		t.Fatal(err)
+	}
+	if got := pixel(cmyk, w, 30, 50); got != [4]uint8{90, 80, 70, 60} {
+		t.Errorf("edge of rich solid = %v, want untouched", got)
+	}
+	rgb, cmyk, w, h = testImage()
+	set(50, 81, 128, 128, 128)
+	opts.Edge = 0
+	if _, err := Apply(rgb, cmyk, w, h, opts); err != nil {
+		t.Fatal(err)
+	}
+	if got := pixel(cmyk, w, 50, 81); got != [4]uint8{90, 80, 70, 60} {
+		t.Errorf("edge with Edge 0 = %v, want untouched", got)
+	}
+}
+
+func TestApplyModes(t *testing.T) {
+	for _, tt := range []struct {
+		mode Mode
+		want [4]uint8
+	}{
+		{Off, [4]uint8{190, 180, 170, 230}},
+		{KOnly, [4]uint8{0, 0, 0, 255}},
+		{Rich, [4]uint8{153, 102, 102, 255}},
+	} {
+		rgb, cmyk, w, h := testImage()
+		opts := DefaultOptions()
+		opts.Mode = tt.mode
+		if _, err := Apply(rgb, cmyk, w, h, opts); err != nil {
+			t.Fatal(err)
+		}
+		if got := pixel(cmyk, w, 30, 30); got != tt.want {
+			t.Errorf("mode %d: solid = %v, want %v", tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestParseRecipe(t *testing.T) {
+	r, err := ParseRecipe("60/40/40/100")
+	if err != nil || r != [4]uint8{153, 102, 102, 255} {
+		t.Errorf("got %v, %v", r, err)
+	}
+	if r, err := ParseRecipe("50,50,50,100"); err != nil || r != [4]uint8{128, 128, 128, 255} {
+		t.Errorf("comma form: %v, %v", r, err)
+	}
+	for _, bad := range []string{"60/40/40", "60/40/40/120", "a/b/c/d"} {
+		if _, err := ParseRecipe(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+func TestOptionsString(t *testing.T) {
+	rich := DefaultOptions()
+	rich.Mode = Rich
+	auto := DefaultOptions()
+	auto.Mode = Auto
+	for _, tc := range []struct {
+		opts Options
+		want string
+	}{
+		{Options{}, "off"},
+		{Options{Mode: KOnly}, "k-only"},
+		{rich, "rich 60/40/40/100"},
+		{DefaultOptions(), "off"},
+		{auto, "auto, rich 60/40/40/100 from 4096 pixels and 12 wide, 2-pixel edges"},
+	} {
+		if got := tc.opts.String(); got != tc.want {
+			t.Errorf("got %q, want %q", got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package chart
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"time"
+)
+
+// WriteCGATS writes the chart's reference data as a CGATS.17 file listing
+// the nominal CMYK value of every patch. Measurement tools merge their
+// readings into this file by SAMPLE_ID/SAMPLE_NAME.
+func WriteCGATS(w io.Writer, samples []Sample, descriptor string, created time.Time) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "CGATS.17")
+	fmt.Fprintln(bw, `ORIGINATOR	"RGBtoCMYK"`)
+	fmt.Fprintf(bw, "DESCRIPTOR\t%q\n", descriptor)
+	fmt.Fprintf(bw, "CREATED\t%q\n", created.Format(time.RFC3339))
+	fmt.Fprintln(bw, "NUMBER_OF_FIELDS\t6")
+	fmt.Fprintln(bw, "BEGIN_DATA_FORMAT")
+	fmt.Fprintln(bw, "SAMPLE_ID\tSAMPLE_NAME\tCMYK_C\tCMYK_M\tCMYK_Y\tCMYK_K")
+	fmt.Fprintln(bw, "END_DATA_FORMAT")
+	fmt.Fprintf(bw, "NUMBER_OF_SETS\t%d\n", len(samples))
+	fmt.Fprintln(bw, "BEGIN_DATA")
+	for _, s := range samples {
+		fmt.Fprintf(bw, "%d\t%s\t%.2f\t%.2f\t%.2f\t%.2f\n", s.ID, s.Name, s.Patch[0], s.Patch[1], s.Patch[2], s.Patch[3])
+	}
+	fmt.Fprintln(bw, "END_DATA")
+	return bw.Flush()
+}
@@ -0,0 +1,175 @@
+// Package chart renders printable CMYK characterisation charts and the
+// matching CGATS reference data.
+package chart
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pageSizes are the named page sizes in millimetres (portrait).
+var pageSizes = map[string][2]float64{
+	"a4":      {210, 297},
+	"a3":      {297, 420},
+	"letter":  {215.9, 279.4},
+	"tabloid": {279.4, 431.8},
+}
+
+// PageSizeNames returns the named page sizes accepted by ParsePageSize.
+func PageSizeNames() []string {
+	var names []string
+	for n := range pageSizes {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParsePageSize accepts a named size (a4, a3, letter, tabloid) or
+// "WIDTHxHEIGHT" in millimetres.
+func ParsePageSize(s string) (width, height float64, err error) {
+	if sz, ok := pageSizes[strings.ToLower(s)]; ok {
+		return sz[0], sz[1], nil
+	}
+	w, h, ok := strings.Cut(strings.ToLower(s), "x")
+	if ok {
+		width, err1 := strconv.ParseFloat(w, 64)
+		height, err2 := strconv.ParseFloat(h, 64)
+		if err1 == nil && err2 == nil && width > 0 && height > 0 {
+			return width, height, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("invalid page size %q (use %s or WIDTHxHEIGHT in mm)", s, strings.Join(PageSizeNames(), ", "))
+}
+
+// Layout describes the chart geometry.
+type Layout struct {
+	PageWidth  float64 // mm
+	PageHeight float64 // mm
+	PatchSize  float64 // mm
+	Margin     float64 // mm
+	DPI        float64
+	Title      string
+}
+
+// Page is one rendered chart page.
+type Page struct {
+	Width  int
+	Height int
+	Pixels []byte // CMYK interleaved, len = Width * Height * 4
+}
+
+// Sample is a patch as placed on the chart.
+type Sample struct {
+	ID    int    // 1-based, in reading order across all pages
+	Name  string // row letter(s) and column number, e.g. "C12"; page-prefixed ("P2C12") on multi-page charts
+	Page  int    // 1-based
+	Patch Patch
+}
+
+// Render lays patches out row by row on as many pages as needed, with row
+// letters, column numbers and a title line in 100% K.
+func Render(patches []Patch, l Layout) ([]Page, []Sample, error) {
+	if len(patches) == 0 {
+		return nil, nil, fmt.Errorf("no patches to render")
+	}
+	if l.DPI <= 0 || l.PatchSize <= 0 {
+		return nil, nil, fmt.Errorf("DPI and patch size must be positive")
+	}
+	px := func(mm float64) int { return int(math.Round(mm / 25.4 * l.DPI)) }
+
+	width, height := px(l.PageWidth), px(l.PageHeight)
+	patch := px(l.PatchSize)
+	margin := px(l.Margin)
+	scale := max(1, int(float64(patch)*0.45/glyphHeight))
+
+	labelW := textWidth("AA", scale) + 4*scale
+	labelH := glyphHeight*scale + 4*scale
+	titleH := glyphHeight*scale + 6*scale
+
+	originX := margin + labelW
+	originY := margin + titleH + labelH
+	cols := (width - margin - originX) / patch
+	rows := (height - margin - originY) / patch
+	if cols < 1 || rows < 1 {
+		return nil, nil, fmt.Errorf("%.1f mm patches do not fit on a %.0fx%.0f mm page", l.PatchSize, l.PageWidth, l.PageHeight)
+	}
+
+	perPage := cols * rows
+	numPages := (len(patches) + perPage - 1) / perPage
+	var pages []Page
+	var samples []Sample
+
+	for p := 0; p < numPages; p++ {
+		pg := Page{Width: width, Height: height, Pixels: make([]byte, width*height*4)}
+		title := fmt.Sprintf("%s PAGE %d/%d", strings.ToUpper(l.Title), p+1, numPages)
+		pg.drawText(strings.TrimSpace(title), margin, margin, scale)
+
+		chunk := patches[p*perPage : min(len(patches), (p+1)*perPage)]
+		usedRows := (len(chunk) + cols - 1) / cols
+		for c := 0; c < min(cols, len(chunk)); c++ {
+			label := strconv.Itoa(c + 1)
+			x := originX + c*patch + (patch-textWidth(label, scale))/2
+			pg.drawText(label, x, originY-labelH+2*scale, scale)
+		}
+		for r := 0; r < usedRows; r++ {
+			label := rowName(r)
+			y := originY + r*patch + (patch-glyphHeight*scale)/2
+			pg.drawText(label, originX-2*scale-textWidth(label, scale), y, scale)
+		}
+
+		for i, v := range chunk {
+			r, c := i/cols, i%cols
+			pg.fill(originX+c*patch, originY+r*patch, patch, patch, v)
+			name := fmt.Sprintf("%s%d", rowName(r), c+1)
+			if numPages > 1 {
+				name = fmt.Sprintf("P%d%s", p+1, name)
+			}
+			samples = append(samples, Sample{ID: len(samples) + 1, Name: name, Page: p + 1, Patch: v})
+		}
+		pages = append(pages, pg)
+	}
+	return pages, samples, nil
+}
+
+// rowName returns spreadsheet-style row letters: A..Z, AA, AB, ...
+func rowName(r int) string {
+	name := ""
+	for r++; r > 0; r = (r - 1) / 26 {
+		name = string(rune('A'+(r-1)%26)) + name
+	}
+	return name
+}
+
+func (pg *Page) fill(x0, y0, w, h int, v Patch) {
+	var px [4]byte
+	for i := range v {
+		px[i] = byte(math.Round(math.Max(0, math.Min(100, v[i])) * 255 / 100))
+	}
+	for y := y0; y < y0+h && y < pg.Height; y++ {
+		for x := x0; x < x0+w && x < pg.Width; x++ {
+			copy(pg.Pixels[(y*pg.Width+x)*4:], px[:])
+		}
+	}
+}
+
+func (pg *Page) drawText(s string, x0, y0, scale int) {
+	black := Patch{0, 0, 0, 100}
+	for i, ch := range s {
+		g, ok := glyphs[ch]
+		if !ok {
+			continue
+		}
+		gx := x0 + i*glyphAdvance*scale
+		for row := 0; row < glyphHeight; row++ {
+			for col := 0; col < glyphWidth; col++ {
+				if g[row][col] == '#' {
+					pg.fill(gx+col*scale, y0+row*scale, scale, scale, black)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package chart
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGeneratePatchesUnique(t *testing.T) {
+	for _, set := range PatchSets {
+		patches, err := GeneratePatches(set)
+		if err != nil {
+			t.Fatalf("%s: %v", set, err)
+		}
+		seen := make(map[Patch]bool)
+		for _, p := range patches {
+			if seen[p] {
+				t.Errorf("%s: duplicate patch %v", set, p)
+			}
+			seen[p] = true
+		}
+		for _, want := range []Patch{{}, {100, 0, 0, 0}, {0, 0, 0, 100}, {100, 100, 100, 100}} {
+			if !seen[want] {
+				t.Errorf("%s: missing patch %v", set, want)
+			}
+		}
+		t.Logf("%s: %d patches", set, len(patches))
+	}
+	if _, err := GeneratePatches("nope"); err == nil {
+		t.Error("expected error for unknown set")
+	}
+}
+
+func TestOrderPatchesRandomIsReproducible(t *testing.T) {
+	patches, _ := GeneratePatches("basic")
+	a, _ := OrderPatches(patches, "random", 7)
+	b, _ := OrderPatches(patches, "random", 7)
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatal("random order differs for the same seed")
+		}
+	}
+}
+
+func TestRenderPlacesPatches(t *testing.T) {
+	patches := []Patch{{100, 0, 0, 0}, {0, 50, 0, 0}, {0, 0, 0, 100}}
+	l := Layout{PageWidth: 60, PageHeight: 40, PatchSize: 10, Margin: 5, DPI: 72, Title: "test"}
+	pages, samples, err := Render(patches, l)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if len(pages) != 1 || len(samples) != 3 {
+		t.Fatalf("got %d pages, %d samples", len(pages), len(samples))
+	}
+	if samples[0].Name != "A1" || samples[2].ID != 3 {
+		t.Errorf("unexpected samples: %+v", samples)
+	}
+
+	// The magenta patch value must appear on the page.
+	found := false
+	pg := pages[0]
+	for i := 0; i < len(pg.Pixels); i += 4 {
+		if pg.Pixels[i] == 0 && pg.Pixels[i+1] == 128 && pg.Pixels[i+3] == 0 {
+			found = true
+			break
+		}
+	}
+	if !found {
+		t.Error("50% magenta patch not found in rendered page")
+	}
+}
+
+func TestRenderSplitsPages(t *testing.T) {
+	patches, _ := GeneratePatches("basic")
+	l := Layout{PageWidth: 100, PageHeight: 100, PatchSize: 10, Margin: 5, DPI: 50}
+	pages, samples, err := Render(patches, l)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if len(pages) < 2 || len(samples) != len(patches) {
+		t.Fatalf("got %d pages, %d samples for %d patches", len(pages), len(samples), len(patches))
+	}
+	if !strings.HasPrefix(samples[len(samples)-1].Name, "P") {
+		t.Errorf("multi-page sample name %q lacks page prefix", samples[len(samples)-1].Name)
+	}
+}
+
+func TestWriteCGATS(t *testing.T) {
+	samples := []Sample{{ID: 1, Name: "A1", Page: 1, Patch: Patch{10, 20, 30, 40}}}
+	var buf bytes.Buffer
+	if err := WriteCGATS(&buf, samples, "test", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("WriteCGATS: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"CGATS.17", "NUMBER_OF_SETS\t1", "1\tA1\t10.00\t20.00\t30.00\t40.00"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestParsePageSize(t *testing.T) {
+	if w, h, err := ParsePageSize("A4"); err != nil || w != 210 || h != 297 {
+		t.Errorf("A4 = %v x %v, %v", w, h, err)
+	}
+	if w, h, err := ParsePageSize("320x450"); err != nil || w != 320 || h != 450 {
+		t.Errorf("320x450 = %v x %v, %v", w, h, err)
+	}
+	if _, _, err := ParsePageSize("huge"); err == nil {
+		t.Error("expected error for invalid page size")
+	}
+}
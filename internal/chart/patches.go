@@ -0,0 +1,101 @@
+package chart
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// Patch is a CMYK patch value, each channel in percent.
+type Patch [4]float64
+
+// PatchSets lists the built-in patch sets.
+var PatchSets = []string{"standard", "basic"}
+
+// GeneratePatches returns the patches of a named set in canonical order.
+//
+// "standard" is an ECI2002/IT8.7/4-style set (about 800 patches): 5% single
+// ink ramps, a dense CMY cube without black, coarser cubes at four black
+// levels, and the solid overprints. "basic" is a quicker set of about 400 patches.
+func GeneratePatches(set string) ([]Patch, error) {
+	var b patchBuilder
+	switch set {
+	case "standard":
+		b.ramps(5)
+		b.cube([]float64{0, 10, 20, 40, 70, 100}, []float64{0})
+		b.cube([]float64{0, 20, 40, 70, 100}, []float64{20, 40, 60, 80})
+		b.cube([]float64{0, 40, 100}, []float64{100})
+	case "basic":
+		b.ramps(10)
+		b.cube([]float64{0, 25, 50, 75, 100}, []float64{0, 50, 100})
+	default:
+		return nil, fmt.Errorf("unknown patch set %q (available: %v)", set, PatchSets)
+	}
+	b.cube([]float64{0, 100}, []float64{0, 100})
+	return b.patches, nil
+}
+
+type patchBuilder struct {
+	patches []Patch
+	seen    map[Patch]bool
+}
+
+func (b *patchBuilder) add(p Patch) {
+	if b.seen == nil {
+		b.seen = make(map[Patch]bool)
+	}
+	if !b.seen[p] {
+		b.seen[p] = true
+		b.patches = append(b.patches, p)
+	}
+}
+
+func (b *patchBuilder) ramps(step float64) {
+	b.add(Patch{})
+	for ch := 0; ch < 4; ch++ {
+		for v := step; v <= 100; v += step {
+			var p Patch
+			p[ch] = v
+			b.add(p)
+		}
+	}
+}
+
+func (b *patchBuilder) cube(cmyLevels, kLevels []float64) {
+	for _, k := range kLevels {
+		for _, c := range cmyLevels {
+			for _, m := range cmyLevels {
+				for _, y := range cmyLevels {
+					b.add(Patch{c, m, y, k})
+				}
+			}
+		}
+	}
+}
+
+// Orders lists the supported patch orders.
+var Orders = []string{"visual", "random"}
+
+// OrderPatches arranges patches for printing. "visual" groups patches by
+// black and then total CMY so that the chart reads as smooth gradients;
+// "random" shuffles them (reproducibly for a given seed) to spread
+// press non-uniformity across the colour space.
+func OrderPatches(patches []Patch, order string, seed int64) ([]Patch, error) {
+	out := append([]Patch(nil), patches...)
+	switch order {
+	case "visual":
+		sort.SliceStable(out, func(i, j int) bool {
+			a, b := out[i], out[j]
+			if a[3] != b[3] {
+				return a[3] < b[3]
+			}
+			return a[0]+a[1]+a[2] < b[0]+b[1]+b[2]
+		})
+	case "random":
+		r := rand.New(rand.NewSource(seed))
+		r.Shuffle(len(out), func(i, j int) { out[i], out[j] = out[j], out[i] })
+	default:
+		return nil, fmt.Errorf("unknown patch order %q (available: %v)", order, Orders)
+	}
+	return out, nil
+}
@@ -0,0 +1,70 @@
+package color
+
+import (
+	_ "embed"
+	"sort"
+)
+
+//go:generate go run ../profile/gen -dir .
+
+// Generic CMYK output profiles generated from the parametric ink model in
+// internal/profile. They make the tool usable without a press profile.
+var (
+	//go:embed generic-coated.icc
+	genericCoated []byte
+	//go:embed generic-uncoated.icc
+	genericUncoated []byte
+	//go:embed generic-newsprint.icc
+	genericNewsprint []byte
+)
+
+// adobeRGB is a matrix/TRC profile with Adobe RGB (1998)-compatible
+// primaries, used as the source for untagged wide-gamut camera images.
+//
+//go:embed adobergb.icc
+var adobeRGB []byte
+
+// linearSRGB has sRGB primaries and a linear curve; it is the default source
+// for float renders.
+//
+//go:embed srgb-linear.icc
+var linearSRGB []byte
+
+// DefaultCMYKProfile names the built-in destination used when none is given.
+const DefaultCMYKProfile = "generic-coated"
+
+func builtinProfiles() map[string][]byte {
+	return map[string][]byte{
+		"srgb":              EmbeddedSRGB,
+		"adobergb":          adobeRGB,
+		"srgb-linear":       linearSRGB,
+		"generic-coated":    genericCoated,
+		"generic-uncoated":  genericUncoated,
+		"generic-newsprint": genericNewsprint,
+	}
+}
+
+// BuiltinProfile returns the embedded profile with the given name.
+func BuiltinProfile(name string) ([]byte, bool) {
+	data, ok := builtinProfiles()[name]
+	return data, ok
+}
+
+// BuiltinProfileNames returns the names accepted by BuiltinProfile, sorted.
+func BuiltinProfileNames() []string {
+	var names []string
+	for n := range builtinProfiles() {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ResolveProfile returns the built-in profile called nameOrPath, or otherwise
+// loads and validates the ICC file at that path.
+func ResolveProfile(nameOrPath string) ([]byte, error) {
+	if data, ok := BuiltinProfile(nameOrPath); ok {
+		return data, nil
+	}
+	return LoadProfile(nameOrPath)
+}
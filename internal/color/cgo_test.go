@@ -1,3 +1,5 @@
+//go:build !nolcms2
+
 package color
 
 import "testing"
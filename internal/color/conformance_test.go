@@ -0,0 +1,195 @@
+//go:build !nolcms2
+
+package color
+
+import (
+	"math"
+	"os"
+	"testing"
+)
+
+// Tolerances for the pure-Go engine against lcms2, in CIE76 ΔE. Both sample
+// the same tables, so differences come from interpolation and lcms2's
+// optimized device links.
+const (
+	conformanceMeanDeltaE = 1.0
+	conformanceMaxDeltaE  = 5.0
+)
+
+// conformancePixels returns a 9×9×9 RGB grid, including the primaries,
+// white and black.
+func conformancePixels() []byte {
+	var px []byte
+	for r := 0; r <= 256; r += 32 {
+		for g := 0; g <= 256; g += 32 {
+			for b := 0; b <= 256; b += 32 {
+				px = append(px, byte(min(r, 255)), byte(min(g, 255)), byte(min(b, 255)))
+			}
+		}
+	}
+	return px
+}
+
+func labDeltaE(t *testing.T, a, b []float64) (mean, max float64) {
+	t.Helper()
+	if len(a) != len(b) {
+		t.Fatalf("Lab lengths differ: %d vs %d", len(a), len(b))
+	}
+	n := len(a) / 3
+	for i := 0; i < n; i++ {
+		dl, da, db := a[3*i]-b[3*i], a[3*i+1]-b[3*i+1], a[3*i+2]-b[3*i+2]
+		de := math.Sqrt(dl*dl + da*da + db*db)
+		mean += de / float64(n)
+		max = math.Max(max, de)
+	}
+	return mean, max
+}
+
+func TestGoEngineMatchesLcms2(t *testing.T) {
+	pixels := conformancePixels()
+	n := len(pixels) / 3
+	for _, srcName := range []string{"srgb", "adobergb", "srgb-linear"} {
+		for _, dstName := range []string{"generic-coated", "generic-uncoated", "generic-newsprint"} {
+			src, _ := BuiltinProfile(srcName)
+			dst, _ := BuiltinProfile(dstName)
+			// CMYK is compared by the colour it prints, so both separations
+			// are measured through the same lcms2 Lab transform.
+			measure, err := NewLabTransform(dst, IntentRelativeColorimetric)
+			if err != nil {
+				t.Fatalf("%s: NewLabTransform: %v", dstName, err)
+			}
+			for intent := IntentPerceptual; intent <= IntentAbsoluteColorimetric; intent++ {
+				ref, err := NewTransform(src, dst, intent)
+				if err != nil {
+					t.Fatalf("%s → %s %s: lcms2: %v", srcName, dstName, IntentName(intent), err)
+				}
+				got, err := newGoTransform(src, dst, intent, false, 8)
+				if err != nil {
+					t.Fatalf("%s → %s %s: go: %v", srcName, dstName, IntentName(intent), err)
+				}
+				if got.Intent() != ref.Intent() {
+					t.Errorf("%s → %s %s: go intent %s, lcms2 %s", srcName, dstName, IntentName(intent),
+						IntentName(got.Intent()), IntentName(ref.Intent()))
+				}
+				refCMYK, err := ref.TransformPixels(pixels, n, 1)
+				if err != nil {
+					t.Fatal(err)
+				}
+				gotCMYK, err := got.TransformPixels(pixels, n, 1)
+				if err != nil {
+					t.Fatal(err)
+				}
+				ref.Close()
+				refLab, _ := measure.Lab(refCMYK)
+				gotLab, _ := measure.Lab(gotCMYK)
+				mean, max := labDeltaE(t, refLab, gotLab)
+				t.Logf("%s → %s %s: mean ΔE %.2f, max %.2f", srcName, dstName, IntentName(intent), mean, max)
+				if mean > conformanceMeanDeltaE || max > conformanceMaxDeltaE {
+					t.Errorf("%s → %s %s: mean ΔE %.2f, max %.2f exceeds %.1f/%.1f", srcName, dstName,
+						IntentName(intent), mean, max, conformanceMeanDeltaE, conformanceMaxDeltaE)
+				}
+			}
+			measure.Close()
+		}
+	}
+}
+
+func TestGoLabTransformMatchesLcms2(t *testing.T) {
+	rgb := conformancePixels()
+	coated, _ := BuiltinProfile(DefaultCMYKProfile)
+	cmyk, err := newGoTransform(EmbeddedSRGB, coated, IntentRelativeColorimetric, false, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmykPixels, _ := cmyk.TransformPixels(rgb, len(rgb)/3, 1)
+
+	cases := []struct {
+		name   string
+		icc    []byte
+		pixels []byte
+	}{
+		{"srgb", EmbeddedSRGB, rgb},
+		{"adobergb", adobeRGB, rgb},
+		{"generic-coated", coated, cmykPixels},
+	}
+	for _, c := range cases {
+		ref, err := NewLabTransform(c.icc, IntentRelativeColorimetric)
+		if err != nil {
+			t.Fatalf("%s: lcms2: %v", c.name, err)
+		}
+		got, err := newGoLabTransform(c.icc, IntentRelativeColorimetric)
+		if err != nil {
+			t.Fatalf("%s: go: %v", c.name, err)
+		}
+		refLab, _ := ref.Lab(c.pixels)
+		gotLab, _ := got.Lab(c.pixels)
+		ref.Close()
+		mean, max := labDeltaE(t, refLab, gotLab)
+		if mean > conformanceMeanDeltaE || max > conformanceMaxDeltaE {
+			t.Errorf("%s: mean ΔE %.2f, max %.2f", c.name, mean, max)
+		}
+	}
+}
+
+// TestGoEngineMatchesLcms2Fixtures checks the mft1, mAB and mBA readers,
+// which the built-in profiles do not use, against lcms2 with the fixtures
+// from internal/icc/testdata.
+func TestGoEngineMatchesLcms2Fixtures(t *testing.T) {
+	rgb := conformancePixels()
+	var cmyk []byte
+	for c := 0; c <= 256; c += 64 {
+		for m := 0; m <= 256; m += 64 {
+			for y := 0; y <= 256; y += 64 {
+				for k := 0; k <= 256; k += 64 {
+					cmyk = append(cmyk, byte(min(c, 255)), byte(min(m, 255)), byte(min(y, 255)), byte(min(k, 255)))
+				}
+			}
+		}
+	}
+	for _, name := range []string{"mft1-cmyk.icc", "mab-cmyk.icc"} {
+		dst, err := os.ReadFile("../icc/testdata/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		measure, err := NewLabTransform(dst, IntentRelativeColorimetric)
+		if err != nil {
+			t.Fatalf("%s: NewLabTransform: %v", name, err)
+		}
+		got, err := newGoLabTransform(dst, IntentRelativeColorimetric)
+		if err != nil {
+			t.Fatalf("%s: go: %v", name, err)
+		}
+		refLab, _ := measure.Lab(cmyk)
+		gotLab, _ := got.Lab(cmyk)
+		mean, max := labDeltaE(t, refLab, gotLab)
+		if mean > conformanceMeanDeltaE || max > conformanceMaxDeltaE {
+			t.Errorf("%s A2B: mean ΔE %.2f, max %.2f", name, mean, max)
+		}
+
+		for intent := IntentPerceptual; intent <= IntentAbsoluteColorimetric; intent++ {
+			ref, err := NewTransform(EmbeddedSRGB, dst, intent)
+			if err != nil {
+				t.Fatalf("%s %s: lcms2: %v", name, IntentName(intent), err)
+			}
+			got, err := newGoTransform(EmbeddedSRGB, dst, intent, false, 8)
+			if err != nil {
+				t.Fatalf("%s %s: go: %v", name, IntentName(intent), err)
+			}
+			if got.Intent() != ref.Intent() {
+				t.Errorf("%s %s: go intent %s, lcms2 %s", name, IntentName(intent),
+					IntentName(got.Intent()), IntentName(ref.Intent()))
+			}
+			refCMYK, _ := ref.TransformPixels(rgb, len(rgb)/3, 1)
+			gotCMYK, _ := got.TransformPixels(rgb, len(rgb)/3, 1)
+			ref.Close()
+			refLab, _ := measure.Lab(refCMYK)
+			gotLab, _ := measure.Lab(gotCMYK)
+			mean, max := labDeltaE(t, refLab, gotLab)
+			t.Logf("%s %s: mean ΔE %.2f, max %.2f", name, IntentName(intent), mean, max)
+			if mean > conformanceMeanDeltaE || max > conformanceMaxDeltaE {
+				t.Errorf("%s %s B2A: mean ΔE %.2f, max %.2f", name, IntentName(intent), mean, max)
+			}
+		}
+		measure.Close()
+	}
+}
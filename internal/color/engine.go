@@ -0,0 +1,142 @@
+package color
+
+import "fmt"
+
+// Intent constants, numbered as in ICC and lcms2.
+const (
+	IntentPerceptual           = 0
+	IntentRelativeColorimetric = 1
+	IntentSaturation           = 2
+	IntentAbsoluteColorimetric = 3
+)
+
+// ParseIntent converts a string intent name to an intent constant.
+func ParseIntent(s string) (int, error) {
+	switch s {
+	case "perceptual":
+		return IntentPerceptual, nil
+	case "relative":
+		return IntentRelativeColorimetric, nil
+	case "saturation":
+		return IntentSaturation, nil
+	case "absolute":
+		return IntentAbsoluteColorimetric, nil
+	default:
+		return 0, fmt.Errorf("unknown rendering intent: %q", s)
+	}
+}
+
+// IntentName returns the command-line name of an intent constant.
+func IntentName(intent int) string {
+	switch intent {
+	case IntentPerceptual:
+		return "perceptual"
+	case IntentRelativeColorimetric:
+		return "relative"
+	case IntentSaturation:
+		return "saturation"
+	case IntentAbsoluteColorimetric:
+		return "absolute"
+	default:
+		return fmt.Sprintf("intent %d", intent)
+	}
+}
+
+// FallbackIntent is used when a profile has no tables for the requested
+// intent. Every valid profile supports relative colorimetric, either through
+// its colorimetric tables or its matrix/TRC, and absolute colorimetric is
+// derived from it.
+const FallbackIntent = IntentRelativeColorimetric
+
+// Transform converts RGB pixels to CMYK. It is implemented with lcms2, or
+// with the pure-Go engine in internal/icc when built with the nolcms2 tag;
+// Engine names the one in use.
+//
+// NewTransform and NewFloatTransform take raw ICC profile data. The
+// destination may be a CMYK output profile or an RGB→CMYK DeviceLink, in
+// which case the source profile is not used. Both profiles are checked before
+// the engine sees them. If either profile lacks tables for the requested
+// intent, FallbackIntent is used instead.
+type Transform interface {
+	// TransformPixels converts 8-bit RGB (width*height*3 bytes) to 8-bit
+	// CMYK (width*height*4 bytes). The transform must come from
+	// NewTransform.
+	TransformPixels(src []byte, width, height int) ([]byte, error)
+	// TransformFloat converts float RGB pixels (3 per pixel) to 8-bit CMYK.
+	// The transform must come from NewFloatTransform with 8 bits.
+	TransformFloat(src []float32, width, height int) ([]byte, error)
+	// TransformFloat16 converts float RGB pixels (3 per pixel) to 16-bit
+	// CMYK. The transform must come from NewFloatTransform with 16 bits.
+	TransformFloat16(src []float32, width, height int) ([]uint16, error)
+	// Intent returns the rendering intent in effect, which differs from the
+	// requested one when FallbackIntent was substituted.
+	Intent() int
+	// Close releases the engine's resources.
+	Close()
+}
+
+// LabTransform converts 8-bit RGB or CMYK device values to D50 CIELAB
+// through a device profile. It is used to measure how closely a separation
+// reproduces its source.
+type LabTransform interface {
+	// Lab converts device pixels to L*, a*, b* triplets (3 float64 per
+	// pixel).
+	Lab(pixels []byte) ([]float64, error)
+	// Close releases the engine's resources.
+	Close()
+}
+
+// checkTransformProfiles validates the intent and the profiles of an
+// RGB→CMYK transform and reports whether dstICC is a DeviceLink.
+func checkTransformProfiles(srcICC, dstICC []byte, intent int) (link bool, err error) {
+	if intent < IntentPerceptual || intent > IntentAbsoluteColorimetric {
+		return false, fmt.Errorf("unsupported rendering intent %d", intent)
+	}
+	dstInfo, err := CheckDestinationProfile(dstICC)
+	if err != nil {
+		return false, err
+	}
+	if dstInfo.Class == "link" {
+		return true, nil
+	}
+	return false, CheckSourceProfile(srcICC, "RGB ")
+}
+
+// checkLabProfile validates a profile for NewLabTransform and returns its
+// channel count.
+func checkLabProfile(icc []byte) (int, error) {
+	pi, err := ValidateProfile(icc)
+	if err != nil {
+		return 0, err
+	}
+	if pi.Class == "link" || pi.Class == "abst" {
+		return 0, fmt.Errorf("Lab transform: %s profiles are not supported", ProfileClassName(pi.Class))
+	}
+	switch pi.ColorSpace {
+	case "RGB ":
+		return 3, nil
+	case "CMYK":
+		return 4, nil
+	}
+	return 0, fmt.Errorf("Lab transform: unsupported colour space %s", ColorSpaceName(pi.ColorSpace))
+}
+
+// BlackPointCompensation reports whether a transform between the profiles
+// with the given intent (after any fallback) applies black point
+// compensation. It is never requested, but both engines apply it, as
+// lcms2 does, for the perceptual and saturation intents when either
+// profile is version 4. A DeviceLink's tables are used as they are.
+func BlackPointCompensation(srcICC, dstICC []byte, intent int) bool {
+	if intent != IntentPerceptual && intent != IntentSaturation {
+		return false
+	}
+	dst, err := ParseProfileInfo(dstICC)
+	if err != nil || dst.Class == "link" {
+		return false
+	}
+	if dstICC[8] >= 4 {
+		return true
+	}
+	_, err = ParseProfileInfo(srcICC)
+	return err == nil && srcICC[8] >= 4
+}
@@ -0,0 +1,41 @@
+//go:build nolcms2
+
+package color
+
+import "fmt"
+
+// Engine names the colour engine the package was built with.
+const Engine = "go"
+
+// NewTransform creates an 8-bit RGB→CMYK color transform from raw ICC
+// profile data; see Transform.
+func NewTransform(srcICC, dstICC []byte, intent int) (Transform, error) {
+	t, err := newGoTransform(srcICC, dstICC, intent, false, 8)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// NewFloatTransform creates a transform from linear or scene-referred float
+// RGB (nominal range 0–1) to 8- or 16-bit CMYK, for use with TransformFloat
+// or TransformFloat16. Profiles are checked as in NewTransform.
+func NewFloatTransform(srcICC, dstICC []byte, intent, bits int) (Transform, error) {
+	if bits != 8 && bits != 16 {
+		return nil, fmt.Errorf("unsupported CMYK output depth %d (want 8 or 16)", bits)
+	}
+	t, err := newGoTransform(srcICC, dstICC, intent, true, bits)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// NewLabTransform creates a device→Lab transform for an RGB or CMYK profile.
+func NewLabTransform(icc []byte, intent int) (LabTransform, error) {
+	t, err := newGoLabTransform(icc, intent)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
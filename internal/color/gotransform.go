@@ -0,0 +1,202 @@
+package color
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/davesmith10/RGBtoCMYK/internal/icc"
+)
+
+// goTransform is the pure-Go implementation of Transform. 8-bit transforms
+// are sampled into a 33³ grid, as lcms2 does when it optimizes; float
+// transforms evaluate the profiles for every pixel.
+type goTransform struct {
+	xf    *icc.Transform
+	float bool
+	bits  int
+}
+
+// newGoTransform builds an RGB→CMYK transform with internal/icc. bits is
+// the CMYK depth; float selects float RGB input.
+func newGoTransform(srcICC, dstICC []byte, intent int, float bool, bits int) (*goTransform, error) {
+	link, err := checkTransformProfiles(srcICC, dstICC, intent)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := icc.Parse(dstICC)
+	if err != nil {
+		return nil, fmt.Errorf("destination profile: %w", err)
+	}
+	var src *icc.Profile
+	if !link {
+		if src, err = icc.Parse(srcICC); err != nil {
+			return nil, fmt.Errorf("source profile: %w", err)
+		}
+	}
+	xf, err := icc.NewTransform(src, dst, intent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s transform: %w", IntentName(intent), err)
+	}
+	if xf.In != 3 || xf.Out != 4 {
+		return nil, fmt.Errorf("transform maps %d to %d channels, want RGB to CMYK", xf.In, xf.Out)
+	}
+	if !float {
+		if err := xf.Optimize(33); err != nil {
+			return nil, err
+		}
+	}
+	return &goTransform{xf: xf, float: float, bits: bits}, nil
+}
+
+// Intent implements Transform.
+func (t *goTransform) Intent() int {
+	return t.xf.Intent
+}
+
+// TransformPixels implements Transform.
+func (t *goTransform) TransformPixels(src []byte, width, height int) ([]byte, error) {
+	if t.float || t.bits != 8 {
+		return nil, fmt.Errorf("TransformPixels needs an 8-bit RGB → CMYK transform")
+	}
+	if expected := width * height * 3; len(src) != expected {
+		return nil, fmt.Errorf("expected %d RGB bytes, got %d", expected, len(src))
+	}
+	dst := make([]byte, width*height*4)
+	parallelRows(height, func(y int) {
+		in, out := make([]float64, 3), make([]float64, 4)
+		for x := 0; x < width; x++ {
+			i := y*width + x
+			// Runs of one colour are common in graphics; reuse the result.
+			if x > 0 && src[3*i] == src[3*i-3] && src[3*i+1] == src[3*i-2] && src[3*i+2] == src[3*i-1] {
+				copy(dst[4*i:4*i+4], dst[4*i-4:4*i])
+				continue
+			}
+			for c := range in {
+				in[c] = float64(src[3*i+c]) / 255
+			}
+			t.xf.EvalTo(in, out)
+			for c, v := range out {
+				dst[4*i+c] = uint8(v*255 + 0.5)
+			}
+		}
+	})
+	return dst, nil
+}
+
+// TransformFloat implements Transform.
+func (t *goTransform) TransformFloat(src []float32, width, height int) ([]byte, error) {
+	if !t.float || t.bits != 8 {
+		return nil, fmt.Errorf("TransformFloat needs an 8-bit float transform")
+	}
+	dst := make([]byte, width*height*4)
+	err := t.transformFloat(src, width, height, func(i int, v float64) {
+		dst[i] = uint8(v*255 + 0.5)
+	})
+	return dst, err
+}
+
+// TransformFloat16 implements Transform.
+func (t *goTransform) TransformFloat16(src []float32, width, height int) ([]uint16, error) {
+	if !t.float || t.bits != 16 {
+		return nil, fmt.Errorf("TransformFloat16 needs a 16-bit float transform")
+	}
+	dst := make([]uint16, width*height*4)
+	err := t.transformFloat(src, width, height, func(i int, v float64) {
+		dst[i] = uint16(v*65535 + 0.5)
+	})
+	return dst, err
+}
+
+func (t *goTransform) transformFloat(src []float32, width, height int, store func(i int, v float64)) error {
+	if len(src) != width*height*3 {
+		return fmt.Errorf("expected %d float RGB values, got %d", width*height*3, len(src))
+	}
+	parallelRows(height, func(y int) {
+		in, out := make([]float64, 3), make([]float64, 4)
+		for x := 0; x < width; x++ {
+			i := y*width + x
+			for c := range in {
+				in[c] = float64(src[3*i+c])
+			}
+			t.xf.EvalTo(in, out)
+			for c, v := range out {
+				store(4*i+c, v)
+			}
+		}
+	})
+	return nil
+}
+
+// Close implements Transform; the Go engine holds no resources.
+func (t *goTransform) Close() {}
+
+// goLabTransform is the pure-Go implementation of LabTransform.
+type goLabTransform struct {
+	xf       *icc.Transform
+	channels int
+}
+
+func newGoLabTransform(data []byte, intent int) (*goLabTransform, error) {
+	channels, err := checkLabProfile(data)
+	if err != nil {
+		return nil, err
+	}
+	p, err := icc.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	xf, err := icc.NewLabTransform(p, intent)
+	if err != nil {
+		return nil, fmt.Errorf("Lab transform: %w", err)
+	}
+	points := 33
+	if channels == 4 {
+		points = 17
+	}
+	if err := xf.Optimize(points); err != nil {
+		return nil, err
+	}
+	return &goLabTransform{xf: xf, channels: channels}, nil
+}
+
+// Lab implements LabTransform.
+func (t *goLabTransform) Lab(pixels []byte) ([]float64, error) {
+	if len(pixels)%t.channels != 0 {
+		return nil, fmt.Errorf("pixel data length %d is not a multiple of %d", len(pixels), t.channels)
+	}
+	n := len(pixels) / t.channels
+	out := make([]float64, n*3)
+	in, enc := make([]float64, t.channels), make([]float64, 3)
+	for i := 0; i < n; i++ {
+		for c := range in {
+			in[c] = float64(pixels[i*t.channels+c]) / 255
+		}
+		t.xf.EvalTo(in, enc)
+		lab := icc.DecodeLab(enc)
+		out[3*i], out[3*i+1], out[3*i+2] = lab.L, lab.A, lab.B
+	}
+	return out, nil
+}
+
+// Close implements LabTransform.
+func (t *goLabTransform) Close() {}
+
+// parallelRows calls fn for every row, spreading rows over the CPUs.
+func parallelRows(height int, fn func(y int)) {
+	workers := runtime.GOMAXPROCS(0)
+	if workers > height {
+		workers = height
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for y := w; y < height; y += workers {
+				fn(y)
+			}
+		}(w)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,70 @@
+//go:build !nolcms2
+
+package color
+
+/*
+#cgo pkg-config: lcms2
+#include <lcms2.h>
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+)
+
+// IT8Table is the data section of a CGATS.17 / IT8 measurement file.
+type IT8Table struct {
+	SheetType string
+	Fields    []string   // DATA_FORMAT column names
+	Rows      [][]string // one entry per data set, in Fields order
+}
+
+// ParseIT8 parses CGATS/IT8 text using lcms2's IT8 parser. Only the first
+// table of multi-table files is returned.
+func ParseIT8(data []byte) (*IT8Table, error) {
+	if len(data) == 0 {
+		return nil, errors.New("empty CGATS data")
+	}
+
+	h := C.cmsIT8LoadFromMem(nil, unsafe.Pointer(&data[0]), C.cmsUInt32Number(len(data)))
+	if h == nil {
+		return nil, fmt.Errorf("lcms2: failed to parse CGATS data")
+	}
+	defer C.cmsIT8Free(h)
+
+	if C.cmsIT8SetTable(h, 0) < 0 {
+		return nil, fmt.Errorf("lcms2: CGATS data has no table")
+	}
+
+	t := &IT8Table{}
+	if st := C.cmsIT8GetSheetType(h); st != nil {
+		t.SheetType = C.GoString(st)
+	}
+
+	var names **C.char
+	n := int(C.cmsIT8EnumDataFormat(h, &names))
+	if n <= 0 {
+		return nil, fmt.Errorf("CGATS data has no DATA_FORMAT")
+	}
+	for _, name := range unsafe.Slice(names, n) {
+		t.Fields = append(t.Fields, C.GoString(name))
+	}
+
+	prop := C.CString("NUMBER_OF_SETS")
+	defer C.free(unsafe.Pointer(prop))
+	sets := int(C.cmsIT8GetPropertyDbl(h, prop))
+
+	for r := 0; r < sets; r++ {
+		row := make([]string, n)
+		for c := 0; c < n; c++ {
+			if v := C.cmsIT8GetDataRowCol(h, C.int(r), C.int(c)); v != nil {
+				row[c] = C.GoString(v)
+			}
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	return t, nil
+}
@@ -0,0 +1,112 @@
+//go:build nolcms2
+
+package color
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// IT8Table is the data section of a CGATS.17 / IT8 measurement file.
+type IT8Table struct {
+	SheetType string
+	Fields    []string   // DATA_FORMAT column names
+	Rows      [][]string // one entry per data set, in Fields order
+}
+
+// ParseIT8 parses CGATS/IT8 text. Only the first table of multi-table files
+// is returned.
+func ParseIT8(data []byte) (*IT8Table, error) {
+	if len(data) == 0 {
+		return nil, errors.New("empty CGATS data")
+	}
+
+	t := &IT8Table{}
+	var section string // "", "format" or "data"
+	var values []string
+	first := true
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		toks, err := it8Tokens(sc.Text())
+		if err != nil {
+			return nil, err
+		}
+		if len(toks) == 0 {
+			continue
+		}
+		if first {
+			t.SheetType = toks[0]
+			first = false
+			continue
+		}
+		switch section {
+		case "format":
+			if toks[0] == "END_DATA_FORMAT" {
+				section = ""
+				continue
+			}
+			t.Fields = append(t.Fields, toks...)
+		case "data":
+			if toks[0] == "END_DATA" {
+				return t, t.setRows(values)
+			}
+			values = append(values, toks...)
+		default:
+			switch toks[0] {
+			case "BEGIN_DATA_FORMAT":
+				section = "format"
+			case "BEGIN_DATA":
+				if len(t.Fields) == 0 {
+					return nil, fmt.Errorf("CGATS data has no DATA_FORMAT")
+				}
+				section = "data"
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("failed to parse CGATS data: no BEGIN_DATA … END_DATA table")
+}
+
+func (t *IT8Table) setRows(values []string) error {
+	n := len(t.Fields)
+	if len(values)%n != 0 {
+		return fmt.Errorf("CGATS data has %d values, not a multiple of %d fields", len(values), n)
+	}
+	for i := 0; i < len(values); i += n {
+		t.Rows = append(t.Rows, values[i:i+n:i+n])
+	}
+	return nil
+}
+
+// it8Tokens splits a CGATS line into whitespace-separated tokens, honouring
+// double quotes and dropping # comments.
+func it8Tokens(line string) ([]string, error) {
+	var toks []string
+	for {
+		line = strings.TrimLeft(line, " \t\r")
+		switch {
+		case line == "" || line[0] == '#':
+			return toks, nil
+		case line[0] == '"':
+			end := strings.IndexByte(line[1:], '"')
+			if end < 0 {
+				return nil, errors.New("CGATS data has an unterminated string")
+			}
+			toks = append(toks, line[1:1+end])
+			line = line[2+end:]
+		default:
+			end := strings.IndexAny(line, " \t\r")
+			if end < 0 {
+				end = len(line)
+			}
+			toks = append(toks, line[:end])
+			line = line[end:]
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package color
+
+import "testing"
+
+const testCGATS = `CGATS.17
+ORIGINATOR "rgbtocmyk test"
+NUMBER_OF_FIELDS 8
+BEGIN_DATA_FORMAT
+SAMPLE_ID CMYK_C CMYK_M CMYK_Y CMYK_K LAB_L LAB_A LAB_B
+END_DATA_FORMAT
+NUMBER_OF_SETS 2
+BEGIN_DATA
+1 0 0 0 0 95.0 0.0 -2.0
+2 100 0 0 0 55.0 -37.0 -50.0
+END_DATA
+`
+
+func TestParseIT8(t *testing.T) {
+	table, err := ParseIT8([]byte(testCGATS))
+	if err != nil {
+		t.Fatalf("ParseIT8: %v", err)
+	}
+	if len(table.Fields) != 8 || table.Fields[1] != "CMYK_C" || table.Fields[7] != "LAB_B" {
+		t.Errorf("unexpected fields: %v", table.Fields)
+	}
+	if len(table.Rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(table.Rows))
+	}
+	if table.Rows[1][1] != "100" && table.Rows[1][1] != "100.00" {
+		t.Errorf("row 2 CMYK_C = %q", table.Rows[1][1])
+	}
+}
+
+func TestParseIT8Invalid(t *testing.T) {
+	if _, err := ParseIT8([]byte("not cgats at all")); err == nil {
+		t.Error("expected error for invalid CGATS data")
+	}
+}
@@ -0,0 +1,119 @@
+//go:build !nolcms2
+
+package color
+
+/*
+#include <lcms2.h>
+#include <stdint.h>
+
+extern cmsContext new_logging_context(uintptr_t handle);
+*/
+import "C"
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/cgo"
+	"unsafe"
+)
+
+// lcmsLabTransform is the lcms2 implementation of LabTransform.
+type lcmsLabTransform struct {
+	ctx        C.cmsContext
+	log        cgo.Handle
+	hProfile   C.cmsHPROFILE
+	hLab       C.cmsHPROFILE
+	hTransform C.cmsHTRANSFORM
+	channels   int
+}
+
+// NewLabTransform creates a device→Lab transform for an RGB or CMYK profile.
+func NewLabTransform(icc []byte, intent int) (LabTransform, error) {
+	t, err := newLcmsLabTransform(icc, intent)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func newLcmsLabTransform(icc []byte, intent int) (*lcmsLabTransform, error) {
+	channels, err := checkLabProfile(icc)
+	if err != nil {
+		return nil, err
+	}
+	format := C.cmsUInt32Number(C.TYPE_RGB_8)
+	if channels == 4 {
+		format = C.TYPE_CMYK_8
+	}
+
+	log := &errorLog{}
+	t := &lcmsLabTransform{log: cgo.NewHandle(log), channels: channels}
+	runtime.SetFinalizer(t, (*lcmsLabTransform).Close)
+
+	t.ctx = C.new_logging_context(C.uintptr_t(t.log))
+	if t.ctx == nil {
+		t.Close()
+		return nil, fmt.Errorf("lcms2: failed to create context")
+	}
+	t.hProfile = C.cmsOpenProfileFromMemTHR(t.ctx, unsafe.Pointer(&icc[0]), C.cmsUInt32Number(len(icc)))
+	if t.hProfile == nil {
+		t.Close()
+		return nil, log.errorf("lcms2: failed to open profile")
+	}
+	t.hLab = C.cmsCreateLab4ProfileTHR(t.ctx, nil)
+	if t.hLab == nil {
+		t.Close()
+		return nil, log.errorf("lcms2: failed to create Lab profile")
+	}
+	if C.cmsIsIntentSupported(t.hProfile, C.cmsUInt32Number(intent), C.LCMS_USED_AS_INPUT) == 0 {
+		intent = FallbackIntent
+	}
+	t.hTransform = C.cmsCreateTransformTHR(t.ctx,
+		t.hProfile, format,
+		t.hLab, C.TYPE_Lab_DBL,
+		C.cmsUInt32Number(intent),
+		C.cmsFLAGS_NOCACHE,
+	)
+	if t.hTransform == nil {
+		t.Close()
+		return nil, log.errorf("lcms2: failed to create Lab transform")
+	}
+	return t, nil
+}
+
+// Lab implements LabTransform.
+func (t *lcmsLabTransform) Lab(pixels []byte) ([]float64, error) {
+	if len(pixels)%t.channels != 0 {
+		return nil, fmt.Errorf("pixel data length %d is not a multiple of %d", len(pixels), t.channels)
+	}
+	n := len(pixels) / t.channels
+	out := make([]float64, n*3)
+	if n > 0 {
+		C.cmsDoTransform(t.hTransform, unsafe.Pointer(&pixels[0]), unsafe.Pointer(&out[0]), C.cmsUInt32Number(n))
+	}
+	return out, nil
+}
+
+// Close releases lcms2 resources.
+func (t *lcmsLabTransform) Close() {
+	if t.hTransform != nil {
+		C.cmsDeleteTransform(t.hTransform)
+		t.hTransform = nil
+	}
+	if t.hLab != nil {
+		C.cmsCloseProfile(t.hLab)
+		t.hLab = nil
+	}
+	if t.hProfile != nil {
+		C.cmsCloseProfile(t.hProfile)
+		t.hProfile = nil
+	}
+	if t.ctx != nil {
+		C.cmsDeleteContext(t.ctx)
+		t.ctx = nil
+	}
+	if t.log != 0 {
+		t.log.Delete()
+		t.log = 0
+	}
+}
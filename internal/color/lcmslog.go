@@ -0,0 +1,53 @@
+//go:build !nolcms2
+
+package color
+
+/*
+#include <lcms2.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"runtime/cgo"
+	"strings"
+	"sync"
+)
+
+// errorLog collects the diagnostics lcms2 reports through the error log
+// handler of one context, so failures can carry the library's own message.
+type errorLog struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+//export goLcmsLogError
+func goLcmsLogError(ctx C.cmsContext, code C.cmsUInt32Number, text *C.char) {
+	p := C.cmsGetContextUserData(ctx)
+	if p == nil {
+		return
+	}
+	l, ok := cgo.Handle(uintptr(p)).Value().(*errorLog)
+	if !ok {
+		return
+	}
+	l.mu.Lock()
+	l.msgs = append(l.msgs, C.GoString(text))
+	l.mu.Unlock()
+}
+
+// errorf returns an error built from format and args, followed by any
+// diagnostics lcms2 has logged since the last call.
+func (l *errorLog) errorf(format string, args ...any) error {
+	l.mu.Lock()
+	msgs := l.msgs
+	l.msgs = nil
+	l.mu.Unlock()
+
+	msg := fmt.Sprintf(format, args...)
+	if len(msgs) == 0 {
+		return errors.New(msg)
+	}
+	return fmt.Errorf("%s: %s", msg, strings.Join(msgs, "; "))
+}
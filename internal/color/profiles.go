@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strings"
+	"unicode/utf16"
 )
 
 //go:embed srgb_v4.icc
@@ -18,11 +20,12 @@ const (
 
 // ProfileInfo contains metadata parsed from an ICC profile header.
 type ProfileInfo struct {
-	Size       uint32
-	Version    string
-	ColorSpace string // "RGB ", "CMYK", etc.
-	PCS        string // "XYZ ", "Lab "
-	Class      string // "mntr", "prtr", "scnr", etc.
+	Size        uint32
+	Version     string
+	ColorSpace  string // "RGB ", "CMYK", etc.
+	PCS         string // "XYZ ", "Lab "
+	Class       string // "mntr", "prtr", "scnr", etc.
+	Description string // 'desc' tag text, "" if missing or unreadable
 }
 
 // ParseProfileInfo reads ICC header metadata from raw profile bytes.
@@ -43,22 +46,118 @@ func ParseProfileInfo(data []byte) (*ProfileInfo, error) {
 	bugfix := data[9] & 0x0f
 
 	info := &ProfileInfo{
-		Size:       size,
-		Version:    fmt.Sprintf("%d.%d.%d", major, minor, bugfix),
-		ColorSpace: string(data[16:20]),
-		PCS:        string(data[20:24]),
-		Class:      string(data[12:16]),
+		Size:        size,
+		Version:     fmt.Sprintf("%d.%d.%d", major, minor, bugfix),
+		ColorSpace:  string(data[16:20]),
+		PCS:         string(data[20:24]),
+		Class:       string(data[12:16]),
+		Description: profileDescription(data),
 	}
 	return info, nil
 }
 
+// profileDescription reads the 'desc' tag: a v2 textDescriptionType's ASCII
+// part or the first record of a v4 multiLocalizedUnicodeType.
+func profileDescription(data []byte) string {
+	be := binary.BigEndian
+	if len(data) < 132 {
+		return ""
+	}
+	n := int(be.Uint32(data[128:]))
+	for i := 0; i < n && 132+12*i+12 <= len(data); i++ {
+		e := data[132+12*i:]
+		if string(e[:4]) != "desc" {
+			continue
+		}
+		off, size := int(be.Uint32(e[4:])), int(be.Uint32(e[8:]))
+		if off < 0 || size < 12 || off+size > len(data) || off+size < off {
+			return ""
+		}
+		tag := data[off : off+size]
+		switch string(tag[:4]) {
+		case "desc":
+			count := int(be.Uint32(tag[8:]))
+			if count > len(tag)-12 {
+				return ""
+			}
+			return strings.TrimRight(string(tag[12:12+count]), "\x00")
+		case "mluc":
+			if len(tag) < 28 || be.Uint32(tag[8:]) == 0 {
+				return ""
+			}
+			length, start := int(be.Uint32(tag[20:])), int(be.Uint32(tag[24:]))
+			if start+length > len(tag) || start+length < start {
+				return ""
+			}
+			u := make([]uint16, length/2)
+			for j := range u {
+				u[j] = be.Uint16(tag[start+2*j:])
+			}
+			return strings.TrimRight(string(utf16.Decode(u)), "\x00")
+		}
+		return ""
+	}
+	return ""
+}
+
+// ValidateProfile parses the header of data and checks that the size it
+// declares matches the data length.
+func ValidateProfile(data []byte) (*ProfileInfo, error) {
+	pi, err := ParseProfileInfo(data)
+	if err != nil {
+		return nil, err
+	}
+	if int(pi.Size) != len(data) {
+		return nil, fmt.Errorf("ICC header declares %d bytes but profile has %d", pi.Size, len(data))
+	}
+	return pi, nil
+}
+
+// CheckSourceProfile verifies that data can serve as the source profile for
+// pixels in colorSpace (an ICC signature such as "RGB ").
+func CheckSourceProfile(data []byte, colorSpace string) error {
+	pi, err := ValidateProfile(data)
+	if err != nil {
+		return fmt.Errorf("source profile: %w", err)
+	}
+	switch pi.Class {
+	case "link", "abst", "nmcl":
+		return fmt.Errorf("source profile is a %s profile; expected a device profile", ProfileClassName(pi.Class))
+	}
+	if pi.ColorSpace != colorSpace {
+		return fmt.Errorf("source profile is %s %s but the image is %s",
+			ColorSpaceName(pi.ColorSpace), ProfileClassName(pi.Class), ColorSpaceName(colorSpace))
+	}
+	return nil
+}
+
+// CheckDestinationProfile verifies that data is a CMYK output profile or an
+// RGB→CMYK DeviceLink.
+func CheckDestinationProfile(data []byte) (*ProfileInfo, error) {
+	pi, err := ValidateProfile(data)
+	if err != nil {
+		return nil, fmt.Errorf("destination profile: %w", err)
+	}
+	switch {
+	case pi.Class == "prtr" && pi.ColorSpace == "CMYK":
+		return pi, nil
+	case pi.Class == "link" && pi.ColorSpace == "RGB " && pi.PCS == "CMYK":
+		return pi, nil
+	case pi.Class == "link":
+		return nil, fmt.Errorf("destination DeviceLink converts %s to %s; expected RGB to CMYK",
+			ColorSpaceName(pi.ColorSpace), ColorSpaceName(pi.PCS))
+	}
+	return nil, fmt.Errorf("destination profile is %s %s; expected a CMYK Output or DeviceLink profile",
+		ColorSpaceName(pi.ColorSpace), ProfileClassName(pi.Class))
+}
+
 // LoadProfile reads an ICC profile from disk and validates it.
 func LoadProfile(path string) ([]byte, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("reading ICC profile: %w", err)
 	}
-	if _, err := ParseProfileInfo(data); err != nil {
+	if _, err := ValidateProfile(data); err != nil {
 		return nil, fmt.Errorf("validating ICC profile %s: %w", path, err)
 	}
 	return data, nil
@@ -1,15 +1,35 @@
+//go:build !nolcms2
+
 package color
 
 /*
 #cgo pkg-config: lcms2
 #include <lcms2.h>
+#include <stdint.h>
 #include <stdlib.h>
+
+extern void goLcmsLogError(cmsContext, cmsUInt32Number, char *);
+
+static void log_trampoline(cmsContext ctx, cmsUInt32Number code, const char *text) {
+    goLcmsLogError(ctx, code, (char *)text);
+}
+
+// new_logging_context creates a context whose errors go to the Go errorLog
+// identified by handle. Not static: lab.go uses it too.
+cmsContext new_logging_context(uintptr_t handle) {
+    cmsContext ctx = cmsCreateContext(NULL, (void *)handle);
+    if (ctx != NULL) {
+        cmsSetLogErrorHandlerTHR(ctx, log_trampoline);
+    }
+    return ctx;
+}
 */
 import "C"
 
 import (
 	"fmt"
 	"runtime"
+	"runtime/cgo"
 	"unsafe"
 )
 
@@ -18,74 +38,117 @@ func Lcms2Version() int {
 	return int(C.cmsGetEncodedCMMversion())
 }
 
-// Intent constants matching lcms2.
-const (
-	IntentPerceptual           = 0
-	IntentRelativeColorimetric = 1
-	IntentSaturation           = 2
-	IntentAbsoluteColorimetric = 3
-)
+// lcmsTransform performs ICC color transformations using lcms2.
+type lcmsTransform struct {
+	ctx        C.cmsContext
+	log        cgo.Handle
+	hSrc       C.cmsHPROFILE
+	hDst       C.cmsHPROFILE
+	hTransform C.cmsHTRANSFORM
+	intent     int
+	inFormat   C.cmsUInt32Number
+	outFormat  C.cmsUInt32Number
+}
 
-// ParseIntent converts a string intent name to an lcms2 intent constant.
-func ParseIntent(s string) (int, error) {
-	switch s {
-	case "perceptual":
-		return IntentPerceptual, nil
-	case "relative":
-		return IntentRelativeColorimetric, nil
-	case "saturation":
-		return IntentSaturation, nil
-	case "absolute":
-		return IntentAbsoluteColorimetric, nil
-	default:
-		return 0, fmt.Errorf("unknown rendering intent: %q", s)
+// Engine names the colour engine the package was built with.
+const Engine = "lcms2"
+
+// NewTransform creates an 8-bit RGB→CMYK color transform from raw ICC
+// profile data; see Transform.
+func NewTransform(srcICC, dstICC []byte, intent int) (Transform, error) {
+	t, err := newLcmsTransform(srcICC, dstICC, intent, C.TYPE_RGB_8, C.TYPE_CMYK_8)
+	if err != nil {
+		return nil, err
 	}
+	return t, nil
 }
 
-// Transform performs ICC color transformations using lcms2.
-type Transform struct {
-	hSrc       C.cmsHPROFILE
-	hDst       C.cmsHPROFILE
-	hTransform C.cmsHTRANSFORM
+// NewFloatTransform creates a transform from linear or scene-referred float
+// RGB (TYPE_RGB_FLT, nominal range 0–1) to 8- or 16-bit CMYK, for use with
+// TransformFloat or TransformFloat16. Profiles are checked as in NewTransform.
+func NewFloatTransform(srcICC, dstICC []byte, intent, bits int) (Transform, error) {
+	var outFormat C.cmsUInt32Number
+	switch bits {
+	case 8:
+		outFormat = C.TYPE_CMYK_8
+	case 16:
+		outFormat = C.TYPE_CMYK_16
+	default:
+		return nil, fmt.Errorf("unsupported CMYK output depth %d (want 8 or 16)", bits)
+	}
+	t, err := newLcmsTransform(srcICC, dstICC, intent, C.TYPE_RGB_FLT, outFormat)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
 }
 
-// NewTransform creates an RGB→CMYK color transform from raw ICC profile data.
-func NewTransform(srcICC, dstICC []byte, intent int) (*Transform, error) {
-	hSrc := C.cmsOpenProfileFromMem(unsafe.Pointer(&srcICC[0]), C.cmsUInt32Number(len(srcICC)))
-	if hSrc == nil {
-		return nil, fmt.Errorf("lcms2: failed to open source profile")
+func newLcmsTransform(srcICC, dstICC []byte, intent int, inFormat, outFormat C.cmsUInt32Number) (*lcmsTransform, error) {
+	link, err := checkTransformProfiles(srcICC, dstICC, intent)
+	if err != nil {
+		return nil, err
 	}
 
-	hDst := C.cmsOpenProfileFromMem(unsafe.Pointer(&dstICC[0]), C.cmsUInt32Number(len(dstICC)))
-	if hDst == nil {
-		C.cmsCloseProfile(hSrc)
-		return nil, fmt.Errorf("lcms2: failed to open destination profile")
+	log := &errorLog{}
+	t := &lcmsTransform{log: cgo.NewHandle(log), intent: intent, inFormat: inFormat, outFormat: outFormat}
+	runtime.SetFinalizer(t, (*lcmsTransform).Close)
+
+	t.ctx = C.new_logging_context(C.uintptr_t(t.log))
+	if t.ctx == nil {
+		t.Close()
+		return nil, fmt.Errorf("lcms2: failed to create context")
 	}
 
-	hTransform := C.cmsCreateTransform(
-		hSrc, C.TYPE_RGB_8,
-		hDst, C.TYPE_CMYK_8,
-		C.cmsUInt32Number(intent),
-		C.cmsFLAGS_NOCACHE,
-	)
-	if hTransform == nil {
-		C.cmsCloseProfile(hDst)
-		C.cmsCloseProfile(hSrc)
-		return nil, fmt.Errorf("lcms2: failed to create transform")
+	t.hDst = C.cmsOpenProfileFromMemTHR(t.ctx, unsafe.Pointer(&dstICC[0]), C.cmsUInt32Number(len(dstICC)))
+	if t.hDst == nil {
+		t.Close()
+		return nil, log.errorf("lcms2: failed to open destination profile")
 	}
 
-	t := &Transform{
-		hSrc:       hSrc,
-		hDst:       hDst,
-		hTransform: hTransform,
+	if link {
+		if C.cmsIsIntentSupported(t.hDst, C.cmsUInt32Number(intent), C.LCMS_USED_AS_INPUT) == 0 {
+			t.intent = FallbackIntent
+		}
+		t.hTransform = C.cmsCreateTransformTHR(t.ctx,
+			t.hDst, inFormat,
+			nil, outFormat,
+			C.cmsUInt32Number(t.intent),
+			C.cmsFLAGS_NOCACHE,
+		)
+	} else {
+		t.hSrc = C.cmsOpenProfileFromMemTHR(t.ctx, unsafe.Pointer(&srcICC[0]), C.cmsUInt32Number(len(srcICC)))
+		if t.hSrc == nil {
+			t.Close()
+			return nil, log.errorf("lcms2: failed to open source profile")
+		}
+		if C.cmsIsIntentSupported(t.hSrc, C.cmsUInt32Number(intent), C.LCMS_USED_AS_INPUT) == 0 ||
+			C.cmsIsIntentSupported(t.hDst, C.cmsUInt32Number(intent), C.LCMS_USED_AS_OUTPUT) == 0 {
+			t.intent = FallbackIntent
+		}
+		t.hTransform = C.cmsCreateTransformTHR(t.ctx,
+			t.hSrc, inFormat,
+			t.hDst, outFormat,
+			C.cmsUInt32Number(t.intent),
+			C.cmsFLAGS_NOCACHE,
+		)
+	}
+	if t.hTransform == nil {
+		t.Close()
+		return nil, log.errorf("lcms2: failed to create %s transform", IntentName(t.intent))
 	}
-	runtime.SetFinalizer(t, (*Transform).Close)
 	return t, nil
 }
 
-// TransformPixels converts RGB pixels to CMYK in-place row by row.
-// src must be width*height*3 bytes (RGB), returns width*height*4 bytes (CMYK).
-func (t *Transform) TransformPixels(src []byte, width, height int) ([]byte, error) {
+// Intent implements Transform.
+func (t *lcmsTransform) Intent() int {
+	return t.intent
+}
+
+// TransformPixels converts RGB pixels to CMYK row by row.
+func (t *lcmsTransform) TransformPixels(src []byte, width, height int) ([]byte, error) {
+	if t.inFormat != C.TYPE_RGB_8 || t.outFormat != C.TYPE_CMYK_8 {
+		return nil, fmt.Errorf("TransformPixels needs an 8-bit RGB → CMYK transform")
+	}
 	expectedSrc := width * height * 3
 	if len(src) != expectedSrc {
 		return nil, fmt.Errorf("expected %d RGB bytes, got %d", expectedSrc, len(src))
@@ -107,8 +170,46 @@ func (t *Transform) TransformPixels(src []byte, width, height int) ([]byte, erro
 	return dst, nil
 }
 
+// TransformFloat converts float RGB pixels to 8-bit CMYK.
+func (t *lcmsTransform) TransformFloat(src []float32, width, height int) ([]byte, error) {
+	if t.inFormat != C.TYPE_RGB_FLT || t.outFormat != C.TYPE_CMYK_8 {
+		return nil, fmt.Errorf("TransformFloat needs an 8-bit float transform")
+	}
+	if len(src) != width*height*3 {
+		return nil, fmt.Errorf("expected %d float RGB values, got %d", width*height*3, len(src))
+	}
+	dst := make([]byte, width*height*4)
+	for y := 0; y < height; y++ {
+		C.cmsDoTransform(t.hTransform,
+			unsafe.Pointer(&src[y*width*3]),
+			unsafe.Pointer(&dst[y*width*4]),
+			C.cmsUInt32Number(width),
+		)
+	}
+	return dst, nil
+}
+
+// TransformFloat16 converts float RGB pixels to 16-bit CMYK.
+func (t *lcmsTransform) TransformFloat16(src []float32, width, height int) ([]uint16, error) {
+	if t.inFormat != C.TYPE_RGB_FLT || t.outFormat != C.TYPE_CMYK_16 {
+		return nil, fmt.Errorf("TransformFloat16 needs a 16-bit float transform")
+	}
+	if len(src) != width*height*3 {
+		return nil, fmt.Errorf("expected %d float RGB values, got %d", width*height*3, len(src))
+	}
+	dst := make([]uint16, width*height*4)
+	for y := 0; y < height; y++ {
+		C.cmsDoTransform(t.hTransform,
+			unsafe.Pointer(&src[y*width*3]),
+			unsafe.Pointer(&dst[y*width*4]),
+			C.cmsUInt32Number(width),
+		)
+	}
+	return dst, nil
+}
+
 // Close releases lcms2 resources.
-func (t *Transform) Close() {
+func (t *lcmsTransform) Close() {
 	if t.hTransform != nil {
 		C.cmsDeleteTransform(t.hTransform)
 		t.hTransform = nil
@@ -121,4 +222,12 @@ func (t *Transform) Close() {
 		C.cmsCloseProfile(t.hSrc)
 		t.hSrc = nil
 	}
+	if t.ctx != nil {
+		C.cmsDeleteContext(t.ctx)
+		t.ctx = nil
+	}
+	if t.log != 0 {
+		t.log.Delete()
+		t.log = 0
+	}
 }
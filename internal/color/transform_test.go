@@ -2,13 +2,41 @@ package color
 
 import (
 	"os"
+	"strings"
 	"testing"
 )
 
+func TestBuiltinProfilesValid(t *testing.T) {
+	for _, name := range BuiltinProfileNames() {
+		data, _ := BuiltinProfile(name)
+		pi, err := ParseProfileInfo(data)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if int(pi.Size) != len(data) {
+			t.Errorf("%s: header size %d, data length %d", name, pi.Size, len(data))
+		}
+		if pi.Description == "" {
+			t.Errorf("%s: no profile description", name)
+		}
+		switch name {
+		case "srgb", "adobergb", "srgb-linear":
+			if pi.ColorSpace != "RGB " {
+				t.Errorf("%s: expected RGB source profile, got %q", name, pi.ColorSpace)
+			}
+		default:
+			if pi.ColorSpace != "CMYK" || pi.Class != "prtr" {
+				t.Errorf("%s: expected CMYK output profile, got %q/%q", name, pi.ColorSpace, pi.Class)
+			}
+		}
+	}
+}
+
 func TestTransformKnownPixels(t *testing.T) {
 	cmykICC, err := os.ReadFile("/mnt/c/Users/daves/OneDrive/Desktop/rgb_to_cmyk/magick-workflow/PSOcoated_v3.icc")
 	if err != nil {
-		t.Skipf("CMYK profile not available: %v", err)
+		cmykICC, _ = BuiltinProfile(DefaultCMYKProfile)
 	}
 
 	xform, err := NewTransform(EmbeddedSRGB, cmykICC, IntentPerceptual)
@@ -52,3 +80,99 @@ func TestTransformKnownPixels(t *testing.T) {
 		t.Errorf("red M=%d, expected >100", cmyk[9])
 	}
 }
+
+func TestNewTransformRejectsMismatchedProfiles(t *testing.T) {
+	cmyk, _ := BuiltinProfile(DefaultCMYKProfile)
+	truncated := append([]byte(nil), cmyk[:len(cmyk)-100]...)
+
+	tests := []struct {
+		name     string
+		src, dst []byte
+		want     string
+	}{
+		{"RGB destination", EmbeddedSRGB, EmbeddedSRGB, "expected a CMYK Output or DeviceLink"},
+		{"CMYK source", cmyk, cmyk, "source profile is CMYK"},
+		{"truncated destination", EmbeddedSRGB, truncated, "header declares"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			xform, err := NewTransform(tt.src, tt.dst, IntentPerceptual)
+			if err == nil {
+				xform.Close()
+				t.Fatal("expected error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %q does not mention %q", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewTransform(EmbeddedSRGB, cmyk, 7); err == nil {
+		t.Error("expected error for invalid intent")
+	}
+}
+
+func TestFloatTransform(t *testing.T) {
+	linear, _ := BuiltinProfile("srgb-linear")
+	cmyk, _ := BuiltinProfile(DefaultCMYKProfile)
+
+	// White, mid-grey (18% linear) and black.
+	src := []float32{1, 1, 1, 0.18, 0.18, 0.18, 0, 0, 0}
+
+	x8, err := NewFloatTransform(linear, cmyk, IntentRelativeColorimetric, 8)
+	if err != nil {
+		t.Fatalf("NewFloatTransform: %v", err)
+	}
+	defer x8.Close()
+	out8, err := x8.TransformFloat(src, 3, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out8[0]+out8[1]+out8[2]+out8[3] > 4 {
+		t.Errorf("white → %v, want no ink", out8[0:4])
+	}
+	if out8[11] < 200 {
+		t.Errorf("black → %v, want heavy K", out8[8:12])
+	}
+
+	x16, err := NewFloatTransform(linear, cmyk, IntentRelativeColorimetric, 16)
+	if err != nil {
+		t.Fatalf("NewFloatTransform 16: %v", err)
+	}
+	defer x16.Close()
+	out16, err := x16.TransformFloat16(src, 3, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for c := 0; c < 4; c++ {
+		if d := int(out16[4+c]>>8) - int(out8[4+c]); d < -2 || d > 2 {
+			t.Errorf("grey channel %d: 16-bit %d vs 8-bit %d", c, out16[4+c], out8[4+c])
+		}
+	}
+
+	if _, err := x8.TransformPixels(make([]byte, 3), 1, 1); err == nil {
+		t.Error("expected TransformPixels to reject a float transform")
+	}
+	if _, err := NewFloatTransform(linear, cmyk, IntentPerceptual, 12); err == nil {
+		t.Error("expected error for 12-bit output")
+	}
+}
+
+func TestBlackPointCompensation(t *testing.T) {
+	adobe, _ := BuiltinProfile("adobergb")
+	coated, _ := BuiltinProfile(DefaultCMYKProfile)
+	for _, tc := range []struct {
+		src    []byte
+		intent int
+		want   bool
+	}{
+		{EmbeddedSRGB, IntentPerceptual, true},
+		{EmbeddedSRGB, IntentSaturation, true},
+		{EmbeddedSRGB, IntentRelativeColorimetric, false},
+		{adobe, IntentPerceptual, false},
+	} {
+		if got := BlackPointCompensation(tc.src, coated, tc.intent); got != tc.want {
+			t.Errorf("%d-byte source, %s: got %v", len(tc.src), IntentName(tc.intent), got)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+// Package fidelity measures how closely a decoded CMYK JPEG matches the
+// pixels it was encoded from, plate by plate, and searches the encoder
+// quality for the smallest file that meets a target.
+package fidelity
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// PlateWeights weight C, M, Y and K in the combined scores. K carries the
+// detail in a separation, so its errors count double.
+var PlateWeights = [4]float64{1, 1, 1, 2}
+
+// Metric selects the score a Target is measured in.
+type Metric int
+
+const (
+	SSIM Metric = iota // structural similarity, 0–1
+	PSNR               // peak signal-to-noise ratio, dB
+)
+
+// String returns the metric's name.
+func (m Metric) String() string {
+	if m == PSNR {
+		return "PSNR"
+	}
+	return "SSIM"
+}
+
+// ParseMetric parses "ssim" or "psnr".
+func ParseMetric(s string) (Metric, error) {
+	switch strings.ToLower(s) {
+	case "ssim":
+		return SSIM, nil
+	case "psnr":
+		return PSNR, nil
+	}
+	return 0, fmt.Errorf("unknown metric %q (want ssim or psnr)", s)
+}
+
+// Target is a minimum combined score.
+type Target struct {
+	Metric Metric
+	Value  float64 // zero disables the target
+}
+
+// Scores holds per-plate fidelity in C, M, Y, K order and the combined
+// values weighted by PlateWeights. A plate that is reproduced exactly has
+// infinite PSNR.
+type Scores struct {
+	SSIM         [4]float64
+	PSNR         [4]float64 // dB
+	WeightedSSIM float64
+	WeightedPSNR float64 // dB, from the weighted mean squared error
+}
+
+// Score returns the combined value for m.
+func (s *Scores) Score(m Metric) float64 {
+	if m == PSNR {
+		return s.WeightedPSNR
+	}
+	return s.WeightedSSIM
+}
+
+// Measure compares width×height interleaved CMYK pixels with a decoded
+// copy of them.
+func Measure(ref, test []byte, width, height int) (*Scores, error) {
+	n := width * height * 4
+	if n <= 0 || len(ref) != n || len(test) != n {
+		return nil, fmt.Errorf("expected %d CMYK bytes for %dx%d, got %d and %d", n, width, height, len(ref), len(test))
+	}
+
+	s := &Scores{}
+	var wsum, wssim, wmse float64
+	for p := 0; p < 4; p++ {
+		ssim, mse := plate(ref, test, width, height, p)
+		s.SSIM[p] = ssim
+		s.PSNR[p] = psnr(mse)
+		w := PlateWeights[p]
+		wsum += w
+		wssim += w * ssim
+		wmse += w * mse
+	}
+	s.WeightedSSIM = wssim / wsum
+	s.WeightedPSNR = psnr(wmse / wsum)
+	return s, nil
+}
+
+func psnr(mse float64) float64 {
+	if mse == 0 {
+		return math.Inf(1)
+	}
+	return 10 * math.Log10(255*255/mse)
+}
+
+// SSIM window size and step; 8×8 windows every 4 pixels are the usual
+// fast approximation of the Gaussian-weighted original.
+const (
+	window = 8
+	stride = 4
+)
+
+// plate returns the mean SSIM and the mean squared error of plate p.
+// Rows of windows are shared out between goroutines.
+func plate(ref, test []byte, width, height, p int) (ssim, mse float64) {
+	ww, wh := min(window, width), min(window, height)
+	cols := (width-ww)/stride + 1
+	rows := (height-wh)/stride + 1
+
+	workers := min(runtime.GOMAXPROCS(0), rows)
+	sums := make([]float64, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for r := w; r < rows; r += workers {
+				for c := 0; c < cols; c++ {
+					sums[w] += windowSSIM(ref, test, width, c*stride, r*stride, ww, wh, p)
+				}
+			}
+		}(w)
+	}
+
+	var sq float64
+	for i := p; i < len(ref); i += 4 {
+		d := float64(ref[i]) - float64(test[i])
+		sq += d * d
+	}
+	wg.Wait()
+
+	for _, v := range sums {
+		ssim += v
+	}
+	return ssim / float64(rows*cols), sq / float64(width*height)
+}
+
+// windowSSIM computes SSIM over one window of plate p.
+func windowSSIM(ref, test []byte, width, x0, y0, ww, wh, p int) float64 {
+	const (
+		c1 = (0.01 * 255) * (0.01 * 255)
+		c2 = (0.03 * 255) * (0.03 * 255)
+	)
+	var sa, sb, saa, sbb, sab float64
+	for y := y0; y < y0+wh; y++ {
+		row := (y*width + x0) * 4
+		for x := 0; x < ww; x++ {
+			a := float64(ref[row+4*x+p])
+			b := float64(test[row+4*x+p])
+			sa += a
+			sb += b
+			saa += a * a
+			sbb += b * b
+			sab += a * b
+		}
+	}
+	n := float64(ww * wh)
+	ma, mb := sa/n, sb/n
+	va := saa/n - ma*ma
+	vb := sbb/n - mb*mb
+	cov := sab/n - ma*mb
+	return (2*ma*mb + c1) * (2*cov + c2) / ((ma*ma + mb*mb + c1) * (va + vb + c2))
+}
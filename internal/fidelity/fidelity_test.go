@@ -0,0 +1,101 @@
+package fidelity
+
+import (
+	"math"
+	"testing"
+
+	"github.com/davesmith10/RGBtoCMYK/internal/jpeg"
+)
+
+func testPixels(width, height int) []byte {
+	pixels := make([]byte, width*height*4)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := (y*width + x) * 4
+			pixels[i] = byte(128 + 100*math.Sin(float64(x)/7))
+			pixels[i+1] = byte(128 + 100*math.Cos(float64(y)/9))
+			pixels[i+2] = byte(x + y)
+			if (x/8+y/8)%2 == 0 {
+				pixels[i+3] = 255
+			}
+		}
+	}
+	return pixels
+}
+
+func TestMeasure(t *testing.T) {
+	width, height := 37, 21
+	ref := testPixels(width, height)
+
+	s, err := Measure(ref, ref, width, height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for p := 0; p < 4; p++ {
+		if math.Abs(s.SSIM[p]-1) > 1e-9 || !math.IsInf(s.PSNR[p], 1) {
+			t.Errorf("plate %d: identical pixels scored SSIM %g, PSNR %g", p, s.SSIM[p], s.PSNR[p])
+		}
+	}
+
+	// An error of 4 on every K sample: MSE 16 on K only.
+	test := append([]byte(nil), ref...)
+	for i := 3; i < len(test); i += 4 {
+		if test[i] == 255 {
+			test[i] -= 4
+		} else {
+			test[i] += 4
+		}
+	}
+	s, err = Measure(ref, test, width, height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 10 * math.Log10(255*255/16.0); math.Abs(s.PSNR[3]-want) > 1e-9 {
+		t.Errorf("K PSNR = %g, want %g", s.PSNR[3], want)
+	}
+	if want := 10 * math.Log10(255*255/(16*2/5.0)); math.Abs(s.WeightedPSNR-want) > 1e-9 {
+		t.Errorf("weighted PSNR = %g, want %g", s.WeightedPSNR, want)
+	}
+	if s.SSIM[3] >= 1 || s.SSIM[0] != 1 {
+		t.Errorf("SSIM = %v", s.SSIM)
+	}
+
+	if _, err := Measure(ref, ref[:8], width, height); err == nil {
+		t.Error("expected a size mismatch error")
+	}
+}
+
+func TestEncodeToTarget(t *testing.T) {
+	width, height := 96, 80
+	pixels := testPixels(width, height)
+	target := Target{Metric: SSIM, Value: 0.97}
+
+	data, used, scores, err := EncodeToTarget(pixels, width, height, nil, jpeg.EncoderOptions{CMYReduction: 15}, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scores.WeightedSSIM < target.Value {
+		t.Errorf("SSIM %g below the target", scores.WeightedSSIM)
+	}
+	decoded, err := jpeg.DecodeCMYK(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := Measure(pixels, decoded.Pixels, width, height); *again != *scores {
+		t.Error("returned scores do not match the returned data")
+	}
+
+	// One step lower must miss the target, or the file is not the smallest.
+	if used.Quality > 1 {
+		used.Quality--
+		lower, _ := jpeg.EncodeCMYK(pixels, width, height, nil, used)
+		d, _ := jpeg.DecodeCMYK(lower)
+		if s, _ := Measure(pixels, d.Pixels, width, height); s.WeightedSSIM >= target.Value {
+			t.Errorf("quality %d also meets the target", used.Quality)
+		}
+	}
+
+	if _, _, _, err := EncodeToTarget(pixels, width, height, nil, jpeg.EncoderOptions{CMYReduction: 15}, Target{Metric: PSNR, Value: 200}); err == nil {
+		t.Error("expected an unreachable target to fail")
+	}
+}
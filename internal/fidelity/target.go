@@ -0,0 +1,55 @@
+package fidelity
+
+import (
+	"fmt"
+
+	"github.com/davesmith10/RGBtoCMYK/internal/jpeg"
+)
+
+// EncodeToTarget encodes at the lowest quality whose decoded output meets
+// target, giving the smallest file that does. Like jpeg.EncodeCMYKToSize
+// it searches opts.Quality with opts.CMYReduction fixed; every step is
+// encoded, decoded and measured against pixels. It returns the output, the
+// options used and their scores.
+func EncodeToTarget(pixels []byte, width, height int, iccProfile []byte, opts jpeg.EncoderOptions, target Target) ([]byte, jpeg.EncoderOptions, *Scores, error) {
+	try := func(quality int) ([]byte, *Scores, error) {
+		o := opts
+		o.Quality = quality
+		data, err := jpeg.EncodeCMYK(pixels, width, height, iccProfile, o)
+		if err != nil {
+			return nil, nil, err
+		}
+		decoded, err := jpeg.DecodeCMYK(data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("decoding trial encode: %w", err)
+		}
+		scores, err := Measure(pixels, decoded.Pixels, width, height)
+		return data, scores, err
+	}
+
+	var best []byte
+	var bestScores *Scores
+	lo, hi := 1, 100
+	for lo <= hi {
+		q := (lo + hi) / 2
+		data, scores, err := try(q)
+		if err != nil {
+			return nil, opts, nil, err
+		}
+		if scores.Score(target.Metric) >= target.Value {
+			best, bestScores, opts.Quality = data, scores, q
+			hi = q - 1
+		} else {
+			lo = q + 1
+		}
+	}
+	if best == nil {
+		_, scores, err := try(100)
+		if err != nil {
+			return nil, opts, nil, err
+		}
+		return nil, opts, scores, fmt.Errorf("%s target %g not reached: quality 100 with CMY reduction %d gives %.4g",
+			target.Metric, target.Value, opts.CMYReduction, scores.Score(target.Metric))
+	}
+	return best, opts, bestScores, nil
+}
@@ -0,0 +1,79 @@
+package hdr
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestPFMRoundTrip(t *testing.T) {
+	img := &Image{Width: 2, Height: 2, Pixels: []float32{
+		0, 0.5, 1, 2, 4, 8,
+		-1, 0.125, 16, 100, 0, 0.25,
+	}}
+	got, err := Decode(EncodePFM(img))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Width != 2 || got.Height != 2 {
+		t.Fatalf("size %dx%d", got.Width, got.Height)
+	}
+	for i, v := range img.Pixels {
+		if got.Pixels[i] != v {
+			t.Errorf("value %d = %v, want %v", i, got.Pixels[i], v)
+		}
+	}
+}
+
+func TestDecodePFMGrayBigEndian(t *testing.T) {
+	// 1x2 grayscale, big-endian (positive scale), bottom row first.
+	data := append([]byte("Pf\n1 2\n1.0\n"),
+		0x3f, 0x80, 0, 0, // 1.0 (bottom)
+		0x3f, 0, 0, 0, // 0.5 (top)
+	)
+	img, err := DecodePFM(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []float32{0.5, 0.5, 0.5, 1, 1, 1}
+	for i, v := range want {
+		if img.Pixels[i] != v {
+			t.Errorf("value %d = %v, want %v", i, img.Pixels[i], v)
+		}
+	}
+
+	if _, err := DecodePFM([]byte("P6\n1 1\n255\n")); err == nil {
+		t.Error("expected error for non-PFM data")
+	}
+	for _, data := range []string{
+		"PF\n4000000000000 4000000000000\n-1\n",      // overflows w*h*12
+		"PF\n2 2\n-1\n" + strings.Repeat("\x00", 47), // one byte short
+	} {
+		if _, err := DecodePFM([]byte(data)); err == nil {
+			t.Errorf("DecodePFM(%.30q) succeeded", data)
+		}
+	}
+}
+
+func TestToneMap(t *testing.T) {
+	px := []float32{0.25, 0.25, 0.25, 4, 4, 4, float32(math.NaN()), -1, 0.5}
+	ToneMap(px, 1, Clip)
+	want := []float32{0.5, 0.5, 0.5, 1, 1, 1, 0, 0, 1}
+	for i, v := range want {
+		if px[i] != v {
+			t.Errorf("clip value %d = %v, want %v", i, px[i], v)
+		}
+	}
+
+	px = []float32{1, 1, 1, 1000, 1000, 1000}
+	ToneMap(px, 0, Reinhard)
+	if px[0] != 0.5 || px[3] >= 1 || px[3] < 0.99 {
+		t.Errorf("reinhard = %v", px)
+	}
+
+	px = []float32{0, 0, 0, 0.18, 0.18, 0.18, 100, 100, 100}
+	ToneMap(px, 0, Filmic)
+	if px[0] != 0 || px[3] <= 0.18 || px[6] > 1 || px[6] < px[3] {
+		t.Errorf("filmic = %v", px)
+	}
+}
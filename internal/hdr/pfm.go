@@ -0,0 +1,137 @@
+// Package hdr reads floating-point RGB images and maps scene-referred,
+// linear-light values into the 0–1 range a colour transform expects.
+package hdr
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/davesmith10/RGBtoCMYK/internal/tiff"
+)
+
+// Image is a float RGB image with 3 interleaved values per pixel, top row
+// first.
+type Image struct {
+	Width  int
+	Height int
+	Pixels []float32
+	DPI    float64 // print resolution from a TIFF, 0 if unknown
+}
+
+// DecodePFM reads a Portable Float Map. Grayscale ("Pf") files are expanded
+// to RGB.
+func DecodePFM(data []byte) (*Image, error) {
+	br := bytes.NewReader(data)
+	r := bufio.NewReader(br)
+	var fields []string
+	for len(fields) < 4 {
+		tok, err := pfmToken(r)
+		if err != nil {
+			return nil, fmt.Errorf("PFM header: %w", err)
+		}
+		fields = append(fields, tok)
+	}
+
+	var channels int
+	switch fields[0] {
+	case "PF":
+		channels = 3
+	case "Pf":
+		channels = 1
+	default:
+		return nil, fmt.Errorf("not a PFM file (magic %q)", fields[0])
+	}
+	w, err1 := strconv.Atoi(fields[1])
+	h, err2 := strconv.Atoi(fields[2])
+	scale, err3 := strconv.ParseFloat(fields[3], 64)
+	if err1 != nil || err2 != nil || err3 != nil || w <= 0 || h <= 0 || scale == 0 {
+		return nil, fmt.Errorf("invalid PFM header %q", fields)
+	}
+	var bo binary.ByteOrder = binary.BigEndian
+	if scale < 0 {
+		bo = binary.LittleEndian
+	}
+
+	// Dividing the bytes left rather than multiplying the dimensions keeps
+	// a forged header from overflowing or allocating more than the file.
+	rest := br.Len() + r.Buffered()
+	if w > rest/(channels*4)/h {
+		return nil, fmt.Errorf("PFM data: %dx%d image needs more than the %d bytes after the header", w, h, rest)
+	}
+	raw := make([]byte, w*h*channels*4)
+	if _, err := io.ReadFull(r, raw); err != nil {
+		return nil, fmt.Errorf("PFM data: %w", err)
+	}
+
+	img := &Image{Width: w, Height: h, Pixels: make([]float32, w*h*3)}
+	for y := 0; y < h; y++ {
+		// PFM stores rows bottom to top.
+		src := raw[(h-1-y)*w*channels*4:]
+		for x := 0; x < w; x++ {
+			for c := 0; c < 3; c++ {
+				sc := c
+				if channels == 1 {
+					sc = 0
+				}
+				v := math.Float32frombits(bo.Uint32(src[(x*channels+sc)*4:]))
+				img.Pixels[(y*w+x)*3+c] = v
+			}
+		}
+	}
+	return img, nil
+}
+
+// pfmToken reads one whitespace-delimited header token and consumes the
+// single whitespace byte that ends it.
+func pfmToken(r *bufio.Reader) (string, error) {
+	var tok []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		space := b == ' ' || b == '\n' || b == '\r' || b == '\t'
+		if space {
+			if len(tok) > 0 {
+				return string(tok), nil
+			}
+			continue
+		}
+		tok = append(tok, b)
+	}
+}
+
+// EncodePFM writes img as a little-endian colour PFM.
+func EncodePFM(img *Image) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "PF\n%d %d\n-1.0\n", img.Width, img.Height)
+	row := make([]byte, img.Width*12)
+	for y := img.Height - 1; y >= 0; y-- {
+		for i, v := range img.Pixels[y*img.Width*3 : (y+1)*img.Width*3] {
+			binary.LittleEndian.PutUint32(row[i*4:], math.Float32bits(v))
+		}
+		b.Write(row)
+	}
+	return b.Bytes()
+}
+
+// Decode reads a PFM or 32-bit float TIFF, chosen by the file signature.
+func Decode(data []byte) (*Image, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("PF")) || bytes.HasPrefix(data, []byte("Pf")):
+		return DecodePFM(data)
+	case bytes.HasPrefix(data, []byte("II")) || bytes.HasPrefix(data, []byte("MM")):
+		w, h, pix, err := tiff.DecodeFloatRGB(data)
+		if err != nil {
+			return nil, err
+		}
+		return &Image{Width: w, Height: h, Pixels: pix, DPI: tiff.Resolution(data)}, nil
+	default:
+		return nil, fmt.Errorf("unrecognised float image format (want PFM or 32-bit TIFF)")
+	}
+}
@@ -0,0 +1,77 @@
+package hdr
+
+import (
+	"fmt"
+	"math"
+)
+
+// Operator compresses scene-referred values into 0–1.
+type Operator int
+
+const (
+	// Clip scales by the exposure and clips at 1.
+	Clip Operator = iota
+	// Reinhard applies x/(1+x) to luminance, preserving hue.
+	Reinhard
+	// Filmic applies the ACES fitted curve (Narkowicz) per channel.
+	Filmic
+)
+
+// ParseOperator converts a command-line name to an Operator.
+func ParseOperator(s string) (Operator, error) {
+	switch s {
+	case "clip":
+		return Clip, nil
+	case "reinhard":
+		return Reinhard, nil
+	case "filmic":
+		return Filmic, nil
+	default:
+		return Clip, fmt.Errorf("unknown tone map operator: %q", s)
+	}
+}
+
+// ToneMap scales linear RGB pixels by 2^exposure (in stops) and maps them
+// into 0–1 with op, in place. Values stay linear; the source profile
+// applies any encoding curve. Negative and NaN values become 0.
+func ToneMap(pixels []float32, exposure float64, op Operator) {
+	gain := math.Exp2(exposure)
+	for i := 0; i+2 < len(pixels); i += 3 {
+		r := sanitize(float64(pixels[i]) * gain)
+		g := sanitize(float64(pixels[i+1]) * gain)
+		b := sanitize(float64(pixels[i+2]) * gain)
+
+		switch op {
+		case Reinhard:
+			// Rec. 709 luminance; scaling all channels by the same factor
+			// keeps the hue, then clip any channel still above 1.
+			l := 0.2126*r + 0.7152*g + 0.0722*b
+			if l > 0 {
+				k := 1 / (1 + l)
+				r, g, b = r*k, g*k, b*k
+			}
+		case Filmic:
+			r, g, b = aces(r), aces(g), aces(b)
+		}
+
+		pixels[i] = float32(math.Min(r, 1))
+		pixels[i+1] = float32(math.Min(g, 1))
+		pixels[i+2] = float32(math.Min(b, 1))
+	}
+}
+
+func sanitize(v float64) float64 {
+	if math.IsNaN(v) || v < 0 {
+		return 0
+	}
+	if math.IsInf(v, 1) {
+		return math.MaxFloat32
+	}
+	return v
+}
+
+// aces is Krzysztof Narkowicz's fit of the ACES filmic tone curve.
+func aces(x float64) float64 {
+	const a, b, c, d, e = 2.51, 0.03, 2.43, 0.59, 0.14
+	return math.Max(0, x*(a*x+b)/(x*(c*x+d)+e))
+}
@@ -0,0 +1,198 @@
+package icc
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// curve is a one-dimensional tone curve on normalised 0–1 values, from a
+// curv or para tag, or an mft table.
+type curve struct {
+	gamma  float64   // used when table and para are empty
+	table  []float64 // sampled curve, evenly spaced over 0–1
+	ptype  int       // parametric function type, 0–4
+	params []float64 // parametric function parameters; nil if not parametric
+	inv    []float64 // lazily built inverse table
+}
+
+var identity = &curve{gamma: 1}
+
+func (c *curve) eval(x float64) float64 {
+	x = clamp01(x)
+	switch {
+	case c.params != nil:
+		return clamp01(c.parametric(x))
+	case len(c.table) > 0:
+		return interp1(c.table, x)
+	case c.gamma == 1:
+		return x
+	}
+	return math.Pow(x, c.gamma)
+}
+
+func (c *curve) parametric(x float64) float64 {
+	p := c.params
+	g := p[0]
+	switch c.ptype {
+	case 0:
+		return math.Pow(x, g)
+	case 1:
+		if x >= -p[2]/p[1] {
+			return math.Pow(p[1]*x+p[2], g)
+		}
+		return 0
+	case 2:
+		if x >= -p[2]/p[1] {
+			return math.Pow(p[1]*x+p[2], g) + p[3]
+		}
+		return p[3]
+	case 3:
+		if x >= p[4] {
+			return math.Pow(p[1]*x+p[2], g)
+		}
+		return p[3] * x
+	case 4:
+		if x >= p[4] {
+			return math.Pow(p[1]*x+p[2], g) + p[5]
+		}
+		return p[3]*x + p[6]
+	}
+	return x
+}
+
+// invert evaluates the inverse of a monotonic curve.
+func (c *curve) invert(y float64) float64 {
+	y = clamp01(y)
+	if c.params == nil && len(c.table) == 0 {
+		if c.gamma == 1 {
+			return y
+		}
+		return math.Pow(y, 1/c.gamma)
+	}
+	if c.inv == nil {
+		c.inv = c.buildInverse(4096)
+	}
+	return interp1(c.inv, y)
+}
+
+// buildInverse samples the inverse by bisection on the forward curve. Flat
+// or decreasing curves are treated as increasing from their lowest value.
+func (c *curve) buildInverse(n int) []float64 {
+	const steps = 8192
+	fwd := make([]float64, steps+1)
+	for i := range fwd {
+		fwd[i] = c.eval(float64(i) / steps)
+	}
+	descending := fwd[steps] < fwd[0]
+	if descending {
+		for i, j := 0, steps; i < j; i, j = i+1, j-1 {
+			fwd[i], fwd[j] = fwd[j], fwd[i]
+		}
+	}
+	for i := 1; i <= steps; i++ {
+		if fwd[i] < fwd[i-1] {
+			fwd[i] = fwd[i-1]
+		}
+	}
+	inv := make([]float64, n)
+	for i := range inv {
+		y := float64(i) / float64(n-1)
+		k := sort.SearchFloat64s(fwd, y)
+		var x float64
+		switch {
+		case k == 0:
+			x = 0
+		case k > steps:
+			x = 1
+		default:
+			lo, hi := fwd[k-1], fwd[k]
+			t := 0.0
+			if hi > lo {
+				t = (y - lo) / (hi - lo)
+			}
+			x = (float64(k-1) + t) / steps
+		}
+		if descending {
+			x = 1 - x
+		}
+		inv[i] = x
+	}
+	return inv
+}
+
+// interp1 linearly interpolates an evenly spaced table at x in 0–1.
+func interp1(t []float64, x float64) float64 {
+	if len(t) == 1 {
+		return t[0]
+	}
+	pos := x * float64(len(t)-1)
+	i := int(pos)
+	if i >= len(t)-1 {
+		return t[len(t)-1]
+	}
+	f := pos - float64(i)
+	return t[i] + f*(t[i+1]-t[i])
+}
+
+// parseCurve reads a curv or para element and returns it with its length
+// in bytes, unpadded.
+func parseCurve(b []byte) (*curve, int, error) {
+	if len(b) < 12 {
+		return nil, 0, errors.New("curve truncated")
+	}
+	switch string(b[0:4]) {
+	case "curv":
+		n := int(be.Uint32(b[8:12]))
+		size := 12 + 2*n
+		if len(b) < size {
+			return nil, 0, errors.New("curv truncated")
+		}
+		switch n {
+		case 0:
+			return identity, size, nil
+		case 1:
+			return &curve{gamma: float64(be.Uint16(b[12:14])) / 256}, size, nil
+		}
+		t := make([]float64, n)
+		for i := range t {
+			t[i] = float64(be.Uint16(b[12+2*i:])) / 65535
+		}
+		return &curve{table: t}, size, nil
+	case "para":
+		counts := []int{1, 3, 4, 5, 7}
+		typ := int(be.Uint16(b[8:10]))
+		if typ >= len(counts) {
+			return nil, 0, fmt.Errorf("unsupported parametric curve type %d", typ)
+		}
+		size := 12 + 4*counts[typ]
+		if len(b) < size {
+			return nil, 0, errors.New("para truncated")
+		}
+		params := make([]float64, 7)
+		for i := 0; i < counts[typ]; i++ {
+			params[i] = s15(b[12+4*i:])
+		}
+		return &curve{ptype: typ, params: params}, size, nil
+	}
+	return nil, 0, fmt.Errorf("unsupported curve type %q", b[0:4])
+}
+
+// parseCurves reads n consecutive curve elements, each padded to 4 bytes.
+func parseCurves(b []byte, n int) ([]*curve, error) {
+	curves := make([]*curve, n)
+	off := 0
+	for i := range curves {
+		if off > len(b) {
+			return nil, errors.New("curve set truncated")
+		}
+		c, size, err := parseCurve(b[off:])
+		if err != nil {
+			return nil, err
+		}
+		curves[i] = c
+		off += (size + 3) &^ 3
+	}
+	return curves, nil
+}
@@ -0,0 +1,266 @@
+package icc
+
+import (
+	"math"
+	"os"
+	"testing"
+)
+
+func loadProfile(t *testing.T, name string) *Profile {
+	t.Helper()
+	data, err := os.ReadFile("../color/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := Parse(data)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return p
+}
+
+func TestParseRejectsGarbage(t *testing.T) {
+	if _, err := Parse(make([]byte, 200)); err == nil {
+		t.Error("expected error for data without acsp signature")
+	}
+	if _, err := Parse([]byte("short")); err == nil {
+		t.Error("expected error for short data")
+	}
+}
+
+func TestMatrixShaperRoundTrip(t *testing.T) {
+	for _, name := range []string{"adobergb.icc", "srgb-linear.icc"} {
+		p := loadProfile(t, name)
+		toLab, err := p.ToLab(RelativeColorimetric)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if white := toLab([]float64{1, 1, 1}); math.Abs(white.L-100) > 0.1 || math.Hypot(white.A, white.B) > 0.1 {
+			t.Errorf("%s: white = %+v, want L*=100 neutral", name, white)
+		}
+		fwd, _ := p.toPCS(RelativeColorimetric)
+		inv, err := p.fromPCS(RelativeColorimetric)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for _, rgb := range [][]float64{{0.2, 0.5, 0.8}, {1, 0, 0}, {0.05, 0.05, 0.05}} {
+			back := inv(fwd(rgb))
+			for i := range rgb {
+				if math.Abs(back[i]-rgb[i]) > 0.002 {
+					t.Errorf("%s: %v round-tripped to %v", name, rgb, back)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestLUTProfileLab(t *testing.T) {
+	// sRGB red in D50 Lab, from the sRGB primaries. The v4 sRGB profile's
+	// colorimetric tables include its viewing flare, so allow some slack.
+	srgb := loadProfile(t, "srgb_v4.icc")
+	toLab, err := srgb.ToLab(RelativeColorimetric)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if red := toLab([]float64{1, 0, 0}); DeltaE(red, Lab{54.29, 80.80, 69.89}) > 10 {
+		t.Errorf("sRGB red = %+v", red)
+	}
+
+	coated := loadProfile(t, "generic-coated.icc")
+	toLab, err = coated.ToLab(RelativeColorimetric)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paper, solid := toLab([]float64{0, 0, 0, 0}), toLab([]float64{1, 1, 1, 1})
+	if paper.L < 90 || solid.L > 25 {
+		t.Errorf("paper L*=%.1f, four-colour solid L*=%.1f", paper.L, solid.L)
+	}
+	inv, err := coated.fromPCS(RelativeColorimetric)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, lab := range []Lab{{50, 0, 0}, {30, 10, 10}, {75, -10, 20}} {
+		if got := toLab(inv(lab.components())); DeltaE(got, lab) > 1.5 {
+			t.Errorf("%+v round-tripped to %+v", lab, got)
+		}
+	}
+}
+
+func TestTransformWhiteAndBlack(t *testing.T) {
+	srgb := loadProfile(t, "srgb_v4.icc")
+	for _, name := range []string{"generic-coated.icc", "generic-uncoated.icc", "generic-newsprint.icc"} {
+		dst := loadProfile(t, name)
+		for intent := Perceptual; intent <= AbsoluteColorimetric; intent++ {
+			xf, err := NewTransform(srgb, dst, intent)
+			if err != nil {
+				t.Fatalf("%s intent %d: %v", name, intent, err)
+			}
+			if xf.In != 3 || xf.Out != 4 {
+				t.Fatalf("%s: %d→%d channels", name, xf.In, xf.Out)
+			}
+			if intent == AbsoluteColorimetric {
+				continue // paper white is simulated, not left blank
+			}
+			white := xf.Eval([]float64{1, 1, 1})
+			for _, v := range white {
+				if v > 0.02 {
+					t.Errorf("%s intent %d: white → %.3f", name, intent, white)
+					break
+				}
+			}
+			if black := xf.Eval([]float64{0, 0, 0}); black[3] < 0.5 {
+				t.Errorf("%s intent %d: black → %.3f", name, intent, black)
+			}
+		}
+	}
+}
+
+func TestOptimizeMatchesDirect(t *testing.T) {
+	xf, err := NewTransform(loadProfile(t, "adobergb.icc"), loadProfile(t, "generic-coated.icc"), RelativeColorimetric)
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := [][]float64{{0.1, 0.7, 0.3}, {0.9, 0.9, 0.2}, {0.5, 0.5, 0.5}, {0.33, 0.1, 0.6}}
+	direct := make([][]float64, len(samples))
+	for i, s := range samples {
+		direct[i] = xf.Eval(s)
+	}
+	if err := xf.Optimize(33); err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range samples {
+		got := xf.Eval(s)
+		for c := range got {
+			if math.Abs(got[c]-direct[i][c]) > 0.01 {
+				t.Errorf("%v: optimized %.4f, direct %.4f", s, got, direct[i])
+				break
+			}
+		}
+	}
+}
+
+func TestTetrahedralExactAtGridPoints(t *testing.T) {
+	c, err := newCLUT([]int{3, 3, 3}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range c.data {
+		c.data[i] = float64(i) / float64(len(c.data)-1)
+	}
+	out := make([]float64, 1)
+	for i := 0; i < 27; i++ {
+		in := []float64{float64(i/9) / 2, float64(i/3%3) / 2, float64(i%3) / 2}
+		c.eval(in, out)
+		if want := c.data[i]; math.Abs(out[0]-want) > 1e-12 {
+			t.Errorf("grid point %v = %v, want %v", in, out[0], want)
+		}
+	}
+	// The table is linear in its index, so interpolation is exact anywhere.
+	c.eval([]float64{0.25, 0.6, 0.9}, out)
+	if want := (0.25*18 + 0.6*6 + 0.9*2) / 26; math.Abs(out[0]-want) > 1e-12 {
+		t.Errorf("interior point = %v, want %v", out[0], want)
+	}
+}
+
+func TestParametricCurves(t *testing.T) {
+	// IEC 61966-2.1 sRGB as a type 3 curve.
+	c := &curve{ptype: 3, params: []float64{2.4, 1 / 1.055, 0.055 / 1.055, 1 / 12.92, 0.04045, 0, 0}}
+	if got := c.eval(0.5); math.Abs(got-0.21404) > 1e-4 {
+		t.Errorf("sRGB(0.5) = %v", got)
+	}
+	if got := c.invert(c.eval(0.3)); math.Abs(got-0.3) > 1e-3 {
+		t.Errorf("inverse(sRGB(0.3)) = %v", got)
+	}
+}
+
+// The fixtures in testdata are built by testdata/mkfixtures.go from affine
+// CLUTs, so every value below follows exactly from the tag definitions.
+// They were computed by evaluating those definitions independently of this
+// package, to six decimals.
+var fixtureCMYK = [][]float64{
+	{0, 0, 0, 0}, {0.2, 0.4, 0.6, 0.1}, {0.5, 0.5, 0.5, 0.5},
+	{1, 0.3, 0.7, 0.9}, {0.05, 0.9, 0.15, 0.6}, {1, 1, 1, 1},
+}
+
+var fixtureLab = [][3]float64{
+	{50, 0, 0}, {80, -20, 30}, {20, 40, -50}, {95, 5, 5}, {0, 0, 0}, {100, 0, 0},
+}
+
+func TestLUTFixtures(t *testing.T) {
+	tests := []struct {
+		name string
+		lab  [][3]float64 // A2B0 of fixtureCMYK
+		cmyk [][]float64  // B2A0 of fixtureLab
+	}{
+		{"mft1-cmyk.icc", [][3]float64{
+			{98.039216, 0, 0},
+			{85.084198, 12.015686, 34.125490},
+			{64.761246, 5.839216, 19.784314},
+			{32.796617, -28.003922, 25.368627},
+			{63.252595, 43.176471, -6.629412},
+			{11.764706, 6, 26},
+		}, [][]float64{
+			{0.259362, 0.412764, 0.432449, 0.382930},
+			{0.181469, 0.289427, 0.359862, 0.160784},
+			{0.310419, 0.542253, 0.471203, 0.607382},
+			{0.111496, 0.257747, 0.278201, 0.062745},
+			{0.395925, 0.549327, 0.569012, 0.729412},
+			{0.093964, 0.235602, 0.255286, 0.035294},
+		}},
+		{"mab-cmyk.icc", [][3]float64{
+			{88.160416, 0.528988, 5.140560},
+			{82.540296, 1.707558, 14.111777},
+			{70.058722, -5.580652, 2.359185},
+			{43.451006, -34.374484, 2.012702},
+			{66.253581, 32.521034, -4.195767},
+			{23.187090, 5.913932, 10.477369},
+		}, [][]float64{
+			{0.183860, 0.439600, 0.485532, 0.489323},
+			{0.117895, 0.307116, 0.415676, 0.260925},
+			{0.228106, 0.579730, 0.512913, 0.700654},
+			{0.061642, 0.267550, 0.314529, 0.132492},
+			{0.325499, 0.585351, 0.631283, 0.827393},
+			{0.095687, 0.240688, 0.286619, 0.092947},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := os.ReadFile("testdata/" + tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, err := Parse(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			toPCS, err := p.toPCS(Perceptual)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, in := range fixtureCMYK {
+				if got := toPCS(in); !near(got[:], tt.lab[i][:], 1e-5) {
+					t.Errorf("A2B0 %v = %v, want %v", in, got, tt.lab[i])
+				}
+			}
+			fromPCS, err := p.fromPCS(Perceptual)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, in := range fixtureLab {
+				if got := fromPCS(in); !near(got, tt.cmyk[i], 1e-5) {
+					t.Errorf("B2A0 %v = %v, want %v", in, got, tt.cmyk[i])
+				}
+			}
+		})
+	}
+}
+
+func near(a, b []float64, tol float64) bool {
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > tol {
+			return false
+		}
+	}
+	return len(a) == len(b)
+}
@@ -0,0 +1,389 @@
+package icc
+
+import (
+	"errors"
+	"fmt"
+)
+
+// clut is a multidimensional colour lookup table holding normalised values.
+// The first input varies slowest, as in the ICC tag layout.
+type clut struct {
+	in, out int
+	grid    []int
+	stride  []int // distance between neighbouring grid points, per input
+	data    []float64
+}
+
+func newCLUT(grid []int, out int) (*clut, error) {
+	c := &clut{in: len(grid), out: out, grid: grid, stride: make([]int, len(grid))}
+	n := out
+	for i := len(grid) - 1; i >= 0; i-- {
+		if grid[i] < 2 {
+			return nil, fmt.Errorf("CLUT dimension %d has %d grid points", i, grid[i])
+		}
+		c.stride[i] = n
+		n *= grid[i]
+	}
+	c.data = make([]float64, n)
+	return c, nil
+}
+
+// eval interpolates the table at in (normalised) into out. Three inputs are
+// interpolated tetrahedrally; with more, the leading inputs are interpolated
+// linearly between tetrahedral results, and with fewer, multilinearly.
+func (c *clut) eval(in, out []float64) {
+	c.evalFrom(in, 0, 0, out)
+}
+
+func (c *clut) evalFrom(in []float64, dim, base int, out []float64) {
+	switch c.in - dim {
+	case 3:
+		c.tetrahedral(in[dim:], dim, base, out)
+		return
+	case 0:
+		copy(out, c.data[base:base+c.out])
+		return
+	}
+	i0, f := c.locate(in[dim], dim)
+	lo := base + i0*c.stride[dim]
+	c.evalFrom(in, dim+1, lo, out)
+	if f == 0 {
+		return
+	}
+	hi := make([]float64, c.out)
+	c.evalFrom(in, dim+1, lo+c.stride[dim], hi)
+	for k := range out {
+		out[k] += f * (hi[k] - out[k])
+	}
+}
+
+// locate returns the lower grid index for v along dim and the fraction
+// towards the next point.
+func (c *clut) locate(v float64, dim int) (int, float64) {
+	pos := clamp01(v) * float64(c.grid[dim]-1)
+	i := int(pos)
+	if i >= c.grid[dim]-1 {
+		i = c.grid[dim] - 2
+	}
+	return i, pos - float64(i)
+}
+
+func (c *clut) tetrahedral(in []float64, dim, base int, out []float64) {
+	x0, rx := c.locate(in[0], dim)
+	y0, ry := c.locate(in[1], dim+1)
+	z0, rz := c.locate(in[2], dim+2)
+	sx, sy, sz := c.stride[dim], c.stride[dim+1], c.stride[dim+2]
+	p := base + x0*sx + y0*sy + z0*sz
+	d := c.data
+	for k := 0; k < c.out; k++ {
+		c000 := d[p+k]
+		c100, c010, c001 := d[p+sx+k], d[p+sy+k], d[p+sz+k]
+		c110, c101, c011 := d[p+sx+sy+k], d[p+sx+sz+k], d[p+sy+sz+k]
+		c111 := d[p+sx+sy+sz+k]
+		var c1, c2, c3 float64
+		switch {
+		case rx >= ry && ry >= rz:
+			c1, c2, c3 = c100-c000, c110-c100, c111-c110
+		case rx >= rz && rz >= ry:
+			c1, c2, c3 = c100-c000, c111-c101, c101-c100
+		case rz >= rx && rx >= ry:
+			c1, c2, c3 = c101-c001, c111-c101, c001-c000
+		case ry >= rx && rx >= rz:
+			c1, c2, c3 = c110-c010, c010-c000, c111-c110
+		case ry >= rz && rz >= rx:
+			c1, c2, c3 = c111-c011, c010-c000, c011-c010
+		default:
+			c1, c2, c3 = c111-c011, c011-c001, c001-c000
+		}
+		out[k] = c000 + c1*rx + c2*ry + c3*rz
+	}
+}
+
+// stage is one processing element of a LUT tag, mapping normalised values.
+type stage func(in []float64) []float64
+
+func curvesStage(curves []*curve) stage {
+	return func(in []float64) []float64 {
+		out := make([]float64, len(in))
+		for i, v := range in {
+			out[i] = curves[i].eval(v)
+		}
+		return out
+	}
+}
+
+func clutStage(c *clut) stage {
+	return func(in []float64) []float64 {
+		out := make([]float64, c.out)
+		c.eval(in, out)
+		return out
+	}
+}
+
+// matrixStage applies a 3×3 matrix and offset (m[9:12]) to three values.
+func matrixStage(m [12]float64) stage {
+	return func(in []float64) []float64 {
+		return []float64{
+			m[0]*in[0] + m[1]*in[1] + m[2]*in[2] + m[9],
+			m[3]*in[0] + m[4]*in[1] + m[5]*in[2] + m[10],
+			m[6]*in[0] + m[7]*in[1] + m[8]*in[2] + m[11],
+		}
+	}
+}
+
+// lut is a parsed AToB, BToA or DeviceLink tag.
+type lut struct {
+	in, out int
+	stages  []stage
+	enc     pcsEncoding // how the PCS side of the tag is encoded
+}
+
+func (l *lut) eval(in []float64) []float64 {
+	v := in
+	for _, s := range l.stages {
+		v = s(v)
+	}
+	return v
+}
+
+// parseLUT reads an mft1, mft2, mAB or mBA tag. pcs is the profile's PCS
+// signature; xyzIn reports whether the tag's input side is PCS XYZ, which
+// is the only case where the mft matrix applies.
+func parseLUT(b []byte, pcs string, xyzIn bool) (*lut, error) {
+	if len(b) < 32 {
+		return nil, errors.New("LUT tag truncated")
+	}
+	switch string(b[0:4]) {
+	case "mft1":
+		return parseMFT(b, pcs, xyzIn, 1)
+	case "mft2":
+		return parseMFT(b, pcs, xyzIn, 2)
+	case "mAB ":
+		return parseMAB(b, pcs, true)
+	case "mBA ":
+		return parseMAB(b, pcs, false)
+	}
+	return nil, fmt.Errorf("unsupported LUT type %q", b[0:4])
+}
+
+func parseMFT(b []byte, pcs string, xyzIn bool, width int) (*lut, error) {
+	if len(b) < 52 {
+		return nil, errors.New("mft tag truncated")
+	}
+	in, out, g := int(b[8]), int(b[9]), int(b[10])
+	if in == 0 || out == 0 || g < 2 {
+		return nil, errors.New("invalid mft dimensions")
+	}
+	inEntries, outEntries, off := 256, 256, 48
+	if width == 2 {
+		inEntries, outEntries, off = int(be.Uint16(b[48:50])), int(be.Uint16(b[50:52])), 52
+	}
+	if inEntries < 2 || outEntries < 2 {
+		return nil, errors.New("invalid mft table size")
+	}
+	grid := make([]int, in)
+	for i := range grid {
+		grid[i] = g
+	}
+	c, err := newCLUT(grid, out)
+	if err != nil {
+		return nil, err
+	}
+	need := off + width*(in*inEntries+len(c.data)+out*outEntries)
+	if len(b) < need {
+		return nil, errors.New("mft tag truncated")
+	}
+	read := func() float64 {
+		var v float64
+		if width == 1 {
+			v = float64(b[off]) / 255
+		} else {
+			v = float64(be.Uint16(b[off:])) / 65535
+		}
+		off += width
+		return v
+	}
+	table := func(n int) *curve {
+		t := make([]float64, n)
+		for i := range t {
+			t[i] = read()
+		}
+		return &curve{table: t}
+	}
+
+	l := &lut{in: in, out: out}
+	if xyzIn && in == 3 {
+		var m [12]float64
+		for i := 0; i < 9; i++ {
+			m[i] = s15(b[12+4*i:])
+		}
+		if m != [12]float64{1, 0, 0, 0, 1, 0, 0, 0, 1} {
+			l.stages = append(l.stages, matrixStage(m))
+		}
+	}
+	inCurves := make([]*curve, in)
+	for i := range inCurves {
+		inCurves[i] = table(inEntries)
+	}
+	for i := range c.data {
+		c.data[i] = read()
+	}
+	outCurves := make([]*curve, out)
+	for i := range outCurves {
+		outCurves[i] = table(outEntries)
+	}
+	l.stages = append(l.stages, curvesStage(inCurves), clutStage(c), curvesStage(outCurves))
+
+	switch {
+	case pcs == "XYZ ":
+		l.enc = encXYZ
+	case pcs != "Lab ":
+		l.enc = encNone
+	case width == 1:
+		l.enc = encLab8
+	default:
+		l.enc = encLabV2
+	}
+	return l, nil
+}
+
+// parseMAB reads an mAB (A→B) or mBA (B→A) tag. Their elements run in
+// opposite orders: A, CLUT, M, matrix, B for mAB and the reverse for mBA.
+func parseMAB(b []byte, pcs string, aToB bool) (*lut, error) {
+	in, out := int(b[8]), int(b[9])
+	if in == 0 || out == 0 {
+		return nil, errors.New("invalid LUT dimensions")
+	}
+	offB, offMatrix, offM := be.Uint32(b[12:]), be.Uint32(b[16:]), be.Uint32(b[20:])
+	offCLUT, offA := be.Uint32(b[24:]), be.Uint32(b[28:])
+	at := func(off uint32) ([]byte, error) {
+		if uint64(off) >= uint64(len(b)) {
+			return nil, errors.New("LUT element offset out of bounds")
+		}
+		return b[off:], nil
+	}
+
+	// Channel counts at each element: the A side has the device channels
+	// of the CLUT's non-PCS end, B, M and the matrix sit on the PCS side.
+	aCount, bCount := in, out
+	if !aToB {
+		aCount, bCount = out, in
+	}
+
+	var curvesA, curvesB, curvesM []*curve
+	var c *clut
+	var matrix *[12]float64
+	var err error
+	if offA != 0 {
+		var e []byte
+		if e, err = at(offA); err == nil {
+			curvesA, err = parseCurves(e, aCount)
+		}
+	}
+	if err == nil && offB != 0 {
+		var e []byte
+		if e, err = at(offB); err == nil {
+			curvesB, err = parseCurves(e, bCount)
+		}
+	}
+	if err == nil && offM != 0 {
+		var e []byte
+		if e, err = at(offM); err == nil {
+			curvesM, err = parseCurves(e, bCount)
+		}
+	}
+	if err == nil && offMatrix != 0 {
+		var e []byte
+		if e, err = at(offMatrix); err == nil {
+			if len(e) < 48 {
+				err = errors.New("LUT matrix truncated")
+			} else {
+				var m [12]float64
+				for i := range m {
+					m[i] = s15(e[4*i:])
+				}
+				matrix = &m
+			}
+		}
+	}
+	if err == nil && offCLUT != 0 {
+		var e []byte
+		if e, err = at(offCLUT); err == nil {
+			cin, cout := in, out
+			c, err = parseMABCLUT(e, cin, cout)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if curvesB == nil {
+		return nil, errors.New("LUT has no B curves")
+	}
+	if matrix != nil && bCount != 3 {
+		return nil, errors.New("LUT matrix needs three PCS-side channels")
+	}
+	if c == nil && in != out {
+		return nil, errors.New("LUT without CLUT changes channel count")
+	}
+
+	l := &lut{in: in, out: out}
+	switch {
+	case pcs == "XYZ ":
+		l.enc = encXYZ
+	case pcs == "Lab ":
+		l.enc = encLabV4
+	default:
+		l.enc = encNone
+	}
+	var forward []stage
+	if curvesA != nil {
+		forward = append(forward, curvesStage(curvesA))
+	}
+	if c != nil {
+		forward = append(forward, clutStage(c))
+	}
+	if curvesM != nil {
+		forward = append(forward, curvesStage(curvesM))
+	}
+	if matrix != nil {
+		forward = append(forward, matrixStage(*matrix))
+	}
+	forward = append(forward, curvesStage(curvesB))
+	if aToB {
+		l.stages = forward
+	} else {
+		for i := len(forward) - 1; i >= 0; i-- {
+			l.stages = append(l.stages, forward[i])
+		}
+	}
+	return l, nil
+}
+
+func parseMABCLUT(b []byte, in, out int) (*clut, error) {
+	if len(b) < 20 || in > 16 {
+		return nil, errors.New("CLUT truncated")
+	}
+	grid := make([]int, in)
+	for i := range grid {
+		grid[i] = int(b[i])
+	}
+	c, err := newCLUT(grid, out)
+	if err != nil {
+		return nil, err
+	}
+	prec := int(b[16])
+	if prec != 1 && prec != 2 {
+		return nil, fmt.Errorf("invalid CLUT precision %d", prec)
+	}
+	if len(b) < 20+prec*len(c.data) {
+		return nil, errors.New("CLUT truncated")
+	}
+	for i := range c.data {
+		if prec == 1 {
+			c.data[i] = float64(b[20+i]) / 255
+		} else {
+			c.data[i] = float64(be.Uint16(b[20+2*i:])) / 65535
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,104 @@
+package icc
+
+import "math"
+
+// XYZ is a CIE XYZ colour with Y = 1 for the reference white.
+type XYZ struct {
+	X, Y, Z float64
+}
+
+// Lab is a CIELAB colour.
+type Lab struct {
+	L, A, B float64
+}
+
+// D50 is the ICC profile connection space illuminant.
+var D50 = XYZ{0.9642, 1.0, 0.8249}
+
+// ToLab converts D50 XYZ to Lab.
+func (c XYZ) ToLab() Lab {
+	f := func(t float64) float64 {
+		if t > 216.0/24389 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27*t + 16) / 116
+	}
+	fx, fy, fz := f(c.X/D50.X), f(c.Y/D50.Y), f(c.Z/D50.Z)
+	return Lab{L: 116*fy - 16, A: 500 * (fx - fy), B: 200 * (fy - fz)}
+}
+
+// ToXYZ converts Lab to D50 XYZ.
+func (c Lab) ToXYZ() XYZ {
+	fy := (c.L + 16) / 116
+	fx := fy + c.A/500
+	fz := fy - c.B/200
+	inv := func(t float64) float64 {
+		if t3 := t * t * t; t3 > 216.0/24389 {
+			return t3
+		}
+		return (116*t - 16) * 27 / 24389
+	}
+	return XYZ{D50.X * inv(fx), D50.Y * inv(fy), D50.Z * inv(fz)}
+}
+
+// DeltaE returns the CIE76 colour difference.
+func DeltaE(a, b Lab) float64 {
+	dl, da, db := a.L-b.L, a.A-b.A, a.B-b.B
+	return math.Sqrt(dl*dl + da*da + db*db)
+}
+
+// pcsEncoding converts between PCS values and the normalised 0–1 values a
+// LUT tag works with.
+type pcsEncoding int
+
+const (
+	encXYZ   pcsEncoding = iota // u1Fixed15: 1.0 = 0x8000
+	encLabV4                    // L 0–100, a/b −128–127 over 0–0xFFFF
+	encLabV2                    // legacy 16-bit: L 0–100 over 0–0xFF00
+	encLab8                     // mft1: L 0–100, a/b −128–127 over 0–0xFF
+	encNone                     // DeviceLink output, no PCS
+)
+
+// decode turns normalised tag output into PCS XYZ or Lab components.
+func (e pcsEncoding) decode(v []float64) [3]float64 {
+	switch e {
+	case encXYZ:
+		k := 65535.0 / 32768
+		return [3]float64{v[0] * k, v[1] * k, v[2] * k}
+	case encLabV4, encLab8:
+		return [3]float64{v[0] * 100, v[1]*255 - 128, v[2]*255 - 128}
+	case encLabV2:
+		return [3]float64{v[0] * 65535 / 652.80, v[1]*65535/256 - 128, v[2]*65535/256 - 128}
+	}
+	return [3]float64{v[0], v[1], v[2]}
+}
+
+// encode turns PCS XYZ or Lab components into normalised tag input.
+func (e pcsEncoding) encode(c [3]float64) []float64 {
+	var v []float64
+	switch e {
+	case encXYZ:
+		k := 32768.0 / 65535
+		v = []float64{c[0] * k, c[1] * k, c[2] * k}
+	case encLabV4, encLab8:
+		v = []float64{c[0] / 100, (c[1] + 128) / 255, (c[2] + 128) / 255}
+	case encLabV2:
+		v = []float64{c[0] * 652.80 / 65535, (c[1] + 128) * 256 / 65535, (c[2] + 128) * 256 / 65535}
+	default:
+		v = []float64{c[0], c[1], c[2]}
+	}
+	for i := range v {
+		v[i] = clamp01(v[i])
+	}
+	return v
+}
+
+func clamp01(v float64) float64 {
+	if v < 0 || math.IsNaN(v) {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
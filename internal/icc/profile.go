@@ -0,0 +1,105 @@
+// Package icc evaluates ICC profiles in pure Go. It reads matrix/TRC and
+// LUT-based (mft1, mft2, mAB, mBA) profiles and builds device-to-device
+// transforms from them, interpolating CLUTs tetrahedrally. It is the colour
+// engine used when the tool is built without lcms2.
+package icc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Profile is a parsed ICC profile.
+type Profile struct {
+	Version    uint32
+	Class      string // "mntr", "prtr", "scnr", "spac", "link", ...
+	ColorSpace string // data colour space, e.g. "RGB ", "CMYK"
+	PCS        string // "XYZ " or "Lab ", or the output space of a DeviceLink
+	MediaWhite XYZ
+
+	data []byte
+	tags map[string][]byte
+}
+
+var be = binary.BigEndian
+
+// Parse reads the header and tag table of an ICC profile.
+func Parse(data []byte) (*Profile, error) {
+	if len(data) < 132 {
+		return nil, errors.New("ICC profile too short")
+	}
+	if string(data[36:40]) != "acsp" {
+		return nil, errors.New("invalid ICC signature")
+	}
+	p := &Profile{
+		Version:    be.Uint32(data[8:12]),
+		Class:      string(data[12:16]),
+		ColorSpace: string(data[16:20]),
+		PCS:        string(data[20:24]),
+		MediaWhite: D50,
+		data:       data,
+		tags:       make(map[string][]byte),
+	}
+	n := int(be.Uint32(data[128:132]))
+	if 132+12*n > len(data) {
+		return nil, errors.New("ICC tag table truncated")
+	}
+	for i := 0; i < n; i++ {
+		e := data[132+12*i:]
+		sig := string(e[0:4])
+		off, size := uint64(be.Uint32(e[4:8])), uint64(be.Uint32(e[8:12]))
+		if off+size > uint64(len(data)) || size < 8 {
+			return nil, fmt.Errorf("ICC tag %q out of bounds", sig)
+		}
+		p.tags[sig] = data[off : off+size]
+	}
+	if wt, ok := p.tags["wtpt"]; ok {
+		if xyz, err := parseXYZ(wt); err == nil {
+			p.MediaWhite = xyz
+		}
+	}
+	return p, nil
+}
+
+// Channels returns the number of components of an ICC colour space
+// signature, or 0 if it is not supported.
+func Channels(space string) int {
+	switch space {
+	case "GRAY":
+		return 1
+	case "RGB ", "XYZ ", "Lab ", "CMY ", "YCbr", "HSV ", "HLS ":
+		return 3
+	case "CMYK":
+		return 4
+	}
+	return 0
+}
+
+// HasTag reports whether the profile contains the tag.
+func (p *Profile) HasTag(sig string) bool {
+	_, ok := p.tags[sig]
+	return ok
+}
+
+// isMatrixShaper reports whether the profile has a complete RGB matrix/TRC
+// model.
+func (p *Profile) isMatrixShaper() bool {
+	for _, t := range []string{"rXYZ", "gXYZ", "bXYZ", "rTRC", "gTRC", "bTRC"} {
+		if !p.HasTag(t) {
+			return false
+		}
+	}
+	return p.ColorSpace == "RGB "
+}
+
+func s15(b []byte) float64 {
+	return float64(int32(be.Uint32(b))) / 65536
+}
+
+func parseXYZ(b []byte) (XYZ, error) {
+	if len(b) < 20 || string(b[0:4]) != "XYZ " {
+		return XYZ{}, errors.New("not an XYZ tag")
+	}
+	return XYZ{s15(b[8:]), s15(b[12:]), s15(b[16:])}, nil
+}
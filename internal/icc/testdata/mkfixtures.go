@@ -0,0 +1,328 @@
+//go:build ignore
+
+// mkfixtures writes the small LUT-based CMYK output profiles used by the
+// icc and color tests:
+//
+//	mft1-cmyk.icc  v2.1, A2B0 and B2A0 as 8-bit mft1 tags
+//	mab-cmyk.icc   v4.3, A2B0 as mAB and B2A0 as mBA, with every
+//	               parametric curve type, curv tables and gammas, a matrix
+//	               with offsets and 8- and 16-bit CLUTs of uneven grids
+//
+// Every CLUT holds an affine function of its inputs, so the tables
+// interpolate exactly whatever the scheme and the expected values in the
+// tests follow from the definitions below. Run it from internal/icc with
+//
+//	go run testdata/mkfixtures.go
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+var be = binary.BigEndian
+
+func main() {
+	write("mft1-cmyk.icc", 0x02100000, "mft1 CMYK test fixture", mft1AToB(), mft1BToA())
+	write("mab-cmyk.icc", 0x04300000, "mAB/mBA CMYK test fixture", mabAToB(), mbaBToA())
+}
+
+// mft1AToB maps CMYK to Lab through a 3-point grid. Its CLUT is
+// (base + Σ step·k)/255 at grid index k of each input.
+func mft1AToB() []byte {
+	const g = 3
+	base := [3]int{250, 128, 128}
+	step := [3][4]int{{-25, -20, -8, -60}, {-20, 25, -2, 0}, {-10, -12, 35, 0}}
+	var clut []byte
+	for c := 0; c < g; c++ {
+		for m := 0; m < g; m++ {
+			for y := 0; y < g; y++ {
+				for k := 0; k < g; k++ {
+					idx := [4]int{c, m, y, k}
+					for o := 0; o < 3; o++ {
+						v := base[o]
+						for i, n := range idx {
+							v += step[o][i] * n
+						}
+						clut = append(clut, byte(v))
+					}
+				}
+			}
+		}
+	}
+	in := [][]byte{table8(1.25), table8(1), table8(0.8), table8(1.5)}
+	out := [][]byte{table8(0.9), table8(1), table8(1)}
+	return mft1(4, 3, g, in, clut, out)
+}
+
+// mft1BToA maps Lab to CMYK through a 3-point grid.
+func mft1BToA() []byte {
+	const g = 3
+	base := [4]int{120, 120, 120, 200}
+	step := [4][3]int{{-40, -20, 10}, {-40, 25, -5}, {-40, -5, 30}, {-90, 0, 0}}
+	var clut []byte
+	for l := 0; l < g; l++ {
+		for a := 0; a < g; a++ {
+			for b := 0; b < g; b++ {
+				idx := [3]int{l, a, b}
+				for o := 0; o < 4; o++ {
+					v := base[o]
+					for i, n := range idx {
+						v += step[o][i] * n
+					}
+					clut = append(clut, byte(v))
+				}
+			}
+		}
+	}
+	in := [][]byte{table8(1.2), table8(1), table8(1)}
+	out := [][]byte{table8(1.1), table8(1), table8(1), table8(1.3)}
+	return mft1(3, 4, g, in, clut, out)
+}
+
+// table8 is a 256-entry 8-bit gamma table.
+func table8(gamma float64) []byte {
+	t := make([]byte, 256)
+	for i := range t {
+		t[i] = byte(math.Round(255 * math.Pow(float64(i)/255, gamma)))
+	}
+	return t
+}
+
+func mft1(in, out, g int, inTables [][]byte, clut []byte, outTables [][]byte) []byte {
+	var b bytes.Buffer
+	b.WriteString("mft1")
+	b.Write(make([]byte, 4))
+	b.Write([]byte{byte(in), byte(out), byte(g), 0})
+	for i := 0; i < 9; i++ { // identity matrix
+		v := 0.0
+		if i%4 == 0 {
+			v = 1
+		}
+		b.Write(s15(v))
+	}
+	for _, t := range inTables {
+		b.Write(t)
+	}
+	b.Write(clut)
+	for _, t := range outTables {
+		b.Write(t)
+	}
+	return b.Bytes()
+}
+
+// mabAToB maps CMYK to Lab: A curves, an 8-bit CLUT with grid 3×5×3×2,
+// M curves, a matrix with offsets and B curves.
+func mabAToB() []byte {
+	grid := []int{3, 5, 3, 2}
+	base := [3]int{250, 128, 128}
+	step := [3][4]int{{-25, -10, -8, -100}, {-20, 12, -2, 0}, {-10, -6, 35, 0}}
+	clut := clutHeader(grid, 1)
+	forGrid(grid, func(idx []int) {
+		for o := 0; o < 3; o++ {
+			v := base[o]
+			for i, n := range idx {
+				v += step[o][i] * n
+			}
+			clut = append(clut, byte(v))
+		}
+	})
+	a := curves(
+		para(0, 1.25),
+		para(1, 1.5, 1.25, -0.25),
+		para(2, 1.5, 1, -0.2, 0.1),
+		para(3, 2.4, 1/1.055, 0.055/1.055, 1/12.92, 0.04045),
+	)
+	m := curves(
+		para(4, 1.2, 0.9, 0.1, 0.6, 0.1, -0.05, 0.025),
+		curvTable([]float64{0, 0.2, 0.5, 0.8, 1}),
+		curvGamma(1),
+	)
+	matrix := matrixElement([12]float64{0.875, 0, 0, 0, 0.75, 0.125, 0, 0.125, 0.75, 0.0625, 0.0625, 0.0625})
+	b := curves(para(0, 0.9), curvTable(nil), para(0, 1))
+	return mab("mAB ", 4, 3, b, matrix, m, clut, a)
+}
+
+// mbaBToA maps Lab to CMYK: B curves, a matrix with offsets, M curves, a
+// 16-bit CLUT with grid 5×7×9 and A curves.
+func mbaBToA() []byte {
+	grid := []int{5, 7, 9}
+	base := [4]int{39000, 33000, 33000, 59000}
+	step := [4][3]int{{-6500, -2000, 800}, {-6500, 3000, -400}, {-6500, -500, 2800}, {-13500, 0, 0}}
+	clut := clutHeader(grid, 2)
+	forGrid(grid, func(idx []int) {
+		for o := 0; o < 4; o++ {
+			v := base[o]
+			for i, n := range idx {
+				v += step[o][i] * n
+			}
+			clut = be.AppendUint16(clut, uint16(v))
+		}
+	})
+	b := curves(para(0, 1.1), curvTable(nil), curvTable(nil))
+	matrix := matrixElement([12]float64{0.875, 0, 0, 0, 0.875, 0, 0, 0, 0.875, 0.0625, 0.0625, 0.0625})
+	m := curves(
+		curvTable([]float64{0, 0.3, 0.6, 1}),
+		para(1, 1.1, 1, 0),
+		para(2, 1, 1, 0, 0),
+	)
+	k := make([]float64, 256)
+	for i := range k {
+		k[i] = math.Pow(float64(i)/255, 1.2)
+	}
+	a := curves(
+		para(3, 1.8, 1, 0, 0.5, 0.2),
+		curvTable(nil),
+		para(0, 1),
+		curvTable(k),
+	)
+	return mab("mBA ", 3, 4, b, matrix, m, clut, a)
+}
+
+// forGrid calls f for every grid index, the first input varying slowest.
+func forGrid(grid []int, f func(idx []int)) {
+	idx := make([]int, len(grid))
+	var walk func(d int)
+	walk = func(d int) {
+		if d == len(grid) {
+			f(idx)
+			return
+		}
+		for i := 0; i < grid[d]; i++ {
+			idx[d] = i
+			walk(d + 1)
+		}
+	}
+	walk(0)
+}
+
+func clutHeader(grid []int, precision byte) []byte {
+	h := make([]byte, 20)
+	for i, g := range grid {
+		h[i] = byte(g)
+	}
+	h[16] = precision
+	return h
+}
+
+func para(typ int, params ...float64) []byte {
+	b := []byte("para\x00\x00\x00\x00")
+	b = be.AppendUint16(b, uint16(typ))
+	b = append(b, 0, 0)
+	for _, p := range params {
+		b = append(b, s15(p)...)
+	}
+	return b
+}
+
+// curvTable is a curv element; nil gives the identity.
+func curvTable(t []float64) []byte {
+	b := []byte("curv\x00\x00\x00\x00")
+	b = be.AppendUint32(b, uint32(len(t)))
+	for _, v := range t {
+		b = be.AppendUint16(b, uint16(math.Round(v*65535)))
+	}
+	return b
+}
+
+// curvGamma is a single-entry curv element holding a u8Fixed8 gamma.
+func curvGamma(g float64) []byte {
+	b := []byte("curv\x00\x00\x00\x00\x00\x00\x00\x01")
+	return be.AppendUint16(b, uint16(math.Round(g*256)))
+}
+
+func curves(cs ...[]byte) []byte {
+	var b []byte
+	for _, c := range cs {
+		b = pad(append(b, c...))
+	}
+	return b
+}
+
+func matrixElement(m [12]float64) []byte {
+	var b []byte
+	for _, v := range m {
+		b = append(b, s15(v)...)
+	}
+	return b
+}
+
+// mab lays out an mAB or mBA tag with its elements in B, matrix, M, CLUT,
+// A order.
+func mab(sig string, in, out int, b, matrix, m, clut, a []byte) []byte {
+	buf := []byte(sig + "\x00\x00\x00\x00")
+	buf = append(buf, byte(in), byte(out), 0, 0)
+	buf = append(buf, make([]byte, 20)...)
+	for i, e := range [][]byte{b, matrix, m, clut, a} {
+		be.PutUint32(buf[12+4*i:], uint32(len(buf)))
+		buf = pad(append(buf, e...))
+	}
+	return buf
+}
+
+func pad(b []byte) []byte {
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func s15(v float64) []byte {
+	return be.AppendUint32(nil, uint32(int32(math.Round(v*65536))))
+}
+
+func desc(text string) []byte {
+	b := []byte("desc\x00\x00\x00\x00")
+	b = be.AppendUint32(b, uint32(len(text)+1))
+	b = append(b, text...)
+	b = append(b, 0)
+	return append(b, make([]byte, 4+4+2+1+67)...)
+}
+
+func xyz(x, y, z float64) []byte {
+	b := []byte("XYZ \x00\x00\x00\x00")
+	for _, v := range []float64{x, y, z} {
+		b = append(b, s15(v)...)
+	}
+	return b
+}
+
+func write(name string, version uint32, description string, aToB, bToA []byte) {
+	tags := []struct {
+		sig  string
+		data []byte
+	}{
+		{"desc", desc(description)},
+		{"cprt", []byte("text\x00\x00\x00\x00No copyright, use freely\x00")},
+		{"wtpt", xyz(0.9642, 1, 0.8249)},
+		{"A2B0", aToB},
+		{"B2A0", bToA},
+	}
+	hdr := make([]byte, 128)
+	be.PutUint32(hdr[8:], version)
+	copy(hdr[12:], "prtrCMYKLab ")
+	be.PutUint16(hdr[24:], 2026)
+	be.PutUint16(hdr[26:], 1)
+	be.PutUint16(hdr[28:], 1)
+	copy(hdr[36:], "acsp")
+	copy(hdr[68:], xyz(0.9642, 1, 0.8249)[8:])
+
+	table := be.AppendUint32(nil, uint32(len(tags)))
+	body := []byte{}
+	off := 128 + 4 + 12*len(tags)
+	for _, t := range tags {
+		table = append(table, t.sig...)
+		table = be.AppendUint32(table, uint32(off+len(body)))
+		table = be.AppendUint32(table, uint32(len(t.data)))
+		body = pad(append(body, t.data...))
+	}
+	data := append(append(hdr, table...), body...)
+	be.PutUint32(data[0:], uint32(len(data)))
+	if err := os.WriteFile(filepath.Join("testdata", name), data, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
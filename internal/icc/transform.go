@@ -0,0 +1,416 @@
+package icc
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Rendering intents, numbered as in the ICC specification.
+const (
+	Perceptual           = 0
+	RelativeColorimetric = 1
+	Saturation           = 2
+	AbsoluteColorimetric = 3
+)
+
+// perceptualBlack is the PCS black point of the v4 perceptual reference
+// medium.
+var perceptualBlack = XYZ{0.00336, 0.0034731, 0.00287}
+
+// tableIntent returns the tag number used for intent: absolute
+// colorimetric is built on the relative colorimetric tables.
+func tableIntent(intent int) int {
+	if intent == AbsoluteColorimetric {
+		return RelativeColorimetric
+	}
+	return intent
+}
+
+// SupportsIntent reports whether the profile has tables for intent in the
+// given direction (toPCS for device→PCS), or a matrix/TRC model, which
+// serves every intent.
+func (p *Profile) SupportsIntent(intent int, toPCS bool) bool {
+	if p.isMatrixShaper() {
+		return true
+	}
+	if p.Class == "link" {
+		return p.HasTag(fmt.Sprintf("A2B%d", tableIntent(intent)))
+	}
+	prefix := "B2A"
+	if toPCS {
+		prefix = "A2B"
+	}
+	return p.HasTag(fmt.Sprintf("%s%d", prefix, tableIntent(intent)))
+}
+
+// readLUT returns the tag for intent with the given prefix, falling back to
+// the intent 0 table as the ICC specification requires.
+func (p *Profile) readLUT(prefix string, intent int) (*lut, error) {
+	sig := fmt.Sprintf("%s%d", prefix, tableIntent(intent))
+	b, ok := p.tags[sig]
+	if !ok {
+		sig = prefix + "0"
+		if b, ok = p.tags[sig]; !ok {
+			return nil, nil
+		}
+	}
+	l, err := parseLUT(b, p.PCS, prefix == "B2A" && p.PCS == "XYZ ")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", sig, err)
+	}
+	return l, nil
+}
+
+// pcsFunc maps between device values and PCS components, which are XYZ or
+// Lab depending on the profile.
+type pcsFunc func([]float64) [3]float64
+
+// toPCS returns the device→PCS function for intent.
+func (p *Profile) toPCS(intent int) (pcsFunc, error) {
+	l, err := p.readLUT("A2B", intent)
+	if err != nil {
+		return nil, err
+	}
+	if l != nil {
+		if l.in != Channels(p.ColorSpace) || l.out != 3 {
+			return nil, errors.New("A2B tag does not match the profile colour spaces")
+		}
+		return func(v []float64) [3]float64 { return l.enc.decode(l.eval(v)) }, nil
+	}
+	if !p.isMatrixShaper() {
+		return nil, errors.New("profile has neither A2B tables nor a matrix/TRC model")
+	}
+	m, trc, err := p.matrixShaper()
+	if err != nil {
+		return nil, err
+	}
+	return func(v []float64) [3]float64 {
+		r, g, b := trc[0].eval(v[0]), trc[1].eval(v[1]), trc[2].eval(v[2])
+		return [3]float64{
+			m[0]*r + m[1]*g + m[2]*b,
+			m[3]*r + m[4]*g + m[5]*b,
+			m[6]*r + m[7]*g + m[8]*b,
+		}
+	}, nil
+}
+
+// fromPCS returns the PCS→device function for intent.
+func (p *Profile) fromPCS(intent int) (func([3]float64) []float64, error) {
+	l, err := p.readLUT("B2A", intent)
+	if err != nil {
+		return nil, err
+	}
+	if l != nil {
+		if l.in != 3 || l.out != Channels(p.ColorSpace) {
+			return nil, errors.New("B2A tag does not match the profile colour spaces")
+		}
+		return func(c [3]float64) []float64 { return l.eval(l.enc.encode(c)) }, nil
+	}
+	if !p.isMatrixShaper() {
+		return nil, errors.New("profile has neither B2A tables nor a matrix/TRC model")
+	}
+	m, trc, err := p.matrixShaper()
+	if err != nil {
+		return nil, err
+	}
+	inv, ok := invert3(m)
+	if !ok {
+		return nil, errors.New("matrix/TRC colorant matrix is singular")
+	}
+	for _, c := range trc {
+		c.invert(0) // build the inverse table now, not concurrently later
+	}
+	return func(c [3]float64) []float64 {
+		out := make([]float64, 3)
+		for i := range out {
+			v := inv[3*i]*c[0] + inv[3*i+1]*c[1] + inv[3*i+2]*c[2]
+			out[i] = trc[i].invert(v)
+		}
+		return out
+	}, nil
+}
+
+func (p *Profile) matrixShaper() ([9]float64, [3]*curve, error) {
+	var m [9]float64
+	var trc [3]*curve
+	for i, ch := range []string{"r", "g", "b"} {
+		xyz, err := parseXYZ(p.tags[ch+"XYZ"])
+		if err != nil {
+			return m, trc, fmt.Errorf("%sXYZ: %w", ch, err)
+		}
+		m[i], m[3+i], m[6+i] = xyz.X, xyz.Y, xyz.Z
+		c, _, err := parseCurve(p.tags[ch+"TRC"])
+		if err != nil {
+			return m, trc, fmt.Errorf("%sTRC: %w", ch, err)
+		}
+		trc[i] = c
+	}
+	return m, trc, nil
+}
+
+func invert3(m [9]float64) ([9]float64, bool) {
+	det := m[0]*(m[4]*m[8]-m[5]*m[7]) - m[1]*(m[3]*m[8]-m[5]*m[6]) + m[2]*(m[3]*m[7]-m[4]*m[6])
+	if math.Abs(det) < 1e-12 {
+		return [9]float64{}, false
+	}
+	return [9]float64{
+		(m[4]*m[8] - m[5]*m[7]) / det, (m[2]*m[7] - m[1]*m[8]) / det, (m[1]*m[5] - m[2]*m[4]) / det,
+		(m[5]*m[6] - m[3]*m[8]) / det, (m[0]*m[8] - m[2]*m[6]) / det, (m[2]*m[3] - m[0]*m[5]) / det,
+		(m[3]*m[7] - m[4]*m[6]) / det, (m[1]*m[6] - m[0]*m[7]) / det, (m[0]*m[4] - m[1]*m[3]) / det,
+	}, true
+}
+
+func (p *Profile) pcsToXYZ(c [3]float64) XYZ {
+	if p.PCS == "Lab " {
+		return Lab{c[0], c[1], c[2]}.ToXYZ()
+	}
+	return XYZ{c[0], c[1], c[2]}
+}
+
+func (p *Profile) xyzToPCS(x XYZ) [3]float64 {
+	if p.PCS == "Lab " {
+		l := x.ToLab()
+		return [3]float64{l.L, l.A, l.B}
+	}
+	return [3]float64{x.X, x.Y, x.Z}
+}
+
+// ToLab returns a function converting device values (normalised 0–1) to
+// D50 Lab through the profile's tables for intent. Absolute colorimetric
+// results are scaled to the media white.
+func (p *Profile) ToLab(intent int) (func([]float64) Lab, error) {
+	if p.Class == "link" || p.Class == "abst" {
+		return nil, fmt.Errorf("%q profiles have no device→Lab mapping", p.Class)
+	}
+	f, err := p.toPCS(intent)
+	if err != nil {
+		return nil, err
+	}
+	return func(v []float64) Lab {
+		x := p.pcsToXYZ(f(v))
+		if intent == AbsoluteColorimetric {
+			x = XYZ{x.X * p.MediaWhite.X / D50.X, x.Y * p.MediaWhite.Y / D50.Y, x.Z * p.MediaWhite.Z / D50.Z}
+		}
+		return x.ToLab()
+	}, nil
+}
+
+// Transform is a device→device colour transform between two profiles, or
+// through a DeviceLink.
+type Transform struct {
+	In, Out int // channel counts
+	Intent  int // intent in effect
+	eval    func([]float64) []float64
+	grid    *clut // set by Optimize
+}
+
+// NewTransform links src and dst for intent. When dst is a DeviceLink, src
+// is ignored and may be nil. If either profile lacks tables for the intent
+// in the needed direction, relative colorimetric is used instead; Intent
+// reports the result.
+//
+// As in lcms2, black point compensation is applied for the perceptual and
+// saturation intents when either profile is version 4.
+func NewTransform(src, dst *Profile, intent int) (*Transform, error) {
+	if intent < Perceptual || intent > AbsoluteColorimetric {
+		return nil, fmt.Errorf("unsupported rendering intent %d", intent)
+	}
+	if dst.Class == "link" {
+		if !dst.SupportsIntent(intent, true) {
+			intent = RelativeColorimetric
+		}
+		l, err := dst.readLUT("A2B", intent)
+		if err != nil {
+			return nil, err
+		}
+		if l == nil {
+			return nil, errors.New("DeviceLink has no A2B0 tag")
+		}
+		return &Transform{In: l.in, Out: l.out, Intent: intent, eval: l.eval}, nil
+	}
+	if src == nil {
+		return nil, errors.New("no source profile")
+	}
+	if !src.SupportsIntent(intent, true) || !dst.SupportsIntent(intent, false) {
+		intent = RelativeColorimetric
+	}
+	fwd, err := src.toPCS(intent)
+	if err != nil {
+		return nil, fmt.Errorf("source profile: %w", err)
+	}
+	inv, err := dst.fromPCS(intent)
+	if err != nil {
+		return nil, fmt.Errorf("destination profile: %w", err)
+	}
+
+	// Per-channel XYZ scale and offset applied between the profiles.
+	scale, offset := [3]float64{1, 1, 1}, [3]float64{}
+	switch {
+	case intent == AbsoluteColorimetric:
+		sw, dw := src.MediaWhite, dst.MediaWhite
+		scale = [3]float64{sw.X / dw.X, sw.Y / dw.Y, sw.Z / dw.Z}
+	case (intent == Perceptual || intent == Saturation) &&
+		(src.Version >= 0x04000000 || dst.Version >= 0x04000000):
+		bs, bd := src.blackPoint(intent, true), dst.blackPoint(intent, false)
+		scale, offset = bpc(bs, bd)
+	}
+	identity := scale == [3]float64{1, 1, 1} && offset == [3]float64{}
+
+	t := &Transform{In: Channels(src.ColorSpace), Out: Channels(dst.ColorSpace), Intent: intent}
+	t.eval = func(v []float64) []float64 {
+		c := fwd(v)
+		if src.PCS != dst.PCS || !identity {
+			x := src.pcsToXYZ(c)
+			if !identity {
+				x = XYZ{x.X*scale[0] + offset[0], x.Y*scale[1] + offset[1], x.Z*scale[2] + offset[2]}
+			}
+			c = dst.xyzToPCS(x)
+		}
+		return inv(c)
+	}
+	return t, nil
+}
+
+// Eval converts one colour. Inputs and outputs are normalised to 0–1.
+func (t *Transform) Eval(in []float64) []float64 {
+	out := make([]float64, t.Out)
+	t.EvalTo(in, out)
+	return out
+}
+
+// EvalTo is Eval writing into out, which must hold t.Out values. It is safe
+// for concurrent use.
+func (t *Transform) EvalTo(in, out []float64) {
+	if t.grid != nil {
+		t.grid.eval(in, out)
+	} else {
+		copy(out, t.eval(in))
+	}
+	for i := range out {
+		out[i] = clamp01(out[i])
+	}
+}
+
+// Optimize replaces the transform with a lookup table sampled at the given
+// number of points per input, interpolated like a profile CLUT. It trades a
+// small loss of accuracy for speed on 8-bit data, as CMMs do.
+func (t *Transform) Optimize(points int) error {
+	grid := make([]int, t.In)
+	for i := range grid {
+		grid[i] = points
+	}
+	c, err := newCLUT(grid, t.Out)
+	if err != nil {
+		return err
+	}
+	in := make([]float64, t.In)
+	for n := 0; n*t.Out < len(c.data); n++ {
+		rem := n
+		for i := t.In - 1; i >= 0; i-- {
+			in[i] = float64(rem%points) / float64(points-1)
+			rem /= points
+		}
+		copy(c.data[n*t.Out:], t.eval(in))
+	}
+	t.grid = c
+	return nil
+}
+
+// NewLabTransform returns a transform from the profile's device space to
+// D50 Lab, encoded as in ICC v4: L/100, (a+128)/255 and (b+128)/255.
+// Absolute colorimetric results are relative to the media white.
+func NewLabTransform(p *Profile, intent int) (*Transform, error) {
+	if intent < Perceptual || intent > AbsoluteColorimetric {
+		return nil, fmt.Errorf("unsupported rendering intent %d", intent)
+	}
+	if !p.SupportsIntent(intent, true) {
+		intent = RelativeColorimetric
+	}
+	f, err := p.ToLab(intent)
+	if err != nil {
+		return nil, err
+	}
+	return &Transform{In: Channels(p.ColorSpace), Out: 3, Intent: intent, eval: func(v []float64) []float64 {
+		return encLabV4.encode(f(v).components())
+	}}, nil
+}
+
+// DecodeLab converts v4-encoded Lab from a NewLabTransform back to L*, a*,
+// b*.
+func DecodeLab(v []float64) Lab {
+	c := encLabV4.decode(v)
+	return Lab{c[0], c[1], c[2]}
+}
+
+func (c Lab) components() [3]float64 {
+	return [3]float64{c.L, c.A, c.B}
+}
+
+// bpc returns the per-channel XYZ mapping that takes black point bs to bd
+// while keeping the D50 white fixed.
+func bpc(bs, bd XYZ) (scale, offset [3]float64) {
+	w := [3]float64{D50.X, D50.Y, D50.Z}
+	s := [3]float64{bs.X, bs.Y, bs.Z}
+	d := [3]float64{bd.X, bd.Y, bd.Z}
+	for i := range w {
+		scale[i] = (w[i] - d[i]) / (w[i] - s[i])
+		offset[i] = w[i] * (1 - scale[i])
+	}
+	return scale, offset
+}
+
+// blackPoint estimates the profile's black point for intent, following
+// lcms2. v4 LUT profiles use the perceptual reference medium black. Source
+// profiles and matrix/TRC profiles measure their darkest device colour.
+// LUT destinations are measured by a round trip through the intent's B2A
+// and the colorimetric A2B: the black point is where the round trip stops
+// clipping to the device black. Chroma is removed in every case.
+func (p *Profile) blackPoint(intent int, input bool) XYZ {
+	if p.Version >= 0x04000000 && !p.isMatrixShaper() {
+		return perceptualBlack
+	}
+	if p.isMatrixShaper() {
+		input, intent = true, RelativeColorimetric
+	}
+	var lab Lab
+	if input {
+		var dark []float64
+		switch p.ColorSpace {
+		case "RGB ":
+			dark = []float64{0, 0, 0}
+		case "CMYK":
+			dark = []float64{1, 1, 1, 1}
+		default:
+			return XYZ{}
+		}
+		f, err := p.toPCS(intent)
+		if err != nil {
+			return XYZ{}
+		}
+		lab = p.pcsToXYZ(f(dark)).ToLab()
+	} else {
+		inv, err := p.fromPCS(intent)
+		if err != nil {
+			return XYZ{}
+		}
+		fwd, err := p.toPCS(RelativeColorimetric)
+		if err != nil {
+			return XYZ{}
+		}
+		roundTrip := func(l float64) float64 {
+			x := Lab{L: l}.ToXYZ()
+			return p.pcsToXYZ(fwd(inv(p.xyzToPCS(x)))).ToLab().L
+		}
+		black := roundTrip(0)
+		for l := 0.5; l <= 50; l += 0.5 {
+			if roundTrip(l) > black+1 {
+				break
+			}
+			lab.L = l
+		}
+	}
+	lab.A, lab.B = 0, 0
+	lab.L = math.Max(0, math.Min(lab.L, 50))
+	return lab.ToXYZ()
+}
@@ -0,0 +1,127 @@
+// Package inks measures ink coverage of CMYK pixel data and estimates ink
+// consumption for a printed size.
+package inks
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PlateNames lists the plates in pixel order.
+var PlateNames = [4]string{"cyan", "magenta", "yellow", "black"}
+
+// HistogramBins is the number of 10% coverage bands per plate.
+const HistogramBins = 10
+
+// Plate holds coverage statistics for one separation.
+type Plate struct {
+	Name      string                 `json:"name"`
+	Average   float64                `json:"average"`   // mean coverage, percent
+	Histogram [HistogramBins]float64 `json:"histogram"` // share of pixels per 10% band, percent
+}
+
+// Report summarises the ink coverage of an image.
+type Report struct {
+	Width          int      `json:"width"`
+	Height         int      `json:"height"`
+	Plates         [4]Plate `json:"plates"`
+	TACMax         float64  `json:"tac_max"`         // percent
+	TACP99         float64  `json:"tac_p99"`         // percent
+	Threshold      float64  `json:"threshold"`       // TAC threshold, percent
+	AboveThreshold float64  `json:"above_threshold"` // share of pixels over Threshold, percent
+}
+
+// Analyze computes coverage statistics for width×height interleaved CMYK
+// pixels (0 = no ink). Pixels whose total area coverage exceeds threshold
+// (percent) are counted in AboveThreshold.
+func Analyze(pixels []byte, width, height int, threshold float64) (*Report, error) {
+	n := width * height
+	if n <= 0 || len(pixels) != n*4 {
+		return nil, fmt.Errorf("expected %d CMYK bytes for %dx%d, got %d", n*4, width, height, len(pixels))
+	}
+
+	var sums [4]uint64
+	var bins [4][HistogramBins]uint64
+	var tacHist [4*255 + 1]uint64
+	for i := 0; i < len(pixels); i += 4 {
+		tac := 0
+		for p := 0; p < 4; p++ {
+			v := int(pixels[i+p])
+			sums[p] += uint64(v)
+			b := v * HistogramBins / 256
+			bins[p][b]++
+			tac += v
+		}
+		tacHist[tac]++
+	}
+
+	r := &Report{Width: width, Height: height, Threshold: threshold}
+	for p := range r.Plates {
+		r.Plates[p].Name = PlateNames[p]
+		r.Plates[p].Average = float64(sums[p]) / float64(n) / 255 * 100
+		for b := range bins[p] {
+			r.Plates[p].Histogram[b] = float64(bins[p][b]) / float64(n) * 100
+		}
+	}
+
+	var seen, above uint64
+	p99 := -1
+	for tac := range tacHist {
+		c := tacHist[tac]
+		if c == 0 {
+			continue
+		}
+		pct := float64(tac) / 255 * 100
+		r.TACMax = pct
+		seen += c
+		if p99 < 0 && float64(seen) >= 0.99*float64(n) {
+			p99 = tac
+		}
+		if pct > threshold {
+			above += c
+		}
+	}
+	r.TACP99 = float64(p99) / 255 * 100
+	r.AboveThreshold = float64(above) / float64(n) * 100
+	return r, nil
+}
+
+// Usage is the estimated ink consumption of one plate.
+type Usage struct {
+	Name  string  `json:"name"`
+	Rate  float64 `json:"rate"`  // g/m² at 100% coverage
+	Grams float64 `json:"grams"` // estimated ink for one copy
+}
+
+// Estimate returns the grams of each ink needed to print the image at
+// widthMM×heightMM, given consumption rates in g/m² at 100% coverage.
+func (r *Report) Estimate(widthMM, heightMM float64, rates [4]float64) [4]Usage {
+	area := widthMM * heightMM / 1e6
+	var u [4]Usage
+	for p := range u {
+		u[p] = Usage{
+			Name:  PlateNames[p],
+			Rate:  rates[p],
+			Grams: r.Plates[p].Average / 100 * area * rates[p],
+		}
+	}
+	return u
+}
+
+// ParseRates parses four comma-separated C,M,Y,K consumption rates in g/m².
+func ParseRates(s string) ([4]float64, error) {
+	var rates [4]float64
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return rates, fmt.Errorf("ink rates %q: expected four values C,M,Y,K", s)
+	}
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil || v < 0 {
+			return rates, fmt.Errorf("ink rates %q: invalid value %q", s, p)
+		}
+		rates[i] = v
+	}
+	return rates, nil
+}
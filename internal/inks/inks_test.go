@@ -0,0 +1,66 @@
+package inks
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	// 100 pixels: 90 paper white, 10 at 100/100/100/100.
+	pixels := make([]byte, 100*4)
+	for i := 90 * 4; i < len(pixels); i++ {
+		pixels[i] = 255
+	}
+	r, err := Analyze(pixels, 10, 10, 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range r.Plates {
+		if math.Abs(p.Average-10) > 1e-9 {
+			t.Errorf("%s average = %v, want 10", p.Name, p.Average)
+		}
+		if p.Histogram[0] != 90 || p.Histogram[HistogramBins-1] != 10 {
+			t.Errorf("%s histogram = %v", p.Name, p.Histogram)
+		}
+	}
+	if r.TACMax != 400 {
+		t.Errorf("TACMax = %v, want 400", r.TACMax)
+	}
+	if r.TACP99 != 400 {
+		t.Errorf("TACP99 = %v, want 400", r.TACP99)
+	}
+	if r.AboveThreshold != 10 {
+		t.Errorf("AboveThreshold = %v, want 10", r.AboveThreshold)
+	}
+
+	if _, err := Analyze(pixels[:10], 10, 10, 300); err == nil {
+		t.Error("expected error for short pixel data")
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	pixels := make([]byte, 4)
+	pixels[3] = 255 // solid black
+	r, err := Analyze(pixels, 1, 1, 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1 m² of solid black at 1.5 g/m².
+	u := r.Estimate(1000, 1000, [4]float64{1, 1, 1, 1.5})
+	if u[3].Grams != 1.5 || u[0].Grams != 0 {
+		t.Errorf("usage = %+v", u)
+	}
+}
+
+func TestParseRates(t *testing.T) {
+	rates, err := ParseRates("1.2, 1.2,1.3,1.5")
+	if err != nil || rates != [4]float64{1.2, 1.2, 1.3, 1.5} {
+		t.Errorf("got %v, %v", rates, err)
+	}
+	for _, bad := range []string{"1,2,3", "a,b,c,d", "1,1,1,-1"} {
+		if _, err := ParseRates(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
@@ -0,0 +1,476 @@
+package jpeg
+
+/*
+#cgo pkg-config: libjpeg
+#include <stdio.h>
+#include <stdlib.h>
+#include <string.h>
+#include <jpeglib.h>
+#include <setjmp.h>
+
+typedef struct {
+    struct jpeg_error_mgr pub;
+    jmp_buf               jmpbuf;
+    char                  msg[JMSG_LENGTH_MAX];
+} coef_err_mgr;
+
+static void coef_error_exit(j_common_ptr cinfo) {
+    coef_err_mgr *e = (coef_err_mgr *)cinfo->err;
+    (*(cinfo->err->format_message))(cinfo, e->msg);
+    longjmp(e->jmpbuf, 1);
+}
+
+// From encoder.go.
+void set_scan_script(j_compress_ptr cinfo, const int *scans, int num_scans);
+
+typedef struct {
+    int            marker;
+    unsigned char *data;
+    unsigned int   len;
+} coef_marker;
+
+typedef struct {
+    int            width;
+    int            height;
+    int            ycck;
+    int            adobe;
+    int            progressive;
+    int            density_unit; // JFIF density, unit and values 0 if absent
+    int            x_density;
+    int            y_density;
+    int            num_tables;
+    unsigned int   qtables[4 * 64];
+    int            slots[4];
+    short         *coefs;        // 4 planes of ceil(w/8) * ceil(h/8) blocks
+    unsigned long  num_coefs;
+    int            has_error;
+    char           error_msg[256];
+} coef_read_result;
+
+// read_cmyk_coefficients reads the quantized DCT coefficients of a CMYK or
+// YCCK JPEG whose components share one sampling factor, with every APPn
+// and COM marker in file order.
+static coef_read_result read_cmyk_coefficients(const unsigned char *buf, unsigned long buf_size,
+                                               coef_marker *markers, int max_markers, int *marker_count) {
+    coef_read_result res;
+    memset(&res, 0, sizeof(res));
+    *marker_count = 0;
+
+    struct jpeg_decompress_struct cinfo;
+    coef_err_mgr jerr;
+
+    cinfo.err = jpeg_std_error(&jerr.pub);
+    jerr.pub.error_exit = coef_error_exit;
+
+    if (setjmp(jerr.jmpbuf)) {
+        strncpy(res.error_msg, jerr.msg, sizeof(res.error_msg)-1);
+        res.has_error = 1;
+        jpeg_destroy_decompress(&cinfo);
+        free(res.coefs);
+        res.coefs = NULL;
+        return res;
+    }
+
+    jpeg_create_decompress(&cinfo);
+    for (int m = 0; m < 16; m++) {
+        jpeg_save_markers(&cinfo, JPEG_APP0 + m, 0xFFFF);
+    }
+    jpeg_save_markers(&cinfo, JPEG_COM, 0xFFFF);
+    jpeg_mem_src(&cinfo, (unsigned char *)buf, buf_size);
+    jpeg_read_header(&cinfo, TRUE);
+
+    if ((cinfo.jpeg_color_space != JCS_CMYK && cinfo.jpeg_color_space != JCS_YCCK) || cinfo.num_components != 4) {
+        strncpy(res.error_msg, "not a CMYK JPEG", sizeof(res.error_msg)-1);
+        res.has_error = 1;
+        jpeg_destroy_decompress(&cinfo);
+        return res;
+    }
+    for (int c = 1; c < 4; c++) {
+        if (cinfo.comp_info[c].h_samp_factor != cinfo.comp_info[0].h_samp_factor ||
+            cinfo.comp_info[c].v_samp_factor != cinfo.comp_info[0].v_samp_factor) {
+            strncpy(res.error_msg, "subsampled components are not supported", sizeof(res.error_msg)-1);
+            res.has_error = 1;
+            jpeg_destroy_decompress(&cinfo);
+            return res;
+        }
+    }
+
+    jvirt_barray_ptr *arrays = jpeg_read_coefficients(&cinfo);
+
+    res.width = cinfo.image_width;
+    res.height = cinfo.image_height;
+    res.ycck = cinfo.jpeg_color_space == JCS_YCCK;
+    res.adobe = cinfo.saw_Adobe_marker;
+    res.progressive = cinfo.progressive_mode;
+    if (cinfo.saw_JFIF_marker) {
+        res.density_unit = cinfo.density_unit;
+        res.x_density = cinfo.X_density;
+        res.y_density = cinfo.Y_density;
+    }
+
+    // With one sampling factor for all components every plane has
+    // ceil(w/8) x ceil(h/8) blocks.
+    JDIMENSION bw = cinfo.comp_info[0].width_in_blocks;
+    JDIMENSION bh = cinfo.comp_info[0].height_in_blocks;
+    unsigned long plane = (unsigned long)bw * bh * DCTSIZE2;
+    res.num_coefs = plane * 4;
+    res.coefs = (short *)malloc(res.num_coefs * sizeof(short));
+    if (res.coefs == NULL) {
+        strncpy(res.error_msg, "malloc failed for coefficient buffer", sizeof(res.error_msg)-1);
+        res.has_error = 1;
+        jpeg_destroy_decompress(&cinfo);
+        return res;
+    }
+    for (int c = 0; c < 4; c++) {
+        for (JDIMENSION row = 0; row < bh; row++) {
+            JBLOCKARRAY blocks = (*cinfo.mem->access_virt_barray)((j_common_ptr)&cinfo, arrays[c], row, 1, FALSE);
+            for (JDIMENSION col = 0; col < bw; col++) {
+                short *dst = res.coefs + c * plane + ((unsigned long)row * bw + col) * DCTSIZE2;
+                for (int i = 0; i < DCTSIZE2; i++) {
+                    dst[i] = blocks[0][col][i];
+                }
+            }
+        }
+    }
+
+    // Quantization tables, renumbered in order of use.
+    int map[NUM_QUANT_TBLS];
+    for (int t = 0; t < NUM_QUANT_TBLS; t++) map[t] = -1;
+    for (int c = 0; c < 4; c++) {
+        int no = cinfo.comp_info[c].quant_tbl_no;
+        if (map[no] < 0) {
+            JQUANT_TBL *q = cinfo.comp_info[c].quant_table;
+            if (q == NULL) q = cinfo.quant_tbl_ptrs[no];
+            map[no] = res.num_tables++;
+            for (int i = 0; i < DCTSIZE2; i++) {
+                res.qtables[map[no] * DCTSIZE2 + i] = q->quantval[i];
+            }
+        }
+        res.slots[c] = map[no];
+    }
+
+    jpeg_saved_marker_ptr m = cinfo.marker_list;
+    int count = 0;
+    while (m != NULL && count < max_markers) {
+        markers[count].marker = m->marker;
+        markers[count].data = (unsigned char *)malloc(m->data_length > 0 ? m->data_length : 1);
+        if (markers[count].data != NULL) {
+            memcpy(markers[count].data, m->data, m->data_length);
+            markers[count].len = m->data_length;
+            count++;
+        }
+        m = m->next;
+    }
+    *marker_count = count;
+
+    jpeg_finish_decompress(&cinfo);
+    jpeg_destroy_decompress(&cinfo);
+    return res;
+}
+
+static void free_coef_markers(coef_marker *markers, int count) {
+    for (int i = 0; i < count; i++) {
+        free(markers[i].data);
+    }
+}
+
+static void free_coefs(short *coefs) {
+    free(coefs);
+}
+
+typedef struct {
+    unsigned char *buf;
+    unsigned long  size;
+    int            has_error;
+    char           error_msg[256];
+} coef_write_result;
+
+// write_cmyk_coefficients writes 4 planes of ceil(w/8) x ceil(h/8)
+// coefficient blocks as a CMYK or YCCK JPEG with the given quantization
+// tables, Huffman tables optimized for the data, and the scan script if
+// num_scans > 0. The num_markers markers are concatenated in data, with
+// their codes and lengths in codes and lens, and follow the JFIF and
+// Adobe markers libjpeg writes.
+static coef_write_result write_cmyk_coefficients(
+    int width, int height, const short *coefs,
+    const unsigned int *qtables, int num_tables, const int *slots,
+    const unsigned char *data, const int *codes, const unsigned int *lens, int num_markers,
+    const int *scans, int num_scans,
+    int ycck, int adobe, int density_unit, int x_density, int y_density
+) {
+    coef_write_result res;
+    memset(&res, 0, sizeof(res));
+
+    struct jpeg_compress_struct cinfo;
+    coef_err_mgr jerr;
+
+    cinfo.err = jpeg_std_error(&jerr.pub);
+    jerr.pub.error_exit = coef_error_exit;
+
+    if (setjmp(jerr.jmpbuf)) {
+        strncpy(res.error_msg, jerr.msg, sizeof(res.error_msg)-1);
+        res.has_error = 1;
+        jpeg_destroy_compress(&cinfo);
+        free(res.buf);
+        res.buf = NULL;
+        return res;
+    }
+
+    jpeg_create_compress(&cinfo);
+    jpeg_mem_dest(&cinfo, &res.buf, &res.size);
+
+    cinfo.image_width = width;
+    cinfo.image_height = height;
+    cinfo.input_components = 4;
+    cinfo.in_color_space = JCS_CMYK;
+    jpeg_set_defaults(&cinfo);
+    jpeg_set_colorspace(&cinfo, ycck ? JCS_YCCK : JCS_CMYK);
+    cinfo.optimize_coding = TRUE;
+    cinfo.write_Adobe_marker = adobe ? TRUE : FALSE;
+    if (x_density > 0 && y_density > 0) {
+        cinfo.write_JFIF_header = TRUE;
+        cinfo.density_unit = (UINT8)density_unit;
+        cinfo.X_density = (UINT16)x_density;
+        cinfo.Y_density = (UINT16)y_density;
+    }
+
+    for (int t = 0; t < num_tables; t++) {
+        if (cinfo.quant_tbl_ptrs[t] == NULL)
+            cinfo.quant_tbl_ptrs[t] = jpeg_alloc_quant_table((j_common_ptr)&cinfo);
+        for (int i = 0; i < DCTSIZE2; i++) {
+            cinfo.quant_tbl_ptrs[t]->quantval[i] = (UINT16)qtables[t * DCTSIZE2 + i];
+        }
+    }
+    for (int c = 0; c < 4; c++) {
+        cinfo.comp_info[c].h_samp_factor = 1;
+        cinfo.comp_info[c].v_samp_factor = 1;
+        cinfo.comp_info[c].quant_tbl_no = slots[c];
+    }
+    set_scan_script(&cinfo, scans, num_scans);
+
+    JDIMENSION bw = (width + DCTSIZE - 1) / DCTSIZE;
+    JDIMENSION bh = (height + DCTSIZE - 1) / DCTSIZE;
+    jvirt_barray_ptr arrays[4];
+    for (int c = 0; c < 4; c++) {
+        arrays[c] = (*cinfo.mem->request_virt_barray)((j_common_ptr)&cinfo, JPOOL_IMAGE, FALSE, bw, bh, 1);
+    }
+
+    jpeg_write_coefficients(&cinfo, arrays);
+
+    for (int i = 0; i < num_markers; i++) {
+        jpeg_write_marker(&cinfo, codes[i], data, lens[i]);
+        data += lens[i];
+    }
+
+    unsigned long plane = (unsigned long)bw * bh * DCTSIZE2;
+    for (int c = 0; c < 4; c++) {
+        for (JDIMENSION row = 0; row < bh; row++) {
+            JBLOCKARRAY blocks = (*cinfo.mem->access_virt_barray)((j_common_ptr)&cinfo, arrays[c], row, 1, TRUE);
+            for (JDIMENSION col = 0; col < bw; col++) {
+                const short *src = coefs + c * plane + ((unsigned long)row * bw + col) * DCTSIZE2;
+                for (int i = 0; i < DCTSIZE2; i++) {
+                    blocks[0][col][i] = src[i];
+                }
+            }
+        }
+    }
+
+    jpeg_finish_compress(&cinfo);
+    jpeg_destroy_compress(&cinfo);
+    return res;
+}
+
+static void free_coef_buf(unsigned char *buf) {
+    free(buf);
+}
+*/
+import "C"
+
+import (
+	"bytes"
+	"fmt"
+	"unsafe"
+)
+
+// Marker is a saved APPn or COM marker segment.
+type Marker struct {
+	Code int    // marker code, e.g. 0xE1 for APP1
+	Data []byte // payload, without the length
+}
+
+// Marker codes used when rewriting saved markers.
+const (
+	MarkerAPP0  = 0xE0
+	MarkerAPP1  = 0xE1
+	MarkerAPP2  = 0xE2
+	MarkerAPP13 = 0xED
+	MarkerAPP14 = 0xEE
+	MarkerCOM   = 0xFE
+)
+
+// Coefficients is a CMYK or YCCK JPEG held as its quantized DCT
+// coefficients, so it can be rotated, flipped, cropped and rewritten
+// without decoding. Each component is a plane of ceil(Width/8) by
+// ceil(Height/8) blocks, row by row, with 64 coefficients per block in
+// natural (not zigzag) order.
+type Coefficients struct {
+	Width, Height int
+	Planes        [4][]int16
+	QuantTables   [][64]uint16 // in natural order
+	Slots         [4]int       // table of each component
+	Convention    Convention   // AdobeInverted if the file had an Adobe APP14 marker
+	YCCK          bool         // components are Y, Cb, Cr and K
+	Progressive   bool         // the source was progressive
+	Markers       []Marker     // APPn and COM markers in file order, except JFIF and Adobe ones
+
+	jfif [3]int // JFIF density unit, X and Y, written back unchanged
+}
+
+// BlocksWide returns the number of blocks in a plane row.
+func (c *Coefficients) BlocksWide() int { return (c.Width + 7) / 8 }
+
+// BlocksHigh returns the number of block rows in a plane.
+func (c *Coefficients) BlocksHigh() int { return (c.Height + 7) / 8 }
+
+// JFIF returns the JFIF density of the source, zero if absent or only an
+// aspect ratio.
+func (c *Coefficients) JFIF() Density {
+	return jfifDensity(c.jfif[0], c.jfif[1], c.jfif[2])
+}
+
+// ReadCoefficients reads a CMYK or YCCK JPEG's DCT coefficients. The
+// components must share one sampling factor, which is the case for every
+// CMYK file this tool and Photoshop write.
+func ReadCoefficients(data []byte) (*Coefficients, error) {
+	if len(data) < 2 {
+		return nil, fmt.Errorf("data too short for JPEG")
+	}
+
+	const maxMarkers = 256
+	var cMarkers [maxMarkers]C.coef_marker
+	var markerCount C.int
+	res := C.read_cmyk_coefficients(
+		(*C.uchar)(unsafe.Pointer(&data[0])),
+		C.ulong(len(data)),
+		&cMarkers[0],
+		C.int(maxMarkers),
+		&markerCount,
+	)
+	defer C.free_coef_markers(&cMarkers[0], markerCount)
+
+	if res.has_error != 0 {
+		return nil, fmt.Errorf("libjpeg decode: %s", C.GoString(&res.error_msg[0]))
+	}
+	defer C.free_coefs(res.coefs)
+
+	c := &Coefficients{
+		Width:       int(res.width),
+		Height:      int(res.height),
+		Convention:  Plain,
+		YCCK:        res.ycck != 0,
+		Progressive: res.progressive != 0,
+		jfif:        [3]int{int(res.density_unit), int(res.x_density), int(res.y_density)},
+	}
+	if res.adobe != 0 {
+		c.Convention = AdobeInverted
+	}
+
+	coefs := unsafe.Slice((*int16)(unsafe.Pointer(res.coefs)), int(res.num_coefs))
+	plane := len(coefs) / 4
+	for i := range c.Planes {
+		c.Planes[i] = append([]int16(nil), coefs[i*plane:(i+1)*plane]...)
+	}
+
+	c.QuantTables = make([][64]uint16, int(res.num_tables))
+	for t := range c.QuantTables {
+		for i := range c.QuantTables[t] {
+			c.QuantTables[t][i] = uint16(res.qtables[t*64+i])
+		}
+	}
+	for i := range c.Slots {
+		c.Slots[i] = int(res.slots[i])
+	}
+
+	// JFIF and Adobe markers are written again from the fields above; a
+	// JFXX thumbnail would no longer match the image.
+	for i := 0; i < int(markerCount); i++ {
+		m := cMarkers[i]
+		payload := C.GoBytes(unsafe.Pointer(m.data), C.int(m.len))
+		switch {
+		case m.marker == MarkerAPP0 && (bytes.HasPrefix(payload, []byte("JFIF\x00")) || bytes.HasPrefix(payload, []byte("JFXX\x00"))):
+			continue
+		case m.marker == MarkerAPP14 && bytes.HasPrefix(payload, []byte("Adobe")):
+			continue
+		}
+		c.Markers = append(c.Markers, Marker{Code: int(m.marker), Data: payload})
+	}
+	return c, nil
+}
+
+// Encode writes the coefficients as a JPEG with Huffman tables optimized
+// for them, followed by c.Markers. With no scans the file is baseline
+// sequential; otherwise the script is used, e.g. DefaultCMYKScans for a
+// progressive file.
+func (c *Coefficients) Encode(scans []Scan) ([]byte, error) {
+	plane := c.BlocksWide() * c.BlocksHigh() * 64
+	coefs := make([]int16, 0, 4*plane)
+	for i, p := range c.Planes {
+		if len(p) != plane {
+			return nil, fmt.Errorf("plane %d has %d coefficients, want %d", i, len(p), plane)
+		}
+		coefs = append(coefs, p...)
+	}
+	if c.YCCK && c.Convention != AdobeInverted {
+		return nil, fmt.Errorf("YCCK output needs the adobe-inverted convention")
+	}
+
+	var data []byte
+	var codes []C.int
+	var lens []C.uint
+	for _, m := range c.Markers {
+		if len(m.Data) > maxMarkerPayload {
+			return nil, fmt.Errorf("marker 0x%02X payload of %d bytes exceeds %d", m.Code, len(m.Data), maxMarkerPayload)
+		}
+		data = append(data, m.Data...)
+		codes = append(codes, C.int(m.Code))
+		lens = append(lens, C.uint(len(m.Data)))
+	}
+	var dataPtr *C.uchar
+	var codesPtr *C.int
+	var lensPtr *C.uint
+	if len(codes) > 0 {
+		if len(data) > 0 {
+			dataPtr = (*C.uchar)(unsafe.Pointer(&data[0]))
+		}
+		codesPtr, lensPtr = &codes[0], &lens[0]
+	}
+
+	var qtables []C.uint
+	for _, t := range c.QuantTables {
+		for _, v := range t {
+			qtables = append(qtables, C.uint(v))
+		}
+	}
+	var slots [4]C.int
+	for i, s := range c.Slots {
+		if s < 0 || s >= len(c.QuantTables) {
+			return nil, fmt.Errorf("component %d uses missing quantization table %d", i, s)
+		}
+		slots[i] = C.int(s)
+	}
+
+	res := C.write_cmyk_coefficients(
+		C.int(c.Width), C.int(c.Height),
+		(*C.short)(unsafe.Pointer(&coefs[0])),
+		&qtables[0], C.int(len(c.QuantTables)), &slots[0],
+		dataPtr, codesPtr, lensPtr, C.int(len(codes)),
+		scanInts(scans), C.int(len(scans)),
+		cBool(c.YCCK), cBool(c.Convention == AdobeInverted),
+		C.int(c.jfif[0]), C.int(c.jfif[1]), C.int(c.jfif[2]),
+	)
+	if res.has_error != 0 {
+		return nil, fmt.Errorf("libjpeg encode: %s", C.GoString(&res.error_msg[0]))
+	}
+	defer C.free_coef_buf(res.buf)
+	return C.GoBytes(unsafe.Pointer(res.buf), C.int(res.size)), nil
+}
@@ -0,0 +1,36 @@
+package jpeg
+
+import "fmt"
+
+// Convention is how CMYK samples are stored in a JPEG file. Readers tell
+// the two apart by the Adobe APP14 marker: Photoshop writes one and stores
+// inverted samples, and browsers, PDF renderers and most RIPs invert CMYK
+// data whenever the marker is present.
+type Convention int
+
+const (
+	// AdobeInverted stores 255 - value (0 = full ink) with an Adobe APP14
+	// marker, as Photoshop does. It is the default.
+	AdobeInverted Convention = iota
+	// Plain stores values as is (0 = no ink) and omits the APP14 marker.
+	Plain
+)
+
+// String returns the convention's command-line name.
+func (c Convention) String() string {
+	if c == Plain {
+		return "plain"
+	}
+	return "adobe-inverted"
+}
+
+// ParseConvention parses "adobe-inverted" or "plain".
+func ParseConvention(s string) (Convention, error) {
+	switch s {
+	case "adobe-inverted":
+		return AdobeInverted, nil
+	case "plain":
+		return Plain, nil
+	}
+	return 0, fmt.Errorf("unknown CMYK convention %q (want adobe-inverted or plain)", s)
+}
@@ -21,6 +21,7 @@ static void decode_error_exit(j_common_ptr cinfo) {
 }
 
 typedef struct {
+    int            marker;
     unsigned char *data;
     unsigned int   len;
 } decode_marker;
@@ -29,15 +30,22 @@ typedef struct {
     int            width;
     int            height;
     int            num_components;
-    unsigned char *pixels;       // RGB output
+    unsigned char *pixels;       // RGB or CMYK output
     unsigned long  pixels_size;
     int            num_markers;
+    int            adobe;        // CMYK only: Adobe APP14 seen, samples inverted back
+    int            density_unit; // JFIF density, unit 0 if absent
+    int            x_density;
+    int            y_density;
     int            has_error;
     char           error_msg[256];
 } decode_result;
 
-static decode_result decode_rgb_jpeg(const unsigned char *buf, unsigned long buf_size,
-                                      decode_marker *markers, int max_markers, int *marker_count) {
+// decode_jpeg decodes to RGB, or to CMYK when want_cmyk is set, in which
+// case the source must itself be CMYK or YCCK. CMYK stored under an Adobe
+// APP14 marker is inverted, so the output is always 0 = no ink.
+static decode_result decode_jpeg(const unsigned char *buf, unsigned long buf_size, int want_cmyk,
+                                 decode_marker *markers, int max_markers, int *marker_count) {
     decode_result res;
     memset(&res, 0, sizeof(res));
     *marker_count = 0;
@@ -56,18 +64,37 @@ static decode_result decode_rgb_jpeg(const unsigned char *buf, unsigned long buf
     }
 
     jpeg_create_decompress(&cinfo);
+    jpeg_save_markers(&cinfo, JPEG_APP0+1, 0xFFFF); // APP1 for EXIF/XMP
     jpeg_save_markers(&cinfo, JPEG_APP0+2, 0xFFFF); // APP2 for ICC
+    jpeg_save_markers(&cinfo, JPEG_APP0+13, 0xFFFF); // APP13 for Photoshop IRB/IPTC
     jpeg_mem_src(&cinfo, (unsigned char *)buf, buf_size);
     jpeg_read_header(&cinfo, TRUE);
 
-    // Force RGB output
-    cinfo.out_color_space = JCS_RGB;
+    if (cinfo.saw_JFIF_marker) {
+        res.density_unit = cinfo.density_unit;
+        res.x_density = cinfo.X_density;
+        res.y_density = cinfo.Y_density;
+    }
+
+    if (want_cmyk) {
+        if (cinfo.jpeg_color_space != JCS_CMYK && cinfo.jpeg_color_space != JCS_YCCK) {
+            strncpy(res.error_msg, "not a CMYK JPEG", sizeof(res.error_msg)-1);
+            res.has_error = 1;
+            jpeg_destroy_decompress(&cinfo);
+            return res;
+        }
+        cinfo.out_color_space = JCS_CMYK;
+        res.adobe = cinfo.saw_Adobe_marker;
+    } else {
+        // Force RGB output
+        cinfo.out_color_space = JCS_RGB;
+    }
 
     jpeg_start_decompress(&cinfo);
 
     res.width = cinfo.output_width;
     res.height = cinfo.output_height;
-    res.num_components = cinfo.output_components; // should be 3 for RGB
+    res.num_components = cinfo.output_components; // 3 for RGB, 4 for CMYK
 
     res.pixels_size = (unsigned long)res.width * res.height * res.num_components;
     res.pixels = (unsigned char *)malloc(res.pixels_size);
@@ -82,13 +109,20 @@ static decode_result decode_rgb_jpeg(const unsigned char *buf, unsigned long buf
     while (cinfo.output_scanline < cinfo.output_height) {
         unsigned char *row = res.pixels + cinfo.output_scanline * row_stride;
         jpeg_read_scanlines(&cinfo, &row, 1);
+        if (res.adobe) {
+            for (int i = 0; i < row_stride; i++) {
+                row[i] = 255 - row[i];
+            }
+        }
     }
 
-    // Extract APP2 markers
+    // Extract APP1, APP2 and APP13 markers
     jpeg_saved_marker_ptr m = cinfo.marker_list;
     int count = 0;
     while (m != NULL && count < max_markers) {
-        if (m->marker == (JPEG_APP0+2) && m->data_length > 0) {
+        if ((m->marker == (JPEG_APP0+1) || m->marker == (JPEG_APP0+2) || m->marker == (JPEG_APP0+13)) &&
+            m->data_length > 0) {
+            markers[count].marker = m->marker;
             markers[count].data = (unsigned char *)malloc(m->data_length);
             if (markers[count].data != NULL) {
                 memcpy(markers[count].data, m->data, m->data_length);
@@ -131,12 +165,78 @@ func LibjpegVersion() int {
 type DecodedRGB struct {
 	Width  int
 	Height int
-	Pixels []byte // RGB interleaved, len = Width * Height * 3
-	ICC    []byte // extracted ICC profile, nil if absent
+	Pixels []byte   // RGB interleaved, len = Width * Height * 3
+	ICC    []byte   // extracted ICC profile, nil if absent
+	APP1   [][]byte // APP1 segment payloads (EXIF, XMP) in file order
+	APP13  [][]byte // APP13 segment payloads (Photoshop IRB with IPTC)
+	JFIF   Density  // JFIF density, zero if absent or only an aspect ratio
+}
+
+// DecodedCMYK holds the result of decoding a CMYK JPEG.
+type DecodedCMYK struct {
+	Width      int
+	Height     int
+	Pixels     []byte     // CMYK interleaved, 0 = no ink, len = Width * Height * 4
+	ICC        []byte     // extracted ICC profile, nil if absent
+	APP1       [][]byte   // APP1 segment payloads (EXIF, XMP) in file order
+	APP13      [][]byte   // APP13 segment payloads (Photoshop IRB with IPTC)
+	Convention Convention // how the file stored the samples
+	JFIF       Density    // JFIF density, zero if absent or only an aspect ratio
 }
 
 // DecodeRGB decodes a JPEG file from memory, outputting RGB pixels.
 func DecodeRGB(data []byte) (*DecodedRGB, error) {
+	d, err := decode(data, false)
+	if err != nil {
+		return nil, err
+	}
+	return &DecodedRGB{
+		Width:  d.width,
+		Height: d.height,
+		Pixels: d.pixels,
+		ICC:    d.icc,
+		APP1:   d.app1,
+		APP13:  d.app13,
+		JFIF:   d.jfif,
+	}, nil
+}
+
+// DecodeCMYK decodes a CMYK or YCCK JPEG from memory, outputting CMYK
+// pixels with 0 = no ink. The convention is taken from the Adobe APP14
+// marker, so files in either Convention round-trip with EncodeCMYK.
+func DecodeCMYK(data []byte) (*DecodedCMYK, error) {
+	d, err := decode(data, true)
+	if err != nil {
+		return nil, err
+	}
+	convention := Plain
+	if d.adobe {
+		convention = AdobeInverted
+	}
+	return &DecodedCMYK{
+		Width:      d.width,
+		Height:     d.height,
+		Pixels:     d.pixels,
+		ICC:        d.icc,
+		APP1:       d.app1,
+		APP13:      d.app13,
+		Convention: convention,
+		JFIF:       d.jfif,
+	}, nil
+}
+
+// decoded is what decode returns.
+type decoded struct {
+	width, height int
+	pixels, icc   []byte
+	app1, app13   [][]byte
+	adobe         bool // CMYK samples were stored inverted
+	jfif          Density
+}
+
+// decode runs libjpeg and returns the pixels with the ICC profile and the
+// APP1 and APP13 segments.
+func decode(data []byte, cmyk bool) (*decoded, error) {
 	if len(data) < 2 {
 		return nil, fmt.Errorf("data too short for JPEG")
 	}
@@ -145,9 +245,14 @@ func DecodeRGB(data []byte) (*DecodedRGB, error) {
 	var cMarkers [maxMarkers]C.decode_marker
 	var markerCount C.int
 
-	res := C.decode_rgb_jpeg(
+	wantCMYK := C.int(0)
+	if cmyk {
+		wantCMYK = 1
+	}
+	res := C.decode_jpeg(
 		(*C.uchar)(unsafe.Pointer(&data[0])),
 		C.ulong(len(data)),
+		wantCMYK,
 		&cMarkers[0],
 		C.int(maxMarkers),
 		&markerCount,
@@ -162,27 +267,35 @@ func DecodeRGB(data []byte) (*DecodedRGB, error) {
 	defer C.free_decode_pixels(res.pixels)
 
 	// Copy pixel data to Go-managed memory
+	d := &decoded{
+		width:  int(res.width),
+		height: int(res.height),
+		adobe:  res.adobe != 0,
+		jfif:   jfifDensity(int(res.density_unit), int(res.x_density), int(res.y_density)),
+	}
 	pixelSize := int(res.pixels_size)
-	pixels := make([]byte, pixelSize)
-	copy(pixels, unsafe.Slice((*byte)(unsafe.Pointer(res.pixels)), pixelSize))
+	d.pixels = make([]byte, pixelSize)
+	copy(d.pixels, unsafe.Slice((*byte)(unsafe.Pointer(res.pixels)), pixelSize))
 
-	// Extract ICC
-	var app2Markers [][]byte
+	// Split saved markers; ICC lives in APP2, EXIF and XMP in APP1, IPTC in APP13
+	var app2 [][]byte
 	for i := 0; i < int(markerCount); i++ {
 		m := cMarkers[i]
 		goData := C.GoBytes(unsafe.Pointer(m.data), C.int(m.len))
-		app2Markers = append(app2Markers, goData)
+		switch m.marker {
+		case C.JPEG_APP0 + 1:
+			d.app1 = append(d.app1, goData)
+		case C.JPEG_APP0 + 13:
+			d.app13 = append(d.app13, goData)
+		default:
+			app2 = append(app2, goData)
+		}
 	}
 
-	icc, err := ExtractICC(app2Markers)
+	var err error
+	d.icc, err = ExtractICC(app2)
 	if err != nil {
 		return nil, fmt.Errorf("extracting ICC: %w", err)
 	}
-
-	return &DecodedRGB{
-		Width:  int(res.width),
-		Height: int(res.height),
-		Pixels: pixels,
-		ICC:    icc,
-	}, nil
+	return d, nil
 }
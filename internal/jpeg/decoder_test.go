@@ -1,6 +1,9 @@
 package jpeg
 
 import (
+	"bytes"
+	"image"
+	stdjpeg "image/jpeg"
 	"os"
 	"testing"
 )
@@ -31,3 +34,66 @@ func TestDecodeRGB(t *testing.T) {
 		t.Logf("ICC profile: %d bytes", len(dec.ICC))
 	}
 }
+
+func TestDecodeRGBKeepsAPP1(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	var buf bytes.Buffer
+	if err := stdjpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	payload := []byte("Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08\x00\x00\x00\x00\x00\x00")
+	seg := []byte{0xFF, 0xE1, byte((len(payload) + 2) >> 8), byte(len(payload) + 2)}
+	data := append([]byte{0xFF, 0xD8}, seg...)
+	data = append(data, payload...)
+	data = append(data, buf.Bytes()[2:]...)
+
+	dec, err := DecodeRGB(data)
+	if err != nil {
+		t.Fatalf("DecodeRGB: %v", err)
+	}
+	if len(dec.APP1) != 1 || !bytes.Equal(dec.APP1[0], payload) {
+		t.Errorf("APP1 = %q, want [%q]", dec.APP1, payload)
+	}
+	if dec.ICC != nil {
+		t.Errorf("ICC = %d bytes, want nil", len(dec.ICC))
+	}
+}
+
+func TestDecodeCMYKRoundTrip(t *testing.T) {
+	width, height := 8, 8
+	pixels := make([]byte, width*height*4)
+	for i := range pixels {
+		pixels[i] = []byte{20, 90, 160, 230}[i%4]
+	}
+	data, err := EncodeCMYK(pixels, width, height, nil, EncoderOptions{Quality: 100})
+	if err != nil {
+		t.Fatalf("EncodeCMYK: %v", err)
+	}
+
+	dec, err := DecodeCMYK(data)
+	if err != nil {
+		t.Fatalf("DecodeCMYK: %v", err)
+	}
+	if dec.Width != width || dec.Height != height || len(dec.Pixels) != len(pixels) {
+		t.Fatalf("decoded %dx%d, %d bytes", dec.Width, dec.Height, len(dec.Pixels))
+	}
+	for i, v := range dec.Pixels {
+		if d := int(v) - int(pixels[i]); d < -2 || d > 2 {
+			t.Fatalf("byte %d: got %d, want %d", i, v, pixels[i])
+		}
+	}
+
+	if _, err := DecodeCMYK(data[:0]); err == nil {
+		t.Error("expected error for empty data")
+	}
+}
+
+func TestDecodeCMYKRejectsRGB(t *testing.T) {
+	var buf bytes.Buffer
+	if err := stdjpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecodeCMYK(buf.Bytes()); err == nil {
+		t.Error("expected error decoding RGB JPEG as CMYK")
+	}
+}
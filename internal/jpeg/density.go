@@ -0,0 +1,54 @@
+package jpeg
+
+import (
+	"fmt"
+	"math"
+)
+
+// Density is a print resolution in pixels per inch. The zero value means
+// the resolution is unknown.
+type Density struct {
+	X, Y float64
+}
+
+// Known reports whether both axes have a resolution.
+func (d Density) Known() bool {
+	return d.X > 0 && d.Y > 0
+}
+
+// String formats d as "300 dpi", or "300 x 150 dpi" when the axes differ.
+func (d Density) String() string {
+	if !d.Known() {
+		return "unknown"
+	}
+	if d.X == d.Y {
+		return fmt.Sprintf("%g dpi", d.X)
+	}
+	return fmt.Sprintf("%g x %g dpi", d.X, d.Y)
+}
+
+// PrintSize returns the size in inches of a width x height image printed
+// at d.
+func (d Density) PrintSize(width, height int) (w, h float64) {
+	if !d.Known() {
+		return 0, 0
+	}
+	return float64(width) / d.X, float64(height) / d.Y
+}
+
+// jfifDensity converts a JFIF density. Unit 0 only gives the pixel aspect
+// ratio, so it yields the zero Density.
+func jfifDensity(unit, x, y int) Density {
+	switch unit {
+	case 1: // dots per inch
+		return Density{float64(x), float64(y)}
+	case 2: // dots per cm
+		return Density{math.Round(float64(x)*2.54*100) / 100, math.Round(float64(y)*2.54*100) / 100}
+	}
+	return Density{}
+}
+
+// jfifValue rounds a resolution to the 16-bit JFIF field.
+func jfifValue(v float64) int {
+	return int(min(max(math.Round(v), 1), 65535))
+}
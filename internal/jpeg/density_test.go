@@ -0,0 +1,26 @@
+package jpeg
+
+import "testing"
+
+func TestJFIFDensity(t *testing.T) {
+	for _, tc := range []struct {
+		unit, x, y int
+		want       Density
+	}{
+		{0, 1, 1, Density{}},
+		{1, 300, 300, Density{300, 300}},
+		{2, 118, 59, Density{299.72, 149.86}},
+	} {
+		if got := jfifDensity(tc.unit, tc.x, tc.y); got != tc.want {
+			t.Errorf("jfifDensity(%d, %d, %d) = %v, want %v", tc.unit, tc.x, tc.y, got, tc.want)
+		}
+	}
+
+	d := Density{300, 150}
+	if s := d.String(); s != "300 x 150 dpi" {
+		t.Errorf("String() = %q", s)
+	}
+	if w, h := d.PrintSize(1200, 300); w != 4 || h != 2 {
+		t.Errorf("PrintSize = %g x %g in", w, h)
+	}
+}
@@ -57,15 +57,63 @@ static void write_icc_markers(j_compress_ptr cinfo, const unsigned char *icc, un
     }
 }
 
-// encode_cmyk_jpeg encodes CMYK pixels to JPEG with custom quantization tables.
+// SCAN_INTS is the number of ints describing one scan in the flat array
+// passed to encode_cmyk_jpeg: component count, four component indexes,
+// Ss, Se, Ah, Al.
+#define SCAN_INTS 9
+
+// set_scan_script installs num_scans scans from the flat scans array; with
+// none, libjpeg's default sequential scan is kept. Not static:
+// coefficients.go uses it too.
+void set_scan_script(j_compress_ptr cinfo, const int *scans, int num_scans) {
+    if (num_scans <= 0) return;
+    jpeg_scan_info *info = (jpeg_scan_info *)(*cinfo->mem->alloc_small)(
+        (j_common_ptr)cinfo, JPOOL_IMAGE, num_scans * sizeof(jpeg_scan_info));
+    for (int i = 0; i < num_scans; i++) {
+        const int *s = scans + i * SCAN_INTS;
+        info[i].comps_in_scan = s[0];
+        for (int c = 0; c < 4; c++) {
+            info[i].component_index[c] = s[1 + c];
+        }
+        info[i].Ss = s[5];
+        info[i].Se = s[6];
+        info[i].Ah = s[7];
+        info[i].Al = s[8];
+    }
+    cinfo->scan_info = info;
+    cinfo->num_scans = num_scans;
+}
+
+// encode_cmyk_jpeg encodes CMYK pixels to JPEG with custom quantization
+// tables: num_tables tables of 64 values, with slots giving the table for
+// each of C, M, Y and K. With num_scans > 0 the scans array is used as the
+// scan script. invert stores 255 - value under an Adobe APP14 marker;
+// otherwise the marker is left out. ycck implies invert. The num_meta
+// metadata markers are concatenated in meta, with their codes and lengths
+// in meta_codes and meta_lens; they are written before the ICC profile.
+// A non-zero x_density and y_density are written in a JFIF marker.
 static encode_result encode_cmyk_jpeg(
     const unsigned char *pixels, int width, int height,
-    const unsigned int *cmy_qtable, const unsigned int *k_qtable,
-    const unsigned char *icc, unsigned long icc_len
+    const unsigned int *qtables, int num_tables, const int *slots,
+    const unsigned char *meta, const int *meta_codes, const unsigned int *meta_lens, int num_meta,
+    const unsigned char *icc, unsigned long icc_len,
+    const int *scans, int num_scans,
+    int invert, int ycck, int x_density, int y_density
 ) {
     encode_result res;
     memset(&res, 0, sizeof(res));
 
+    if (ycck) invert = 1;
+    unsigned char *inverted = NULL;
+    if (invert) {
+        inverted = (unsigned char *)malloc((size_t)width * 4);
+        if (inverted == NULL) {
+            strncpy(res.error_msg, "malloc failed for row buffer", sizeof(res.error_msg)-1);
+            res.has_error = 1;
+            return res;
+        }
+    }
+
     struct jpeg_compress_struct cinfo;
     encode_err_mgr jerr;
 
@@ -76,6 +124,7 @@ static encode_result encode_cmyk_jpeg(
         strncpy(res.error_msg, jerr.msg, sizeof(res.error_msg)-1);
         res.has_error = 1;
         jpeg_destroy_compress(&cinfo);
+        free(inverted);
         return res;
     }
 
@@ -89,6 +138,19 @@ static encode_result encode_cmyk_jpeg(
 
     jpeg_set_defaults(&cinfo);
     cinfo.optimize_coding = TRUE;
+    if (ycck) {
+        // libjpeg converts the (inverted) CMY to YCbCr and passes K through.
+        jpeg_set_colorspace(&cinfo, JCS_YCCK);
+    }
+    cinfo.write_Adobe_marker = invert ? TRUE : FALSE;
+    if (x_density > 0 && y_density > 0) {
+        // JFIF is defined for YCbCr and grayscale only, but it is where
+        // Photoshop, ImageMagick and prepress tools look for the density.
+        cinfo.write_JFIF_header = TRUE;
+        cinfo.density_unit = 1;
+        cinfo.X_density = (UINT16)x_density;
+        cinfo.Y_density = (UINT16)y_density;
+    }
 
     // Set all sampling factors to 1x1 (no subsampling for CMYK)
     for (int i = 0; i < 4; i++) {
@@ -97,37 +159,49 @@ static encode_result encode_cmyk_jpeg(
     }
 
     // Set quantization tables directly (pre-scaled values).
-    if (cinfo.quant_tbl_ptrs[0] == NULL)
-        cinfo.quant_tbl_ptrs[0] = jpeg_alloc_quant_table((j_common_ptr)&cinfo);
-    if (cinfo.quant_tbl_ptrs[1] == NULL)
-        cinfo.quant_tbl_ptrs[1] = jpeg_alloc_quant_table((j_common_ptr)&cinfo);
-
-    for (int i = 0; i < 64; i++) {
-        cinfo.quant_tbl_ptrs[0]->quantval[i] = (UINT16)cmy_qtable[i];
-        cinfo.quant_tbl_ptrs[1]->quantval[i] = (UINT16)k_qtable[i];
+    for (int t = 0; t < num_tables; t++) {
+        if (cinfo.quant_tbl_ptrs[t] == NULL)
+            cinfo.quant_tbl_ptrs[t] = jpeg_alloc_quant_table((j_common_ptr)&cinfo);
+        for (int i = 0; i < 64; i++) {
+            cinfo.quant_tbl_ptrs[t]->quantval[i] = (UINT16)qtables[t * 64 + i];
+        }
+    }
+    for (int c = 0; c < 4; c++) {
+        cinfo.comp_info[c].quant_tbl_no = slots[c];
     }
 
-    cinfo.comp_info[0].quant_tbl_no = 0;
-    cinfo.comp_info[1].quant_tbl_no = 0;
-    cinfo.comp_info[2].quant_tbl_no = 0;
-    cinfo.comp_info[3].quant_tbl_no = 1;
+    // Custom scan script; libjpeg validates it in jpeg_start_compress.
+    set_scan_script(&cinfo, scans, num_scans);
 
     jpeg_start_compress(&cinfo, TRUE);
 
+    // Write metadata markers (EXIF, XMP, Photoshop IRB)
+    for (int i = 0; i < num_meta; i++) {
+        jpeg_write_marker(&cinfo, meta_codes[i], meta, meta_lens[i]);
+        meta += meta_lens[i];
+    }
+
     // Write ICC profile as APP2 marker chunks
     if (icc != NULL && icc_len > 0) {
         write_icc_markers(&cinfo, icc, icc_len);
     }
 
-    // Write scanlines
+    // Write scanlines, inverting a row at a time if needed
     int row_stride = width * 4;
     while (cinfo.next_scanline < cinfo.image_height) {
         const unsigned char *row = pixels + cinfo.next_scanline * row_stride;
+        if (inverted != NULL) {
+            for (int i = 0; i < row_stride; i++) {
+                inverted[i] = 255 - row[i];
+            }
+            row = inverted;
+        }
         jpeg_write_scanlines(&cinfo, (JSAMPARRAY)&row, 1);
     }
 
     jpeg_finish_compress(&cinfo);
     jpeg_destroy_compress(&cinfo);
+    free(inverted);
     return res;
 }
 
@@ -139,13 +213,42 @@ import "C"
 
 import (
 	"fmt"
+	"slices"
 	"unsafe"
 )
 
 // EncoderOptions controls CMYK JPEG encoding.
 type EncoderOptions struct {
-	Quality      int // 1-100, default 85
-	CMYReduction int // quality reduction for CMY vs K, default 15
+	Quality        int        // 1-100, default 85
+	CMYReduction   int        // quality reduction for CMY vs K; 0 encodes every channel at Quality
+	ChannelQuality [4]int     // per-channel quality for C, M, Y, K; 0 follows Quality and CMYReduction
+	BaseTables     [][64]int  // base tables for C, M, Y, K (last repeated), nil for Annex K
+	Progressive    bool       // use DefaultCMYKScans when Scans is nil
+	Scans          []Scan     // custom scan script, progressive or multi-scan sequential
+	Convention     Convention // how samples are stored, AdobeInverted by default
+	YCCK           bool       // store CMY as YCbCr (Adobe transform 2); needs AdobeInverted
+	APP1           [][]byte   // EXIF and XMP payloads to write, e.g. from meta.Carry
+	APP13          [][]byte   // Photoshop IRB payloads to write
+	Density        Density    // print resolution for a JFIF marker, none if unknown
+}
+
+// maxMarkerPayload is the largest payload a JPEG marker segment can hold.
+const maxMarkerPayload = 65533
+
+// Qualities returns the quality each of C, M, Y and K is encoded at.
+func (o EncoderOptions) Qualities() [4]int {
+	quality := o.Quality
+	if quality == 0 {
+		quality = 85
+	}
+	cmy := max(quality-o.CMYReduction, 1)
+	q := [4]int{cmy, cmy, cmy, quality}
+	for c, v := range o.ChannelQuality {
+		if v != 0 {
+			q[c] = v
+		}
+	}
+	return q
 }
 
 // EncodeCMYK encodes CMYK pixel data to JPEG format with channel-aware quantization.
@@ -157,20 +260,50 @@ func EncodeCMYK(pixels []byte, width, height int, iccProfile []byte, opts Encode
 		return nil, fmt.Errorf("expected %d CMYK bytes, got %d", expectedSize, len(pixels))
 	}
 
-	if opts.Quality == 0 {
-		opts.Quality = 85
-	}
-	if opts.CMYReduction == 0 {
-		opts.CMYReduction = 15
+	if opts.YCCK && opts.Convention != AdobeInverted {
+		return nil, fmt.Errorf("YCCK output needs the adobe-inverted convention")
 	}
 
-	cmyTable, kTable := GenerateQuantTables(opts.Quality, opts.CMYReduction)
+	var meta []byte
+	var metaCodes []C.int
+	var metaLens []C.uint
+	for _, m := range []struct {
+		code     int
+		payloads [][]byte
+	}{{C.JPEG_APP0 + 1, opts.APP1}, {C.JPEG_APP0 + 13, opts.APP13}} {
+		for _, p := range m.payloads {
+			if len(p) > maxMarkerPayload {
+				return nil, fmt.Errorf("APP%d payload of %d bytes exceeds %d", m.code-C.JPEG_APP0, len(p), maxMarkerPayload)
+			}
+			meta = append(meta, p...)
+			metaCodes = append(metaCodes, C.int(m.code))
+			metaLens = append(metaLens, C.uint(len(p)))
+		}
+	}
+	var metaPtr *C.uchar
+	var metaCodesPtr *C.int
+	var metaLensPtr *C.uint
+	if len(metaCodes) > 0 {
+		if len(meta) > 0 {
+			metaPtr = (*C.uchar)(unsafe.Pointer(&meta[0]))
+		}
+		metaCodesPtr, metaLensPtr = &metaCodes[0], &metaLens[0]
+	}
 
-	var cmyQtableC [64]C.uint
-	var kQtableC [64]C.uint
-	for i := 0; i < 64; i++ {
-		cmyQtableC[i] = C.uint(cmyTable[i])
-		kQtableC[i] = C.uint(kTable[i])
+	// Identical tables share a slot, so the file carries each once.
+	var qtablesC []C.uint
+	var slotsC [4]C.int
+	var tables [][64]uint16
+	for c, table := range ChannelQuantTables(opts.Qualities(), opts.BaseTables) {
+		slot := slices.Index(tables, table)
+		if slot < 0 {
+			slot = len(tables)
+			tables = append(tables, table)
+			for _, v := range table {
+				qtablesC = append(qtablesC, C.uint(v))
+			}
+		}
+		slotsC[c] = C.int(slot)
 	}
 
 	var iccPtr *C.uchar
@@ -180,11 +313,26 @@ func EncodeCMYK(pixels []byte, width, height int, iccProfile []byte, opts Encode
 		iccLen = C.ulong(len(iccProfile))
 	}
 
+	scans := opts.Scans
+	if scans == nil && opts.Progressive {
+		scans = DefaultCMYKScans()
+	}
+	scanPtr := scanInts(scans)
+
+	var xDensity, yDensity C.int
+	if opts.Density.Known() {
+		xDensity, yDensity = C.int(jfifValue(opts.Density.X)), C.int(jfifValue(opts.Density.Y))
+	}
+
 	res := C.encode_cmyk_jpeg(
 		(*C.uchar)(unsafe.Pointer(&pixels[0])),
 		C.int(width), C.int(height),
-		&cmyQtableC[0], &kQtableC[0],
+		&qtablesC[0], C.int(len(tables)), &slotsC[0],
+		metaPtr, metaCodesPtr, metaLensPtr, C.int(len(metaCodes)),
 		iccPtr, iccLen,
+		scanPtr, C.int(len(scans)),
+		cBool(opts.Convention == AdobeInverted), cBool(opts.YCCK),
+		xDensity, yDensity,
 	)
 
 	if res.has_error != 0 {
@@ -196,3 +344,28 @@ func EncodeCMYK(pixels []byte, width, height int, iccProfile []byte, opts Encode
 	output := C.GoBytes(unsafe.Pointer(res.buf), C.int(res.size))
 	return output, nil
 }
+
+func cBool(b bool) C.int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// scanInts flattens a scan script into the SCAN_INTS layout read by
+// set_scan_script; nil if there are no scans.
+func scanInts(scans []Scan) *C.int {
+	var ints []C.int
+	for _, s := range scans {
+		var comps [4]C.int
+		for i, c := range s.Components {
+			comps[i] = C.int(c)
+		}
+		ints = append(ints, C.int(len(s.Components)), comps[0], comps[1], comps[2], comps[3],
+			C.int(s.Ss), C.int(s.Se), C.int(s.Ah), C.int(s.Al))
+	}
+	if len(ints) == 0 {
+		return nil
+	}
+	return &ints[0]
+}
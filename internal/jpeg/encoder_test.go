@@ -1,6 +1,7 @@
 package jpeg
 
 import (
+	"bytes"
 	"testing"
 )
 
@@ -41,3 +42,164 @@ func TestEncodeCMYKSynthetic(t *testing.T) {
 	t.Logf("Encoded %dx%d CMYK JPEG: %d bytes, %d components, %s",
 		info.Width, info.Height, len(data), info.NumComponents, info.ColorSpace)
 }
+
+func TestEncodeCMYKProgressive(t *testing.T) {
+	width, height := 64, 48
+	pixels := make([]byte, width*height*4)
+	for i := range pixels {
+		pixels[i] = byte(i * 7)
+	}
+	baseline, err := EncodeCMYK(pixels, width, height, nil, EncoderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, opts := range map[string]EncoderOptions{
+		"default script": {Progressive: true},
+		"custom script":  {Scans: mustParseScans(t, "0 1 2 3: 0-0, 0, 0; 3: 1-63, 0, 0; 0: 1-63, 0, 0; 1: 1-63, 0, 0; 2: 1-63, 0, 0")},
+	} {
+		data, err := EncodeCMYK(pixels, width, height, nil, opts)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		info, err := GetInfo(data)
+		if err != nil {
+			t.Fatalf("%s: GetInfo: %v", name, err)
+		}
+		if !info.Progressive {
+			t.Errorf("%s: output is not progressive", name)
+		}
+		// Same quantization, so the decoded pixels must match baseline.
+		a, err := DecodeCMYK(data)
+		if err != nil {
+			t.Fatalf("%s: decode: %v", name, err)
+		}
+		b, _ := DecodeCMYK(baseline)
+		if string(a.Pixels) != string(b.Pixels) {
+			t.Errorf("%s: decoded pixels differ from the baseline encoding", name)
+		}
+	}
+}
+
+func TestEncodeCMYKRejectsInvalidScript(t *testing.T) {
+	// Each scan is valid on its own, but AC data cannot precede DC.
+	scans := mustParseScans(t, "3: 1-63, 0, 0; 0 1 2 3: 0-0, 0, 0")
+	pixels := make([]byte, 8*8*4)
+	if _, err := EncodeCMYK(pixels, 8, 8, nil, EncoderOptions{Scans: scans}); err == nil {
+		t.Error("expected libjpeg to reject AC before DC")
+	}
+}
+
+func mustParseScans(t *testing.T, script string) []Scan {
+	t.Helper()
+	scans, err := ParseScanScript(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return scans
+}
+
+func TestEncodeCMYKChannelQuality(t *testing.T) {
+	width, height := 64, 64
+	pixels := make([]byte, width*height*4)
+	for i := range pixels {
+		pixels[i] = byte(i*13 + i/256)
+	}
+	size := func(opts EncoderOptions) int {
+		data, err := EncodeCMYK(pixels, width, height, nil, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := DecodeCMYK(data); err != nil {
+			t.Fatal(err)
+		}
+		return len(data)
+	}
+	base := size(EncoderOptions{})
+	if low := size(EncoderOptions{ChannelQuality: [4]int{2: 20}}); low >= base {
+		t.Errorf("lower Y quality gave %d bytes, default %d", low, base)
+	}
+	if four := size(EncoderOptions{ChannelQuality: [4]int{90, 80, 30, 95}}); four >= base*2 {
+		t.Errorf("four-table encode is %d bytes, default %d", four, base)
+	}
+}
+
+func TestEncodeCMYKConventions(t *testing.T) {
+	width, height := 16, 16
+	pixels := make([]byte, width*height*4)
+	for i := range pixels {
+		pixels[i] = []byte{10, 120, 200, 250}[i%4]
+	}
+	for _, tc := range []struct {
+		opts       EncoderOptions
+		convention Convention
+		colorSpace string
+	}{
+		{EncoderOptions{}, AdobeInverted, "CMYK"},
+		{EncoderOptions{Convention: Plain}, Plain, "CMYK"},
+		{EncoderOptions{YCCK: true}, AdobeInverted, "YCCK"},
+	} {
+		tc.opts.Quality = 100
+		data, err := EncodeCMYK(pixels, width, height, nil, tc.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hasAPP14 := bytes.Contains(data, []byte{0xFF, 0xEE, 0x00, 0x0E, 'A', 'd', 'o', 'b', 'e'}); hasAPP14 != (tc.convention == AdobeInverted) {
+			t.Errorf("%+v: APP14 present = %v", tc.opts, hasAPP14)
+		}
+		info, err := GetInfo(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Convention != tc.convention || info.ColorSpace != tc.colorSpace {
+			t.Errorf("%+v: GetInfo reports %v %s", tc.opts, info.Convention, info.ColorSpace)
+		}
+		dec, err := DecodeCMYK(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dec.Convention != tc.convention {
+			t.Errorf("%+v: decoded as %v", tc.opts, dec.Convention)
+		}
+		for i, v := range dec.Pixels {
+			if d := int(v) - int(pixels[i]); d < -3 || d > 3 {
+				t.Fatalf("%+v: pixel byte %d = %d, want about %d", tc.opts, i, v, pixels[i])
+			}
+		}
+	}
+
+	if _, err := EncodeCMYK(pixels, width, height, nil, EncoderOptions{YCCK: true, Convention: Plain}); err == nil {
+		t.Error("expected plain YCCK to be rejected")
+	}
+}
+
+func TestEncodeCMYKDensity(t *testing.T) {
+	width, height := 16, 16
+	pixels := make([]byte, width*height*4)
+	for _, tc := range []struct {
+		density, want Density
+	}{
+		{Density{}, Density{}},
+		{Density{300, 300}, Density{300, 300}},
+		{Density{299.6, 150}, Density{300, 150}},
+	} {
+		data, err := EncodeCMYK(pixels, width, height, nil, EncoderOptions{Density: tc.density})
+		if err != nil {
+			t.Fatal(err)
+		}
+		info, err := GetInfo(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec, err := DecodeCMYK(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.JFIF != tc.want || dec.JFIF != tc.want {
+			t.Errorf("density %v: GetInfo %v, DecodeCMYK %v, want %v", tc.density, info.JFIF, dec.JFIF, tc.want)
+		}
+		if dec.Convention != AdobeInverted {
+			t.Errorf("density %v: JFIF marker changed the convention to %v", tc.density, dec.Convention)
+		}
+	}
+}
@@ -25,13 +25,28 @@ typedef struct {
     int height;
     int num_components;
     int color_space;    // J_COLOR_SPACE enum value
+    int progressive;
+    int adobe;          // Adobe APP14 marker present
+    int density_unit;   // JFIF density, unit 0 if absent
+    int x_density;
+    int y_density;
+    int jfif_version;   // major * 100 + minor, 0 without a JFIF marker
+    int adobe_transform;
+    int precision;
+    int arithmetic;
+    unsigned int restart_interval;
+    int comp_id[4];     // the first four components
+    int h_samp[4];
+    int v_samp[4];
+    unsigned int qtable[4 * 64];
     int num_markers;
     int has_error;
     char error_msg[256];
 } jpeg_info_result;
 
-// info_marker holds extracted APP2 marker data
+// info_marker holds extracted APP1 and APP2 marker data
 typedef struct {
+    int           marker;
     unsigned char *data;
     unsigned int  len;
 } info_marker;
@@ -56,6 +71,7 @@ static jpeg_info_result get_jpeg_info(const unsigned char *buf, unsigned long bu
     }
 
     jpeg_create_decompress(&cinfo);
+    jpeg_save_markers(&cinfo, JPEG_APP0+1, 0xFFFF); // APP1 for EXIF/XMP
     jpeg_save_markers(&cinfo, JPEG_APP0+2, 0xFFFF); // APP2 for ICC
     jpeg_mem_src(&cinfo, (unsigned char *)buf, buf_size);
     jpeg_read_header(&cinfo, TRUE);
@@ -64,12 +80,37 @@ static jpeg_info_result get_jpeg_info(const unsigned char *buf, unsigned long bu
     res.height = cinfo.image_height;
     res.num_components = cinfo.num_components;
     res.color_space = cinfo.jpeg_color_space;
+    res.progressive = cinfo.progressive_mode;
+    res.adobe = cinfo.saw_Adobe_marker;
+    res.adobe_transform = cinfo.saw_Adobe_marker ? cinfo.Adobe_transform : -1;
+    if (cinfo.saw_JFIF_marker) {
+        res.density_unit = cinfo.density_unit;
+        res.x_density = cinfo.X_density;
+        res.y_density = cinfo.Y_density;
+        res.jfif_version = cinfo.JFIF_major_version * 100 + cinfo.JFIF_minor_version;
+    }
+    res.precision = cinfo.data_precision;
+    res.arithmetic = cinfo.arith_code;
+    res.restart_interval = cinfo.restart_interval;
+
+    // Tables are defined before the first scan, so the header has them.
+    for (int c = 0; c < cinfo.num_components && c < 4; c++) {
+        jpeg_component_info *comp = &cinfo.comp_info[c];
+        res.comp_id[c] = comp->component_id;
+        res.h_samp[c] = comp->h_samp_factor;
+        res.v_samp[c] = comp->v_samp_factor;
+        JQUANT_TBL *q = cinfo.quant_tbl_ptrs[comp->quant_tbl_no];
+        for (int i = 0; q != NULL && i < DCTSIZE2; i++) {
+            res.qtable[c * DCTSIZE2 + i] = q->quantval[i];
+        }
+    }
 
-    // extract APP2 markers
+    // extract APP1 and APP2 markers
     jpeg_saved_marker_ptr m = cinfo.marker_list;
     int count = 0;
     while (m != NULL && count < max_markers) {
-        if (m->marker == (JPEG_APP0+2) && m->data_length > 0) {
+        if ((m->marker == (JPEG_APP0+1) || m->marker == (JPEG_APP0+2)) && m->data_length > 0) {
+            markers[count].marker = m->marker;
             markers[count].data = (unsigned char *)malloc(m->data_length);
             if (markers[count].data != NULL) {
                 memcpy(markers[count].data, m->data, m->data_length);
@@ -94,6 +135,7 @@ static void free_info_markers(info_marker *markers, int count) {
 import "C"
 
 import (
+	"bytes"
 	"fmt"
 	"unsafe"
 )
@@ -120,11 +162,48 @@ func colorSpaceName(cs int) string {
 
 // ImageInfo contains metadata about a JPEG file.
 type ImageInfo struct {
-	Width         int
-	Height        int
-	NumComponents int
-	ColorSpace    string
-	ICC           []byte // extracted ICC profile, nil if absent
+	Width           int
+	Height          int
+	NumComponents   int
+	ColorSpace      string
+	Progressive     bool
+	Baseline        bool       // SOF0: 8-bit Huffman sequential
+	Arithmetic      bool       // arithmetic rather than Huffman coding
+	Precision       int        // bits per sample
+	RestartInterval int        // MCUs per restart interval, 0 for none
+	Convention      Convention // CMYK and YCCK only: sample convention from APP14
+	AdobeTransform  int        // Adobe APP14 transform code, -1 without the marker
+	JFIFVersion     string     // e.g. "1.01", "" without a JFIF marker
+	EXIF            bool       // an EXIF APP1 segment is present
+	ICC             []byte     // extracted ICC profile, nil if absent
+	APP1            [][]byte   // APP1 segment payloads (EXIF, XMP) in file order
+	JFIF            Density    // JFIF density, zero if absent or only an aspect ratio
+	Components      []ComponentInfo
+	Segments        []Segment // markers in file order, as far as they could be parsed
+}
+
+// ComponentInfo describes one image component.
+type ComponentInfo struct {
+	ID           int // component identifier from the frame header
+	HSamp, VSamp int // sampling factors
+	Quant        [64]uint16
+	Quality      QualityEstimate // estimated from Quant
+}
+
+// Coding describes the coding process, e.g. "baseline",
+// "extended sequential" or "progressive, arithmetic".
+func (i *ImageInfo) Coding() string {
+	coding := "extended sequential"
+	switch {
+	case i.Progressive:
+		coding = "progressive"
+	case i.Baseline:
+		coding = "baseline"
+	}
+	if i.Arithmetic {
+		coding += ", arithmetic"
+	}
+	return coding
 }
 
 // GetInfo reads JPEG metadata and extracts any ICC profile without fully decoding the image.
@@ -151,12 +230,16 @@ func GetInfo(data []byte) (*ImageInfo, error) {
 		return nil, fmt.Errorf("libjpeg: %s", C.GoString(&res.error_msg[0]))
 	}
 
-	// Collect APP2 marker data into Go slices
-	var app2Markers [][]byte
+	// Collect marker data into Go slices
+	var app1Markers, app2Markers [][]byte
 	for i := 0; i < int(markerCount); i++ {
 		m := cMarkers[i]
 		goData := C.GoBytes(unsafe.Pointer(m.data), C.int(m.len))
-		app2Markers = append(app2Markers, goData)
+		if m.marker == C.JPEG_APP0+1 {
+			app1Markers = append(app1Markers, goData)
+		} else {
+			app2Markers = append(app2Markers, goData)
+		}
 	}
 
 	icc, err := ExtractICC(app2Markers)
@@ -164,11 +247,50 @@ func GetInfo(data []byte) (*ImageInfo, error) {
 		return nil, fmt.Errorf("extracting ICC: %w", err)
 	}
 
-	return &ImageInfo{
-		Width:         int(res.width),
-		Height:        int(res.height),
-		NumComponents: int(res.num_components),
-		ColorSpace:    colorSpaceName(int(res.color_space)),
-		ICC:           icc,
-	}, nil
+	convention := Plain
+	if res.adobe != 0 {
+		convention = AdobeInverted
+	}
+	info := &ImageInfo{
+		Width:           int(res.width),
+		Height:          int(res.height),
+		NumComponents:   int(res.num_components),
+		ColorSpace:      colorSpaceName(int(res.color_space)),
+		Progressive:     res.progressive != 0,
+		Arithmetic:      res.arithmetic != 0,
+		Precision:       int(res.precision),
+		RestartInterval: int(res.restart_interval),
+		Convention:      convention,
+		AdobeTransform:  int(res.adobe_transform),
+		ICC:             icc,
+		APP1:            app1Markers,
+		JFIF:            jfifDensity(int(res.density_unit), int(res.x_density), int(res.y_density)),
+	}
+	if v := int(res.jfif_version); v != 0 {
+		info.JFIFVersion = fmt.Sprintf("%d.%02d", v/100, v%100)
+	}
+	for _, seg := range app1Markers {
+		if bytes.HasPrefix(seg, []byte("Exif\x00")) {
+			info.EXIF = true
+		}
+	}
+	for c := 0; c < min(info.NumComponents, 4); c++ {
+		ci := ComponentInfo{ID: int(res.comp_id[c]), HSamp: int(res.h_samp[c]), VSamp: int(res.v_samp[c])}
+		for i := range ci.Quant {
+			ci.Quant[i] = uint16(res.qtable[c*64+i])
+		}
+		ci.Quality = EstimateQuality(ci.Quant)
+		info.Components = append(info.Components, ci)
+	}
+	// libjpeg has accepted the header, so a marker the scanner cannot
+	// follow further on only shortens the list. The v6b API libjpeg is
+	// built with has no is_baseline, so the frame marker tells.
+	info.Segments, _ = Segments(data)
+	for _, seg := range info.Segments {
+		if seg.Marker >= 0xC0 && seg.Marker <= 0xCF && seg.Marker != 0xC4 && seg.Marker != 0xC8 && seg.Marker != 0xCC {
+			info.Baseline = seg.Marker == 0xC0
+			break
+		}
+	}
+	return info, nil
 }
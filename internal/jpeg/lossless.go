@@ -0,0 +1,206 @@
+package jpeg
+
+import (
+	"fmt"
+	"image"
+)
+
+// Transform is a lossless rotation or flip, as in jpegtran.
+type Transform int
+
+const (
+	NoTransform    Transform = iota
+	FlipHorizontal           // mirror left to right
+	FlipVertical             // mirror top to bottom
+	Transpose                // across the top-left to bottom-right diagonal
+	Transverse               // across the top-right to bottom-left diagonal
+	Rotate90                 // clockwise
+	Rotate180
+	Rotate270
+)
+
+var transformNames = [...]string{"none", "flip horizontal", "flip vertical", "transpose", "transverse", "rotate 90", "rotate 180", "rotate 270"}
+
+// String returns a description such as "rotate 90".
+func (t Transform) String() string {
+	if t < 0 || int(t) >= len(transformNames) {
+		return fmt.Sprintf("Transform(%d)", int(t))
+	}
+	return transformNames[t]
+}
+
+// OrientationTransform returns the transform that displays an image with
+// EXIF orientation o (1–8) upright.
+func OrientationTransform(o int) Transform {
+	switch o {
+	case 2:
+		return FlipHorizontal
+	case 3:
+		return Rotate180
+	case 4:
+		return FlipVertical
+	case 5:
+		return Transpose
+	case 6:
+		return Rotate90
+	case 7:
+		return Transverse
+	case 8:
+		return Rotate270
+	}
+	return NoTransform
+}
+
+// SwapsAxes reports whether t exchanges width and height.
+func (t Transform) SwapsAxes() bool {
+	swap, _, _ := t.axes()
+	return swap
+}
+
+// axes describes t as a mapping from source to destination pixels: swap
+// exchanges x and y, then mirrorX and mirrorY reverse the destination
+// axes.
+func (t Transform) axes() (swap, mirrorX, mirrorY bool) {
+	switch t {
+	case FlipHorizontal:
+		return false, true, false
+	case FlipVertical:
+		return false, false, true
+	case Transpose:
+		return true, false, false
+	case Transverse:
+		return true, true, true
+	case Rotate90:
+		return true, true, false
+	case Rotate180:
+		return false, true, true
+	case Rotate270:
+		return true, false, true
+	}
+	return false, false, false
+}
+
+// Transform returns a copy of c rotated or flipped by t. Blocks are moved
+// and, within each block, coefficients are transposed and odd frequencies
+// negated, with the quantization tables transposed to match, so no pixel
+// changes. An edge whose partial blocks would end up at the top or left of
+// the result is trimmed to whole blocks, as with jpegtran -trim; an image
+// too small to keep a block is an error.
+func (c *Coefficients) Transform(t Transform) (*Coefficients, error) {
+	swap, mirrorX, mirrorY := t.axes()
+	w, h := c.Width, c.Height
+	trimX, trimY := mirrorX, mirrorY
+	if swap {
+		trimX, trimY = mirrorY, mirrorX
+	}
+	if trimX {
+		w -= w % 8
+	}
+	if trimY {
+		h -= h % 8
+	}
+	if w == 0 || h == 0 {
+		return nil, fmt.Errorf("%dx%d image is too small to %s", c.Width, c.Height, t)
+	}
+
+	out := c.withSize(w, h)
+	if swap {
+		// Each coefficient keeps the divisor it was quantized with.
+		out.Width, out.Height = h, w
+		out.jfif[1], out.jfif[2] = c.jfif[2], c.jfif[1]
+		for i, q := range out.QuantTables {
+			for v := 0; v < 8; v++ {
+				for u := 0; u < 8; u++ {
+					out.QuantTables[i][v*8+u] = q[u*8+v]
+				}
+			}
+		}
+	}
+	srcWide := c.BlocksWide()
+	dstWide, dstHigh := out.BlocksWide(), out.BlocksHigh()
+	for p, src := range c.Planes {
+		dst := make([]int16, dstWide*dstHigh*64)
+		for by := 0; by < dstHigh; by++ {
+			for bx := 0; bx < dstWide; bx++ {
+				sx, sy := bx, by
+				if mirrorX {
+					sx = dstWide - 1 - sx
+				}
+				if mirrorY {
+					sy = dstHigh - 1 - sy
+				}
+				if swap {
+					sx, sy = sy, sx
+				}
+				transformBlock(dst[(by*dstWide+bx)*64:][:64], src[(sy*srcWide+sx)*64:][:64], swap, mirrorX, mirrorY)
+			}
+		}
+		out.Planes[p] = dst
+	}
+	return out, nil
+}
+
+// transformBlock writes the coefficients of src, transposed if swap is
+// set, to dst. Mirroring a block in x negates its odd horizontal
+// frequencies, and likewise in y.
+func transformBlock(dst, src []int16, swap, mirrorX, mirrorY bool) {
+	for v := 0; v < 8; v++ {
+		for u := 0; u < 8; u++ {
+			c := src[v*8+u]
+			if swap {
+				c = src[u*8+v]
+			}
+			if mirrorX && u%2 == 1 {
+				c = -c
+			}
+			if mirrorY && v%2 == 1 {
+				c = -c
+			}
+			dst[v*8+u] = c
+		}
+	}
+}
+
+// AlignCrop returns the region Crop keeps for r: its top-left corner
+// moved up and left to a block boundary, with the bottom-right corner
+// unchanged.
+func AlignCrop(r image.Rectangle) image.Rectangle {
+	r.Min.X -= r.Min.X % 8
+	r.Min.Y -= r.Min.Y % 8
+	return r
+}
+
+// Crop returns the part of c inside AlignCrop(r). Only the top-left
+// corner has to be on a block boundary: the right and bottom edges can
+// fall inside a block, as at the edge of any image.
+func (c *Coefficients) Crop(r image.Rectangle) (*Coefficients, error) {
+	r = AlignCrop(r.Canon())
+	if r.Empty() || !r.In(image.Rect(0, 0, c.Width, c.Height)) {
+		return nil, fmt.Errorf("crop %dx%d+%d+%d is outside the %dx%d image",
+			r.Dx(), r.Dy(), r.Min.X, r.Min.Y, c.Width, c.Height)
+	}
+	out := c.withSize(r.Dx(), r.Dy())
+	srcWide := c.BlocksWide()
+	dstWide, dstHigh := out.BlocksWide(), out.BlocksHigh()
+	x0, y0 := r.Min.X/8, r.Min.Y/8
+	for p, src := range c.Planes {
+		dst := make([]int16, 0, dstWide*dstHigh*64)
+		for by := 0; by < dstHigh; by++ {
+			row := ((y0+by)*srcWide + x0) * 64
+			dst = append(dst, src[row:row+dstWide*64]...)
+		}
+		out.Planes[p] = dst
+	}
+	return out, nil
+}
+
+// withSize returns a copy of c's header and markers for a w x h image,
+// without coefficients.
+func (c *Coefficients) withSize(w, h int) *Coefficients {
+	out := *c
+	out.Width, out.Height = w, h
+	out.Planes = [4][]int16{}
+	out.QuantTables = append([][64]uint16(nil), c.QuantTables...)
+	out.Markers = append([]Marker(nil), c.Markers...)
+	return &out
+}
@@ -0,0 +1,198 @@
+package jpeg
+
+import (
+	"bytes"
+	"image"
+	"slices"
+	"testing"
+)
+
+// gradientCMYK returns a w x h CMYK image with a different gradient in
+// each plate, so every transform changes the pixels.
+func gradientCMYK(w, h int) []byte {
+	pixels := make([]byte, w*h*4)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := (y*w + x) * 4
+			pixels[i] = byte(x * 255 / w)
+			pixels[i+1] = byte(y * 255 / h)
+			pixels[i+2] = byte((x + 2*y) % 256)
+			pixels[i+3] = byte((x * y) % 200)
+		}
+	}
+	return pixels
+}
+
+func TestCoefficientsTransform(t *testing.T) {
+	const w, h = 45, 30 // partial blocks on both edges
+	icc := bytes.Repeat([]byte("profile"), 10)
+	src, err := EncodeCMYK(gradientCMYK(w, h), w, h, icc, EncoderOptions{
+		Quality: 95,
+		APP1:    [][]byte{[]byte("Exif\x00\x00not really")},
+		Density: Density{X: 300, Y: 300},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig, err := DecodeCMYK(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := ReadCoefficients(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Width != w || c.Height != h || c.Convention != AdobeInverted || c.JFIF().X != 300 {
+		t.Fatalf("read %dx%d %v %v", c.Width, c.Height, c.Convention, c.JFIF())
+	}
+	if len(c.Markers) != 2 || c.Markers[0].Code != MarkerAPP1 || c.Markers[1].Code != MarkerAPP2 {
+		t.Fatalf("markers %+v", c.Markers)
+	}
+
+	for _, tc := range []struct {
+		t           Transform
+		w, h        int
+		pixel       func(x, y int) (int, int) // source of a destination pixel
+		progressive bool
+	}{
+		{NoTransform, 45, 30, func(x, y int) (int, int) { return x, y }, true},
+		{FlipHorizontal, 40, 30, func(x, y int) (int, int) { return 39 - x, y }, false},
+		{FlipVertical, 45, 24, func(x, y int) (int, int) { return x, 23 - y }, false},
+		{Transpose, 30, 45, func(x, y int) (int, int) { return y, x }, false},
+		{Transverse, 24, 40, func(x, y int) (int, int) { return 39 - y, 23 - x }, false},
+		{Rotate90, 24, 45, func(x, y int) (int, int) { return y, 23 - x }, true},
+		{Rotate180, 40, 24, func(x, y int) (int, int) { return 39 - x, 23 - y }, false},
+		{Rotate270, 30, 40, func(x, y int) (int, int) { return 39 - y, x }, false},
+	} {
+		out, err := c.Transform(tc.t)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.t, err)
+		}
+		var scans []Scan
+		if tc.progressive {
+			scans = DefaultCMYKScans()
+		}
+		data, err := out.Encode(scans)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.t, err)
+		}
+		info, err := GetInfo(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Width != tc.w || info.Height != tc.h || info.Progressive != tc.progressive {
+			t.Errorf("%s: %dx%d progressive %v, want %dx%d %v", tc.t, info.Width, info.Height, info.Progressive, tc.w, tc.h, tc.progressive)
+			continue
+		}
+		if !bytes.Equal(info.ICC, icc) {
+			t.Errorf("%s: ICC profile not kept", tc.t)
+		}
+
+		back, err := ReadCoefficients(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for p := range out.Planes {
+			if !slices.Equal(back.Planes[p], out.Planes[p]) {
+				t.Errorf("%s: plane %d changed when written", tc.t, p)
+			}
+		}
+
+		// The IDCT rounds differently along rows and columns, so a
+		// transposed block can decode one level off.
+		d, err := DecodeCMYK(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		worst := 0
+		for y := 0; y < tc.h; y++ {
+			for x := 0; x < tc.w; x++ {
+				sx, sy := tc.pixel(x, y)
+				for k := 0; k < 4; k++ {
+					diff := int(d.Pixels[(y*tc.w+x)*4+k]) - int(orig.Pixels[(sy*w+sx)*4+k])
+					worst = max(worst, diff, -diff)
+				}
+			}
+		}
+		if worst > 1 {
+			t.Errorf("%s: pixels differ by up to %d", tc.t, worst)
+		}
+	}
+}
+
+func TestCoefficientsRotateIdentity(t *testing.T) {
+	src, err := EncodeCMYK(gradientCMYK(32, 16), 32, 16, nil, EncoderOptions{Quality: 80, Convention: Plain})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := ReadCoefficients(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := c
+	for i := 0; i < 4; i++ {
+		if r, err = r.Transform(Rotate90); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for p := range c.Planes {
+		if !slices.Equal(r.Planes[p], c.Planes[p]) {
+			t.Errorf("plane %d changed by four quarter turns", p)
+		}
+	}
+	data, err := r.Encode(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := GetInfo(data); info.Convention != Plain {
+		t.Error("plain file gained an Adobe marker")
+	}
+}
+
+func TestCoefficientsCrop(t *testing.T) {
+	const w, h = 45, 30
+	src, err := EncodeCMYK(gradientCMYK(w, h), w, h, nil, EncoderOptions{Quality: 90})
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig, _ := DecodeCMYK(src)
+	c, err := ReadCoefficients(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := image.Rect(10, 3, 45, 20)
+	if got, want := AlignCrop(r), image.Rect(8, 0, 45, 20); got != want {
+		t.Errorf("AlignCrop = %v, want %v", got, want)
+	}
+	out, err := c.Crop(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := out.Encode(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := DecodeCMYK(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Width != 37 || d.Height != 20 {
+		t.Fatalf("cropped to %dx%d, want 37x20", d.Width, d.Height)
+	}
+	// Without subsampling every block decodes on its own, so the kept
+	// pixels match exactly.
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 32; x++ {
+			for k := 0; k < 4; k++ {
+				if a, b := d.Pixels[(y*37+x)*4+k], orig.Pixels[(y*w+x+8)*4+k]; a != b {
+					t.Fatalf("pixel %d,%d plate %d = %d, want %d", x, y, k, a, b)
+				}
+			}
+		}
+	}
+
+	if _, err := c.Crop(image.Rect(40, 0, 50, 10)); err == nil {
+		t.Error("crop past the right edge accepted")
+	}
+}
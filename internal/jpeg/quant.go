@@ -1,5 +1,12 @@
 package jpeg
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
 // Standard JPEG luminance quantization table (from JPEG spec, Annex K).
 var stdLuminanceQuant = [64]int{
 	16, 11, 10, 16, 24, 40, 51, 61,
@@ -12,6 +19,19 @@ var stdLuminanceQuant = [64]int{
 	72, 92, 95, 98, 112, 100, 103, 99,
 }
 
+// Standard JPEG chrominance quantization table (Annex K), which IJG
+// encoders use for Cb and Cr.
+var stdChrominanceQuant = [64]int{
+	17, 18, 24, 47, 99, 99, 99, 99,
+	18, 21, 26, 66, 99, 99, 99, 99,
+	24, 26, 56, 99, 99, 99, 99, 99,
+	47, 66, 99, 99, 99, 99, 99, 99,
+	99, 99, 99, 99, 99, 99, 99, 99,
+	99, 99, 99, 99, 99, 99, 99, 99,
+	99, 99, 99, 99, 99, 99, 99, 99,
+	99, 99, 99, 99, 99, 99, 99, 99,
+}
+
 // ScaleQuantTable scales a base quantization table by a quality factor (1-100)
 // using the standard IJG formula.
 func ScaleQuantTable(base [64]int, quality int) [64]uint16 {
@@ -55,3 +75,229 @@ func GenerateQuantTables(quality, cmyReduction int) (cmy [64]uint16, k [64]uint1
 	k = ScaleQuantTable(stdLuminanceQuant, quality)
 	return
 }
+
+// baseTables are the alternative base tables offered by mozjpeg's cjpeg
+// -quant-table option, in its numbering. Entries above 255 are clamped
+// after scaling, since baseline JPEG stores 8-bit tables.
+var baseTables = []struct {
+	name  string
+	table [64]int
+}{
+	{"annex-k", stdLuminanceQuant},
+	{"flat", [64]int{
+		16, 16, 16, 16, 16, 16, 16, 16,
+		16, 16, 16, 16, 16, 16, 16, 16,
+		16, 16, 16, 16, 16, 16, 16, 16,
+		16, 16, 16, 16, 16, 16, 16, 16,
+		16, 16, 16, 16, 16, 16, 16, 16,
+		16, 16, 16, 16, 16, 16, 16, 16,
+		16, 16, 16, 16, 16, 16, 16, 16,
+		16, 16, 16, 16, 16, 16, 16, 16,
+	}},
+	{"ms-ssim", [64]int{ // tuned for MS-SSIM on the Kodak set
+		12, 17, 20, 21, 30, 34, 56, 63,
+		18, 20, 20, 26, 28, 51, 61, 55,
+		19, 20, 21, 26, 33, 58, 69, 55,
+		26, 26, 26, 30, 46, 87, 86, 66,
+		31, 33, 36, 40, 46, 96, 100, 73,
+		40, 35, 46, 62, 81, 100, 111, 91,
+		46, 66, 76, 86, 102, 121, 120, 101,
+		68, 90, 90, 96, 113, 102, 105, 103,
+	}},
+	{"imagemagick", [64]int{ // N. Robidoux, ImageMagick forum
+		16, 16, 16, 18, 25, 37, 56, 85,
+		16, 17, 20, 27, 34, 40, 53, 75,
+		16, 20, 24, 31, 43, 62, 91, 135,
+		18, 27, 31, 40, 53, 74, 106, 156,
+		25, 34, 43, 53, 69, 94, 131, 189,
+		37, 40, 62, 74, 94, 124, 169, 238,
+		56, 53, 91, 106, 131, 169, 226, 311,
+		85, 75, 135, 156, 189, 238, 311, 418,
+	}},
+	{"psnr-hvs", [64]int{ // tuned for PSNR-HVS-M on the Kodak set
+		9, 10, 12, 14, 27, 32, 51, 62,
+		11, 12, 14, 19, 27, 44, 59, 73,
+		12, 14, 18, 25, 42, 59, 79, 78,
+		17, 18, 25, 42, 61, 92, 87, 92,
+		23, 28, 42, 75, 79, 112, 112, 99,
+		40, 42, 59, 84, 88, 124, 132, 111,
+		42, 64, 78, 95, 105, 126, 125, 99,
+		70, 75, 100, 102, 116, 100, 107, 98,
+	}},
+	{"klein", [64]int{ // Klein, Silverstein and Carney (1992)
+		10, 12, 14, 19, 26, 38, 57, 86,
+		12, 18, 21, 28, 35, 41, 54, 76,
+		14, 21, 25, 32, 44, 63, 92, 136,
+		19, 28, 32, 41, 54, 75, 107, 157,
+		26, 35, 44, 54, 70, 95, 132, 190,
+		38, 41, 63, 75, 95, 125, 170, 239,
+		57, 54, 92, 107, 132, 170, 227, 312,
+		86, 76, 136, 157, 190, 239, 312, 419,
+	}},
+	{"watson", [64]int{ // Watson, Taylor and Borthwick (1997)
+		7, 8, 10, 14, 23, 44, 95, 241,
+		8, 8, 11, 15, 25, 47, 102, 255,
+		10, 11, 13, 19, 31, 58, 127, 255,
+		14, 15, 19, 27, 44, 83, 181, 255,
+		23, 25, 31, 44, 72, 136, 255, 255,
+		44, 47, 58, 83, 136, 255, 255, 255,
+		95, 102, 127, 181, 255, 255, 255, 255,
+		241, 255, 255, 255, 255, 255, 255, 255,
+	}},
+	{"ahumada", [64]int{ // Ahumada, Watson and Peterson (1993)
+		15, 11, 11, 12, 15, 19, 25, 32,
+		11, 13, 10, 10, 12, 15, 19, 24,
+		11, 10, 14, 14, 16, 18, 22, 27,
+		12, 10, 14, 18, 21, 24, 28, 33,
+		15, 12, 16, 21, 26, 31, 36, 42,
+		19, 15, 18, 24, 31, 38, 45, 53,
+		25, 19, 22, 28, 36, 45, 55, 65,
+		32, 24, 27, 33, 42, 53, 65, 77,
+	}},
+	{"peterson", [64]int{ // Peterson, Ahumada and Watson (1993)
+		14, 10, 11, 14, 19, 25, 34, 45,
+		10, 11, 11, 12, 15, 20, 26, 33,
+		11, 11, 15, 18, 21, 25, 31, 38,
+		14, 12, 18, 24, 28, 33, 39, 47,
+		19, 15, 21, 28, 36, 43, 51, 59,
+		25, 20, 25, 33, 43, 54, 64, 74,
+		34, 26, 31, 39, 51, 64, 77, 91,
+		45, 33, 38, 47, 59, 74, 91, 108,
+	}},
+}
+
+// BaseTableNames lists the names accepted by BaseTable, in cjpeg's
+// -quant-table order.
+func BaseTableNames() []string {
+	names := make([]string, len(baseTables))
+	for i, t := range baseTables {
+		names[i] = t.name
+	}
+	return names
+}
+
+// BaseTable returns a built-in base quantization table by name or by its
+// cjpeg -quant-table number.
+func BaseTable(name string) ([64]int, error) {
+	for i, t := range baseTables {
+		if name == t.name || name == strconv.Itoa(i) {
+			return t.table, nil
+		}
+	}
+	return [64]int{}, fmt.Errorf("unknown quantization table %q (want one of %s)", name, strings.Join(BaseTableNames(), ", "))
+}
+
+// ParseQuantTables parses a quantization table file in cjpeg -qtables
+// format: 64 integers per table in natural (row-major) order, separated by
+// white space or commas, with # comments. Up to four tables are allowed.
+// Entries may be 1-32767, as in cjpeg: ScaleQuantTable clamps the scaled
+// values to 255, and above quality 50 the scaling divides, so a base entry
+// of 418 becomes 125 at quality 85.
+func ParseQuantTables(text string) ([][64]int, error) {
+	var values []int
+	for n, line := range strings.Split(text, "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		for _, f := range strings.FieldsFunc(line, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+			v, err := strconv.Atoi(f)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: bad table entry %q", n+1, f)
+			}
+			if v < 1 || v > 32767 {
+				return nil, fmt.Errorf("line %d: table entry %d out of range 1-32767", n+1, v)
+			}
+			values = append(values, v)
+		}
+	}
+	switch {
+	case len(values) == 0:
+		return nil, fmt.Errorf("no quantization tables")
+	case len(values)%64 != 0:
+		return nil, fmt.Errorf("%d table entries is not a multiple of 64", len(values))
+	case len(values) > 4*64:
+		return nil, fmt.Errorf("%d tables, at most 4 allowed", len(values)/64)
+	}
+	tables := make([][64]int, len(values)/64)
+	for i := range tables {
+		copy(tables[i][:], values[i*64:])
+	}
+	return tables, nil
+}
+
+// ChannelQuantTables scales a table for each of C, M, Y and K at its own
+// quality. bases holds one to four base tables for the channels in order,
+// the last repeated for any channels left over; nil uses the Annex K table
+// for all four.
+func ChannelQuantTables(qualities [4]int, bases [][64]int) [4][64]uint16 {
+	if len(bases) == 0 {
+		bases = [][64]int{stdLuminanceQuant}
+	}
+	var tables [4][64]uint16
+	for c := range tables {
+		tables[c] = ScaleQuantTable(bases[min(c, len(bases)-1)], qualities[c])
+	}
+	return tables
+}
+
+// QualityEstimate is the IJG quality a quantization table appears to have
+// been scaled at.
+type QualityEstimate struct {
+	Quality    int
+	Base       string // base table name, or "annex-k-chroma" for the Annex K chrominance table
+	Exact      bool   // the table is exactly Base scaled at Quality
+	Transposed bool   // the match is for the transposed table, as after a lossless quarter turn
+}
+
+// String returns e.g. "85 (annex-k)" or "~83 (annex-k, transposed)".
+func (e QualityEstimate) String() string {
+	approx, base := "~", e.Base
+	if e.Exact {
+		approx = ""
+	}
+	if e.Transposed {
+		base += ", transposed"
+	}
+	return fmt.Sprintf("%s%d (%s)", approx, e.Quality, base)
+}
+
+// EstimateQuality finds the base table and quality whose IJG scaling comes
+// closest to table, or to its transpose, by the sum of absolute
+// differences. The Annex K luminance and chrominance tables and the other
+// built-in bases are tried; ties go to the untransposed table, the base
+// tried first and then the lower quality.
+func EstimateQuality(table [64]uint16) QualityEstimate {
+	bases := []struct {
+		name  string
+		table [64]int
+	}{{baseTables[0].name, stdLuminanceQuant}, {"annex-k-chroma", stdChrominanceQuant}}
+	bases = append(bases, baseTables[1:]...)
+
+	var transposed [64]uint16
+	for v := 0; v < 8; v++ {
+		for u := 0; u < 8; u++ {
+			transposed[v*8+u] = table[u*8+v]
+		}
+	}
+
+	best, bestErr := QualityEstimate{}, -1
+	for _, t := range []struct {
+		table      [64]uint16
+		transposed bool
+	}{{table, false}, {transposed, true}} {
+		for _, b := range bases {
+			for q := 1; q <= 100; q++ {
+				scaled := ScaleQuantTable(b.table, q)
+				diff := 0
+				for i, v := range scaled {
+					diff += max(int(v)-int(t.table[i]), int(t.table[i])-int(v))
+				}
+				if bestErr < 0 || diff < bestErr {
+					best = QualityEstimate{Quality: q, Base: b.name, Exact: diff == 0, Transposed: t.transposed}
+					bestErr = diff
+				}
+			}
+		}
+	}
+	return best
+}
@@ -0,0 +1,102 @@
+package jpeg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseQuantTables(t *testing.T) {
+	one := strings.Repeat("2 ", 63) + "3\n"
+	tables, err := ParseQuantTables("# C\n" + one + "# K, comma separated\n" + strings.Repeat("5,", 64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tables) != 2 || tables[0][63] != 3 || tables[1][0] != 5 {
+		t.Fatalf("got %v", tables)
+	}
+
+	// Entries above 255 are kept for scaling, like those of the klein table.
+	tables, err = ParseQuantTables(strings.Repeat("418 ", 64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q := ScaleQuantTable(tables[0], 85); q[0] != 125 {
+		t.Errorf("418 at quality 85 scaled to %d, want 125", q[0])
+	}
+	if q := ScaleQuantTable(tables[0], 50); q[0] != 255 {
+		t.Errorf("418 at quality 50 scaled to %d, want 255", q[0])
+	}
+
+	for _, text := range []string{"", "1 2 3", strings.Repeat("1 ", 5*64), strings.Repeat("0 ", 64), "x" + strings.Repeat(" 1", 63)} {
+		if _, err := ParseQuantTables(text); err == nil {
+			t.Errorf("%.20q: expected an error", text)
+		}
+	}
+}
+
+func TestBaseTable(t *testing.T) {
+	for i, name := range BaseTableNames() {
+		byName, err := BaseTable(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		byNumber, _ := BaseTable(string(rune('0' + i)))
+		if byName != byNumber {
+			t.Errorf("%s: number %d gives a different table", name, i)
+		}
+	}
+	if _, err := BaseTable("jpeg"); err == nil {
+		t.Error("expected an error for an unknown table")
+	}
+}
+
+func TestChannelQuantTables(t *testing.T) {
+	tables := ChannelQuantTables(EncoderOptions{Quality: 90, CMYReduction: 15, ChannelQuality: [4]int{2: 50}}.Qualities(), nil)
+	cmy, k := GenerateQuantTables(90, 15)
+	if tables[0] != cmy || tables[1] != cmy || tables[3] != k {
+		t.Error("C, M and K should follow Quality and CMYReduction")
+	}
+	if tables[2] != ScaleQuantTable(stdLuminanceQuant, 50) {
+		t.Error("Y should use its own quality")
+	}
+	if q := (EncoderOptions{Quality: 100}).Qualities(); q != [4]int{100, 100, 100, 100} {
+		t.Errorf("no CMY reduction gives %v", q)
+	}
+
+	flat, _ := BaseTable("flat")
+	tables = ChannelQuantTables([4]int{50, 50, 50, 50}, [][64]int{stdLuminanceQuant, flat})
+	if tables[0][1] != 11 || tables[1][1] != 16 || tables[3] != tables[1] {
+		t.Error("the last base table should be repeated for the remaining channels")
+	}
+}
+
+func TestEstimateQuality(t *testing.T) {
+	ms, _ := BaseTable("ms-ssim")
+	for _, tc := range []struct {
+		table [64]uint16
+		want  QualityEstimate
+	}{
+		{ScaleQuantTable(stdLuminanceQuant, 85), QualityEstimate{85, "annex-k", true, false}},
+		{ScaleQuantTable(stdChrominanceQuant, 70), QualityEstimate{70, "annex-k-chroma", true, false}},
+		{ScaleQuantTable(ms, 40), QualityEstimate{40, "ms-ssim", true, false}},
+	} {
+		if got := EstimateQuality(tc.table); got != tc.want {
+			t.Errorf("got %v, want %v", got, tc.want)
+		}
+	}
+
+	table := ScaleQuantTable(stdLuminanceQuant, 70)
+	var transposed [64]uint16
+	for i, v := range table {
+		transposed[i%8*8+i/8] = v
+	}
+	if got := EstimateQuality(transposed); got.String() != "70 (annex-k, transposed)" {
+		t.Errorf("transposed table: got %v", got)
+	}
+
+	table = ScaleQuantTable(stdLuminanceQuant, 60)
+	table[63]++
+	if got := EstimateQuality(table); got.Quality != 60 || got.Exact || got.String() != "~60 (annex-k)" {
+		t.Errorf("nudged table: got %v", got)
+	}
+}
@@ -0,0 +1,125 @@
+package jpeg
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Scan is one entry of a JPEG scan script: the components it carries
+// (0 = C, 1 = M, 2 = Y, 3 = K) and its spectral selection (Ss–Se) and
+// successive approximation (Ah, Al) parameters.
+type Scan struct {
+	Components []int
+	Ss, Se     int
+	Ah, Al     int
+}
+
+// DefaultCMYKScanScript is the progressive script used by --progressive. K
+// carries most of the visible detail in a separation, as luma does in
+// YCbCr, so it leads every stage: its DC first, then its low frequencies,
+// before the colour plates get their coarse AC pass.
+const DefaultCMYKScanScript = `# K-first progressive script for CMYK
+3: 0-0, 0, 1;          # K DC at half precision
+0 1 2: 0-0, 0, 1;      # C, M, Y DC
+3: 1-5, 0, 2;          # K low frequencies
+0: 1-63, 0, 1;         # C, M, Y AC, one bit short
+1: 1-63, 0, 1;
+2: 1-63, 0, 1;
+3: 6-63, 0, 2;         # rest of K
+3: 1-63, 2, 1;         # K AC refinement
+0 1 2 3: 0-0, 1, 0;    # DC refinement
+0: 1-63, 1, 0;         # final AC bits
+1: 1-63, 1, 0;
+2: 1-63, 1, 0;
+3: 1-63, 1, 0;
+`
+
+// DefaultCMYKScans returns the parsed DefaultCMYKScanScript.
+func DefaultCMYKScans() []Scan {
+	scans, err := ParseScanScript(DefaultCMYKScanScript)
+	if err != nil {
+		panic(err)
+	}
+	return scans
+}
+
+var progressionRe = regexp.MustCompile(`^(\d+)\s*-\s*(\d+)\s*,\s*(\d+)\s*,\s*(\d+)$`)
+
+// ParseScanScript reads a scan script in the format of cjpeg's -scans
+// option: scans separated by semicolons, each a list of component indexes
+// optionally followed by ": Ss-Se, Ah, Al", with # comments. A scan without
+// progression parameters is a full sequential scan (0-63, 0, 0).
+//
+// Each scan is checked on its own; libjpeg checks that the script as a whole
+// covers every coefficient bit exactly once when it is used.
+func ParseScanScript(text string) ([]Scan, error) {
+	var b strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		b.WriteString(line)
+		b.WriteByte(' ')
+	}
+
+	var scans []Scan
+	for n, part := range strings.Split(b.String(), ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		s := Scan{Ss: 0, Se: 63}
+		comps, prog, hasProg := strings.Cut(part, ":")
+		for _, f := range strings.FieldsFunc(comps, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' || r == '\r' }) {
+			c, err := strconv.Atoi(f)
+			if err != nil {
+				return nil, fmt.Errorf("scan %d: bad component %q", n+1, f)
+			}
+			s.Components = append(s.Components, c)
+		}
+		if hasProg {
+			m := progressionRe.FindStringSubmatch(strings.TrimSpace(prog))
+			if m == nil {
+				return nil, fmt.Errorf("scan %d: expected \"Ss-Se, Ah, Al\", got %q", n+1, strings.TrimSpace(prog))
+			}
+			s.Ss, _ = strconv.Atoi(m[1])
+			s.Se, _ = strconv.Atoi(m[2])
+			s.Ah, _ = strconv.Atoi(m[3])
+			s.Al, _ = strconv.Atoi(m[4])
+		}
+		if err := s.validate(); err != nil {
+			return nil, fmt.Errorf("scan %d: %w", n+1, err)
+		}
+		scans = append(scans, s)
+	}
+	if len(scans) == 0 {
+		return nil, fmt.Errorf("scan script has no scans")
+	}
+	return scans, nil
+}
+
+func (s Scan) validate() error {
+	if len(s.Components) == 0 || len(s.Components) > 4 {
+		return fmt.Errorf("%d components, want 1-4", len(s.Components))
+	}
+	for i, c := range s.Components {
+		if c < 0 || c > 3 {
+			return fmt.Errorf("component %d out of range (0-3 for C, M, Y, K)", c)
+		}
+		if i > 0 && c <= s.Components[i-1] {
+			return fmt.Errorf("components must be listed in increasing order")
+		}
+	}
+	if s.Ss < 0 || s.Se > 63 || s.Ss > s.Se {
+		return fmt.Errorf("spectral range %d-%d invalid", s.Ss, s.Se)
+	}
+	if s.Ss > 0 && len(s.Components) > 1 {
+		return fmt.Errorf("AC scans (Ss > 0) must carry a single component")
+	}
+	if s.Ah > 13 || s.Al > 13 {
+		return fmt.Errorf("successive approximation %d, %d out of range (0-13)", s.Ah, s.Al)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package jpeg
+
+import "testing"
+
+func TestParseScanScript(t *testing.T) {
+	scans, err := ParseScanScript("# comment\n3: 0-0, 0, 1;\n0,1,2: 0-0,0,1 ;\n3\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scans) != 3 {
+		t.Fatalf("got %d scans, want 3", len(scans))
+	}
+	if s := scans[1]; len(s.Components) != 3 || s.Components[2] != 2 || s.Se != 0 || s.Al != 1 {
+		t.Errorf("scan 2 = %+v", s)
+	}
+	if s := scans[2]; s.Ss != 0 || s.Se != 63 || s.Ah != 0 || s.Al != 0 {
+		t.Errorf("scan without progression = %+v, want sequential", s)
+	}
+	if len(DefaultCMYKScans()) == 0 || DefaultCMYKScans()[0].Components[0] != 3 {
+		t.Error("default script should start with K")
+	}
+}
+
+func TestParseScanScriptErrors(t *testing.T) {
+	for _, script := range []string{
+		"",
+		"4: 0-0, 0, 0",
+		"1 0: 0-0, 0, 0",
+		"0 1: 1-63, 0, 0",
+		"0: 1-64, 0, 0",
+		"0: 1-63",
+		"c: 0-63, 0, 0",
+	} {
+		if _, err := ParseScanScript(script); err == nil {
+			t.Errorf("%q: expected an error", script)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package jpeg
+
+import (
+	"fmt"
+)
+
+// Segment is one marker in a JPEG file.
+type Segment struct {
+	Marker  byte   // marker code, e.g. 0xDB for DQT
+	Offset  int    // position of the marker's 0xFF
+	Length  int    // length field and payload in bytes, 0 for SOI, EOI and RSTn
+	Entropy int    // SOS only: entropy-coded bytes that follow, restart markers included
+	Ident   string // APPn only: the payload's identifier, e.g. "Exif" or "ICC_PROFILE"
+}
+
+// Name returns the marker's name, such as "SOF2", "DHT" or "APP13".
+func (s Segment) Name() string {
+	m := s.Marker
+	switch {
+	case m == 0xC4:
+		return "DHT"
+	case m == 0xC8:
+		return "JPG"
+	case m == 0xCC:
+		return "DAC"
+	case m >= 0xC0 && m <= 0xCF:
+		return fmt.Sprintf("SOF%d", m-0xC0)
+	case m >= 0xD0 && m <= 0xD7:
+		return fmt.Sprintf("RST%d", m-0xD0)
+	case m >= 0xE0 && m <= 0xEF:
+		return fmt.Sprintf("APP%d", m-0xE0)
+	}
+	switch m {
+	case 0xD8:
+		return "SOI"
+	case 0xD9:
+		return "EOI"
+	case 0xDA:
+		return "SOS"
+	case 0xDB:
+		return "DQT"
+	case 0xDC:
+		return "DNL"
+	case 0xDD:
+		return "DRI"
+	case 0xFE:
+		return "COM"
+	}
+	return fmt.Sprintf("0x%02X", m)
+}
+
+// standalone reports whether marker m has no length field.
+func standalone(m byte) bool {
+	return m == 0xD8 || m == 0xD9 || m == 0x01 || (m >= 0xD0 && m <= 0xD7)
+}
+
+// Segments lists the markers of a JPEG file in order, up to EOI. An
+// entropy-coded scan that runs to the end of the data, as in a truncated
+// file, ends the list without an error; a malformed marker returns the
+// segments before it with the error.
+func Segments(data []byte) ([]Segment, error) {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, fmt.Errorf("missing SOI marker")
+	}
+	segs := []Segment{{Marker: 0xD8}}
+	pos := 2
+	for pos < len(data) {
+		if data[pos] != 0xFF {
+			return segs, fmt.Errorf("expected a marker at offset %d, found 0x%02X", pos, data[pos])
+		}
+		start := pos
+		for pos < len(data) && data[pos] == 0xFF { // fill bytes
+			pos++
+		}
+		if pos >= len(data) {
+			break
+		}
+		seg := Segment{Marker: data[pos], Offset: start}
+		pos++
+		if standalone(seg.Marker) {
+			segs = append(segs, seg)
+			if seg.Marker == 0xD9 {
+				break
+			}
+			continue
+		}
+
+		if pos+2 > len(data) {
+			return segs, fmt.Errorf("%s at offset %d is truncated", seg.Name(), start)
+		}
+		seg.Length = int(data[pos])<<8 | int(data[pos+1])
+		if seg.Length < 2 || pos+seg.Length > len(data) {
+			return segs, fmt.Errorf("%s at offset %d has bad length %d", seg.Name(), start, seg.Length)
+		}
+		payload := data[pos+2 : pos+seg.Length]
+		pos += seg.Length
+		if seg.Marker >= 0xE0 && seg.Marker <= 0xEF {
+			seg.Ident = appIdent(payload)
+		}
+		if seg.Marker == 0xDA {
+			end := pos
+			for end < len(data) {
+				if data[end] == 0xFF && end+1 < len(data) {
+					next := data[end+1]
+					if next != 0x00 && next != 0xFF && (next < 0xD0 || next > 0xD7) {
+						break
+					}
+				}
+				end++
+			}
+			seg.Entropy = end - pos
+			pos = end
+		}
+		segs = append(segs, seg)
+	}
+	return segs, nil
+}
+
+// appIdent returns the identifier an APPn payload starts with: the text
+// up to the first NUL or non-printable byte, at most 40 bytes.
+func appIdent(payload []byte) string {
+	n := 0
+	for n < len(payload) && n < 40 && payload[n] >= 0x20 && payload[n] < 0x7F {
+		n++
+	}
+	return string(payload[:n])
+}
@@ -0,0 +1,99 @@
+package jpeg
+
+import (
+	"testing"
+)
+
+func TestSegments(t *testing.T) {
+	data := []byte{
+		0xFF, 0xD8,
+		0xFF, 0xE1, 0x00, 0x08, 'E', 'x', 'i', 'f', 0, 0,
+		0xFF, 0xFF, 0xDD, 0x00, 0x04, 0x00, 0x10, // fill byte before DRI
+		0xFF, 0xDA, 0x00, 0x03, 0x01,
+		0x12, 0xFF, 0x00, 0x34, 0xFF, 0xD0, 0x56, // stuffed byte and RST0
+		0xFF, 0xD9,
+		0xAA, // trailing garbage
+	}
+	segs, err := Segments(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		name           string
+		offset, length int
+		entropy        int
+		ident          string
+	}{
+		{"SOI", 0, 0, 0, ""},
+		{"APP1", 2, 8, 0, "Exif"},
+		{"DRI", 12, 4, 0, ""},
+		{"SOS", 19, 3, 7, ""},
+		{"EOI", 31, 0, 0, ""},
+	}
+	if len(segs) != len(want) {
+		t.Fatalf("got %d segments: %+v", len(segs), segs)
+	}
+	for i, w := range want {
+		s := segs[i]
+		if s.Name() != w.name || s.Offset != w.offset || s.Length != w.length || s.Entropy != w.entropy || s.Ident != w.ident {
+			t.Errorf("segment %d = %s %+v, want %+v", i, s.Name(), s, w)
+		}
+	}
+
+	if segs, err := Segments(data[:8]); err == nil || len(segs) != 1 {
+		t.Errorf("truncated APP1: %d segments, error %v", len(segs), err)
+	}
+	if _, err := Segments([]byte{0xFF, 0xD9}); err == nil {
+		t.Error("missing SOI accepted")
+	}
+}
+
+func TestGetInfoStructure(t *testing.T) {
+	pixels := make([]byte, 16*16*4)
+	data, err := EncodeCMYK(pixels, 16, 16, nil, EncoderOptions{
+		Quality: 90, CMYReduction: 15,
+		APP1:    [][]byte{[]byte("Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08\x00\x00")},
+		Density: Density{X: 300, Y: 300},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := GetInfo(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Coding() != "baseline" || info.Precision != 8 || info.RestartInterval != 0 {
+		t.Errorf("coding %q, precision %d, restart %d", info.Coding(), info.Precision, info.RestartInterval)
+	}
+	if info.AdobeTransform != 0 || info.JFIFVersion != "1.01" || !info.EXIF {
+		t.Errorf("Adobe transform %d, JFIF %q, EXIF %v", info.AdobeTransform, info.JFIFVersion, info.EXIF)
+	}
+	if len(info.Components) != 4 {
+		t.Fatalf("%d components", len(info.Components))
+	}
+	for c, want := range []int{75, 75, 75, 90} {
+		ci := info.Components[c]
+		if ci.HSamp != 1 || ci.VSamp != 1 || ci.Quality != (QualityEstimate{want, "annex-k", true, false}) {
+			t.Errorf("component %d: %dx%d, quality %v, want %d", c, ci.HSamp, ci.VSamp, ci.Quality, want)
+		}
+	}
+
+	segs := info.Segments
+	if len(segs) < 4 || segs[0].Name() != "SOI" || segs[len(segs)-1].Name() != "EOI" || segs[len(segs)-1].Offset != len(data)-2 {
+		t.Fatalf("segments %+v", segs)
+	}
+	idents := map[string]bool{}
+	for _, s := range segs {
+		idents[s.Name()+" "+s.Ident] = true
+	}
+	for _, want := range []string{"APP0 JFIF", "APP14 Adobe", "APP1 Exif", "SOF0 ", "SOS "} {
+		if !idents[want] {
+			t.Errorf("no %q segment", want)
+		}
+	}
+
+	prog, _ := EncodeCMYK(pixels, 16, 16, nil, EncoderOptions{Progressive: true, Convention: Plain})
+	if info, _ := GetInfo(prog); info.Coding() != "progressive" || info.AdobeTransform != -1 {
+		t.Errorf("progressive: coding %q, Adobe transform %d", info.Coding(), info.AdobeTransform)
+	}
+}
@@ -0,0 +1,67 @@
+package jpeg
+
+import (
+	"errors"
+	"fmt"
+)
+
+// EncodeCMYKToSize encodes at the highest quality whose output, embedded
+// profile included, fits in limit bytes. The K quality (opts.Quality) is
+// searched first with opts.CMYReduction fixed; the reduction is then
+// lowered as far as the limit allows, giving the colour plates back what
+// room is left. Channels set in opts.ChannelQuality are not searched. The
+// returned options record the settings used.
+func EncodeCMYKToSize(pixels []byte, width, height int, iccProfile []byte, opts EncoderOptions, limit int) ([]byte, EncoderOptions, error) {
+	encode := func(quality, reduction int) ([]byte, error) {
+		o := opts
+		o.Quality, o.CMYReduction = quality, reduction
+		return EncodeCMYK(pixels, width, height, iccProfile, o)
+	}
+
+	// The largest quality that fits, with the smallest output kept for the
+	// error message.
+	var best []byte
+	lo, hi := 1, 100
+	for lo <= hi {
+		q := (lo + hi) / 2
+		data, err := encode(q, opts.CMYReduction)
+		if err != nil {
+			return nil, opts, err
+		}
+		if len(data) <= limit {
+			best, opts.Quality = data, q
+			lo = q + 1
+		} else {
+			hi = q - 1
+		}
+	}
+	if best == nil {
+		data, err := encode(1, 100)
+		if err != nil {
+			return nil, opts, err
+		}
+		msg := fmt.Sprintf("cannot fit in %d bytes: quality 1 gives %d bytes", limit, len(data))
+		if len(iccProfile) > 0 {
+			msg += fmt.Sprintf(", %d of them the ICC profile", len(iccProfile))
+		}
+		return nil, opts, errors.New(msg)
+	}
+
+	// A smaller reduction means larger output, so the smallest one that
+	// still fits is found the same way.
+	lo, hi = 0, opts.CMYReduction-1
+	for lo <= hi {
+		r := (lo + hi) / 2
+		data, err := encode(opts.Quality, r)
+		if err != nil {
+			return nil, opts, err
+		}
+		if len(data) <= limit {
+			best, opts.CMYReduction = data, r
+			hi = r - 1
+		} else {
+			lo = r + 1
+		}
+	}
+	return best, opts, nil
+}
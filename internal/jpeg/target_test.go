@@ -0,0 +1,53 @@
+package jpeg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEncodeCMYKToSize(t *testing.T) {
+	width, height := 96, 96
+	pixels := make([]byte, width*height*4)
+	for i := range pixels {
+		pixels[i] = byte(i*31 + i/97)
+	}
+	icc := make([]byte, 3000)
+
+	full, err := EncodeCMYK(pixels, width, height, icc, EncoderOptions{Quality: 95, CMYReduction: 15})
+	if err != nil {
+		t.Fatal(err)
+	}
+	limit := len(full) / 2
+	data, used, err := EncodeCMYKToSize(pixels, width, height, icc, EncoderOptions{Quality: 95, CMYReduction: 15}, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) > limit {
+		t.Fatalf("%d bytes over the %d-byte limit", len(data), limit)
+	}
+	if used.Quality >= 95 || used.Quality < 1 {
+		t.Errorf("chose quality %d", used.Quality)
+	}
+	// One step up in K quality must not fit.
+	up := used
+	up.Quality++
+	if bigger, _ := EncodeCMYK(pixels, width, height, icc, up); len(bigger) <= limit && used.Quality < 100 {
+		t.Errorf("quality %d also fits (%d bytes)", up.Quality, len(bigger))
+	}
+
+	// With room to spare the reduction search reaches zero.
+	best, err := EncodeCMYK(pixels, width, height, icc, EncoderOptions{Quality: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, used, err = EncodeCMYKToSize(pixels, width, height, icc, EncoderOptions{CMYReduction: 15}, len(best)); err != nil {
+		t.Fatal(err)
+	} else if used.Quality != 100 || used.CMYReduction != 0 {
+		t.Errorf("unlimited room chose quality %d, reduction %d", used.Quality, used.CMYReduction)
+	}
+
+	_, _, err = EncodeCMYKToSize(pixels, width, height, icc, EncoderOptions{CMYReduction: 15}, 2000)
+	if err == nil || !strings.Contains(err.Error(), "3000 of them the ICC profile") {
+		t.Errorf("expected a cannot-fit error naming the profile size, got %v", err)
+	}
+}
@@ -0,0 +1,329 @@
+// Package lut freezes an RGB→CMYK separation as a 3D lookup table, reads and
+// writes it as .cube or CSV text, and separates images from it.
+//
+// The .cube variant follows the Resolve/Adobe layout (TITLE, LUT_3D_SIZE,
+// DOMAIN_MIN, DOMAIN_MAX, then one row per grid point with red varying
+// fastest) and adds a LUT_3D_OUTPUT_CHANNELS 4 keyword; each row holds C, M,
+// Y and K coverages from 0 to 1. The CSV variant has the header
+// r,g,b,c,m,y,k and the same rows with the RGB grid coordinates in front.
+package lut
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/davesmith10/RGBtoCMYK/internal/color"
+)
+
+// MaxSize is the largest grid accepted, in points per axis.
+const MaxSize = 256
+
+// LUT is an RGB→CMYK lookup table on a Size×Size×Size grid.
+type LUT struct {
+	Title    string
+	Comments []string  // free-text lines written as # comments
+	Size     int       // grid points per axis
+	Data     []float64 // Size³ × 4 coverages (0–1), red varying fastest
+}
+
+// Sample evaluates a 16-bit float transform (see color.NewFloatTransform)
+// at every grid point.
+func Sample(xf color.Transform, size int) (*LUT, error) {
+	if size < 2 || size > MaxSize {
+		return nil, fmt.Errorf("LUT size %d out of range (2-%d)", size, MaxSize)
+	}
+	n := size * size * size
+	rgb := make([]float32, 0, 3*n)
+	for b := 0; b < size; b++ {
+		for g := 0; g < size; g++ {
+			for r := 0; r < size; r++ {
+				rgb = append(rgb, node(r, size), node(g, size), node(b, size))
+			}
+		}
+	}
+	cmyk, err := xf.TransformFloat16(rgb, n, 1)
+	if err != nil {
+		return nil, err
+	}
+	l := &LUT{Size: size, Data: make([]float64, len(cmyk))}
+	for i, v := range cmyk {
+		l.Data[i] = float64(v) / 65535
+	}
+	return l, nil
+}
+
+func node(i, size int) float32 {
+	return float32(i) / float32(size-1)
+}
+
+// LimitTAC scales down C, M and Y wherever the total coverage exceeds tac
+// (a percentage), keeping K. It returns the number of grid points changed.
+func (l *LUT) LimitTAC(tac float64) int {
+	limit := tac / 100
+	changed := 0
+	for i := 0; i < len(l.Data); i += 4 {
+		p := l.Data[i : i+4]
+		sum := p[0] + p[1] + p[2] + p[3]
+		if sum <= limit+1e-9 {
+			continue
+		}
+		changed++
+		if p[3] >= limit {
+			p[0], p[1], p[2], p[3] = 0, 0, 0, limit
+			continue
+		}
+		f := (limit - p[3]) / (sum - p[3])
+		p[0], p[1], p[2] = p[0]*f, p[1]*f, p[2]*f
+	}
+	return changed
+}
+
+// WriteCube writes the table in the .cube variant described in the package
+// documentation.
+func (l *LUT) WriteCube(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, c := range l.Comments {
+		fmt.Fprintf(bw, "# %s\n", c)
+	}
+	if l.Title != "" {
+		fmt.Fprintf(bw, "TITLE %q\n", l.Title)
+	}
+	fmt.Fprintf(bw, "LUT_3D_SIZE %d\n", l.Size)
+	fmt.Fprintf(bw, "LUT_3D_OUTPUT_CHANNELS 4\n")
+	fmt.Fprintf(bw, "DOMAIN_MIN 0.0 0.0 0.0\n")
+	fmt.Fprintf(bw, "DOMAIN_MAX 1.0 1.0 1.0\n")
+	for i := 0; i < len(l.Data); i += 4 {
+		p := l.Data[i : i+4]
+		fmt.Fprintf(bw, "%.6f %.6f %.6f %.6f\n", p[0], p[1], p[2], p[3])
+	}
+	return bw.Flush()
+}
+
+// WriteCSV writes the table as CSV, with the title and comments as leading
+// # lines.
+func (l *LUT) WriteCSV(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if l.Title != "" {
+		fmt.Fprintf(bw, "# %s\n", l.Title)
+	}
+	for _, c := range l.Comments {
+		fmt.Fprintf(bw, "# %s\n", c)
+	}
+	fmt.Fprintln(bw, "r,g,b,c,m,y,k")
+	i := 0
+	for b := 0; b < l.Size; b++ {
+		for g := 0; g < l.Size; g++ {
+			for r := 0; r < l.Size; r++ {
+				p := l.Data[i : i+4]
+				fmt.Fprintf(bw, "%.6f,%.6f,%.6f,%.6f,%.6f,%.6f,%.6f\n",
+					node(r, l.Size), node(g, l.Size), node(b, l.Size), p[0], p[1], p[2], p[3])
+				i += 4
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// Parse reads a table written by WriteCube or WriteCSV, telling them apart
+// by the CSV header.
+func Parse(data []byte) (*LUT, error) {
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(strings.ToLower(line), "r,g,b,") {
+			return parseCSV(data)
+		}
+		break
+	}
+	return parseCube(data)
+}
+
+func parseCube(data []byte) (*LUT, error) {
+	l := &LUT{}
+	channels := 3
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; sc.Scan(); lineNo++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			l.Comments = append(l.Comments, strings.TrimSpace(line[1:]))
+			continue
+		}
+		fields := strings.Fields(line)
+		switch fields[0] {
+		case "TITLE":
+			t := strings.TrimSpace(strings.TrimPrefix(line, "TITLE"))
+			if u, err := strconv.Unquote(t); err == nil {
+				t = u
+			}
+			l.Title = t
+			continue
+		case "LUT_3D_SIZE", "LUT_3D_OUTPUT_CHANNELS":
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("line %d: %s needs one value", lineNo, fields[0])
+			}
+			v, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			if fields[0] == "LUT_3D_SIZE" {
+				if v < 2 || v > MaxSize {
+					return nil, fmt.Errorf("line %d: LUT size %d out of range (2-%d)", lineNo, v, MaxSize)
+				}
+				l.Size = v
+			} else {
+				channels = v
+			}
+			continue
+		case "DOMAIN_MIN", "DOMAIN_MAX":
+			want := "0"
+			if fields[0] == "DOMAIN_MAX" {
+				want = "1"
+			}
+			for _, f := range fields[1:] {
+				if v, err := strconv.ParseFloat(f, 64); err != nil || fmt.Sprint(v) != want {
+					return nil, fmt.Errorf("line %d: only the 0-1 domain is supported", lineNo)
+				}
+			}
+			continue
+		case "LUT_1D_SIZE":
+			return nil, errors.New("1D LUTs are not supported")
+		}
+		if channels != 4 {
+			return nil, errors.New("not a CMYK LUT: LUT_3D_OUTPUT_CHANNELS 4 is missing")
+		}
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("line %d: expected 4 values, got %d", lineNo, len(fields))
+		}
+		for _, f := range fields {
+			v, err := strconv.ParseFloat(f, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			l.Data = append(l.Data, v)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if l.Size == 0 {
+		return nil, errors.New("LUT_3D_SIZE is missing")
+	}
+	return l, l.check()
+}
+
+func parseCSV(data []byte) (*LUT, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comment = '#'
+	r.FieldsPerRecord = 7
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	rows := records[1:]
+	size := int(math.Round(math.Cbrt(float64(len(rows)))))
+	if size < 2 || size > MaxSize || size*size*size != len(rows) {
+		return nil, fmt.Errorf("%d rows do not form a cubic grid", len(rows))
+	}
+	l := &LUT{Size: size, Data: make([]float64, 0, 4*len(rows))}
+	for i, row := range rows {
+		var v [7]float64
+		for j, f := range row {
+			if v[j], err = strconv.ParseFloat(strings.TrimSpace(f), 64); err != nil {
+				return nil, fmt.Errorf("row %d: %w", i+2, err)
+			}
+		}
+		r, g, b := i%size, i/size%size, i/(size*size)
+		if math.Abs(v[0]-float64(node(r, size))) > 1e-4 || math.Abs(v[1]-float64(node(g, size))) > 1e-4 ||
+			math.Abs(v[2]-float64(node(b, size))) > 1e-4 {
+			return nil, fmt.Errorf("row %d: RGB %g,%g,%g is not grid point %d,%d,%d", i+2, v[0], v[1], v[2], r, g, b)
+		}
+		l.Data = append(l.Data, v[3:]...)
+	}
+	return l, l.check()
+}
+
+func (l *LUT) check() error {
+	if want := l.Size * l.Size * l.Size * 4; len(l.Data) != want {
+		return fmt.Errorf("LUT has %d values, expected %d for size %d", len(l.Data), want, l.Size)
+	}
+	for _, v := range l.Data {
+		if v < 0 || v > 1 || math.IsNaN(v) {
+			return fmt.Errorf("coverage %g outside 0-1", v)
+		}
+	}
+	return nil
+}
+
+// Apply separates 8-bit RGB pixels (width*height*3 bytes) to 8-bit CMYK by
+// tetrahedral interpolation.
+func (l *LUT) Apply(rgb []byte, width, height int) ([]byte, error) {
+	if expected := width * height * 3; len(rgb) != expected {
+		return nil, fmt.Errorf("expected %d RGB bytes, got %d", expected, len(rgb))
+	}
+	out := make([]byte, width*height*4)
+	var c [4]float64
+	for i := 0; i < width*height; i++ {
+		if i > 0 && rgb[3*i] == rgb[3*i-3] && rgb[3*i+1] == rgb[3*i-2] && rgb[3*i+2] == rgb[3*i-1] {
+			copy(out[4*i:4*i+4], out[4*i-4:4*i])
+			continue
+		}
+		l.eval(float64(rgb[3*i])/255, float64(rgb[3*i+1])/255, float64(rgb[3*i+2])/255, &c)
+		for k, v := range c {
+			out[4*i+k] = uint8(v*255 + 0.5)
+		}
+	}
+	return out, nil
+}
+
+// eval interpolates the table at r, g, b (0–1).
+func (l *LUT) eval(r, g, b float64, out *[4]float64) {
+	locate := func(v float64) (int, float64) {
+		pos := v * float64(l.Size-1)
+		i := int(pos)
+		if i >= l.Size-1 {
+			i = l.Size - 2
+		}
+		return i, pos - float64(i)
+	}
+	r0, fr := locate(r)
+	g0, fg := locate(g)
+	b0, fb := locate(b)
+	sr, sg, sb := 4, 4*l.Size, 4*l.Size*l.Size
+	base := r0*sr + g0*sg + b0*sb
+	d := l.Data
+	for k := 0; k < 4; k++ {
+		p := base + k
+		c000 := d[p]
+		c100, c010, c001 := d[p+sr], d[p+sg], d[p+sb]
+		c110, c101, c011 := d[p+sr+sg], d[p+sr+sb], d[p+sg+sb]
+		c111 := d[p+sr+sg+sb]
+		var v float64
+		switch {
+		case fr >= fg && fg >= fb:
+			v = c000 + fr*(c100-c000) + fg*(c110-c100) + fb*(c111-c110)
+		case fr >= fb && fb >= fg:
+			v = c000 + fr*(c100-c000) + fb*(c101-c100) + fg*(c111-c101)
+		case fb >= fr && fr >= fg:
+			v = c000 + fb*(c001-c000) + fr*(c101-c001) + fg*(c111-c101)
+		case fg >= fr && fr >= fb:
+			v = c000 + fg*(c010-c000) + fr*(c110-c010) + fb*(c111-c110)
+		case fg >= fb && fb >= fr:
+			v = c000 + fg*(c010-c000) + fb*(c011-c010) + fr*(c111-c011)
+		default:
+			v = c000 + fb*(c001-c000) + fg*(c011-c001) + fr*(c111-c011)
+		}
+		out[k] = math.Max(0, math.Min(1, v))
+	}
+}
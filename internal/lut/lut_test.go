@@ -0,0 +1,129 @@
+package lut
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/davesmith10/RGBtoCMYK/internal/color"
+)
+
+// rampLUT maps R, G and B to C, M and Y as 1-v, and K to 1-max(R, G, B).
+func rampLUT(size int) *LUT {
+	l := &LUT{Title: "ramp", Size: size}
+	for b := 0; b < size; b++ {
+		for g := 0; g < size; g++ {
+			for r := 0; r < size; r++ {
+				rv, gv, bv := float64(node(r, size)), float64(node(g, size)), float64(node(b, size))
+				l.Data = append(l.Data, 1-rv, 1-gv, 1-bv, 1-math.Max(rv, math.Max(gv, bv)))
+			}
+		}
+	}
+	return l
+}
+
+func TestCubeRoundTrip(t *testing.T) {
+	l := rampLUT(5)
+	l.Comments = []string{"Source: test"}
+	var buf bytes.Buffer
+	if err := l.WriteCube(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "LUT_3D_OUTPUT_CHANNELS 4\n") {
+		t.Error("cube output lacks the channel count keyword")
+	}
+	got, err := Parse(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got.Size != 5 || got.Title != "ramp" || len(got.Comments) != 1 || got.Comments[0] != "Source: test" {
+		t.Errorf("header = %d %q %q", got.Size, got.Title, got.Comments)
+	}
+	for i := range l.Data {
+		if math.Abs(got.Data[i]-l.Data[i]) > 1e-6 {
+			t.Fatalf("value %d = %v, want %v", i, got.Data[i], l.Data[i])
+		}
+	}
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	l := rampLUT(4)
+	var buf bytes.Buffer
+	if err := l.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Parse(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got.Size != 4 {
+		t.Fatalf("size = %d", got.Size)
+	}
+	for i := range l.Data {
+		if math.Abs(got.Data[i]-l.Data[i]) > 1e-6 {
+			t.Fatalf("value %d = %v, want %v", i, got.Data[i], l.Data[i])
+		}
+	}
+}
+
+func TestParseRejectsRGBCube(t *testing.T) {
+	cube := "LUT_3D_SIZE 2\n0 0 0\n1 0 0\n0 1 0\n1 1 0\n0 0 1\n1 0 1\n0 1 1\n1 1 1\n"
+	if _, err := Parse([]byte(cube)); err == nil {
+		t.Error("expected an error for a three-channel cube")
+	}
+}
+
+func TestApply(t *testing.T) {
+	l := rampLUT(3)
+	rgb := []byte{255, 255, 255, 0, 0, 0, 255, 0, 0, 64, 128, 192}
+	cmyk, err := l.Apply(rgb, 4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		0, 0, 0, 0,
+		255, 255, 255, 255,
+		0, 255, 255, 0,
+		191, 127, 63, 63,
+	}
+	for i := range want {
+		if d := int(cmyk[i]) - int(want[i]); d < -1 || d > 1 {
+			t.Errorf("byte %d = %d, want %d", i, cmyk[i], want[i])
+		}
+	}
+}
+
+func TestLimitTAC(t *testing.T) {
+	l := &LUT{Size: 2, Data: make([]float64, 32)}
+	copy(l.Data, []float64{1, 1, 1, 1, 0.2, 0.2, 0.2, 0.2})
+	if n := l.LimitTAC(300); n != 1 {
+		t.Errorf("changed %d points, want 1", n)
+	}
+	if sum := l.Data[0] + l.Data[1] + l.Data[2] + l.Data[3]; math.Abs(sum-3) > 1e-9 || l.Data[3] != 1 {
+		t.Errorf("limited point = %v", l.Data[:4])
+	}
+	if l.Data[4] != 0.2 {
+		t.Errorf("point under the limit changed: %v", l.Data[4:8])
+	}
+}
+
+func TestSample(t *testing.T) {
+	dst, _ := color.BuiltinProfile(color.DefaultCMYKProfile)
+	xf, err := color.NewFloatTransform(color.EmbeddedSRGB, dst, color.IntentRelativeColorimetric, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer xf.Close()
+	l, err := Sample(xf, 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	white := l.Data[len(l.Data)-4:]
+	if white[0]+white[1]+white[2]+white[3] > 0.05 {
+		t.Errorf("white = %v, expected no ink", white)
+	}
+	if black := l.Data[:4]; black[3] < 0.5 {
+		t.Errorf("black = %v, expected heavy K", black)
+	}
+}
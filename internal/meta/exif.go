@@ -0,0 +1,248 @@
+// Package meta reads the EXIF and XMP metadata carried in JPEG APP1
+// segments.
+package meta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// exifHeader prefixes the TIFF structure in an EXIF APP1 segment.
+const exifHeader = "Exif\x00\x00"
+
+// EXIF tags used by this package.
+const (
+	tagExifIFD             = 0x8769
+	tagInteropIFD          = 0xA005
+	tagColorSpace          = 0xA001
+	tagInteroperabilityIdx = 0x0001
+	tagXResolution         = 0x011A
+	tagYResolution         = 0x011B
+	tagResolutionUnit      = 0x0128
+)
+
+// EXIF ColorSpace values.
+const (
+	ColorSpaceSRGB         = 1
+	ColorSpaceUncalibrated = 0xFFFF
+)
+
+// IsEXIF reports whether an APP1 payload holds EXIF data.
+func IsEXIF(app1 []byte) bool {
+	return bytes.HasPrefix(app1, []byte(exifHeader))
+}
+
+// ifdEntry is one 12-byte TIFF directory entry.
+type ifdEntry struct {
+	pos   int // offset of the entry within the TIFF data
+	tag   uint16
+	typ   uint16
+	count uint32
+	value []byte // 4 raw value/offset bytes
+}
+
+// tiffData is the TIFF structure inside an EXIF segment.
+type tiffData struct {
+	b  []byte
+	bo binary.ByteOrder
+}
+
+func parseTIFF(app1 []byte) (*tiffData, error) {
+	if !IsEXIF(app1) {
+		return nil, errors.New("not an EXIF segment")
+	}
+	b := app1[len(exifHeader):]
+	if len(b) < 8 {
+		return nil, errors.New("EXIF data too short")
+	}
+	t := &tiffData{b: b}
+	switch string(b[:2]) {
+	case "II":
+		t.bo = binary.LittleEndian
+	case "MM":
+		t.bo = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("invalid TIFF byte order %q", b[:2])
+	}
+	if t.bo.Uint16(b[2:]) != 42 {
+		return nil, errors.New("invalid TIFF magic")
+	}
+	return t, nil
+}
+
+// ifd0 returns the offset of the first IFD.
+func (t *tiffData) ifd0() uint32 {
+	return t.bo.Uint32(t.b[4:])
+}
+
+// entries reads the directory at off.
+func (t *tiffData) entries(off uint32) ([]ifdEntry, error) {
+	if int(off)+2 > len(t.b) || off == 0 {
+		return nil, fmt.Errorf("IFD offset %d out of range", off)
+	}
+	n := int(t.bo.Uint16(t.b[off:]))
+	if int(off)+2+12*n+4 > len(t.b) {
+		return nil, fmt.Errorf("IFD at %d truncated", off)
+	}
+	out := make([]ifdEntry, n)
+	for i := range out {
+		p := int(off) + 2 + 12*i
+		e := t.b[p:]
+		out[i] = ifdEntry{
+			pos:   p,
+			tag:   t.bo.Uint16(e[0:]),
+			typ:   t.bo.Uint16(e[2:]),
+			count: t.bo.Uint32(e[4:]),
+			value: e[8:12],
+		}
+	}
+	return out, nil
+}
+
+// find returns the entry with the given tag in the directory at off.
+func (t *tiffData) find(off uint32, tag uint16) (ifdEntry, bool) {
+	entries, err := t.entries(off)
+	if err != nil {
+		return ifdEntry{}, false
+	}
+	for _, e := range entries {
+		if e.tag == tag {
+			return e, true
+		}
+	}
+	return ifdEntry{}, false
+}
+
+// uint returns the first SHORT or LONG value of e.
+func (t *tiffData) uint(e ifdEntry) (uint32, bool) {
+	switch e.typ {
+	case 3: // SHORT
+		return uint32(t.bo.Uint16(e.value)), true
+	case 4: // LONG
+		return t.bo.Uint32(e.value), true
+	}
+	return 0, false
+}
+
+// ascii returns the string value of an ASCII or UNDEFINED entry.
+func (t *tiffData) ascii(e ifdEntry) string {
+	data := e.value
+	if e.count > 4 {
+		off := t.bo.Uint32(e.value)
+		if int(off)+int(e.count) > len(t.b) {
+			return ""
+		}
+		data = t.b[off : off+e.count]
+	} else {
+		data = data[:e.count]
+	}
+	return string(bytes.TrimRight(data, "\x00 "))
+}
+
+// subIFD follows a pointer tag in the directory at off.
+func (t *tiffData) subIFD(off uint32, tag uint16) (uint32, bool) {
+	e, ok := t.find(off, tag)
+	if !ok {
+		return 0, false
+	}
+	return t.uint(e)
+}
+
+// rational returns the first RATIONAL value of e.
+func (t *tiffData) rational(e ifdEntry) (float64, bool) {
+	if e.typ != 5 || e.count < 1 {
+		return 0, false
+	}
+	off := t.bo.Uint32(e.value)
+	if int(off)+8 > len(t.b) {
+		return 0, false
+	}
+	num, den := t.bo.Uint32(t.b[off:]), t.bo.Uint32(t.b[off+4:])
+	if den == 0 {
+		return 0, false
+	}
+	return float64(num) / float64(den), true
+}
+
+// ReadEXIFResolution returns the IFD0 XResolution and YResolution of an
+// EXIF APP1 payload in pixels per inch. ok is false if they are missing
+// or the ResolutionUnit gives no absolute size.
+func ReadEXIFResolution(app1 []byte) (x, y float64, ok bool) {
+	t, err := parseTIFF(app1)
+	if err != nil {
+		return 0, 0, false
+	}
+	ifd0 := t.ifd0()
+	unit := uint32(2) // inches, the TIFF default
+	if e, found := t.find(ifd0, tagResolutionUnit); found {
+		unit, _ = t.uint(e)
+	}
+	xe, ok1 := t.find(ifd0, tagXResolution)
+	ye, ok2 := t.find(ifd0, tagYResolution)
+	if !ok1 || !ok2 {
+		return 0, 0, false
+	}
+	x, ok1 = t.rational(xe)
+	y, ok2 = t.rational(ye)
+	if !ok1 || !ok2 || x <= 0 || y <= 0 {
+		return 0, 0, false
+	}
+	switch unit {
+	case 2:
+		return x, y, true
+	case 3: // centimetres
+		return x * 2.54, y * 2.54, true
+	}
+	return 0, 0, false
+}
+
+// ReadEXIFOrientation returns the IFD0 Orientation of an EXIF APP1
+// payload, 1 (top-left) to 8. ok is false if it is missing or out of range.
+func ReadEXIFOrientation(app1 []byte) (int, bool) {
+	t, err := parseTIFF(app1)
+	if err != nil {
+		return 0, false
+	}
+	e, found := t.find(t.ifd0(), tagOrientation)
+	if !found {
+		return 0, false
+	}
+	v, ok := t.uint(e)
+	if !ok || v < 1 || v > 8 {
+		return 0, false
+	}
+	return int(v), true
+}
+
+// EXIFColorInfo holds the colour-space hints of an EXIF segment.
+type EXIFColorInfo struct {
+	ColorSpace int    // EXIF ColorSpace tag (0 if absent)
+	InteropIdx string // InteroperabilityIndex, e.g. "R98" or "R03"
+}
+
+// ReadEXIFColorInfo extracts ColorSpace and InteroperabilityIndex from an
+// EXIF APP1 payload.
+func ReadEXIFColorInfo(app1 []byte) (EXIFColorInfo, error) {
+	var info EXIFColorInfo
+	t, err := parseTIFF(app1)
+	if err != nil {
+		return info, err
+	}
+	exifIFD, ok := t.subIFD(t.ifd0(), tagExifIFD)
+	if !ok {
+		return info, nil
+	}
+	if e, ok := t.find(exifIFD, tagColorSpace); ok {
+		if v, ok := t.uint(e); ok {
+			info.ColorSpace = int(v)
+		}
+	}
+	if interop, ok := t.subIFD(exifIFD, tagInteropIFD); ok {
+		if e, ok := t.find(interop, tagInteroperabilityIdx); ok {
+			info.InteropIdx = t.ascii(e)
+		}
+	}
+	return info, nil
+}
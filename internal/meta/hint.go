@@ -0,0 +1,61 @@
+package meta
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Hint is a source colour space inferred from metadata.
+type Hint struct {
+	Space  string // "adobergb" or "srgb"
+	Reason string // human-readable description of the evidence
+}
+
+// DetectColorSpace inspects APP1 payloads for colour-space hints. XMP
+// photoshop:ICCProfile names are checked first since they name the profile
+// outright; then EXIF ColorSpace and InteroperabilityIndex. It returns false
+// when no hint is present.
+func DetectColorSpace(app1 [][]byte) (Hint, bool) {
+	for _, seg := range app1 {
+		packet := XMPPacket(seg)
+		if packet == nil {
+			continue
+		}
+		name, ok := XMPProperty(packet, "photoshop:ICCProfile")
+		if !ok {
+			continue
+		}
+		if space := spaceFromProfileName(name); space != "" {
+			return Hint{Space: space, Reason: "XMP photoshop:ICCProfile " + strconv.Quote(name)}, true
+		}
+	}
+	for _, seg := range app1 {
+		if !IsEXIF(seg) {
+			continue
+		}
+		info, err := ReadEXIFColorInfo(seg)
+		if err != nil {
+			continue
+		}
+		switch {
+		case info.InteropIdx == "R03":
+			return Hint{Space: "adobergb", Reason: "EXIF InteroperabilityIndex R03"}, true
+		case info.ColorSpace == ColorSpaceSRGB:
+			return Hint{Space: "srgb", Reason: "EXIF ColorSpace sRGB"}, true
+		}
+	}
+	return Hint{}, false
+}
+
+// spaceFromProfileName maps a profile description to a known space.
+func spaceFromProfileName(name string) string {
+	n := strings.ToLower(name)
+	switch {
+	case strings.Contains(n, "adobe rgb"), strings.Contains(n, "adobergb"),
+		strings.Contains(n, "compatible with adobe"):
+		return "adobergb"
+	case strings.Contains(n, "srgb"):
+		return "srgb"
+	}
+	return ""
+}
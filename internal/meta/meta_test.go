@@ -0,0 +1,108 @@
+package meta
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// buildEXIF assembles a big-endian EXIF payload with an Exif IFD holding
+// ColorSpace and, if interop is non-empty, an Interop IFD.
+func buildEXIF(colorSpace uint16, interop string) []byte {
+	bo := binary.BigEndian
+	b := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	entry := func(tag, typ uint16, count uint32, value []byte) []byte {
+		e := make([]byte, 12)
+		bo.PutUint16(e, tag)
+		bo.PutUint16(e[2:], typ)
+		bo.PutUint32(e[4:], count)
+		copy(e[8:], value)
+		return e
+	}
+	long := func(v uint32) []byte {
+		return bo.AppendUint32(nil, v)
+	}
+	short := func(v uint16) []byte {
+		return bo.AppendUint16(nil, v)
+	}
+
+	// IFD0 at 8 with one entry: 2+12+4 = 18 bytes, Exif IFD at 26.
+	b = append(b, 0, 1)
+	b = append(b, entry(tagExifIFD, 4, 1, long(26))...)
+	b = append(b, 0, 0, 0, 0)
+
+	n := uint16(1)
+	if interop != "" {
+		n = 2
+	}
+	exifEnd := 26 + 2 + 12*int(n) + 4
+	b = append(b, short(n)...)
+	b = append(b, entry(tagColorSpace, 3, 1, short(colorSpace))...)
+	if interop != "" {
+		b = append(b, entry(tagInteropIFD, 4, 1, long(uint32(exifEnd)))...)
+	}
+	b = append(b, 0, 0, 0, 0)
+	if interop != "" {
+		b = append(b, 0, 1)
+		b = append(b, entry(tagInteroperabilityIdx, 2, 4, append([]byte(interop), 0))...)
+		b = append(b, 0, 0, 0, 0)
+	}
+	return append([]byte(exifHeader), b...)
+}
+
+func TestReadEXIFColorInfo(t *testing.T) {
+	info, err := ReadEXIFColorInfo(buildEXIF(ColorSpaceUncalibrated, "R03"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ColorSpace != ColorSpaceUncalibrated || info.InteropIdx != "R03" {
+		t.Errorf("info = %+v", info)
+	}
+
+	if _, err := ReadEXIFColorInfo([]byte("Exif\x00\x00XX")); err == nil {
+		t.Error("expected error for truncated EXIF")
+	}
+}
+
+func TestXMPProperty(t *testing.T) {
+	attr := []byte(`<rdf:Description photoshop:ICCProfile="Adobe RGB (1998)"/>`)
+	if v, ok := XMPProperty(attr, "photoshop:ICCProfile"); !ok || v != "Adobe RGB (1998)" {
+		t.Errorf("attribute form: %q, %v", v, ok)
+	}
+	elem := []byte(`<photoshop:ICCProfile>sRGB IEC61966-2.1</photoshop:ICCProfile>`)
+	if v, ok := XMPProperty(elem, "photoshop:ICCProfile"); !ok || v != "sRGB IEC61966-2.1" {
+		t.Errorf("element form: %q, %v", v, ok)
+	}
+	if _, ok := XMPProperty(elem, "photoshop:ColorMode"); ok {
+		t.Error("found absent property")
+	}
+}
+
+func TestDetectColorSpace(t *testing.T) {
+	xmp := func(name string) []byte {
+		return []byte(xmpHeader + `<x:xmpmeta><rdf:Description photoshop:ICCProfile="` + name + `"/></x:xmpmeta>`)
+	}
+	tests := []struct {
+		name  string
+		app1  [][]byte
+		space string
+	}{
+		{"none", nil, ""},
+		{"exif R03", [][]byte{buildEXIF(ColorSpaceUncalibrated, "R03")}, "adobergb"},
+		{"exif sRGB", [][]byte{buildEXIF(ColorSpaceSRGB, "R98")}, "srgb"},
+		{"exif uncalibrated only", [][]byte{buildEXIF(ColorSpaceUncalibrated, "")}, ""},
+		{"xmp adobe", [][]byte{xmp("Adobe RGB (1998)")}, "adobergb"},
+		{"xmp beats exif", [][]byte{buildEXIF(ColorSpaceSRGB, "R98"), xmp("Adobe RGB (1998)")}, "adobergb"},
+		{"xmp unknown name", [][]byte{xmp("ProPhoto RGB")}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, ok := DetectColorSpace(tt.app1)
+			if ok != (tt.space != "") || h.Space != tt.space {
+				t.Errorf("got %+v, %v; want space %q", h, ok, tt.space)
+			}
+			if ok && h.Reason == "" {
+				t.Error("empty reason")
+			}
+		})
+	}
+}
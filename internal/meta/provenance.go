@@ -0,0 +1,200 @@
+package meta
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProvenanceNS is the XMP namespace of the conversion record.
+const ProvenanceNS = "https://github.com/davesmith10/RGBtoCMYK/ns/conversion/1.0/"
+
+// Namespaces of the xmpMM:History event that accompanies the record.
+const (
+	xmpMMNS = "http://ns.adobe.com/xap/1.0/mm/"
+	stEvtNS = "http://ns.adobe.com/xap/1.0/sType/ResourceEvent#"
+)
+
+// Provenance records how a CMYK file was produced from its RGB source.
+type Provenance struct {
+	Software            string // tool name, version and colour engine
+	When                time.Time
+	SourceDigest        string // "sha256:<hex>" of the input file
+	SourceProfile       string // source profile description
+	SourceProfileDigest string
+	DestProfile         string // destination profile description
+	DestProfileDigest   string
+	Intent              string // rendering intent used, after any fallback
+	BPC                 bool   // black point compensation applied
+	Black               string // pure-black handling, e.g. "rich 60/40/40/100"
+	Quality             int
+	CMYReduction        int
+	Qualities           [4]int // quality of each of C, M, Y and K
+}
+
+// fields returns the record's XMP properties, without prefix, in writing
+// order.
+func (p *Provenance) fields() [][2]string {
+	q := p.Qualities
+	return [][2]string{
+		{"Software", p.Software},
+		{"When", p.When.UTC().Format(time.RFC3339)},
+		{"SourceDigest", p.SourceDigest},
+		{"SourceProfile", p.SourceProfile},
+		{"SourceProfileDigest", p.SourceProfileDigest},
+		{"DestinationProfile", p.DestProfile},
+		{"DestinationProfileDigest", p.DestProfileDigest},
+		{"Intent", p.Intent},
+		{"BlackPointCompensation", strconv.FormatBool(p.BPC)},
+		{"BlackHandling", p.Black},
+		{"Quality", strconv.Itoa(p.Quality)},
+		{"CMYReduction", strconv.Itoa(p.CMYReduction)},
+		{"ChannelQuality", fmt.Sprintf("%d/%d/%d/%d", q[0], q[1], q[2], q[3])},
+	}
+}
+
+// emptyPacket is the XMP packet a record is added to when the source had
+// none.
+const emptyPacket = "<?xpacket begin=\"\uFEFF\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n" + `<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+ </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>`
+
+// AddProvenance returns a copy of an XMP packet, or a new packet if it is
+// nil, with the conversion record p and a "converted" event in
+// xmpMM:History. A record already in the packet is replaced; an existing
+// history gets the event appended. A packet without an rdf:RDF element is
+// returned unchanged.
+func AddProvenance(packet []byte, p Provenance) []byte {
+	if packet == nil {
+		packet = []byte(emptyPacket)
+	}
+	packet = RemoveXMPProperty(packet, "rgbtocmyk:Conversion")
+	end := bytes.LastIndex(packet, []byte("</rdf:RDF>"))
+	if end < 0 {
+		return packet
+	}
+
+	event := fmt.Sprintf(`<rdf:li rdf:parseType="Resource" xmlns:stEvt="%s">
+      <stEvt:action>converted</stEvt:action>
+      <stEvt:parameters>from RGB to CMYK</stEvt:parameters>
+      <stEvt:softwareAgent>%s</stEvt:softwareAgent>
+      <stEvt:when>%s</stEvt:when>
+     </rdf:li>`, stEvtNS, html.EscapeString(p.Software), p.When.UTC().Format(time.RFC3339))
+
+	var desc strings.Builder
+	fmt.Fprintf(&desc, "  <rdf:Description rdf:about=\"\" xmlns:rgbtocmyk=\"%s\"", ProvenanceNS)
+	history := appendHistory(packet, event)
+	if history != nil {
+		packet = history
+		end = bytes.LastIndex(packet, []byte("</rdf:RDF>"))
+	} else {
+		fmt.Fprintf(&desc, " xmlns:xmpMM=\"%s\"", xmpMMNS)
+	}
+	desc.WriteString(">\n   <rgbtocmyk:Conversion rdf:parseType=\"Resource\">\n")
+	for _, f := range p.fields() {
+		fmt.Fprintf(&desc, "    <rgbtocmyk:%s>%s</rgbtocmyk:%[1]s>\n", f[0], html.EscapeString(f[1]))
+	}
+	desc.WriteString("   </rgbtocmyk:Conversion>\n")
+	if history == nil {
+		fmt.Fprintf(&desc, "   <xmpMM:History>\n    <rdf:Seq>\n     %s\n    </rdf:Seq>\n   </xmpMM:History>\n", event)
+	}
+	desc.WriteString("  </rdf:Description>\n ")
+	return splice(packet, end, end, []byte(desc.String()))
+}
+
+// appendHistory adds an event to the packet's xmpMM:History sequence, or
+// returns nil if there is none.
+func appendHistory(packet []byte, event string) []byte {
+	i := bytes.Index(packet, []byte("<xmpMM:History>"))
+	if i < 0 {
+		return nil
+	}
+	j := bytes.Index(packet[i:], []byte("</rdf:Seq>"))
+	if j < 0 {
+		return nil
+	}
+	return splice(packet, i+j, i+j, []byte(" "+event+"\n    "))
+}
+
+// ReadProvenance returns the conversion record of an XMP packet.
+// Properties that are missing or malformed are left zero.
+func ReadProvenance(packet []byte) (*Provenance, bool) {
+	if !bytes.Contains(packet, []byte("<rgbtocmyk:Conversion")) {
+		return nil, false
+	}
+	get := func(name string) string {
+		v, _ := XMPProperty(packet, "rgbtocmyk:"+name)
+		return v
+	}
+	p := &Provenance{
+		Software:            get("Software"),
+		SourceDigest:        get("SourceDigest"),
+		SourceProfile:       get("SourceProfile"),
+		SourceProfileDigest: get("SourceProfileDigest"),
+		DestProfile:         get("DestinationProfile"),
+		DestProfileDigest:   get("DestinationProfileDigest"),
+		Intent:              get("Intent"),
+		Black:               get("BlackHandling"),
+	}
+	p.When, _ = time.Parse(time.RFC3339, get("When"))
+	p.BPC, _ = strconv.ParseBool(get("BlackPointCompensation"))
+	p.Quality, _ = strconv.Atoi(get("Quality"))
+	p.CMYReduction, _ = strconv.Atoi(get("CMYReduction"))
+	q := &p.Qualities
+	fmt.Sscanf(get("ChannelQuality"), "%d/%d/%d/%d", &q[0], &q[1], &q[2], &q[3])
+	return p, true
+}
+
+// maxSegment is the largest payload of a JPEG APPn segment.
+const maxSegment = 65533
+
+// WithProvenance adds the record p to the XMP segment of APP1 payloads, or
+// appends a new XMP segment if there is none. When the record would push
+// the segment past the 65533 bytes a marker holds, it takes the room from
+// the packet's padding; if that is not enough, app1 is returned unchanged
+// with an error.
+func WithProvenance(app1 [][]byte, p Provenance) ([][]byte, error) {
+	out := make([][]byte, 0, len(app1)+1)
+	added := false
+	for _, seg := range app1 {
+		if IsXMP(seg) && !added {
+			packet := AddProvenance(XMPPacket(seg), p)
+			if over := len(xmpHeader) + len(packet) - maxSegment; over > 0 {
+				packet = trimPadding(packet, over)
+				if over = len(xmpHeader) + len(packet) - maxSegment; over > 0 {
+					return app1, fmt.Errorf("the XMP segment has no room for the conversion record (%d bytes over)", over)
+				}
+			}
+			seg = append([]byte(xmpHeader), packet...)
+			added = true
+		}
+		out = append(out, seg)
+	}
+	if !added {
+		out = append(out, append([]byte(xmpHeader), AddProvenance(nil, p)...))
+	}
+	return out, nil
+}
+
+// trimPadding removes up to n bytes of the white space that pads a packet
+// before its trailer, keeping one byte of it.
+func trimPadding(packet []byte, n int) []byte {
+	end := bytes.LastIndex(packet, []byte("<?xpacket end"))
+	if end < 0 {
+		return packet
+	}
+	start := end
+	for start > 0 && strings.IndexByte(" \t\r\n", packet[start-1]) >= 0 {
+		start--
+	}
+	cut := min(n, end-start-1)
+	if cut <= 0 {
+		return packet
+	}
+	return splice(packet, end-cut, end, nil)
+}
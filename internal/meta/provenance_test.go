@@ -0,0 +1,122 @@
+package meta
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProvenanceRoundTrip(t *testing.T) {
+	p := Provenance{
+		Software:            "rgbtocmyk test (go)",
+		When:                time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC),
+		SourceDigest:        "sha256:00ff",
+		SourceProfile:       "sRGB <v4> & co",
+		SourceProfileDigest: "sha256:11",
+		DestProfile:         "Coated FOGRA39",
+		DestProfileDigest:   "sha256:22",
+		Intent:              "perceptual",
+		BPC:                 true,
+		Black:               "rich 60/40/40/100",
+		Quality:             85,
+		CMYReduction:        15,
+		Qualities:           [4]int{70, 70, 60, 85},
+	}
+
+	packet := AddProvenance(nil, p)
+	if !bytes.HasPrefix(packet, []byte("<?xpacket begin=\"\uFEFF\"")) || !bytes.Contains(packet, []byte("<xmpMM:History>")) {
+		t.Errorf("new packet:\n%s", packet)
+	}
+	got, ok := ReadProvenance(packet)
+	if !ok || *got != p {
+		t.Errorf("read back %+v, want %+v", got, p)
+	}
+
+	// A second record replaces the first and joins the existing history.
+	p2 := p
+	p2.Quality = 90
+	packet = AddProvenance(packet, p2)
+	if n := bytes.Count(packet, []byte("<rgbtocmyk:Conversion")); n != 1 {
+		t.Errorf("%d records after replacing", n)
+	}
+	if n := bytes.Count(packet, []byte("<stEvt:action>converted</stEvt:action>")); n != 2 {
+		t.Errorf("%d history events, want 2", n)
+	}
+	if n := bytes.Count(packet, []byte("<xmpMM:History>")); n != 1 {
+		t.Errorf("%d xmpMM:History properties", n)
+	}
+	if got, _ := ReadProvenance(packet); got.Quality != 90 {
+		t.Errorf("quality %d after replacing", got.Quality)
+	}
+
+	if _, ok := ReadProvenance([]byte(`<rdf:Description dc:format="image/jpeg"/>`)); ok {
+		t.Error("record found in a packet without one")
+	}
+}
+
+func TestWithProvenance(t *testing.T) {
+	exif := buildFullEXIF()
+	xmp := []byte(xmpHeader + `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF><rdf:Description dc:format="image/jpeg"/></rdf:RDF></x:xmpmeta>`)
+	p := Provenance{Software: "rgbtocmyk test"}
+
+	out, err := WithProvenance([][]byte{exif, xmp}, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 || !bytes.Equal(out[0], exif) {
+		t.Fatalf("got %d segments", len(out))
+	}
+	if s := string(out[1]); !strings.Contains(s, `dc:format="image/jpeg"`) || !strings.Contains(s, "<rgbtocmyk:Software>rgbtocmyk test<") {
+		t.Errorf("merged packet:\n%s", s)
+	}
+
+	out, err = WithProvenance([][]byte{exif}, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 || !IsXMP(out[1]) {
+		t.Fatalf("no XMP segment added: %d segments", len(out))
+	}
+	if _, ok := ReadProvenance(XMPPacket(out[1])); !ok {
+		t.Error("added packet has no record")
+	}
+}
+
+func TestWithProvenanceNearLimit(t *testing.T) {
+	p := Provenance{Software: "rgbtocmyk test", SourceDigest: "sha256:00ff"}
+	record := len(AddProvenance(nil, p)) - len(emptyPacket)
+	// nearLimit is a packet whose segment is 300 bytes short of the
+	// limit, padded with pad bytes of white space.
+	nearLimit := func(pad int) []byte {
+		head := "<?xpacket begin=\"\uFEFF\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n" +
+			`<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF><rdf:Description dc:description="`
+		tail := `"/></rdf:RDF></x:xmpmeta>` + "\n"
+		trailer := `<?xpacket end="w"?>`
+		fill := maxSegment - 300 - len(xmpHeader) - len(head) - len(tail) - pad - len(trailer)
+		return []byte(xmpHeader + head + strings.Repeat("x", fill) + tail + strings.Repeat(" ", pad) + trailer)
+	}
+
+	// The record takes its room from the padding.
+	xmp := nearLimit(record)
+	out, err := WithProvenance([][]byte{xmp}, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || len(out[0]) != maxSegment {
+		t.Fatalf("got %d segments, the first of %d bytes", len(out), len(out[0]))
+	}
+	if _, ok := ReadProvenance(XMPPacket(out[0])); !ok {
+		t.Error("trimmed packet has no record")
+	}
+	if !bytes.Contains(out[0], []byte(" <?xpacket end")) {
+		t.Error("padding not kept before the trailer")
+	}
+
+	// Without enough padding the segments come back unchanged.
+	xmp = nearLimit(10)
+	out, err = WithProvenance([][]byte{xmp}, p)
+	if err == nil || len(out) != 1 || !bytes.Equal(out[0], xmp) {
+		t.Errorf("got %d segments and error %v, want the XMP unchanged and an error", len(out), err)
+	}
+}
@@ -0,0 +1,372 @@
+package meta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"html"
+	"math"
+	"regexp"
+	"strconv"
+)
+
+// More EXIF tags, used when rewriting.
+const (
+	tagImageWidth     = 0x0100
+	tagImageLength    = 0x0101
+	tagOrientation    = 0x0112
+	tagGPSIFD         = 0x8825
+	tagPixelXDim      = 0xA002
+	tagPixelYDim      = 0xA003
+	tagThumbOffset    = 0x0201 // JPEGInterchangeFormat
+	tagThumbLength    = 0x0202 // JPEGInterchangeFormatLength
+	irbThumbnail      = 0x040C
+	irbThumbnailOld   = 0x0409
+	irbResolution     = 0x03ED // ResolutionInfo
+	photoshopColorCMY = "4"    // photoshop:ColorMode for CMYK
+)
+
+// Update describes the image metadata is being carried over to.
+type Update struct {
+	Width, Height int
+	Orientation   int     // new EXIF orientation, 0 to keep the source's
+	ProfileName   string  // description of the embedded profile, "" to drop photoshop:ICCProfile
+	StripGPS      bool    // remove EXIF and XMP GPS data
+	XDPI, YDPI    float64 // print resolution in pixels per inch, 0 to keep the source's
+}
+
+// Carry prepares source APP1 (EXIF, XMP) and APP13 (Photoshop IRB)
+// payloads for a CMYK conversion of the image. Fields the conversion makes
+// wrong are rewritten: colour space, pixel dimensions, orientation,
+// resolution and colour mode. Thumbnails are removed, not regenerated,
+// rather than left showing RGB data. Everything else, such as copyright,
+// captions and IPTC, is kept. A segment that cannot be parsed is kept as
+// is, unless GPS data has to be stripped, in which case it is dropped.
+func Carry(app1, app13 [][]byte, u Update) (outAPP1, outAPP13 [][]byte) {
+	for _, seg := range app1 {
+		switch {
+		case IsEXIF(seg):
+			rewritten, err := RewriteEXIF(seg, u)
+			if err != nil {
+				if u.StripGPS {
+					continue
+				}
+				rewritten = seg
+			}
+			outAPP1 = append(outAPP1, rewritten)
+		case IsXMP(seg):
+			outAPP1 = append(outAPP1, append([]byte(xmpHeader), RewriteXMP(XMPPacket(seg), u)...))
+		default:
+			outAPP1 = append(outAPP1, seg)
+		}
+	}
+	for _, seg := range app13 {
+		seg = StripIRBThumbnails(seg)
+		if u.XDPI > 0 && u.YDPI > 0 {
+			seg = SetIRBResolution(seg, u.XDPI, u.YDPI)
+		}
+		outAPP13 = append(outAPP13, seg)
+	}
+	return outAPP1, outAPP13
+}
+
+// RewriteEXIF returns a copy of an EXIF APP1 payload updated for u.
+// ColorSpace becomes Uncalibrated, since EXIF has no value for CMYK.
+// Tags are only changed where present; none are added.
+func RewriteEXIF(app1 []byte, u Update) ([]byte, error) {
+	t, err := parseTIFF(append([]byte(nil), app1...))
+	if err != nil {
+		return nil, err
+	}
+	ifd0 := t.ifd0()
+	if _, err := t.entries(ifd0); err != nil {
+		return nil, err
+	}
+
+	t.setUint(ifd0, tagImageWidth, uint32(u.Width))
+	t.setUint(ifd0, tagImageLength, uint32(u.Height))
+	if u.Orientation != 0 {
+		t.setUint(ifd0, tagOrientation, uint32(u.Orientation))
+	}
+	if u.XDPI > 0 && u.YDPI > 0 {
+		t.setRational(ifd0, tagXResolution, u.XDPI)
+		t.setRational(ifd0, tagYResolution, u.YDPI)
+		t.setUint(ifd0, tagResolutionUnit, 2)
+	}
+	if exifIFD, ok := t.subIFD(ifd0, tagExifIFD); ok {
+		t.setUint(exifIFD, tagColorSpace, ColorSpaceUncalibrated)
+		t.setUint(exifIFD, tagPixelXDim, uint32(u.Width))
+		t.setUint(exifIFD, tagPixelYDim, uint32(u.Height))
+	}
+	if u.StripGPS {
+		if gps, ok := t.subIFD(ifd0, tagGPSIFD); ok {
+			t.zeroIFD(gps)
+			t.removeEntry(ifd0, tagGPSIFD)
+		}
+	}
+	t.dropThumbnail(ifd0)
+	return append([]byte(exifHeader), t.b...), nil
+}
+
+// setUint overwrites a single SHORT or LONG value.
+func (t *tiffData) setUint(off uint32, tag uint16, v uint32) {
+	e, ok := t.find(off, tag)
+	if !ok || e.count != 1 {
+		return
+	}
+	switch e.typ {
+	case 3:
+		t.bo.PutUint16(e.value, uint16(v))
+	case 4:
+		t.bo.PutUint32(e.value, v)
+	}
+}
+
+// setRational overwrites a single RATIONAL value, keeping three decimals.
+func (t *tiffData) setRational(off uint32, tag uint16, v float64) {
+	e, ok := t.find(off, tag)
+	if !ok || e.typ != 5 || e.count != 1 {
+		return
+	}
+	p := t.bo.Uint32(e.value)
+	if int(p)+8 > len(t.b) {
+		return
+	}
+	num, den := rational(v)
+	t.bo.PutUint32(t.b[p:], num)
+	t.bo.PutUint32(t.b[p+4:], den)
+}
+
+// rational approximates v as a fraction with denominator 1 or 1000.
+func rational(v float64) (num, den uint32) {
+	if v == math.Trunc(v) {
+		return uint32(v), 1
+	}
+	return uint32(math.Round(v * 1000)), 1000
+}
+
+// xmpRational formats v the way XMP writes TIFF rationals, e.g. "300/1".
+func xmpRational(v float64) string {
+	num, den := rational(v)
+	return fmt.Sprintf("%d/%d", num, den)
+}
+
+// nextIFDPos returns the position of the next-IFD pointer of the directory
+// at off.
+func (t *tiffData) nextIFDPos(off uint32) int {
+	return int(off) + 2 + 12*int(t.bo.Uint16(t.b[off:]))
+}
+
+// removeEntry deletes an entry from the directory at off, moving the rest
+// of the directory up. The freed 12 bytes at its end are zeroed.
+func (t *tiffData) removeEntry(off uint32, tag uint16) {
+	e, ok := t.find(off, tag)
+	if !ok {
+		return
+	}
+	end := t.nextIFDPos(off) + 4
+	copy(t.b[e.pos:], t.b[e.pos+12:end])
+	clear(t.b[end-12 : end])
+	t.bo.PutUint16(t.b[off:], t.bo.Uint16(t.b[off:])-1)
+}
+
+// zeroIFD clears a directory and the out-of-line values it points to.
+func (t *tiffData) zeroIFD(off uint32) {
+	entries, err := t.entries(off)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if size := int(e.count) * typeSize(e.typ); size > 4 {
+			if p := int(t.bo.Uint32(e.value)); p >= 0 && p+size <= len(t.b) {
+				clear(t.b[p : p+size])
+			}
+		}
+	}
+	clear(t.b[off : t.nextIFDPos(off)+4])
+}
+
+// dropThumbnail unlinks IFD1, which holds the thumbnail, and clears the
+// thumbnail image. A thumbnail at the end of the data is cut off.
+func (t *tiffData) dropThumbnail(ifd0 uint32) {
+	pos := t.nextIFDPos(ifd0)
+	ifd1 := t.bo.Uint32(t.b[pos:])
+	if ifd1 == 0 {
+		return
+	}
+	t.bo.PutUint32(t.b[pos:], 0)
+	offEntry, ok1 := t.find(ifd1, tagThumbOffset)
+	lenEntry, ok2 := t.find(ifd1, tagThumbLength)
+	if !ok1 || !ok2 {
+		return
+	}
+	off, _ := t.uint(offEntry)
+	n, _ := t.uint(lenEntry)
+	if int(off)+int(n) > len(t.b) || int(off)+int(n) < int(off) {
+		return
+	}
+	clear(t.b[off : off+n])
+	if int(off+n) == len(t.b) {
+		t.b = t.b[:off]
+	}
+}
+
+// typeSize returns the size in bytes of one value of a TIFF field type.
+func typeSize(typ uint16) int {
+	switch typ {
+	case 1, 2, 6, 7: // BYTE, ASCII, SBYTE, UNDEFINED
+		return 1
+	case 3, 8: // SHORT, SSHORT
+		return 2
+	case 5, 10, 12: // RATIONAL, SRATIONAL, DOUBLE
+		return 8
+	}
+	return 4
+}
+
+// RewriteXMP returns a copy of an XMP packet updated for u, in step with
+// RewriteEXIF: dimensions, resolution, colour space, photoshop:ColorMode and
+// photoshop:ICCProfile change, and GPS properties go if u.StripGPS is set.
+func RewriteXMP(packet []byte, u Update) []byte {
+	p := append([]byte(nil), packet...)
+	w, h := strconv.Itoa(u.Width), strconv.Itoa(u.Height)
+	p = SetXMPProperty(p, "tiff:ImageWidth", w)
+	p = SetXMPProperty(p, "tiff:ImageLength", h)
+	p = SetXMPProperty(p, "exif:PixelXDimension", w)
+	p = SetXMPProperty(p, "exif:PixelYDimension", h)
+	p = SetXMPProperty(p, "exif:ColorSpace", strconv.Itoa(ColorSpaceUncalibrated))
+	p = SetXMPProperty(p, "photoshop:ColorMode", photoshopColorCMY)
+	if u.Orientation != 0 {
+		p = SetXMPProperty(p, "tiff:Orientation", strconv.Itoa(u.Orientation))
+	}
+	if u.XDPI > 0 && u.YDPI > 0 {
+		p = SetXMPProperty(p, "tiff:XResolution", xmpRational(u.XDPI))
+		p = SetXMPProperty(p, "tiff:YResolution", xmpRational(u.YDPI))
+		p = SetXMPProperty(p, "tiff:ResolutionUnit", "2")
+	}
+	if u.ProfileName != "" {
+		p = SetXMPProperty(p, "photoshop:ICCProfile", u.ProfileName)
+	} else {
+		p = RemoveXMPProperty(p, "photoshop:ICCProfile")
+	}
+	if u.StripGPS {
+		for _, m := range regexp.MustCompile(`exif:GPS\w+`).FindAll(p, -1) {
+			p = RemoveXMPProperty(p, string(m))
+		}
+	}
+	return p
+}
+
+// SetXMPProperty replaces the value of a simple property written as an
+// attribute or an element. A missing property is not added.
+func SetXMPProperty(packet []byte, name, value string) []byte {
+	q := regexp.QuoteMeta(name)
+	v := []byte(html.EscapeString(value))
+	attr := regexp.MustCompile(`(` + q + `\s*=\s*)(?:"[^"]*"|'[^']*')`)
+	if loc := attr.FindSubmatchIndex(packet); loc != nil {
+		return splice(packet, loc[3], loc[1], append(append([]byte{'"'}, v...), '"'))
+	}
+	elem := regexp.MustCompile(`<` + q + `>([^<]*)</` + q + `>`)
+	if loc := elem.FindSubmatchIndex(packet); loc != nil {
+		return splice(packet, loc[2], loc[3], v)
+	}
+	return packet
+}
+
+// RemoveXMPProperty deletes every attribute or element with the given name,
+// including structured elements and their contents.
+func RemoveXMPProperty(packet []byte, name string) []byte {
+	q := regexp.QuoteMeta(name)
+	packet = regexp.MustCompile(`\s+`+q+`\s*=\s*(?:"[^"]*"|'[^']*')`).ReplaceAll(packet, nil)
+	open := regexp.MustCompile(`<` + q + `(?:\s[^>]*?)?(/?)>`)
+	closing := []byte("</" + name + ">")
+	for {
+		loc := open.FindSubmatchIndex(packet)
+		if loc == nil {
+			return packet
+		}
+		end := loc[1]
+		if loc[3] == loc[2] { // not self-closing
+			i := bytes.Index(packet[end:], closing)
+			if i < 0 {
+				return packet
+			}
+			end += i + len(closing)
+		}
+		packet = splice(packet, loc[0], end, nil)
+	}
+}
+
+func splice(b []byte, start, end int, repl []byte) []byte {
+	out := make([]byte, 0, len(b)-(end-start)+len(repl))
+	out = append(out, b[:start]...)
+	out = append(out, repl...)
+	return append(out, b[end:]...)
+}
+
+// irbHeader prefixes Photoshop image resource blocks in APP13.
+const irbHeader = "Photoshop 3.0\x00"
+
+// StripIRBThumbnails removes thumbnail resources from a Photoshop APP13
+// payload, keeping IPTC and all other resources. Unparseable data is
+// returned unchanged.
+func StripIRBThumbnails(app13 []byte) []byte {
+	return rewriteIRB(app13, func(id uint16, block, data []byte) []byte {
+		if id == irbThumbnail || id == irbThumbnailOld {
+			return nil
+		}
+		return block
+	})
+}
+
+// SetIRBResolution sets the horizontal and vertical resolution, in pixels
+// per inch, of the ResolutionInfo resource in a Photoshop APP13 payload.
+// The display units are kept. A payload without the resource is returned
+// unchanged.
+func SetIRBResolution(app13 []byte, x, y float64) []byte {
+	return rewriteIRB(app13, func(id uint16, block, data []byte) []byte {
+		if id != irbResolution || len(data) < 16 {
+			return block
+		}
+		block = append([]byte(nil), block...)
+		res := block[len(block)-len(data)-(len(data)&1):]
+		binary.BigEndian.PutUint32(res[0:], uint32(math.Round(x*65536))) // 16.16 fixed point
+		binary.BigEndian.PutUint32(res[8:], uint32(math.Round(y*65536)))
+		return block
+	})
+}
+
+// rewriteIRB replaces each resource block of a Photoshop APP13 payload
+// with edit(id, block, data), where data is the block's resource data;
+// edit returns nil to drop the block. Unparseable data is returned
+// unchanged.
+func rewriteIRB(app13 []byte, edit func(id uint16, block, data []byte) []byte) []byte {
+	if !bytes.HasPrefix(app13, []byte(irbHeader)) {
+		return app13
+	}
+	out := []byte(irbHeader)
+	b := app13[len(irbHeader):]
+	for len(b) > 0 {
+		if len(b) < 7 || string(b[:4]) != "8BIM" {
+			return app13
+		}
+		id := binary.BigEndian.Uint16(b[4:])
+		nameLen := 1 + int(b[6])
+		nameLen += nameLen & 1
+		if 6+nameLen+4 > len(b) {
+			return app13
+		}
+		start := 6 + nameLen + 4
+		size := int(binary.BigEndian.Uint32(b[6+nameLen:]))
+		total := start + size + size&1
+		if total > len(b) {
+			if start+size != len(b) { // unpadded final block
+				return app13
+			}
+			total = len(b)
+		}
+		out = append(out, edit(id, b[:total], b[start:start+size])...)
+		b = b[total:]
+	}
+	return out
+}
@@ -0,0 +1,263 @@
+package meta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// buildFullEXIF assembles a little-endian EXIF payload with an Exif IFD,
+// a GPS IFD holding a latitude and an IFD1 thumbnail at the end.
+func buildFullEXIF() []byte {
+	bo := binary.LittleEndian
+	b := []byte("II\x2a\x00\x08\x00\x00\x00")
+	ifd := func(next uint32, entries ...[]byte) {
+		b = bo.AppendUint16(b, uint16(len(entries)))
+		for _, e := range entries {
+			b = append(b, e...)
+		}
+		b = bo.AppendUint32(b, next)
+	}
+	entry := func(tag, typ uint16, count, value uint32) []byte {
+		e := bo.AppendUint16(nil, tag)
+		e = bo.AppendUint16(e, typ)
+		e = bo.AppendUint32(e, count)
+		return bo.AppendUint32(e, value)
+	}
+
+	// IFD0 at 8, Exif IFD at 50, GPS IFD at 92 with its rationals at
+	// 110, IFD1 at 134, thumbnail at 164.
+	ifd(134,
+		entry(tagOrientation, 3, 1, 6),
+		entry(tagExifIFD, 4, 1, 50),
+		entry(tagGPSIFD, 4, 1, 92))
+	ifd(0,
+		entry(tagColorSpace, 3, 1, ColorSpaceSRGB),
+		entry(tagPixelXDim, 4, 1, 4000),
+		entry(tagPixelYDim, 3, 1, 3000))
+	ifd(0, entry(0x0002, 5, 3, 110))
+	for i := 0; i < 6; i++ {
+		b = bo.AppendUint32(b, 0x2a2a2a2a)
+	}
+	ifd(0,
+		entry(tagThumbOffset, 4, 1, 164),
+		entry(tagThumbLength, 4, 1, 10))
+	b = append(b, "\xff\xd8thumbs\xff\xd9"...)
+	return append([]byte(exifHeader), b...)
+}
+
+func TestRewriteEXIF(t *testing.T) {
+	src := buildFullEXIF()
+	out, err := RewriteEXIF(src, Update{Width: 640, Height: 480, StripGPS: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, buildFullEXIF()) {
+		t.Error("source payload modified")
+	}
+
+	tt, err := parseTIFF(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ifd0 := tt.ifd0()
+	value := func(off uint32, tag uint16) uint32 {
+		t.Helper()
+		e, ok := tt.find(off, tag)
+		if !ok {
+			t.Fatalf("tag 0x%04x missing", tag)
+		}
+		v, _ := tt.uint(e)
+		return v
+	}
+	if v := value(ifd0, tagOrientation); v != 6 {
+		t.Errorf("orientation = %d, want 6 kept", v)
+	}
+	exifIFD := value(ifd0, tagExifIFD)
+	if v := value(exifIFD, tagColorSpace); v != ColorSpaceUncalibrated {
+		t.Errorf("ColorSpace = %d", v)
+	}
+	if x, y := value(exifIFD, tagPixelXDim), value(exifIFD, tagPixelYDim); x != 640 || y != 480 {
+		t.Errorf("pixel dimensions = %dx%d", x, y)
+	}
+	if _, ok := tt.find(ifd0, tagGPSIFD); ok {
+		t.Error("GPS IFD pointer kept")
+	}
+	if bytes.Contains(out, []byte{0x2a, 0x2a, 0x2a, 0x2a}) {
+		t.Error("GPS values not cleared")
+	}
+	if next := tt.bo.Uint32(tt.b[tt.nextIFDPos(ifd0):]); next != 0 {
+		t.Errorf("IFD1 still linked at %d", next)
+	}
+	if bytes.Contains(out, []byte("thumbs")) || len(out) != len(src)-10 {
+		t.Errorf("thumbnail not removed (%d → %d bytes)", len(src), len(out))
+	}
+
+	out, _ = RewriteEXIF(src, Update{Width: 1, Height: 1, Orientation: 1})
+	tt, _ = parseTIFF(out)
+	if v := value(tt.ifd0(), tagOrientation); v != 1 {
+		t.Errorf("orientation = %d, want 1", v)
+	}
+	if o, ok := ReadEXIFOrientation(src); !ok || o != 6 {
+		t.Errorf("ReadEXIFOrientation = %d (%v), want 6", o, ok)
+	}
+	if _, ok := ReadEXIFOrientation(buildResolutionEXIF([2]uint32{72, 1}, [2]uint32{72, 1}, 2)); ok {
+		t.Error("orientation read from a segment without one")
+	}
+	if _, ok := tt.find(tt.ifd0(), tagGPSIFD); !ok {
+		t.Error("GPS removed without StripGPS")
+	}
+}
+
+func TestRewriteXMP(t *testing.T) {
+	packet := []byte(`<rdf:Description photoshop:ColorMode="3" photoshop:ICCProfile="Adobe RGB (1998)"
+    exif:GPSLatitude="52,31.0N" exif:PixelXDimension="4000" dc:format="image/jpeg">
+  <exif:PixelYDimension>3000</exif:PixelYDimension>
+  <exif:GPSVersionID>2.2.0.0</exif:GPSVersionID>
+  <exif:GPSDestBearing rdf:parseType="Resource"><rdf:value>1</rdf:value></exif:GPSDestBearing>
+  <exif:GPSAreaInformation/>
+  <dc:rights><rdf:Alt><rdf:li xml:lang="x-default">© Agency &amp; Co</rdf:li></rdf:Alt></dc:rights>
+</rdf:Description>`)
+	out := string(RewriteXMP(packet, Update{Width: 640, Height: 480, ProfileName: "Coated & Co", StripGPS: true}))
+
+	for _, want := range []string{
+		`photoshop:ColorMode="4"`,
+		`photoshop:ICCProfile="Coated &amp; Co"`,
+		`exif:PixelXDimension="640"`,
+		`<exif:PixelYDimension>480</exif:PixelYDimension>`,
+		`© Agency &amp; Co`,
+		`dc:format="image/jpeg"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %s in\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "GPS") {
+		t.Errorf("GPS left in\n%s", out)
+	}
+
+	out = string(RewriteXMP(packet, Update{Width: 1, Height: 1}))
+	if strings.Contains(out, "ICCProfile") || !strings.Contains(out, "GPSLatitude") {
+		t.Errorf("without a profile name or StripGPS:\n%s", out)
+	}
+}
+
+func TestStripIRBThumbnails(t *testing.T) {
+	block := func(id uint16, data string) []byte {
+		b := append([]byte("8BIM"), byte(id>>8), byte(id), 0, 0)
+		b = binary.BigEndian.AppendUint32(b, uint32(len(data)))
+		b = append(b, data...)
+		if len(data)%2 == 1 {
+			b = append(b, 0)
+		}
+		return b
+	}
+	iptc := block(0x0404, "\x1c\x02\x74\x00\x05(c)AB")
+	app13 := []byte(irbHeader)
+	app13 = append(app13, iptc...)
+	app13 = append(app13, block(irbThumbnail, "thumbnail")...)
+	app13 = append(app13, block(0x03ED, "0123456789abcdef")...)
+
+	out := StripIRBThumbnails(app13)
+	want := append([]byte(irbHeader), iptc...)
+	want = append(want, block(0x03ED, "0123456789abcdef")...)
+	if !bytes.Equal(out, want) {
+		t.Errorf("got %q", out)
+	}
+	if bad := []byte(irbHeader + "8BIMxx"); !bytes.Equal(StripIRBThumbnails(bad), bad) {
+		t.Error("malformed data changed")
+	}
+}
+
+func TestCarry(t *testing.T) {
+	exif := buildFullEXIF()
+	other := []byte("http://ns.adobe.com/xmp/extension/\x00...")
+	app1, app13 := Carry([][]byte{exif, other, []byte("Exif\x00\x00bad")}, [][]byte{[]byte("Photoshop 3.0\x00")}, Update{Width: 2, Height: 2})
+	if len(app1) != 3 || !bytes.Equal(app1[1], other) || len(app13) != 1 {
+		t.Fatalf("got %d APP1, %d APP13", len(app1), len(app13))
+	}
+	if bytes.Equal(app1[0], exif) {
+		t.Error("EXIF not rewritten")
+	}
+	if app1, _ = Carry([][]byte{[]byte("Exif\x00\x00bad")}, nil, Update{StripGPS: true}); len(app1) != 0 {
+		t.Error("unparseable EXIF kept although GPS had to be stripped")
+	}
+}
+
+// buildResolutionEXIF assembles a big-endian EXIF payload with IFD0
+// XResolution and YResolution in the given ResolutionUnit.
+func buildResolutionEXIF(x, y [2]uint32, unit uint16) []byte {
+	bo := binary.BigEndian
+	b := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x03")
+	entry := func(tag, typ uint16, value uint32) {
+		b = bo.AppendUint16(b, tag)
+		b = bo.AppendUint16(b, typ)
+		b = bo.AppendUint32(b, 1)
+		if typ == 3 {
+			b = bo.AppendUint16(b, uint16(value))
+			b = append(b, 0, 0)
+		} else {
+			b = bo.AppendUint32(b, value)
+		}
+	}
+	entry(tagXResolution, 5, 50)
+	entry(tagYResolution, 5, 58)
+	entry(tagResolutionUnit, 3, uint32(unit))
+	b = bo.AppendUint32(b, 0)
+	for _, v := range append(x[:], y[:]...) {
+		b = bo.AppendUint32(b, v)
+	}
+	return append([]byte(exifHeader), b...)
+}
+
+func TestEXIFResolution(t *testing.T) {
+	for _, tc := range []struct {
+		seg  []byte
+		x, y float64
+		ok   bool
+	}{
+		{buildResolutionEXIF([2]uint32{300, 1}, [2]uint32{600, 2}, 2), 300, 300, true},
+		{buildResolutionEXIF([2]uint32{100, 1}, [2]uint32{50, 1}, 3), 254, 127, true},
+		{buildResolutionEXIF([2]uint32{1, 1}, [2]uint32{1, 1}, 1), 0, 0, false},
+		{buildResolutionEXIF([2]uint32{300, 0}, [2]uint32{300, 1}, 2), 0, 0, false},
+	} {
+		x, y, ok := ReadEXIFResolution(tc.seg)
+		if ok != tc.ok || x != tc.x || y != tc.y {
+			t.Errorf("got %g x %g (%v), want %g x %g (%v)", x, y, ok, tc.x, tc.y, tc.ok)
+		}
+	}
+
+	out, err := RewriteEXIF(buildResolutionEXIF([2]uint32{72, 1}, [2]uint32{72, 1}, 3), Update{XDPI: 300, YDPI: 299.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x, y, ok := ReadEXIFResolution(out); !ok || x != 300 || y != 299.5 {
+		t.Errorf("rewritten resolution %g x %g (%v)", x, y, ok)
+	}
+
+	xmp := []byte(`<rdf:Description tiff:XResolution="72/1" tiff:ResolutionUnit="3"><tiff:YResolution>72/1</tiff:YResolution></rdf:Description>`)
+	got := string(RewriteXMP(xmp, Update{XDPI: 300, YDPI: 299.5}))
+	for _, want := range []string{`tiff:XResolution="300/1"`, `tiff:ResolutionUnit="2"`, `<tiff:YResolution>299500/1000</tiff:YResolution>`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %s in %s", want, got)
+		}
+	}
+}
+
+func TestSetIRBResolution(t *testing.T) {
+	res := []byte{0x00, 0x48, 0x00, 0x00, 0, 1, 0, 1, 0x00, 0x48, 0x00, 0x00, 0, 1, 0, 1} // 72 dpi
+	app13 := []byte(irbHeader + "8BIM\x03\xed\x00\x00\x00\x00\x00\x10")
+	app13 = append(app13, res...)
+	out := SetIRBResolution(app13, 300, 150.5)
+	data := out[len(out)-16:]
+	if x := binary.BigEndian.Uint32(data); x != 300<<16 {
+		t.Errorf("horizontal resolution %#x", x)
+	}
+	if y := binary.BigEndian.Uint32(data[8:]); y != 150<<16|0x8000 {
+		t.Errorf("vertical resolution %#x", y)
+	}
+	if !bytes.Equal(app13[len(app13)-16:], res) {
+		t.Error("source payload modified")
+	}
+}
@@ -0,0 +1,38 @@
+package meta
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+)
+
+// xmpHeader prefixes the XMP packet in a standard XMP APP1 segment.
+const xmpHeader = "http://ns.adobe.com/xap/1.0/\x00"
+
+// IsXMP reports whether an APP1 payload holds a standard XMP packet.
+func IsXMP(app1 []byte) bool {
+	return bytes.HasPrefix(app1, []byte(xmpHeader))
+}
+
+// XMPPacket returns the XMP packet of an APP1 payload, or nil.
+func XMPPacket(app1 []byte) []byte {
+	if !IsXMP(app1) {
+		return nil
+	}
+	return app1[len(xmpHeader):]
+}
+
+// XMPProperty returns a simple property such as "photoshop:ICCProfile",
+// written either as an attribute or as an element.
+func XMPProperty(packet []byte, name string) (string, bool) {
+	q := regexp.QuoteMeta(name)
+	attr := regexp.MustCompile(q + `\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	if m := attr.FindSubmatch(packet); m != nil {
+		return html.UnescapeString(string(m[1]) + string(m[2])), true
+	}
+	elem := regexp.MustCompile(`<` + q + `>([^<]*)</` + q + `>`)
+	if m := elem.FindSubmatch(packet); m != nil {
+		return html.UnescapeString(string(m[1])), true
+	}
+	return "", false
+}
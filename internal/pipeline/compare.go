@@ -0,0 +1,94 @@
+package pipeline
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/davesmith10/RGBtoCMYK/internal/color"
+	"github.com/davesmith10/RGBtoCMYK/internal/jpeg"
+)
+
+// OutOfGamutDeltaE is the colorimetric round-trip error above which a source
+// colour counts as out of gamut for a destination.
+const OutOfGamutDeltaE = 3.0
+
+// maxGamutSamples bounds the number of pixels measured per destination.
+const maxGamutSamples = 1 << 18
+
+// Gamut summarises how well a destination reproduces its source.
+type Gamut struct {
+	OutOfGamut float64 // percent of sampled pixels beyond OutOfGamutDeltaE
+	MeanDeltaE float64 // mean ΔE*ab between source and separated colour
+	Samples    int
+}
+
+// measureGamut compares source colours with their separations on an evenly
+// spaced pixel sample. MeanDeltaE uses the separation actually produced;
+// OutOfGamut uses a relative colorimetric round trip, so gamut mapping done
+// by the perceptual intent is not counted against in-gamut colours.
+// DeviceLinks carry no colorimetry, so they yield nil.
+func measureGamut(decoded *jpeg.DecodedRGB, r *Result, opts Options) (*Gamut, error) {
+	if pi, err := color.ParseProfileInfo(opts.DstProfile); err == nil && pi.Class == "link" {
+		return nil, nil
+	}
+	n := decoded.Width * decoded.Height
+	step := (n + maxGamutSamples - 1) / maxGamutSamples
+	var rgb, cmyk []byte
+	for i := 0; i < n; i += step {
+		rgb = append(rgb, decoded.Pixels[3*i:3*i+3]...)
+		cmyk = append(cmyk, r.CMYK[4*i:4*i+4]...)
+	}
+
+	srcLab, err := toLab(r.SrcICC, rgb)
+	if err != nil {
+		return nil, fmt.Errorf("source Lab: %w", err)
+	}
+	dstLab, err := toLab(opts.DstProfile, cmyk)
+	if err != nil {
+		return nil, fmt.Errorf("destination Lab: %w", err)
+	}
+
+	xform, err := color.NewTransform(r.SrcICC, opts.DstProfile, color.IntentRelativeColorimetric)
+	if err != nil {
+		return nil, fmt.Errorf("gamut transform: %w", err)
+	}
+	defer xform.Close()
+	samples := len(rgb) / 3
+	roundTrip, err := xform.TransformPixels(rgb, samples, 1)
+	if err != nil {
+		return nil, err
+	}
+	rtLab, err := toLab(opts.DstProfile, roundTrip)
+	if err != nil {
+		return nil, fmt.Errorf("round-trip Lab: %w", err)
+	}
+
+	g := &Gamut{Samples: samples}
+	var sum float64
+	var out int
+	for i := 0; i < samples; i++ {
+		sum += deltaE(srcLab[3*i:], dstLab[3*i:])
+		if deltaE(srcLab[3*i:], rtLab[3*i:]) > OutOfGamutDeltaE {
+			out++
+		}
+	}
+	g.MeanDeltaE = sum / float64(samples)
+	g.OutOfGamut = float64(out) / float64(samples) * 100
+	return g, nil
+}
+
+// toLab converts device pixels to Lab with the profile's colorimetric tables.
+func toLab(icc, pixels []byte) ([]float64, error) {
+	t, err := color.NewLabTransform(icc, color.IntentRelativeColorimetric)
+	if err != nil {
+		return nil, err
+	}
+	defer t.Close()
+	return t.Lab(pixels)
+}
+
+// deltaE returns the CIE76 colour difference of two Lab triplets.
+func deltaE(a, b []float64) float64 {
+	dl, da, db := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return math.Sqrt(dl*dl + da*da + db*db)
+}
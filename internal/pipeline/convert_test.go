@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/davesmith10/RGBtoCMYK/internal/black"
 	"github.com/davesmith10/RGBtoCMYK/internal/color"
 	"github.com/davesmith10/RGBtoCMYK/internal/jpeg"
 )
@@ -14,11 +15,13 @@ const (
 	cmykProfile = "/mnt/c/Users/daves/OneDrive/Desktop/rgb_to_cmyk/magick-workflow/PSOcoated_v3.icc"
 )
 
+// loadCMYKProfile returns the press profile if it is installed, otherwise
+// the built-in generic coated profile.
 func loadCMYKProfile(t *testing.T) []byte {
 	t.Helper()
 	data, err := os.ReadFile(cmykProfile)
 	if err != nil {
-		t.Skipf("CMYK profile not available: %v", err)
+		data, _ = color.BuiltinProfile(color.DefaultCMYKProfile)
 	}
 	return data
 }
@@ -487,3 +490,71 @@ func TestConvert_SrcProfileOverride(t *testing.T) {
 	}
 	verifyOutput(t, "src-profile-override", input, result.Data, result)
 }
+
+// --- Black handling test ---
+
+func TestConvert_VectorBlackAuto(t *testing.T) {
+	profile := loadCMYKProfile(t)
+	input := loadTestImage(t, filepath.Join(testdataDir, "openprint", "a6-portrait-vector-srgb.jpg"))
+
+	blackOpts := black.DefaultOptions()
+	blackOpts.Mode = black.Auto
+	result, err := Run(input, Options{
+		DstProfile:   profile,
+		Quality:      85,
+		CMYReduction: 15,
+		Intent:       color.IntentPerceptual,
+		Black:        blackOpts,
+	})
+	if err != nil {
+		t.Fatalf("pipeline failed: %v", err)
+	}
+	verifyOutput(t, "vector-black-auto", input, result.Data, result)
+
+	if result.Black.Regions == 0 || result.Black.KOnlyPixels == 0 {
+		t.Errorf("expected K-only black text, got %+v", result.Black)
+	}
+	for i := 0; i < len(result.CMYK); i += 4 {
+		px := result.CMYK[i : i+4]
+		if px[3] == 255 && px[0] == 0 && px[1] == 0 && px[2] == 0 {
+			return
+		}
+	}
+	t.Error("no K-only pixels in output")
+}
+
+// --- Fan-out test ---
+
+func TestRunAll_FanOut(t *testing.T) {
+	input := loadTestImage(t, filepath.Join(testdataDir, "openprint", "a6-portrait-photo-srgb.jpg"))
+
+	var opts []Options
+	for _, name := range []string{"generic-coated", "generic-uncoated", "generic-newsprint"} {
+		profile, _ := color.BuiltinProfile(name)
+		opts = append(opts, Options{
+			DstProfile:   profile,
+			Quality:      85,
+			CMYReduction: 15,
+			Intent:       color.IntentPerceptual,
+		})
+	}
+
+	results, err := RunAll(input, opts, true)
+	if err != nil {
+		t.Fatalf("RunAll: %v", err)
+	}
+	if len(results) != len(opts) {
+		t.Fatalf("expected %d results, got %d", len(opts), len(results))
+	}
+	for i, r := range results {
+		verifyOutput(t, "fan-out", input, r.Data, r)
+		if r.Gamut == nil || r.Gamut.Samples == 0 {
+			t.Fatalf("result %d: missing gamut statistics", i)
+		}
+	}
+	// Newsprint has the smallest gamut of the three.
+	if results[2].Gamut.OutOfGamut < results[0].Gamut.OutOfGamut {
+		t.Errorf("newsprint out of gamut %.1f%% < coated %.1f%%",
+			results[2].Gamut.OutOfGamut, results[0].Gamut.OutOfGamut)
+	}
+}
@@ -0,0 +1,28 @@
+package pipeline
+
+// Stage is the step of a run an error comes from.
+type Stage int
+
+const (
+	StageDecode    Stage = iota + 1 // reading the input image
+	StageProfile                    // setting up a transform from the profiles
+	StageTransform                  // colour transform and black handling
+	StageEncode                     // writing the output JPEG
+	StageOptions                    // checking the options, before any work
+)
+
+// StageError is an error from one stage of a pipeline run. Its message is
+// the failed operation followed by the cause, as in "decode: ...".
+type StageError struct {
+	Stage Stage
+	Op    string
+	Err   error
+}
+
+func (e *StageError) Error() string { return e.Op + ": " + e.Err.Error() }
+
+func (e *StageError) Unwrap() error { return e.Err }
+
+func stageError(stage Stage, op string, err error) error {
+	return &StageError{Stage: stage, Op: op, Err: err}
+}
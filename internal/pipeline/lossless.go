@@ -0,0 +1,146 @@
+package pipeline
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/davesmith10/RGBtoCMYK/internal/color"
+	"github.com/davesmith10/RGBtoCMYK/internal/jpeg"
+	"github.com/davesmith10/RGBtoCMYK/internal/meta"
+)
+
+// LosslessOptions controls a lossless transform of a CMYK JPEG.
+type LosslessOptions struct {
+	AutoOrient  bool            // first turn the image upright by its EXIF orientation
+	Transform   jpeg.Transform  // rotation or flip, after AutoOrient
+	Crop        image.Rectangle // region to keep after the transforms, empty for all
+	Progressive bool            // write progressive
+	Baseline    bool            // write baseline sequential
+	Scans       []jpeg.Scan     // progressive scan script, nil for the default; implies Progressive
+}
+
+// LosslessResult holds the output of Lossless.
+type LosslessResult struct {
+	Data        []byte
+	Width       int
+	Height      int
+	Orientation int              // source EXIF orientation, 0 if none
+	Applied     []jpeg.Transform // transforms applied, in order
+	Crop        image.Rectangle  // region kept after alignment, empty if not cropped
+	Progressive bool
+}
+
+// Lossless rotates, flips, crops or rescans a CMYK JPEG without decoding
+// it: the DCT coefficients and quantization tables are kept, and Huffman
+// tables are optimized for the result. ICC, EXIF, XMP, IPTC and other
+// markers are kept in order; when the geometry changes, EXIF, XMP and
+// Photoshop fields describing it are rewritten and thumbnails dropped.
+//
+// Without Progressive, Baseline or Scans the source's mode is kept, a
+// progressive source getting the default scan script.
+func Lossless(data []byte, opts LosslessOptions) (*LosslessResult, error) {
+	if (opts.Progressive || opts.Scans != nil) && opts.Baseline {
+		return nil, fmt.Errorf("progressive and baseline output cannot be combined")
+	}
+	c, err := jpeg.ReadCoefficients(data)
+	if err != nil {
+		return nil, stageError(StageDecode, "decode", err)
+	}
+
+	res := &LosslessResult{}
+	var app1, app2, app13 [][]byte
+	for _, m := range c.Markers {
+		switch m.Code {
+		case jpeg.MarkerAPP1:
+			app1 = append(app1, m.Data)
+		case jpeg.MarkerAPP2:
+			app2 = append(app2, m.Data)
+		case jpeg.MarkerAPP13:
+			app13 = append(app13, m.Data)
+		}
+	}
+	for _, seg := range app1 {
+		if o, ok := meta.ReadEXIFOrientation(seg); ok {
+			res.Orientation = o
+			break
+		}
+	}
+
+	var transforms []jpeg.Transform
+	if opts.AutoOrient {
+		if t := jpeg.OrientationTransform(res.Orientation); t != jpeg.NoTransform {
+			transforms = append(transforms, t)
+		}
+	}
+	if opts.Transform != jpeg.NoTransform {
+		transforms = append(transforms, opts.Transform)
+	}
+	swapped := false
+	for _, t := range transforms {
+		if c, err = c.Transform(t); err != nil {
+			return nil, err
+		}
+		res.Applied = append(res.Applied, t)
+		swapped = swapped != t.SwapsAxes()
+	}
+	if !opts.Crop.Empty() {
+		if c, err = c.Crop(opts.Crop); err != nil {
+			return nil, err
+		}
+		res.Crop = jpeg.AlignCrop(opts.Crop.Canon())
+	}
+
+	if len(res.Applied) > 0 || !res.Crop.Empty() {
+		u := meta.Update{Width: c.Width, Height: c.Height}
+		if opts.AutoOrient && res.Orientation != 0 {
+			u.Orientation = 1
+		}
+		if swapped {
+			for _, seg := range app1 {
+				if x, y, ok := meta.ReadEXIFResolution(seg); ok {
+					u.XDPI, u.YDPI = y, x
+					break
+				}
+			}
+		}
+		if icc, err := jpeg.ExtractICC(app2); err == nil && icc != nil {
+			if pi, err := color.ParseProfileInfo(icc); err == nil {
+				u.ProfileName = pi.Description
+			}
+		}
+		app1, app13 = meta.Carry(app1, app13, u)
+		c.Markers = replaceMarkers(c.Markers, jpeg.MarkerAPP1, app1)
+		c.Markers = replaceMarkers(c.Markers, jpeg.MarkerAPP13, app13)
+	}
+
+	var scans []jpeg.Scan
+	switch {
+	case opts.Scans != nil:
+		scans = opts.Scans
+	case opts.Progressive, c.Progressive && !opts.Baseline:
+		scans = jpeg.DefaultCMYKScans()
+	}
+	res.Data, err = c.Encode(scans)
+	if err != nil {
+		return nil, stageError(StageEncode, "encode", err)
+	}
+	res.Width, res.Height = c.Width, c.Height
+	res.Progressive = scans != nil
+	return res, nil
+}
+
+// replaceMarkers puts payloads, in order, in place of the markers with
+// the given code; markers without a replacement are dropped.
+func replaceMarkers(markers []jpeg.Marker, code int, payloads [][]byte) []jpeg.Marker {
+	var out []jpeg.Marker
+	for _, m := range markers {
+		if m.Code == code {
+			if len(payloads) == 0 {
+				continue
+			}
+			m.Data, payloads = payloads[0], payloads[1:]
+		}
+		out = append(out, m)
+	}
+	return out
+}
@@ -0,0 +1,79 @@
+package pipeline
+
+import (
+	"bytes"
+	"image"
+	"strings"
+	"testing"
+
+	"github.com/davesmith10/RGBtoCMYK/internal/color"
+	"github.com/davesmith10/RGBtoCMYK/internal/jpeg"
+	"github.com/davesmith10/RGBtoCMYK/internal/meta"
+)
+
+func TestLossless(t *testing.T) {
+	const w, h = 24, 16
+	pixels := make([]byte, w*h*4)
+	for i := range pixels {
+		pixels[i] = byte(i * 7)
+	}
+	exif := []byte("Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08" +
+		"\x00\x01" +
+		"\x01\x12\x00\x03\x00\x00\x00\x01\x00\x06\x00\x00" + // Orientation 6
+		"\x00\x00\x00\x00")
+	xmp := []byte(`http://ns.adobe.com/xap/1.0/` + "\x00" + `<rdf:Description tiff:ImageWidth="24" tiff:Orientation="6"/>`)
+	profile, _ := color.BuiltinProfile(color.DefaultCMYKProfile)
+	src, err := jpeg.EncodeCMYK(pixels, w, h, profile, jpeg.EncoderOptions{Quality: 90, APP1: [][]byte{exif, xmp}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src = withSegments(src, append([]byte{0xFE}, "made for a test"...))
+
+	r, err := Lossless(src, LosslessOptions{AutoOrient: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Width != h || r.Height != w || r.Orientation != 6 || len(r.Applied) != 1 || r.Applied[0] != jpeg.Rotate90 || r.Progressive {
+		t.Fatalf("result %dx%d orientation %d applied %v progressive %v", r.Width, r.Height, r.Orientation, r.Applied, r.Progressive)
+	}
+	app1 := appSegments(r.Data, 0xE1)
+	if len(app1) != 2 {
+		t.Fatalf("got %d APP1 segments, want 2", len(app1))
+	}
+	if o, ok := meta.ReadEXIFOrientation(app1[0]); !ok || o != 1 {
+		t.Errorf("EXIF orientation = %d, want 1", o)
+	}
+	if x := string(app1[1]); !strings.Contains(x, `tiff:ImageWidth="16"`) || !strings.Contains(x, `tiff:Orientation="1"`) {
+		t.Errorf("XMP = %s", x)
+	}
+	if com := appSegments(r.Data, 0xFE); len(com) != 1 || string(com[0]) != "made for a test" {
+		t.Errorf("COM = %q", com)
+	}
+	if info, err := jpeg.GetInfo(r.Data); err != nil || !bytes.Equal(info.ICC, profile) || info.Convention != jpeg.AdobeInverted {
+		t.Errorf("ICC profile or convention not kept: %v", err)
+	}
+
+	r, err = Lossless(src, LosslessOptions{Transform: jpeg.FlipHorizontal, Crop: image.Rect(3, 2, 20, 16), Progressive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Width != 20 || r.Height != 16 || r.Crop != image.Rect(0, 0, 20, 16) || !r.Progressive {
+		t.Errorf("result %dx%d crop %v progressive %v", r.Width, r.Height, r.Crop, r.Progressive)
+	}
+	if o, _ := meta.ReadEXIFOrientation(appSegments(r.Data, 0xE1)[0]); o != 6 {
+		t.Errorf("orientation %d changed without AutoOrient", o)
+	}
+
+	// Rescanning alone leaves the markers untouched.
+	r, err = Lossless(src, LosslessOptions{Progressive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := appSegments(r.Data, 0xE1); len(got) != 2 || !bytes.Equal(got[0], exif) || !bytes.Equal(got[1], xmp) {
+		t.Error("APP1 segments changed by a rescan")
+	}
+
+	if _, err := Lossless(src, LosslessOptions{Progressive: true, Baseline: true}); err == nil {
+		t.Error("progressive and baseline accepted together")
+	}
+}
@@ -0,0 +1,63 @@
+package pipeline
+
+import (
+	"github.com/davesmith10/RGBtoCMYK/internal/jpeg"
+)
+
+// OptimizeOptions controls recompression of an existing CMYK JPEG.
+type OptimizeOptions struct {
+	Quality        int         // JPEG quality (1-100)
+	CMYReduction   int         // quality reduction for CMY channels
+	ChannelQuality [4]int      // per-channel C, M, Y, K quality; 0 follows Quality
+	BaseTables     [][64]int   // base quantization tables, nil for Annex K
+	Progressive    bool        // write a progressive JPEG
+	Scans          []jpeg.Scan // progressive scan script, nil for the default
+}
+
+// OptimizeResult holds the output of Optimize.
+type OptimizeResult struct {
+	Data          []byte // recompressed file, or the input if that was smaller
+	Width, Height int
+	Kept          bool // the input was no larger than the re-encode and is returned as is
+	Encoded       int  // size of the re-encode, also when it was not used
+}
+
+// Optimize re-encodes a CMYK or YCCK JPEG with channel-aware quantization
+// and optimized Huffman tables, without any colour transform. The ICC
+// profile, EXIF, XMP, IPTC, JFIF density and sample convention are kept;
+// EXIF and XMP are copied as they are, since the image itself does not
+// change. The result is never larger than the input: if the re-encode is
+// not smaller, the input is returned with Kept set.
+func Optimize(data []byte, opts OptimizeOptions) (*OptimizeResult, error) {
+	info, err := jpeg.GetInfo(data)
+	if err != nil {
+		return nil, stageError(StageDecode, "decode", err)
+	}
+	decoded, err := jpeg.DecodeCMYK(data)
+	if err != nil {
+		return nil, stageError(StageDecode, "decode", err)
+	}
+
+	encoded, err := jpeg.EncodeCMYK(decoded.Pixels, decoded.Width, decoded.Height, decoded.ICC, jpeg.EncoderOptions{
+		Quality:        opts.Quality,
+		CMYReduction:   opts.CMYReduction,
+		ChannelQuality: opts.ChannelQuality,
+		BaseTables:     opts.BaseTables,
+		Progressive:    opts.Progressive,
+		Scans:          opts.Scans,
+		Convention:     decoded.Convention,
+		YCCK:           info.ColorSpace == "YCCK",
+		APP1:           decoded.APP1,
+		APP13:          decoded.APP13,
+		Density:        decoded.JFIF,
+	})
+	if err != nil {
+		return nil, stageError(StageEncode, "encode", err)
+	}
+
+	r := &OptimizeResult{Data: encoded, Width: decoded.Width, Height: decoded.Height, Encoded: len(encoded)}
+	if len(encoded) >= len(data) {
+		r.Data, r.Kept = data, true
+	}
+	return r, nil
+}
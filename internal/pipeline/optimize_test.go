@@ -0,0 +1,57 @@
+package pipeline
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/davesmith10/RGBtoCMYK/internal/color"
+	"github.com/davesmith10/RGBtoCMYK/internal/jpeg"
+)
+
+func TestOptimize(t *testing.T) {
+	const w, h = 64, 48
+	pixels := make([]byte, w*h*4)
+	for i := range pixels {
+		pixels[i] = byte(i*13) ^ byte(i>>7)
+	}
+	exif := []byte("Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08\x00\x00\x00\x00\x00\x00")
+	profile, _ := color.BuiltinProfile(color.DefaultCMYKProfile)
+	src, err := jpeg.EncodeCMYK(pixels, w, h, profile, jpeg.EncoderOptions{
+		Quality: 100, CMYReduction: 1, Convention: jpeg.Plain,
+		APP1: [][]byte{exif}, Density: jpeg.Density{X: 300, Y: 300},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Optimize(src, OptimizeOptions{Quality: 80, CMYReduction: 15})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Kept || len(r.Data) >= len(src) || r.Encoded != len(r.Data) {
+		t.Fatalf("%d → %d bytes, kept %v", len(src), len(r.Data), r.Kept)
+	}
+	info, err := jpeg.GetInfo(r.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(info.ICC, profile) || info.Convention != jpeg.Plain || info.JFIF.X != 300 {
+		t.Errorf("profile, convention or density changed: %v %v", info.Convention, info.JFIF)
+	}
+	if len(info.APP1) != 1 || !bytes.Equal(info.APP1[0], exif) {
+		t.Errorf("APP1 = %q", info.APP1)
+	}
+
+	// Going back up in quality cannot shrink the file, so it is kept.
+	again, err := Optimize(r.Data, OptimizeOptions{Quality: 100, CMYReduction: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again.Kept || !bytes.Equal(again.Data, r.Data) || again.Encoded <= len(r.Data) {
+		t.Errorf("kept %v, re-encode %d of %d bytes", again.Kept, again.Encoded, len(r.Data))
+	}
+
+	if _, err := Optimize([]byte("not a jpeg"), OptimizeOptions{Quality: 80}); err == nil {
+		t.Error("non-JPEG input accepted")
+	}
+}
@@ -1,76 +1,321 @@
 package pipeline
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"sync"
+	"time"
 
+	"github.com/davesmith10/RGBtoCMYK/internal/black"
 	"github.com/davesmith10/RGBtoCMYK/internal/color"
+	"github.com/davesmith10/RGBtoCMYK/internal/fidelity"
 	"github.com/davesmith10/RGBtoCMYK/internal/jpeg"
+	"github.com/davesmith10/RGBtoCMYK/internal/meta"
 )
 
 // Options controls the full RGB→CMYK conversion pipeline.
 type Options struct {
-	SrcProfileOverride []byte // optional: override source RGB ICC profile
-	DstProfile         []byte // required: destination CMYK ICC profile
-	Quality            int    // JPEG quality (1-100)
-	CMYReduction       int    // quality reduction for CMY channels
-	Intent             int    // lcms2 rendering intent
+	SrcProfileOverride []byte          // optional: override source RGB ICC profile
+	AssumeProfile      []byte          // optional: source profile for untagged input
+	DstProfile         []byte          // required: destination CMYK ICC profile
+	Quality            int             // JPEG quality (1-100)
+	CMYReduction       int             // quality reduction for CMY channels
+	ChannelQuality     [4]int          // per-channel C, M, Y, K quality; 0 follows Quality
+	BaseTables         [][64]int       // base quantization tables, nil for Annex K
+	Intent             int             // lcms2 rendering intent
+	Black              black.Options   // pure-black handling, off by default
+	Progressive        bool            // write a progressive JPEG
+	Scans              []jpeg.Scan     // progressive scan script, nil for the default
+	Convention         jpeg.Convention // CMYK sample convention, Adobe-inverted by default
+	YCCK               bool            // store CMY as YCbCr (Adobe transform 2)
+	StripMetadata      bool            // drop the source's EXIF, XMP and IPTC
+	StripGPS           bool            // drop GPS data from carried-over metadata
+	DPI                float64         // print resolution in pixels per inch, 0 keeps the source's
+	Software           string          // tool name and version for the XMP conversion record, "" for none
+	TargetSize         int             // if set, search Quality and CMYReduction to fit this many bytes
+	Target             fidelity.Target // if set, the smallest output meeting this score
 }
 
 // Result holds the output of a pipeline run.
 type Result struct {
-	Data      []byte // encoded CMYK JPEG
-	SrcWidth  int
-	SrcHeight int
+	Data         []byte // encoded CMYK JPEG
+	CMYK         []byte // CMYK pixels before encoding, interleaved
+	SrcWidth     int
+	SrcHeight    int
+	SrcReason    string // how the source profile was chosen
+	Intent       int    // rendering intent used, after any fallback
+	Quality      int    // JPEG quality used, after any TargetSize search
+	CMYReduction int    // CMY quality reduction used
+	Black        black.Stats
+	SrcICC       []byte           // source profile used
+	Density      jpeg.Density     // print resolution written, zero if unknown
+	DensityFrom  string           // where Density came from
+	Gamut        *Gamut           // reproduction statistics, set by RunAll when comparing
+	Fidelity     *fidelity.Scores // per-plate scores of the output, set with Options.Target
+	Warnings     []string         // things the caller should know, such as a discarded profile
+}
+
+// SourceProfile picks the RGB profile for a decoded image and describes why.
+// The order is: explicit override, embedded RGB profile, assumed profile,
+// EXIF/XMP colour-space hints, then sRGB.
+func SourceProfile(decoded *jpeg.DecodedRGB, override, assume []byte) ([]byte, string) {
+	if override != nil {
+		return override, "source profile override"
+	}
+	if decoded.ICC != nil && IgnoredProfile(decoded) == "" {
+		return decoded.ICC, "embedded ICC profile"
+	}
+	if assume != nil {
+		return assume, "assumed profile for untagged input"
+	}
+	if hint, ok := meta.DetectColorSpace(decoded.APP1); ok {
+		if data, ok := color.BuiltinProfile(hint.Space); ok {
+			return data, fmt.Sprintf("%s, using built-in %s", hint.Reason, hint.Space)
+		}
+	}
+	return color.EmbeddedSRGB, "no profile or metadata hints, assuming sRGB"
+}
+
+// IgnoredProfile says why SourceProfile passes over the embedded profile of
+// a decoded image, or returns "" if it has none or it is usable.
+func IgnoredProfile(decoded *jpeg.DecodedRGB) string {
+	if decoded.ICC == nil {
+		return ""
+	}
+	// libjpeg already converted grayscale pixels to RGB, so we need an RGB
+	// source profile.
+	if pi, err := color.ParseProfileInfo(decoded.ICC); err == nil && pi.ColorSpace == "GRAY" {
+		return "embedded grayscale profile discarded, the decoder delivers RGB"
+	}
+	return ""
+}
+
+// newProvenance starts the conversion record for a run with opts; the
+// encoder settings are filled in once they are final.
+func newProvenance(srcDigest string, srcICC []byte, opts Options, intent int) *meta.Provenance {
+	p := &meta.Provenance{
+		Software:            opts.Software,
+		When:                time.Now().UTC().Truncate(time.Second),
+		SourceDigest:        srcDigest,
+		SourceProfileDigest: digest(srcICC),
+		DestProfileDigest:   digest(opts.DstProfile),
+		Intent:              color.IntentName(intent),
+		BPC:                 color.BlackPointCompensation(srcICC, opts.DstProfile, intent),
+		Black:               opts.Black.String(),
+	}
+	if pi, err := color.ParseProfileInfo(srcICC); err == nil {
+		p.SourceProfile = pi.Description
+	}
+	if pi, err := color.ParseProfileInfo(opts.DstProfile); err == nil {
+		p.DestProfile = pi.Description
+	}
+	return p
+}
+
+// SourceDensity picks the print resolution of a source image from its
+// JFIF density, given in absolute units, then the EXIF resolution, and
+// says where it came from. It returns the zero Density if neither is set.
+func SourceDensity(jfif jpeg.Density, app1 [][]byte) (jpeg.Density, string) {
+	if jfif.Known() {
+		return jfif, "JFIF"
+	}
+	for _, seg := range app1 {
+		if x, y, ok := meta.ReadEXIFResolution(seg); ok {
+			return jpeg.Density{X: x, Y: y}, "EXIF"
+		}
+	}
+	return jpeg.Density{}, ""
 }
 
 // Run executes the full RGB→CMYK pipeline: decode → color transform → encode.
 func Run(jpegData []byte, opts Options) (*Result, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
 	// 1. Decode RGB JPEG
 	decoded, err := jpeg.DecodeRGB(jpegData)
 	if err != nil {
-		return nil, fmt.Errorf("decode: %w", err)
+		return nil, stageError(StageDecode, "decode", err)
 	}
+	return convert(decoded, digest(jpegData), opts)
+}
 
-	// 2. Determine source ICC profile
-	// If the embedded profile is grayscale, discard it — libjpeg already
-	// converted the pixels to RGB, so we need an RGB source profile.
-	srcICC := opts.SrcProfileOverride
-	if srcICC == nil {
-		srcICC = decoded.ICC
-	}
-	if srcICC != nil {
-		if pi, err := color.ParseProfileInfo(srcICC); err == nil && pi.ColorSpace == "GRAY" {
-			srcICC = nil // fall through to sRGB fallback
+// RunAll converts one source for several destinations. The source is decoded
+// once and the destinations run in parallel; results are in opts order. When
+// compare is set, each result carries Gamut statistics.
+func RunAll(jpegData []byte, opts []Options, compare bool) ([]*Result, error) {
+	for i := range opts {
+		if err := opts[i].validate(); err != nil {
+			return nil, fmt.Errorf("destination %d: %w", i+1, err)
+		}
+	}
+	decoded, err := jpeg.DecodeRGB(jpegData)
+	if err != nil {
+		return nil, stageError(StageDecode, "decode", err)
+	}
+
+	srcDigest := digest(jpegData)
+	results := make([]*Result, len(opts))
+	errs := make([]error, len(opts))
+	var wg sync.WaitGroup
+	for i := range opts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r, err := convert(decoded, srcDigest, opts[i])
+			if err == nil && compare {
+				r.Gamut, err = measureGamut(decoded, r, opts[i])
+			}
+			results[i], errs[i] = r, err
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("destination %d: %w", i+1, err)
 		}
 	}
-	if srcICC == nil {
-		srcICC = color.EmbeddedSRGB
+	return results, nil
+}
+
+// validate rejects option combinations that cannot run, so they fail
+// before the source is decoded.
+func (o Options) validate() error {
+	if o.TargetSize > 0 && o.Target.Value > 0 {
+		return stageError(StageOptions, "options", fmt.Errorf("a size target and a %s target cannot be combined", o.Target.Metric))
+	}
+	return nil
+}
+
+// digest returns the SHA-256 of data in the form used by the conversion
+// record.
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// convert runs the pipeline after decoding; srcDigest identifies the input
+// file in the conversion record. decoded is not modified.
+func convert(decoded *jpeg.DecodedRGB, srcDigest string, opts Options) (*Result, error) {
+	// 2. Determine source ICC profile
+	srcICC, srcReason := SourceProfile(decoded, opts.SrcProfileOverride, opts.AssumeProfile)
+	var warnings []string
+	if ignored := IgnoredProfile(decoded); ignored != "" && opts.SrcProfileOverride == nil {
+		warnings = append(warnings, ignored)
 	}
 
 	// 3. Color transform RGB → CMYK
 	xform, err := color.NewTransform(srcICC, opts.DstProfile, opts.Intent)
 	if err != nil {
-		return nil, fmt.Errorf("color transform setup: %w", err)
+		return nil, stageError(StageProfile, "color transform setup", err)
 	}
 	defer xform.Close()
 
 	cmykPixels, err := xform.TransformPixels(decoded.Pixels, decoded.Width, decoded.Height)
 	if err != nil {
-		return nil, fmt.Errorf("color transform: %w", err)
+		return nil, stageError(StageTransform, "color transform", err)
 	}
 
-	// 4. Encode CMYK JPEG
-	encoded, err := jpeg.EncodeCMYK(cmykPixels, decoded.Width, decoded.Height, opts.DstProfile, jpeg.EncoderOptions{
-		Quality:      opts.Quality,
-		CMYReduction: opts.CMYReduction,
-	})
+	// 4. Rewrite pure-black areas (text, hairlines, solids)
+	blackStats, err := black.Apply(decoded.Pixels, cmykPixels, decoded.Width, decoded.Height, opts.Black)
+	if err != nil {
+		return nil, stageError(StageTransform, "black handling", err)
+	}
+
+	// 5. Encode CMYK JPEG
+	density, densityFrom := SourceDensity(decoded.JFIF, decoded.APP1)
+	if opts.DPI > 0 {
+		density, densityFrom = jpeg.Density{X: opts.DPI, Y: opts.DPI}, "override"
+	}
+	var app1, app13 [][]byte
+	if !opts.StripMetadata {
+		u := meta.Update{
+			Width:    decoded.Width,
+			Height:   decoded.Height,
+			StripGPS: opts.StripGPS,
+			XDPI:     density.X,
+			YDPI:     density.Y,
+		}
+		if pi, err := color.ParseProfileInfo(opts.DstProfile); err == nil {
+			u.ProfileName = pi.Description
+		}
+		app1, app13 = meta.Carry(decoded.APP1, decoded.APP13, u)
+	}
+	encOpts := jpeg.EncoderOptions{
+		Quality:        opts.Quality,
+		CMYReduction:   opts.CMYReduction,
+		ChannelQuality: opts.ChannelQuality,
+		BaseTables:     opts.BaseTables,
+		Progressive:    opts.Progressive,
+		Scans:          opts.Scans,
+		Convention:     opts.Convention,
+		YCCK:           opts.YCCK,
+		APP1:           app1,
+		APP13:          app13,
+		Density:        density,
+	}
+
+	// The conversion record names the settings found by a target search,
+	// so a search runs with a record of the widest values and the result is
+	// encoded again with the real one. The record can only shrink, so a
+	// size target still holds.
+	// A source XMP segment too full to take the record is kept as it was
+	// and the record left out.
+	var record *meta.Provenance
+	search := opts.TargetSize > 0 || opts.Target.Value > 0
+	if opts.Software != "" {
+		record = newProvenance(srcDigest, srcICC, opts, xform.Intent())
+		written := *record
+		if search {
+			written.Quality, written.CMYReduction, written.Qualities = 100, 100, [4]int{100, 100, 100, 100}
+		} else {
+			record.Quality, record.CMYReduction, record.Qualities = encOpts.Quality, encOpts.CMYReduction, encOpts.Qualities()
+			written = *record
+		}
+		withRecord, err := meta.WithProvenance(app1, written)
+		if err != nil {
+			warnings = append(warnings, "conversion record not written: "+err.Error())
+			record = nil
+		} else {
+			encOpts.APP1 = withRecord
+		}
+	}
+	var encoded []byte
+	var scores *fidelity.Scores
+	switch {
+	case opts.Target.Value > 0:
+		encoded, encOpts, scores, err = fidelity.EncodeToTarget(cmykPixels, decoded.Width, decoded.Height, opts.DstProfile, encOpts, opts.Target)
+	case opts.TargetSize > 0:
+		encoded, encOpts, err = jpeg.EncodeCMYKToSize(cmykPixels, decoded.Width, decoded.Height, opts.DstProfile, encOpts, opts.TargetSize)
+	default:
+		encoded, err = jpeg.EncodeCMYK(cmykPixels, decoded.Width, decoded.Height, opts.DstProfile, encOpts)
+	}
+	if err == nil && record != nil && search {
+		record.Quality, record.CMYReduction, record.Qualities = encOpts.Quality, encOpts.CMYReduction, encOpts.Qualities()
+		// The real record is no longer than the widest one, so it fits.
+		encOpts.APP1, _ = meta.WithProvenance(app1, *record)
+		encoded, err = jpeg.EncodeCMYK(cmykPixels, decoded.Width, decoded.Height, opts.DstProfile, encOpts)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("encode: %w", err)
+		return nil, stageError(StageEncode, "encode", err)
 	}
 
 	return &Result{
-		Data:      encoded,
-		SrcWidth:  decoded.Width,
-		SrcHeight: decoded.Height,
+		Data:         encoded,
+		CMYK:         cmykPixels,
+		SrcWidth:     decoded.Width,
+		SrcHeight:    decoded.Height,
+		SrcReason:    srcReason,
+		SrcICC:       srcICC,
+		Density:      density,
+		DensityFrom:  densityFrom,
+		Intent:       xform.Intent(),
+		Quality:      encOpts.Quality,
+		CMYReduction: encOpts.CMYReduction,
+		Black:        blackStats,
+		Fidelity:     scores,
+		Warnings:     warnings,
 	}, nil
 }
@@ -1,11 +1,18 @@
 package pipeline
 
 import (
+	"bytes"
+	"errors"
+	"image"
+	stdjpeg "image/jpeg"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/davesmith10/RGBtoCMYK/internal/color"
+	"github.com/davesmith10/RGBtoCMYK/internal/fidelity"
 	"github.com/davesmith10/RGBtoCMYK/internal/jpeg"
+	"github.com/davesmith10/RGBtoCMYK/internal/meta"
 )
 
 func TestFullPipeline(t *testing.T) {
@@ -13,10 +20,7 @@ func TestFullPipeline(t *testing.T) {
 	if err != nil {
 		t.Skipf("test input not available: %v", err)
 	}
-	dstProfile, err := os.ReadFile("/mnt/c/Users/daves/OneDrive/Desktop/rgb_to_cmyk/magick-workflow/PSOcoated_v3.icc")
-	if err != nil {
-		t.Skipf("CMYK profile not available: %v", err)
-	}
+	dstProfile := loadCMYKProfile(t)
 
 	result, err := Run(inputData, Options{
 		DstProfile:   dstProfile,
@@ -55,3 +59,212 @@ func TestFullPipeline(t *testing.T) {
 	t.Logf("Input size: %d bytes, Output size: %d bytes, Ratio: %.1f%%",
 		len(inputData), len(result.Data), float64(len(result.Data))/float64(len(inputData))*100)
 }
+
+func TestSourceProfile(t *testing.T) {
+	adobe, _ := color.BuiltinProfile("adobergb")
+	xmp := []byte("http://ns.adobe.com/xap/1.0/\x00<rdf:Description photoshop:ICCProfile=\"Adobe RGB (1998)\"/>")
+	override := []byte("override")
+	gray := append([]byte(nil), color.EmbeddedSRGB...)
+	copy(gray[16:20], "GRAY")
+
+	tests := []struct {
+		name    string
+		decoded *jpeg.DecodedRGB
+		assume  []byte
+		want    []byte
+	}{
+		{"untagged", &jpeg.DecodedRGB{}, nil, color.EmbeddedSRGB},
+		{"xmp hint", &jpeg.DecodedRGB{APP1: [][]byte{xmp}}, nil, adobe},
+		{"assume beats hint", &jpeg.DecodedRGB{APP1: [][]byte{xmp}}, override, override},
+		{"embedded beats hint", &jpeg.DecodedRGB{ICC: color.EmbeddedSRGB, APP1: [][]byte{xmp}}, nil, color.EmbeddedSRGB},
+		{"grayscale discarded", &jpeg.DecodedRGB{ICC: gray, APP1: [][]byte{xmp}}, nil, adobe},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reason := SourceProfile(tt.decoded, nil, tt.assume)
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("got %d-byte profile, want %d bytes (reason %q)", len(got), len(tt.want), reason)
+			}
+			if reason == "" {
+				t.Error("empty reason")
+			}
+			if ignored := IgnoredProfile(tt.decoded); (ignored != "") != (tt.decoded.ICC != nil && !bytes.Equal(got, tt.decoded.ICC)) {
+				t.Errorf("IgnoredProfile = %q", ignored)
+			}
+		})
+	}
+}
+
+func TestStageError(t *testing.T) {
+	_, err := Run([]byte("not a JPEG"), Options{DstProfile: color.EmbeddedSRGB})
+	var se *StageError
+	if !errors.As(err, &se) || se.Stage != StageDecode || !strings.HasPrefix(err.Error(), "decode: ") {
+		t.Errorf("got %v, want a decode StageError", err)
+	}
+
+	// Conflicting targets are rejected before the input is read.
+	opts := Options{DstProfile: color.EmbeddedSRGB, TargetSize: 100000, Target: fidelity.Target{Metric: fidelity.SSIM, Value: 0.98}}
+	_, err = Run([]byte("not a JPEG"), opts)
+	if !errors.As(err, &se) || se.Stage != StageOptions {
+		t.Errorf("got %v, want an options StageError", err)
+	}
+	if _, err = RunAll([]byte("not a JPEG"), []Options{{DstProfile: color.EmbeddedSRGB}, opts}, false); !errors.As(err, &se) || se.Stage != StageOptions {
+		t.Errorf("RunAll: got %v, want an options StageError", err)
+	}
+}
+
+// withSegments inserts APPn segments after SOI.
+func withSegments(jpegData []byte, segs ...[]byte) []byte {
+	out := []byte{0xFF, 0xD8}
+	for _, s := range segs {
+		n := len(s) - 1 + 2 // s[0] is the marker code
+		out = append(out, 0xFF, s[0], byte(n>>8), byte(n))
+		out = append(out, s[1:]...)
+	}
+	return append(out, jpegData[2:]...)
+}
+
+// appSegments returns the payloads of the given APPn marker before SOS.
+func appSegments(data []byte, marker byte) [][]byte {
+	var segs [][]byte
+	for i := 2; i+4 <= len(data) && data[i] == 0xFF && data[i+1] != 0xDA; {
+		n := int(data[i+2])<<8 | int(data[i+3])
+		if data[i+1] == marker {
+			segs = append(segs, data[i+4:i+2+n])
+		}
+		i += 2 + n
+	}
+	return segs
+}
+
+func TestRunCarriesMetadata(t *testing.T) {
+	var buf bytes.Buffer
+	if err := stdjpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 16, 16)), nil); err != nil {
+		t.Fatal(err)
+	}
+	exif := []byte("Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08" +
+		"\x00\x02" +
+		"\x01\x12\x00\x03\x00\x00\x00\x01\x00\x06\x00\x00" + // Orientation 6
+		"\x88\x25\x00\x04\x00\x00\x00\x01\x00\x00\x00\x26" + // GPS IFD
+		"\x00\x00\x00\x00" +
+		"\x00\x01\x00\x01\x00\x02\x00\x00\x00\x02N\x00\x00\x00" + // GPSLatitudeRef
+		"\x00\x00\x00\x00")
+	xmp := []byte("http://ns.adobe.com/xap/1.0/\x00<rdf:Description photoshop:ColorMode=\"3\" exif:GPSLatitude=\"52,31.0N\"/>")
+	app13 := []byte("Photoshop 3.0\x008BIM\x04\x04\x00\x00\x00\x00\x00\x02\x1c\x02")
+	input := withSegments(buf.Bytes(),
+		append([]byte{0xE1}, exif...), append([]byte{0xE1}, xmp...), append([]byte{0xED}, app13...))
+	profile, _ := color.BuiltinProfile(color.DefaultCMYKProfile)
+
+	r, err := Run(input, Options{DstProfile: profile, Quality: 80, StripGPS: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app1 := appSegments(r.Data, 0xE1)
+	if len(app1) != 2 {
+		t.Fatalf("got %d APP1 segments, want 2", len(app1))
+	}
+	if bytes.Contains(app1[0], []byte("N\x00")) || bytes.Contains(app1[0], []byte{0x88, 0x25}) {
+		t.Error("GPS kept in EXIF")
+	}
+	if !bytes.Contains(app1[0], []byte{0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x00, 0x06}) {
+		t.Error("orientation not carried")
+	}
+	if x := string(app1[1]); !strings.Contains(x, `photoshop:ColorMode="4"`) || strings.Contains(x, "GPS") {
+		t.Errorf("XMP = %s", x)
+	}
+	if got := appSegments(r.Data, 0xED); len(got) != 1 || !bytes.Equal(got[0], app13) {
+		t.Errorf("APP13 = %q", got)
+	}
+	if info, err := jpeg.GetInfo(r.Data); err != nil || info.ICC == nil {
+		t.Errorf("ICC profile missing after metadata: %v", err)
+	}
+
+	r, err = Run(input, Options{DstProfile: profile, Quality: 80, StripMetadata: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(appSegments(r.Data, 0xE1)) + len(appSegments(r.Data, 0xED)); n != 0 {
+		t.Errorf("%d metadata segments left with StripMetadata", n)
+	}
+}
+
+func TestSourceDensity(t *testing.T) {
+	// IFD0 with XResolution and YResolution of 240/1 in inches.
+	exif := []byte("Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08\x00\x02" +
+		"\x01\x1a\x00\x05\x00\x00\x00\x01\x00\x00\x00\x26" +
+		"\x01\x1b\x00\x05\x00\x00\x00\x01\x00\x00\x00\x26" +
+		"\x00\x00\x00\x00" +
+		"\x00\x00\x00\xf0\x00\x00\x00\x01")
+	jfif := jpeg.Density{X: 300, Y: 300}
+
+	tests := []struct {
+		name string
+		jfif jpeg.Density
+		app1 [][]byte
+		want jpeg.Density
+		from string
+	}{
+		{"none", jpeg.Density{}, nil, jpeg.Density{}, ""},
+		{"jfif", jfif, [][]byte{exif}, jfif, "JFIF"},
+		{"exif", jpeg.Density{}, [][]byte{[]byte("http://ns.adobe.com/xap/1.0/\x00"), exif}, jpeg.Density{X: 240, Y: 240}, "EXIF"},
+	}
+	for _, tt := range tests {
+		got, from := SourceDensity(tt.jfif, tt.app1)
+		if got != tt.want || from != tt.from {
+			t.Errorf("%s: got %v from %q, want %v from %q", tt.name, got, from, tt.want, tt.from)
+		}
+	}
+}
+
+func TestRunWritesProvenance(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 128, 128))
+	for i := range img.Pix {
+		img.Pix[i] = byte(i*7 + i*i/1000)
+	}
+	var buf bytes.Buffer
+	if err := stdjpeg.Encode(&buf, img, &stdjpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	profile, _ := color.BuiltinProfile(color.DefaultCMYKProfile)
+	opts := Options{DstProfile: profile, Quality: 80, CMYReduction: 10, Software: "rgbtocmyk test"}
+
+	r, err := Run(buf.Bytes(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p *meta.Provenance
+	for _, seg := range appSegments(r.Data, 0xE1) {
+		if got, ok := meta.ReadProvenance(meta.XMPPacket(seg)); ok {
+			p = got
+		}
+	}
+	if p == nil {
+		t.Fatal("no conversion record in the output")
+	}
+	if p.Software != "rgbtocmyk test" || p.SourceDigest != digest(buf.Bytes()) || p.Intent != "perceptual" ||
+		p.Quality != 80 || p.CMYReduction != 10 || p.Qualities != [4]int{70, 70, 70, 80} || p.Black != "off" {
+		t.Errorf("record %+v", p)
+	}
+	if p.SourceProfile == "" || p.DestProfile == "" || p.When.IsZero() {
+		t.Errorf("record %+v", p)
+	}
+	// sRGB v4 as source: the engines apply BPC for perceptual.
+	if !p.BPC {
+		t.Error("BPC not recorded")
+	}
+
+	// A size search records the settings it found.
+	opts.TargetSize = len(r.Data) - 2000
+	r, err = Run(buf.Bytes(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, seg := range appSegments(r.Data, 0xE1) {
+		if got, ok := meta.ReadProvenance(meta.XMPPacket(seg)); ok {
+			p = got
+		}
+	}
+	if p.Quality != r.Quality || p.CMYReduction != r.CMYReduction || len(r.Data) > opts.TargetSize {
+		t.Errorf("record quality %d/%d, result %d/%d, %d bytes", p.Quality, p.CMYReduction, r.Quality, r.CMYReduction, len(r.Data))
+	}
+}
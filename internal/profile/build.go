@@ -0,0 +1,164 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// MediaModel is a Model that also knows the absolute colour of its paper.
+type MediaModel interface {
+	Model
+	MediaWhite() XYZ
+}
+
+// Options controls CMYK output profile generation.
+type Options struct {
+	Description string
+	Copyright   string
+	Separation  Separation
+	A2BGrid     int       // grid points per CMYK axis in the A2B tables, default 11
+	B2AGrid     int       // grid points per Lab axis in the B2A tables, default 33
+	Created     time.Time // profile creation date, default now
+}
+
+// BuildCMYK generates an ICC v2 CMYK output profile from a printing model.
+// The profile carries A2B0-2 and B2A0-2 lut16 tables with a Lab PCS. The
+// colorimetric tables are media-relative; the perceptual and saturation
+// tables additionally map the PCS black point onto the darkest printable
+// colour within the separation's TAC.
+func BuildCMYK(model MediaModel, opts Options) ([]byte, error) {
+	if opts.A2BGrid == 0 {
+		opts.A2BGrid = 11
+	}
+	if opts.B2AGrid == 0 {
+		opts.B2AGrid = 33
+	}
+	if opts.A2BGrid < 2 || opts.A2BGrid > 255 || opts.B2AGrid < 2 || opts.B2AGrid > 255 {
+		return nil, fmt.Errorf("grid points must be 2-255 (A2B %d, B2A %d)", opts.A2BGrid, opts.B2AGrid)
+	}
+	if err := opts.Separation.validate(); err != nil {
+		return nil, err
+	}
+	if opts.Created.IsZero() {
+		opts.Created = time.Now()
+	}
+
+	sep := &separator{model: model, sep: opts.Separation}
+	blackL := sep.blackPoint().L
+
+	a2bColorimetric := buildA2B(model, opts.A2BGrid, 0)
+	a2bPerceptual := buildA2B(model, opts.A2BGrid, blackL)
+	b2aColorimetric := buildB2A(sep, opts.B2AGrid, 0)
+	b2aPerceptual := buildB2A(sep, opts.B2AGrid, blackL)
+
+	tags := []tag{
+		{"desc", descTag(opts.Description)},
+		{"cprt", textTag(opts.Copyright)},
+		{"wtpt", xyzTag(model.MediaWhite())},
+		{"A2B0", lut16Tag(4, 3, opts.A2BGrid, a2bPerceptual)},
+		{"A2B1", lut16Tag(4, 3, opts.A2BGrid, a2bColorimetric)},
+		{"A2B2", lut16Tag(4, 3, opts.A2BGrid, a2bPerceptual)},
+		{"B2A0", lut16Tag(3, 4, opts.B2AGrid, b2aPerceptual)},
+		{"B2A1", lut16Tag(3, 4, opts.B2AGrid, b2aColorimetric)},
+		{"B2A2", lut16Tag(3, 4, opts.B2AGrid, b2aPerceptual)},
+	}
+	return assemble(header{class: "prtr", colorSpace: "CMYK", pcs: "Lab ", created: opts.Created}, tags), nil
+}
+
+func (s Separation) validate() error {
+	switch {
+	case s.TAC <= 0 || s.TAC > 400:
+		return fmt.Errorf("TAC %.0f%% out of range (1-400)", s.TAC)
+	case s.MaxK < 0 || s.MaxK > 100:
+		return fmt.Errorf("max black %.0f%% out of range (0-100)", s.MaxK)
+	case s.BlackStart < 0 || s.BlackStart >= 100:
+		return fmt.Errorf("black start %.0f%% out of range (0-99)", s.BlackStart)
+	case s.GCR < 0 || s.GCR > 100:
+		return fmt.Errorf("GCR %.0f%% out of range (0-100)", s.GCR)
+	case s.MaxK > s.TAC:
+		return errors.New("max black exceeds TAC")
+	}
+	return nil
+}
+
+// blackPoint returns the darkest colour printable within the TAC limit.
+func (s *separator) blackPoint() Lab {
+	k := s.sep.MaxK / 100
+	cmy := math.Min(1, (s.sep.TAC/100-k)/3)
+	return s.model.Lab(cmy, cmy, cmy, k)
+}
+
+// buildA2B samples the model on a CMYK grid. With blackL > 0 the lightness
+// is rescaled so the printable black maps to L*=0.
+func buildA2B(model Model, grid int, blackL float64) []uint16 {
+	out := make([]uint16, 0, grid*grid*grid*grid*3)
+	step := 1 / float64(grid-1)
+	for c := 0; c < grid; c++ {
+		for m := 0; m < grid; m++ {
+			for y := 0; y < grid; y++ {
+				for k := 0; k < grid; k++ {
+					lab := model.Lab(float64(c)*step, float64(m)*step, float64(y)*step, float64(k)*step)
+					if blackL > 0 {
+						lab.L = math.Max(0, (lab.L-blackL)*100/(100-blackL))
+					}
+					v := encodeLab16(lab)
+					out = append(out, v[0], v[1], v[2])
+				}
+			}
+		}
+	}
+	return out
+}
+
+// buildB2A separates every point of a Lab grid. With blackL > 0 the PCS
+// lightness range is compressed onto [blackL, 100] before separation.
+// Lightness planes are processed in parallel.
+func buildB2A(sep *separator, grid int, blackL float64) []uint16 {
+	out := make([]uint16, grid*grid*grid*4)
+	planes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for l := range planes {
+				separatePlane(sep, grid, blackL, l, out)
+			}
+		}()
+	}
+	for l := 0; l < grid; l++ {
+		planes <- l
+	}
+	close(planes)
+	wg.Wait()
+	return out
+}
+
+func separatePlane(sep *separator, grid int, blackL float64, l int, out []uint16) {
+	enc := func(i int) uint16 {
+		return uint16(math.Round(float64(i) * 65535 / float64(grid-1)))
+	}
+	var rowStart [3]float64
+	for a := 0; a < grid; a++ {
+		start := rowStart
+		for b := 0; b < grid; b++ {
+			lab := decodeLab16([3]uint16{enc(l), enc(a), enc(b)})
+			if blackL > 0 {
+				lab.L = blackL + lab.L*(100-blackL)/100
+			}
+			cmyk := sep.separate(lab, start)
+			start = [3]float64{cmyk[0], cmyk[1], cmyk[2]}
+			if b == 0 {
+				rowStart = start
+			}
+			idx := ((l*grid+a)*grid + b) * 4
+			for i, v := range cmyk {
+				out[idx+i] = uint16(math.Round(clamp01(v) * 65535))
+			}
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package profile
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func coatedModel() (*InkModel, Separation) {
+	p := Presets["generic-coated"]
+	return NewInkModel(p.Ink), p.Separation
+}
+
+func TestInkModelPaperWhite(t *testing.T) {
+	m, _ := coatedModel()
+	lab := m.Lab(0, 0, 0, 0)
+	if DeltaE76(lab, Lab{L: 100}) > 0.01 {
+		t.Errorf("paper = %+v, expected media-relative white", lab)
+	}
+	if k := m.Lab(0, 0, 0, 1); k.L > 25 {
+		t.Errorf("solid K L*=%.1f, expected dark", k.L)
+	}
+}
+
+func TestSeparateRoundTrip(t *testing.T) {
+	m, sep := coatedModel()
+	s := &separator{model: m, sep: sep}
+
+	for _, c := range [][4]float64{
+		{0.2, 0.1, 0.1, 0},
+		{0.6, 0, 0.8, 0},
+		{0, 0.7, 0.5, 0.1},
+		{0.4, 0.3, 0.3, 0.3},
+	} {
+		target := m.Lab(c[0], c[1], c[2], c[3])
+		got := s.separate(target, [3]float64{})
+		if tac := (got[0] + got[1] + got[2] + got[3]) * 100; tac > sep.TAC+0.01 {
+			t.Errorf("%v: TAC %.1f%% exceeds %.0f%%", c, tac, sep.TAC)
+		}
+		if de := DeltaE76(m.Lab(got[0], got[1], got[2], got[3]), target); de > 1 {
+			t.Errorf("%v → %+v → %.3v: ΔE %.2f", c, target, got, de)
+		}
+	}
+}
+
+// A separation started from a solid must still find bare paper for white;
+// a dot gain curve that flattens near 100% traps the solver there.
+func TestSeparatePaperFromSolidStart(t *testing.T) {
+	for _, name := range PresetNames() {
+		p := Presets[name]
+		s := &separator{model: NewInkModel(p.Ink), sep: p.Separation}
+		got := s.separate(Lab{L: 100}, [3]float64{1, 0, 0})
+		for _, v := range got {
+			if v > 0.01 {
+				t.Errorf("%s: paper white separated to %.3f", name, got)
+				break
+			}
+		}
+	}
+}
+
+func TestSeparateTACLimit(t *testing.T) {
+	m, sep := coatedModel()
+	sep.TAC = 260
+	s := &separator{model: m, sep: sep}
+	got := s.separate(Lab{L: 5}, [3]float64{})
+	if tac := (got[0] + got[1] + got[2] + got[3]) * 100; tac > 260.01 {
+		t.Errorf("TAC %.1f%% exceeds 260%%", tac)
+	}
+}
+
+func TestBuildCMYKStructure(t *testing.T) {
+	m, sep := coatedModel()
+	data, err := BuildCMYK(m, Options{
+		Description: "test",
+		Separation:  sep,
+		A2BGrid:     3,
+		B2AGrid:     5,
+		Created:     time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("BuildCMYK: %v", err)
+	}
+
+	be := binary.BigEndian
+	if size := be.Uint32(data[0:4]); int(size) != len(data) {
+		t.Errorf("header size %d, data length %d", size, len(data))
+	}
+	if string(data[36:40]) != "acsp" || string(data[12:16]) != "prtr" ||
+		string(data[16:20]) != "CMYK" || string(data[20:24]) != "Lab " {
+		t.Errorf("unexpected header: %q", data[12:40])
+	}
+
+	count := int(be.Uint32(data[128:132]))
+	if count != 9 {
+		t.Fatalf("expected 9 tags, got %d", count)
+	}
+	offsets := map[string]uint32{}
+	for i := 0; i < count; i++ {
+		e := data[132+12*i:]
+		off, size := be.Uint32(e[4:8]), be.Uint32(e[8:12])
+		if int(off+size) > len(data) || off%4 != 0 {
+			t.Errorf("tag %q at %d+%d out of bounds or misaligned", e[0:4], off, size)
+		}
+		offsets[string(e[0:4])] = off
+	}
+
+	// The saturation tables repeat the perceptual ones and share their data.
+	if offsets["A2B2"] != offsets["A2B0"] || offsets["B2A2"] != offsets["B2A0"] {
+		t.Errorf("saturation tables not shared: %v", offsets)
+	}
+	if offsets["A2B1"] == offsets["A2B0"] || offsets["B2A1"] == offsets["B2A0"] {
+		t.Errorf("colorimetric tables share perceptual data: %v", offsets)
+	}
+}
+
+func TestBuildCMYKRejectsBadSeparation(t *testing.T) {
+	m, sep := coatedModel()
+	sep.TAC = 450
+	if _, err := BuildCMYK(m, Options{Separation: sep}); err == nil {
+		t.Error("expected error for TAC > 400%")
+	}
+}
@@ -0,0 +1,55 @@
+// Command gen writes the built-in generic CMYK and RGB source profiles
+// embedded by the color package. Run it through go generate in internal/color.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/davesmith10/RGBtoCMYK/internal/profile"
+)
+
+func main() {
+	dir := flag.String("dir", ".", "output directory")
+	flag.Parse()
+
+	// A fixed creation date keeps the generated files reproducible.
+	created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, name := range profile.PresetNames() {
+		p := profile.Presets[name]
+		data, err := profile.BuildCMYK(profile.NewInkModel(p.Ink), profile.Options{
+			Description: p.Description,
+			Copyright:   "No copyright, use freely",
+			Separation:  p.Separation,
+			// Coarser grids than BuildCMYK's defaults keep each profile
+			// small enough to embed in every converted file.
+			A2BGrid: 9,
+			B2AGrid: 17,
+			Created: created,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			os.Exit(1)
+		}
+		path := filepath.Join(*dir, name+".icc")
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s: %d bytes\n", path, len(data))
+	}
+
+	for name, space := range profile.RGBSpaces {
+		data := profile.BuildMatrixRGB(space, "No copyright, use freely", created)
+		path := filepath.Join(*dir, name+".icc")
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s: %d bytes\n", path, len(data))
+	}
+}
@@ -0,0 +1,179 @@
+package profile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// header holds the ICC header fields the writer needs to vary.
+type header struct {
+	class      string // "prtr", "mntr", ...
+	colorSpace string // "CMYK", "RGB "
+	pcs        string // "Lab ", "XYZ "
+	created    time.Time
+}
+
+// tag is one entry of the ICC tag table.
+type tag struct {
+	sig  string
+	data []byte
+}
+
+// assemble serialises an ICC v2.1 profile. Tags with identical data share a
+// single copy, as permitted by the ICC specification.
+func assemble(h header, tags []tag) []byte {
+	tableLen := 4 + 12*len(tags)
+	offset := 128 + tableLen
+	offset = (offset + 3) &^ 3
+
+	var body bytes.Buffer
+	type placed struct {
+		off, size int
+	}
+	var seen []struct {
+		data []byte
+		p    placed
+	}
+	entries := make([]placed, len(tags))
+	for i, t := range tags {
+		found := false
+		for _, s := range seen {
+			if bytes.Equal(s.data, t.data) {
+				entries[i] = s.p
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+		p := placed{off: offset + body.Len(), size: len(t.data)}
+		body.Write(t.data)
+		for body.Len()%4 != 0 {
+			body.WriteByte(0)
+		}
+		entries[i] = p
+		seen = append(seen, struct {
+			data []byte
+			p    placed
+		}{t.data, p})
+	}
+
+	total := offset + body.Len()
+	out := make([]byte, offset, total)
+	be := binary.BigEndian
+
+	be.PutUint32(out[0:], uint32(total))
+	be.PutUint32(out[8:], 0x02100000)
+	copy(out[12:16], h.class)
+	copy(out[16:20], h.colorSpace)
+	copy(out[20:24], h.pcs)
+	ts := h.created.UTC()
+	for i, v := range []int{ts.Year(), int(ts.Month()), ts.Day(), ts.Hour(), ts.Minute(), ts.Second()} {
+		be.PutUint16(out[24+2*i:], uint16(v))
+	}
+	copy(out[36:40], "acsp")
+	putXYZ(out[68:80], D50)
+
+	be.PutUint32(out[128:], uint32(len(tags)))
+	for i, t := range tags {
+		e := out[132+12*i:]
+		copy(e[0:4], t.sig)
+		be.PutUint32(e[4:], uint32(entries[i].off))
+		be.PutUint32(e[8:], uint32(entries[i].size))
+	}
+
+	return append(out, body.Bytes()...)
+}
+
+func s15Fixed16(v float64) uint32 {
+	return uint32(int32(math.Round(v * 65536)))
+}
+
+func putXYZ(b []byte, c XYZ) {
+	binary.BigEndian.PutUint32(b[0:], s15Fixed16(c.X))
+	binary.BigEndian.PutUint32(b[4:], s15Fixed16(c.Y))
+	binary.BigEndian.PutUint32(b[8:], s15Fixed16(c.Z))
+}
+
+// xyzTag encodes an XYZType tag.
+func xyzTag(c XYZ) []byte {
+	b := make([]byte, 20)
+	copy(b, "XYZ ")
+	putXYZ(b[8:], c)
+	return b
+}
+
+// textTag encodes a textType tag (used for copyright).
+func textTag(s string) []byte {
+	b := make([]byte, 8, 8+len(s)+1)
+	copy(b, "text")
+	b = append(b, s...)
+	return append(b, 0)
+}
+
+// descTag encodes a v2 textDescriptionType tag with ASCII content only.
+func descTag(s string) []byte {
+	var b bytes.Buffer
+	b.WriteString("desc")
+	b.Write(make([]byte, 4))
+	binary.Write(&b, binary.BigEndian, uint32(len(s)+1))
+	b.WriteString(s)
+	b.WriteByte(0)
+	b.Write(make([]byte, 4+4+2+1+67)) // empty Unicode and ScriptCode records
+	return b.Bytes()
+}
+
+// lut16Tag encodes an mft2 (lut16Type) tag with identity matrix and linear
+// input/output curves. clut holds gridPoints^inChan entries of outChan
+// values, first input channel varying slowest.
+func lut16Tag(inChan, outChan, gridPoints int, clut []uint16) []byte {
+	var b bytes.Buffer
+	be := binary.BigEndian
+	b.WriteString("mft2")
+	b.Write(make([]byte, 4))
+	b.Write([]byte{byte(inChan), byte(outChan), byte(gridPoints), 0})
+	for i := 0; i < 9; i++ {
+		v := 0.0
+		if i%4 == 0 {
+			v = 1
+		}
+		binary.Write(&b, be, s15Fixed16(v))
+	}
+	binary.Write(&b, be, uint16(2))
+	binary.Write(&b, be, uint16(2))
+	for i := 0; i < inChan; i++ {
+		binary.Write(&b, be, []uint16{0, 0xFFFF})
+	}
+	binary.Write(&b, be, clut)
+	for i := 0; i < outChan; i++ {
+		binary.Write(&b, be, []uint16{0, 0xFFFF})
+	}
+	return b.Bytes()
+}
+
+// encodeLab16 converts Lab to the ICC v2 16-bit Lab encoding used by lut16.
+func encodeLab16(c Lab) [3]uint16 {
+	enc := func(v, scale, offset float64) uint16 {
+		x := math.Round((v + offset) * scale)
+		if x < 0 {
+			return 0
+		}
+		if x > 0xFFFF {
+			return 0xFFFF
+		}
+		return uint16(x)
+	}
+	return [3]uint16{enc(c.L, 652.80, 0), enc(c.A, 256, 128), enc(c.B, 256, 128)}
+}
+
+// decodeLab16 is the inverse of encodeLab16.
+func decodeLab16(v [3]uint16) Lab {
+	return Lab{
+		L: float64(v[0]) / 652.80,
+		A: float64(v[1])/256 - 128,
+		B: float64(v[2])/256 - 128,
+	}
+}
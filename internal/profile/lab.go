@@ -0,0 +1,57 @@
+package profile
+
+import "math"
+
+// XYZ is a CIE XYZ triple normalised so that Y = 1 for the reference white.
+type XYZ struct {
+	X, Y, Z float64
+}
+
+// Lab is a CIELAB triple.
+type Lab struct {
+	L, A, B float64
+}
+
+// D50 is the ICC profile connection space illuminant.
+var D50 = XYZ{X: 0.9642, Y: 1.0, Z: 0.8249}
+
+const (
+	labEpsilon = 216.0 / 24389.0
+	labKappa   = 24389.0 / 27.0
+)
+
+func labF(t float64) float64 {
+	if t > labEpsilon {
+		return math.Cbrt(t)
+	}
+	return (labKappa*t + 16) / 116
+}
+
+func labFInv(f float64) float64 {
+	if t := f * f * f; t > labEpsilon {
+		return t
+	}
+	return (116*f - 16) / labKappa
+}
+
+// ToLab converts XYZ to CIELAB relative to the white point w.
+func (c XYZ) ToLab(w XYZ) Lab {
+	fx := labF(c.X / w.X)
+	fy := labF(c.Y / w.Y)
+	fz := labF(c.Z / w.Z)
+	return Lab{L: 116*fy - 16, A: 500 * (fx - fy), B: 200 * (fy - fz)}
+}
+
+// ToXYZ converts CIELAB to XYZ relative to the white point w.
+func (c Lab) ToXYZ(w XYZ) XYZ {
+	fy := (c.L + 16) / 116
+	fx := fy + c.A/500
+	fz := fy - c.B/200
+	return XYZ{X: w.X * labFInv(fx), Y: w.Y * labFInv(fy), Z: w.Z * labFInv(fz)}
+}
+
+// DeltaE76 returns the CIE 1976 colour difference between two Lab values.
+func DeltaE76(p, q Lab) float64 {
+	dl, da, db := p.L-q.L, p.A-q.A, p.B-q.B
+	return math.Sqrt(dl*dl + da*da + db*db)
+}
@@ -0,0 +1,391 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Sample is one measured patch of a characterisation chart.
+type Sample struct {
+	CMYK [4]float64 // nominal coverage in percent
+	Lab  Lab        // absolute measurement, D50
+}
+
+// SamplesFromTable extracts samples from the data section of a CGATS/IT8
+// file. It needs CMYK_C, CMYK_M, CMYK_Y and CMYK_K columns plus either
+// LAB_L/LAB_A/LAB_B or XYZ_X/XYZ_Y/XYZ_Z (0-100 scale, D50).
+func SamplesFromTable(fields []string, rows [][]string) ([]Sample, error) {
+	col := make(map[string]int, len(fields))
+	for i, f := range fields {
+		col[strings.ToUpper(strings.TrimSpace(f))] = i
+	}
+	find := func(names ...string) ([]int, bool) {
+		idx := make([]int, len(names))
+		for i, n := range names {
+			c, ok := col[n]
+			if !ok {
+				return nil, false
+			}
+			idx[i] = c
+		}
+		return idx, true
+	}
+
+	cmykCols, ok := find("CMYK_C", "CMYK_M", "CMYK_Y", "CMYK_K")
+	if !ok {
+		return nil, errors.New("measurement data has no CMYK_C/CMYK_M/CMYK_Y/CMYK_K columns")
+	}
+	labCols, isLab := find("LAB_L", "LAB_A", "LAB_B")
+	xyzCols, isXYZ := find("XYZ_X", "XYZ_Y", "XYZ_Z")
+	if !isLab && !isXYZ {
+		return nil, errors.New("measurement data has neither LAB_L/LAB_A/LAB_B nor XYZ_X/XYZ_Y/XYZ_Z columns")
+	}
+
+	samples := make([]Sample, 0, len(rows))
+	for r, row := range rows {
+		num := func(c int) (float64, error) {
+			if c >= len(row) {
+				return 0, fmt.Errorf("row %d: missing column %s", r+1, fields[c])
+			}
+			v, err := strconv.ParseFloat(strings.TrimSpace(row[c]), 64)
+			if err != nil {
+				return 0, fmt.Errorf("row %d, %s: %w", r+1, fields[c], err)
+			}
+			return v, nil
+		}
+		var s Sample
+		for i, c := range cmykCols {
+			v, err := num(c)
+			if err != nil {
+				return nil, err
+			}
+			s.CMYK[i] = v
+		}
+		var v [3]float64
+		cols := labCols
+		if !isLab {
+			cols = xyzCols
+		}
+		for i, c := range cols {
+			x, err := num(c)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = x
+		}
+		if isLab {
+			s.Lab = Lab{L: v[0], A: v[1], B: v[2]}
+		} else {
+			s.Lab = XYZ{X: v[0] / 100, Y: v[1] / 100, Z: v[2] / 100}.ToLab(D50)
+		}
+		samples = append(samples, s)
+	}
+	return samples, nil
+}
+
+// MeasuredModel is a printing model fitted to characterisation data. A
+// Neugebauer model with measured primaries and per-ink tone curves is
+// fitted first; its remaining error at the patches is then spread over a
+// CMYK grid by Gaussian-weighted interpolation, and the model is evaluated
+// from that grid.
+type MeasuredModel struct {
+	white   XYZ
+	grid    int
+	table   []Lab
+	meanErr float64
+	maxErr  float64
+}
+
+// measuredGrid is the number of points per CMYK axis of the fitted model.
+const measuredGrid = 17
+
+// correctionSigma is the CMYK distance (as a coverage fraction) over which
+// a patch's residual error influences the model.
+const correctionSigma = 0.1
+
+// FitMeasured fits a MeasuredModel to samples. The samples must include
+// the paper (0/0/0/0) and the four solids.
+func FitMeasured(samples []Sample) (*MeasuredModel, error) {
+	near := func(v, want float64) bool { return math.Abs(v-want) < 0.5 }
+	isCombo := func(s Sample, set int) bool {
+		for i := 0; i < 4; i++ {
+			want := 0.0
+			if set&(1<<i) != 0 {
+				want = 100
+			}
+			if !near(s.CMYK[i], want) {
+				return false
+			}
+		}
+		return true
+	}
+	average := func(set int) (Lab, bool) {
+		var sum Lab
+		n := 0
+		for _, s := range samples {
+			if isCombo(s, set) {
+				sum.L += s.Lab.L
+				sum.A += s.Lab.A
+				sum.B += s.Lab.B
+				n++
+			}
+		}
+		if n == 0 {
+			return Lab{}, false
+		}
+		return Lab{L: sum.L / float64(n), A: sum.A / float64(n), B: sum.B / float64(n)}, true
+	}
+
+	paperLab, ok := average(0)
+	if !ok {
+		return nil, errors.New("measurement data has no paper white (0/0/0/0) patch")
+	}
+	white := paperLab.ToXYZ(D50)
+
+	var solids [4]XYZ
+	for i, name := range []string{"cyan", "magenta", "yellow", "black"} {
+		lab, ok := average(1 << i)
+		if !ok {
+			return nil, fmt.Errorf("measurement data has no 100%% %s patch", name)
+		}
+		solids[i] = relativeXYZ(lab, white)
+	}
+	prims := derivePrimaries(solids, 0)
+	for set := 3; set < 16; set++ {
+		if lab, ok := average(set); ok {
+			prims[set] = relativeXYZ(lab, white)
+		}
+	}
+
+	rel := make([]Lab, len(samples))
+	cov := make([][4]float64, len(samples))
+	for i, s := range samples {
+		rel[i] = relativeXYZ(s.Lab, white).ToLab(D50)
+		for c := 0; c < 4; c++ {
+			cov[i][c] = clamp01(s.CMYK[c] / 100)
+		}
+	}
+
+	// Pick the Yule–Nielsen factor that, with fitted tone curves, explains
+	// the data best.
+	var best struct {
+		ng     neugebauer
+		curves toneCurves
+		err    float64
+	}
+	best.err = math.Inf(1)
+	for n := 1.0; n <= 3.0; n += 0.25 {
+		ng := newNeugebauer(prims, n)
+		var curves toneCurves
+		for c := 0; c < 4; c++ {
+			curves[c] = fitToneCurve(&ng, c, cov, rel)
+		}
+		sum := 0.0
+		for i := range samples {
+			sum += DeltaE76(ng.lab(curves.apply(cov[i])), rel[i])
+		}
+		if mean := sum / float64(len(samples)); mean < best.err {
+			best.ng, best.curves, best.err = ng, curves, mean
+		}
+	}
+
+	residuals := make([]Lab, len(samples))
+	for i := range samples {
+		p := best.ng.lab(best.curves.apply(cov[i]))
+		residuals[i] = Lab{L: rel[i].L - p.L, A: rel[i].A - p.A, B: rel[i].B - p.B}
+	}
+
+	m := &MeasuredModel{white: white, grid: measuredGrid}
+	m.table = make([]Lab, 0, measuredGrid*measuredGrid*measuredGrid*measuredGrid)
+	step := 1 / float64(measuredGrid-1)
+	cutoff := 9 * correctionSigma * correctionSigma
+	for c := 0; c < measuredGrid; c++ {
+		for mg := 0; mg < measuredGrid; mg++ {
+			for y := 0; y < measuredGrid; y++ {
+				for k := 0; k < measuredGrid; k++ {
+					q := [4]float64{float64(c) * step, float64(mg) * step, float64(y) * step, float64(k) * step}
+					lab := best.ng.lab(best.curves.apply(q))
+					var corr Lab
+					wsum := 0.0
+					for i, p := range cov {
+						d2 := 0.0
+						for j := 0; j < 4; j++ {
+							d := p[j] - q[j]
+							d2 += d * d
+						}
+						if d2 > cutoff {
+							continue
+						}
+						w := math.Exp(-d2 / (2 * correctionSigma * correctionSigma))
+						corr.L += w * residuals[i].L
+						corr.A += w * residuals[i].A
+						corr.B += w * residuals[i].B
+						wsum += w
+					}
+					// Far from any patch the correction fades out.
+					norm := math.Max(wsum, 1)
+					lab.L += corr.L / norm
+					lab.A += corr.A / norm
+					lab.B += corr.B / norm
+					m.table = append(m.table, lab)
+				}
+			}
+		}
+	}
+
+	sum := 0.0
+	for i := range samples {
+		de := DeltaE76(m.Lab(cov[i][0], cov[i][1], cov[i][2], cov[i][3]), rel[i])
+		sum += de
+		m.maxErr = math.Max(m.maxErr, de)
+	}
+	m.meanErr = sum / float64(len(samples))
+	return m, nil
+}
+
+// MediaWhite implements MediaModel.
+func (m *MeasuredModel) MediaWhite() XYZ {
+	return m.white
+}
+
+// FitError returns the mean and maximum ΔE76 between the model and the
+// measurements it was fitted to.
+func (m *MeasuredModel) FitError() (mean, max float64) {
+	return m.meanErr, m.maxErr
+}
+
+// Lab implements Model by quadrilinear interpolation of the fitted grid.
+func (m *MeasuredModel) Lab(c, mg, y, k float64) Lab {
+	g := m.grid
+	var base [4]int
+	var frac [4]float64
+	for i, v := range [4]float64{c, mg, y, k} {
+		p := clamp01(v) * float64(g-1)
+		b := int(p)
+		if b >= g-1 {
+			b = g - 2
+		}
+		base[i] = b
+		frac[i] = p - float64(b)
+	}
+	var out Lab
+	for corner := 0; corner < 16; corner++ {
+		w := 1.0
+		idx := 0
+		for i := 0; i < 4; i++ {
+			o := 0
+			if corner&(8>>i) != 0 {
+				o = 1
+				w *= frac[i]
+			} else {
+				w *= 1 - frac[i]
+			}
+			idx = idx*g + base[i] + o
+		}
+		if w == 0 {
+			continue
+		}
+		v := m.table[idx]
+		out.L += w * v.L
+		out.A += w * v.A
+		out.B += w * v.B
+	}
+	return out
+}
+
+// toneCurve maps nominal coverage to effective coverage by linear
+// interpolation between fitted points.
+type toneCurve struct {
+	in, out []float64
+}
+
+func (t toneCurve) eval(v float64) float64 {
+	v = clamp01(v)
+	if len(t.in) < 2 {
+		return v
+	}
+	for i := 1; i < len(t.in); i++ {
+		if v <= t.in[i] {
+			f := (v - t.in[i-1]) / (t.in[i] - t.in[i-1])
+			return t.out[i-1] + f*(t.out[i]-t.out[i-1])
+		}
+	}
+	return t.out[len(t.out)-1]
+}
+
+type toneCurves [4]toneCurve
+
+func (t *toneCurves) apply(v [4]float64) [4]float64 {
+	return [4]float64{t[0].eval(v[0]), t[1].eval(v[1]), t[2].eval(v[2]), t[3].eval(v[3])}
+}
+
+// fitToneCurve finds, for each single-ink patch of channel c, the effective
+// coverage that best matches the measurement under ng. Without single-ink
+// patches the curve is the identity.
+func fitToneCurve(ng *neugebauer, c int, cov [][4]float64, rel []Lab) toneCurve {
+	type point struct{ in, out float64 }
+	pts := []point{{0, 0}, {1, 1}}
+	for i, v := range cov {
+		single := v[c] > 0.005 && v[c] < 0.995
+		for j := 0; j < 4 && single; j++ {
+			if j != c && v[j] > 0.005 {
+				single = false
+			}
+		}
+		if !single {
+			continue
+		}
+		target := rel[i]
+		f := func(a float64) float64 {
+			var x [4]float64
+			x[c] = a
+			return DeltaE76(ng.lab(x), target)
+		}
+		pts = append(pts, point{v[c], goldenMin(f, 0, 1)})
+	}
+
+	// Sort by nominal coverage, merge duplicates, and force monotonicity.
+	for i := 1; i < len(pts); i++ {
+		for j := i; j > 0 && pts[j].in < pts[j-1].in; j-- {
+			pts[j], pts[j-1] = pts[j-1], pts[j]
+		}
+	}
+	var t toneCurve
+	for i := 0; i < len(pts); {
+		j, sum := i, 0.0
+		for ; j < len(pts) && pts[j].in-pts[i].in < 1e-6; j++ {
+			sum += pts[j].out
+		}
+		out := sum / float64(j-i)
+		if n := len(t.out); n > 0 && out < t.out[n-1] {
+			out = t.out[n-1]
+		}
+		t.in = append(t.in, pts[i].in)
+		t.out = append(t.out, out)
+		i = j
+	}
+	return t
+}
+
+// goldenMin minimises a unimodal f over [lo, hi].
+func goldenMin(f func(float64) float64, lo, hi float64) float64 {
+	const phi = 0.6180339887498949
+	a, b := lo, hi
+	x1, x2 := b-phi*(b-a), a+phi*(b-a)
+	f1, f2 := f(x1), f(x2)
+	for b-a > 1e-4 {
+		if f1 < f2 {
+			b, x2, f2 = x2, x1, f1
+			x1 = b - phi*(b-a)
+			f1 = f(x1)
+		} else {
+			a, x1, f1 = x1, x2, f2
+			x2 = a + phi*(b-a)
+			f2 = f(x2)
+		}
+	}
+	return (a + b) / 2
+}
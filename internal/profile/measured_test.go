@@ -0,0 +1,83 @@
+package profile
+
+import (
+	"fmt"
+	"testing"
+)
+
+// syntheticChart "prints" a chart with single-ink ramps and a coarse
+// four-ink grid through m, returning the patches as samples.
+func syntheticChart(m *InkModel) []Sample {
+	var samples []Sample
+	add := func(c, mg, y, k float64) {
+		lab := m.Lab(c/100, mg/100, y/100, k/100).ToXYZ(D50)
+		w := m.MediaWhite()
+		abs := XYZ{X: lab.X / D50.X * w.X, Y: lab.Y / D50.Y * w.Y, Z: lab.Z / D50.Z * w.Z}.ToLab(D50)
+		samples = append(samples, Sample{CMYK: [4]float64{c, mg, y, k}, Lab: abs})
+	}
+	for ch := 0; ch < 4; ch++ {
+		for v := 10.0; v < 100; v += 10 {
+			var x [4]float64
+			x[ch] = v
+			add(x[0], x[1], x[2], x[3])
+		}
+	}
+	levels := []float64{0, 25, 50, 75, 100}
+	for _, c := range levels {
+		for _, mg := range levels {
+			for _, y := range levels {
+				for _, k := range []float64{0, 50, 100} {
+					add(c, mg, y, k)
+				}
+			}
+		}
+	}
+	return samples
+}
+
+func TestFitMeasuredSynthetic(t *testing.T) {
+	truth := NewInkModel(Presets["generic-uncoated"].Ink)
+	samples := syntheticChart(truth)
+
+	m, err := FitMeasured(samples)
+	if err != nil {
+		t.Fatalf("FitMeasured: %v", err)
+	}
+	mean, max := m.FitError()
+	t.Logf("fit error: mean ΔE %.2f, max ΔE %.2f over %d patches", mean, max, len(samples))
+	if mean > 1 || max > 4 {
+		t.Errorf("fit too loose: mean %.2f, max %.2f", mean, max)
+	}
+
+	// Between patches the fitted model should still track the truth.
+	for _, c := range [][4]float64{{0.35, 0.15, 0.6, 0.2}, {0.6, 0.6, 0.1, 0.05}} {
+		got := m.Lab(c[0], c[1], c[2], c[3])
+		want := truth.Lab(c[0], c[1], c[2], c[3])
+		if de := DeltaE76(got, want); de > 3 {
+			t.Errorf("%v: ΔE %.2f between fitted and true model", c, de)
+		}
+	}
+}
+
+func TestFitMeasuredNeedsPaperAndSolids(t *testing.T) {
+	samples := []Sample{{CMYK: [4]float64{100, 0, 0, 0}, Lab: Lab{L: 55, A: -37, B: -50}}}
+	if _, err := FitMeasured(samples); err == nil {
+		t.Error("expected error without a paper patch")
+	}
+}
+
+func TestSamplesFromTableXYZ(t *testing.T) {
+	fields := []string{"SAMPLE_ID", "CMYK_C", "CMYK_M", "CMYK_Y", "CMYK_K", "XYZ_X", "XYZ_Y", "XYZ_Z"}
+	rows := [][]string{{"1", "0", "0", "0", "0", fmt.Sprint(D50.X * 100), "100", fmt.Sprint(D50.Z * 100)}}
+	samples, err := SamplesFromTable(fields, rows)
+	if err != nil {
+		t.Fatalf("SamplesFromTable: %v", err)
+	}
+	if len(samples) != 1 || DeltaE76(samples[0].Lab, Lab{L: 100}) > 0.01 {
+		t.Errorf("unexpected samples: %+v", samples)
+	}
+
+	if _, err := SamplesFromTable([]string{"CMYK_C", "LAB_L"}, nil); err == nil {
+		t.Error("expected error for missing columns")
+	}
+}
@@ -0,0 +1,154 @@
+package profile
+
+import "math"
+
+// Model predicts the colour printed for an ink combination. Coverages are
+// fractions (0-1) and the result is media-relative CIELAB under D50, i.e.
+// bare paper maps to L*=100, a*=b*=0.
+type Model interface {
+	Lab(c, m, y, k float64) Lab
+}
+
+// InkParams describes a printing condition for the parametric ink model.
+type InkParams struct {
+	Paper  Lab        // absolute Lab of the unprinted stock
+	Solids [4]Lab     // absolute Lab of 100% C, M, Y and K printed on the stock
+	TVI    [4]float64 // tone value increase at 50% per ink, as a fraction (0.14 = 14%)
+	N      float64    // Yule–Nielsen factor (1 = Murray–Davies)
+	MinL   float64    // darkest achievable L* for any overprint (ink trapping limit)
+}
+
+// neugebauer is a Yule–Nielsen modified Neugebauer model evaluated on
+// effective (post dot gain) coverages.
+type neugebauer struct {
+	primaries [16]XYZ // media-relative primaries raised to 1/n
+	n         float64
+}
+
+func newNeugebauer(prims [16]XYZ, n float64) neugebauer {
+	ng := neugebauer{n: n}
+	inv := 1 / n
+	for s, p := range prims {
+		ng.primaries[s] = XYZ{X: math.Pow(p.X, inv), Y: math.Pow(p.Y, inv), Z: math.Pow(p.Z, inv)}
+	}
+	return ng
+}
+
+// lab evaluates the Demichel-weighted primaries for coverages a.
+func (ng *neugebauer) lab(a [4]float64) Lab {
+	var sum XYZ
+	for s := 0; s < 16; s++ {
+		w := 1.0
+		for i := 0; i < 4; i++ {
+			if s&(1<<i) != 0 {
+				w *= a[i]
+			} else {
+				w *= 1 - a[i]
+			}
+		}
+		if w == 0 {
+			continue
+		}
+		p := ng.primaries[s]
+		sum.X += w * p.X
+		sum.Y += w * p.Y
+		sum.Z += w * p.Z
+	}
+	return XYZ{X: math.Pow(sum.X, ng.n), Y: math.Pow(sum.Y, ng.n), Z: math.Pow(sum.Z, ng.n)}.ToLab(D50)
+}
+
+// derivePrimaries builds the sixteen media-relative Neugebauer primaries by
+// treating each solid as a filter over the paper. Overprints are clamped to
+// minY, the trapping limit.
+func derivePrimaries(solids [4]XYZ, minY float64) [16]XYZ {
+	var prims [16]XYZ
+	for s := 0; s < 16; s++ {
+		prim := D50
+		for i := 0; i < 4; i++ {
+			if s&(1<<i) != 0 {
+				prim.X *= solids[i].X / D50.X
+				prim.Y *= solids[i].Y / D50.Y
+				prim.Z *= solids[i].Z / D50.Z
+			}
+		}
+		// Heavy overprints cannot get darker than the trapping limit, and
+		// in practice they print close to neutral.
+		if prim.Y < minY {
+			prim = XYZ{X: D50.X * minY, Y: minY, Z: D50.Z * minY}
+		}
+		prims[s] = prim
+	}
+	return prims
+}
+
+// relativeXYZ converts an absolute Lab measurement to media-relative XYZ.
+func relativeXYZ(c Lab, paper XYZ) XYZ {
+	x := c.ToXYZ(D50)
+	return XYZ{X: x.X / paper.X * D50.X, Y: x.Y / paper.Y * D50.Y, Z: x.Z / paper.Z * D50.Z}
+}
+
+// InkModel is a Yule–Nielsen modified Neugebauer model whose primaries are
+// derived from the single-ink solids, so only paper, four solids and dot
+// gain need to be known.
+type InkModel struct {
+	params InkParams
+	white  XYZ // absolute paper XYZ
+	ng     neugebauer
+}
+
+// NewInkModel prepares the Neugebauer primaries for p.
+func NewInkModel(p InkParams) *InkModel {
+	if p.N <= 0 {
+		p.N = 1
+	}
+	white := p.Paper.ToXYZ(D50)
+	var solids [4]XYZ
+	for i, s := range p.Solids {
+		solids[i] = relativeXYZ(s, white)
+	}
+	minY := Lab{L: p.MinL}.ToXYZ(D50).Y
+	return &InkModel{
+		params: p,
+		white:  white,
+		ng:     newNeugebauer(derivePrimaries(solids, minY), p.N),
+	}
+}
+
+// MediaWhite returns the absolute XYZ of the paper, as stored in the
+// profile's media white point tag.
+func (m *InkModel) MediaWhite() XYZ {
+	return m.white
+}
+
+// effectiveCoverage applies a dot gain of tvi at 50% coverage. The curve
+// 1-(1-v)^g rises monotonically for any tvi below 50%, so the separation
+// solver always sees a gradient; a parabola flattens out near solid once
+// the gain exceeds 25%.
+func effectiveCoverage(v, tvi float64) float64 {
+	v = clamp01(v)
+	if tvi <= 0 {
+		return v
+	}
+	g := math.Log2(1 / (0.5 - math.Min(tvi, 0.49)))
+	return 1 - math.Pow(1-v, g)
+}
+
+// Lab implements Model.
+func (m *InkModel) Lab(c, mg, y, k float64) Lab {
+	return m.ng.lab([4]float64{
+		effectiveCoverage(c, m.params.TVI[0]),
+		effectiveCoverage(mg, m.params.TVI[1]),
+		effectiveCoverage(y, m.params.TVI[2]),
+		effectiveCoverage(k, m.params.TVI[3]),
+	})
+}
+
+func clamp01(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
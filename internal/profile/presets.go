@@ -0,0 +1,60 @@
+package profile
+
+import "sort"
+
+// Preset is a generic printing condition used to generate the built-in
+// CMYK profiles.
+type Preset struct {
+	Description string
+	Ink         InkParams
+	Separation  Separation
+}
+
+// Presets are approximations of common offset conditions. They are
+// deliberately generic: good enough to produce sensible separations out of
+// the box, not a substitute for a characterised press profile.
+var Presets = map[string]Preset{
+	"generic-coated": {
+		Description: "Generic Coated (RGBtoCMYK ink model, TAC 330%)",
+		Ink: InkParams{
+			Paper:  Lab{L: 95, A: 0, B: -2},
+			Solids: [4]Lab{{L: 55, A: -37, B: -50}, {L: 48, A: 74, B: -3}, {L: 89, A: -5, B: 93}, {L: 16, A: 0, B: 0}},
+			TVI:    [4]float64{0.14, 0.14, 0.14, 0.17},
+			N:      1.8,
+			MinL:   9,
+		},
+		Separation: Separation{TAC: 330, MaxK: 95, BlackStart: 20, GCR: 50},
+	},
+	"generic-uncoated": {
+		Description: "Generic Uncoated (RGBtoCMYK ink model, TAC 300%)",
+		Ink: InkParams{
+			Paper:  Lab{L: 95, A: 0, B: -2},
+			Solids: [4]Lab{{L: 60, A: -26, B: -44}, {L: 56, A: 61, B: -1}, {L: 89, A: -4, B: 78}, {L: 31, A: 1, B: 1}},
+			TVI:    [4]float64{0.20, 0.20, 0.20, 0.22},
+			N:      2.2,
+			MinL:   27,
+		},
+		Separation: Separation{TAC: 300, MaxK: 95, BlackStart: 15, GCR: 50},
+	},
+	"generic-newsprint": {
+		Description: "Generic Newsprint (RGBtoCMYK ink model, TAC 240%)",
+		Ink: InkParams{
+			Paper:  Lab{L: 82, A: 0, B: 3},
+			Solids: [4]Lab{{L: 57, A: -23, B: -27}, {L: 54, A: 44, B: 0}, {L: 78, A: -3, B: 58}, {L: 36, A: 1, B: 3}},
+			TVI:    [4]float64{0.26, 0.26, 0.26, 0.28},
+			N:      2.5,
+			MinL:   33,
+		},
+		Separation: Separation{TAC: 240, MaxK: 90, BlackStart: 10, GCR: 60},
+	},
+}
+
+// PresetNames returns the preset names in sorted order.
+func PresetNames() []string {
+	names := make([]string, 0, len(Presets))
+	for n := range Presets {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
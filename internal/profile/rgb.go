@@ -0,0 +1,130 @@
+package profile
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// Chromaticity is a CIE xy coordinate.
+type Chromaticity struct {
+	X, Y float64
+}
+
+// RGBSpace describes a matrix/TRC RGB colour space with a pure gamma curve.
+type RGBSpace struct {
+	Description string
+	Red         Chromaticity
+	Green       Chromaticity
+	Blue        Chromaticity
+	White       Chromaticity
+	Gamma       float64
+}
+
+// RGBSpaces are the RGB spaces written as built-in source profiles.
+var RGBSpaces = map[string]RGBSpace{
+	// Same primaries, white point and transfer curve as Adobe RGB (1998),
+	// built independently so it can be redistributed.
+	"adobergb": {
+		Description: "Adobe RGB (1998) compatible (RGBtoCMYK)",
+		Red:         Chromaticity{0.6400, 0.3300},
+		Green:       Chromaticity{0.2100, 0.7100},
+		Blue:        Chromaticity{0.1500, 0.0600},
+		White:       Chromaticity{0.3127, 0.3290},
+		Gamma:       563.0 / 256,
+	},
+	// sRGB primaries and white with a linear transfer curve, the source
+	// space for linear-light renders.
+	"srgb-linear": {
+		Description: "sRGB linear (RGBtoCMYK)",
+		Red:         Chromaticity{0.6400, 0.3300},
+		Green:       Chromaticity{0.3000, 0.6000},
+		Blue:        Chromaticity{0.1500, 0.0600},
+		White:       Chromaticity{0.3127, 0.3290},
+		Gamma:       1,
+	},
+}
+
+// BuildMatrixRGB writes an ICC v2 display-class matrix/TRC profile for s.
+// The colorants are Bradford-adapted to the D50 PCS.
+func BuildMatrixRGB(s RGBSpace, copyright string, created time.Time) []byte {
+	if created.IsZero() {
+		created = time.Now()
+	}
+	xyz := func(c Chromaticity) XYZ { return XYZ{X: c.X / c.Y, Y: 1, Z: (1 - c.X - c.Y) / c.Y} }
+	white := xyz(s.White)
+
+	// Scale the primaries so that R+G+B reproduces the white point.
+	p := [3]XYZ{xyz(s.Red), xyz(s.Green), xyz(s.Blue)}
+	m := [3][3]float64{
+		{p[0].X, p[1].X, p[2].X},
+		{p[0].Y, p[1].Y, p[2].Y},
+		{p[0].Z, p[1].Z, p[2].Z},
+	}
+	scale, _ := solve3(m, [3]float64{white.X, white.Y, white.Z})
+
+	adapt := bradford(white, D50)
+	var colorants [3]XYZ
+	for i := range p {
+		c := XYZ{X: p[i].X * scale[i], Y: p[i].Y * scale[i], Z: p[i].Z * scale[i]}
+		colorants[i] = XYZ{
+			X: adapt[0][0]*c.X + adapt[0][1]*c.Y + adapt[0][2]*c.Z,
+			Y: adapt[1][0]*c.X + adapt[1][1]*c.Y + adapt[1][2]*c.Z,
+			Z: adapt[2][0]*c.X + adapt[2][1]*c.Y + adapt[2][2]*c.Z,
+		}
+	}
+
+	trc := gammaCurveTag(s.Gamma)
+	tags := []tag{
+		{"desc", descTag(s.Description)},
+		{"cprt", textTag(copyright)},
+		{"wtpt", xyzTag(D50)},
+		{"rXYZ", xyzTag(colorants[0])},
+		{"gXYZ", xyzTag(colorants[1])},
+		{"bXYZ", xyzTag(colorants[2])},
+		{"rTRC", trc},
+		{"gTRC", trc},
+		{"bTRC", trc},
+	}
+	return assemble(header{class: "mntr", colorSpace: "RGB ", pcs: "XYZ ", created: created}, tags)
+}
+
+// gammaCurveTag encodes a curveType tag holding a single u8Fixed8 gamma.
+func gammaCurveTag(gamma float64) []byte {
+	b := make([]byte, 14)
+	copy(b, "curv")
+	binary.BigEndian.PutUint32(b[8:], 1)
+	binary.BigEndian.PutUint16(b[12:], uint16(math.Round(gamma*256)))
+	return b
+}
+
+// bradford returns the Bradford chromatic adaptation matrix from src to dst.
+func bradford(src, dst XYZ) [3][3]float64 {
+	mb := [3][3]float64{
+		{0.8951, 0.2664, -0.1614},
+		{-0.7502, 1.7135, 0.0367},
+		{0.0389, -0.0685, 1.0296},
+	}
+	inv := [3][3]float64{
+		{0.9869929, -0.1470543, 0.1599627},
+		{0.4323053, 0.5183603, 0.0492912},
+		{-0.0085287, 0.0400428, 0.9684867},
+	}
+	cone := func(c XYZ) [3]float64 {
+		return [3]float64{
+			mb[0][0]*c.X + mb[0][1]*c.Y + mb[0][2]*c.Z,
+			mb[1][0]*c.X + mb[1][1]*c.Y + mb[1][2]*c.Z,
+			mb[2][0]*c.X + mb[2][1]*c.Y + mb[2][2]*c.Z,
+		}
+	}
+	s, d := cone(src), cone(dst)
+	var out [3][3]float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				out[i][j] += inv[i][k] * (d[k] / s[k]) * mb[k][j]
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,48 @@
+package profile
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBuildMatrixRGBColorants(t *testing.T) {
+	data := BuildMatrixRGB(RGBSpaces["adobergb"], "", time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	be := binary.BigEndian
+	if size := be.Uint32(data[0:4]); int(size) != len(data) {
+		t.Errorf("header size %d, data length %d", size, len(data))
+	}
+	if string(data[12:16]) != "mntr" || string(data[16:20]) != "RGB " || string(data[20:24]) != "XYZ " {
+		t.Errorf("unexpected header: %q", data[12:24])
+	}
+
+	// The colorants of a D50-adapted matrix profile must sum to D50, and
+	// red must match the published Adobe RGB (1998) value.
+	var sum, red XYZ
+	count := int(be.Uint32(data[128:132]))
+	for i := 0; i < count; i++ {
+		e := data[132+12*i:]
+		sig := string(e[0:4])
+		if sig != "rXYZ" && sig != "gXYZ" && sig != "bXYZ" {
+			continue
+		}
+		v := data[be.Uint32(e[4:8])+8:]
+		c := XYZ{
+			X: float64(int32(be.Uint32(v[0:]))) / 65536,
+			Y: float64(int32(be.Uint32(v[4:]))) / 65536,
+			Z: float64(int32(be.Uint32(v[8:]))) / 65536,
+		}
+		sum.X, sum.Y, sum.Z = sum.X+c.X, sum.Y+c.Y, sum.Z+c.Z
+		if sig == "rXYZ" {
+			red = c
+		}
+	}
+	if math.Abs(sum.X-D50.X) > 1e-3 || math.Abs(sum.Y-D50.Y) > 1e-3 || math.Abs(sum.Z-D50.Z) > 1e-3 {
+		t.Errorf("colorant sum %+v, want D50 %+v", sum, D50)
+	}
+	if math.Abs(red.X-0.6097) > 1e-3 || math.Abs(red.Y-0.3111) > 1e-3 || math.Abs(red.Z-0.0195) > 1e-3 {
+		t.Errorf("rXYZ = %+v", red)
+	}
+}
@@ -0,0 +1,203 @@
+package profile
+
+import "math"
+
+// Separation controls how Lab colours are separated into CMYK. All values
+// are percentages.
+type Separation struct {
+	TAC        float64 // total area coverage limit, e.g. 330
+	MaxK       float64 // maximum black, e.g. 95
+	BlackStart float64 // gray component at which black generation begins
+	GCR        float64 // gray component replacement strength (0 = UCR only, 100 = maximum GCR)
+}
+
+// tacPenalty weights TAC violations against Lab error in the solver.
+const tacPenalty = 200
+
+// separator inverts a Model under a Separation.
+type separator struct {
+	model Model
+	sep   Separation
+}
+
+// cost returns the squared Lab error plus the TAC penalty.
+func (s *separator) cost(t Lab, x [3]float64, k, tac float64) float64 {
+	lab := s.model.Lab(x[0], x[1], x[2], k)
+	dl, da, db := lab.L-t.L, lab.A-t.A, lab.B-t.B
+	over := x[0] + x[1] + x[2] + k - tac
+	if over < 0 {
+		over = 0
+	}
+	over *= tacPenalty
+	return dl*dl + da*da + db*db + over*over
+}
+
+// residual returns the Lab error vector and the TAC penalty as a fourth term.
+func (s *separator) residual(t Lab, x [3]float64, k, tac float64) [4]float64 {
+	lab := s.model.Lab(x[0], x[1], x[2], k)
+	over := x[0] + x[1] + x[2] + k - tac
+	if over < 0 {
+		over = 0
+	}
+	return [4]float64{lab.L - t.L, lab.A - t.A, lab.B - t.B, over * tacPenalty}
+}
+
+// solveCMY finds the C, M and Y coverages (with K fixed) that best reproduce
+// t, using a box-constrained Levenberg–Marquardt iteration. It returns the
+// coverages and the remaining Lab error.
+func (s *separator) solveCMY(t Lab, k float64, start [3]float64, tac float64) ([3]float64, float64) {
+	const h = 1e-4
+	x := start
+	cur := s.cost(t, x, k, tac)
+	lambda := 1e-3
+
+	for iter := 0; iter < 40 && cur > 1e-8; iter++ {
+		r := s.residual(t, x, k, tac)
+		var jac [4][3]float64
+		for j := 0; j < 3; j++ {
+			xp := x
+			step := h
+			if xp[j]+step > 1 {
+				step = -h
+			}
+			xp[j] += step
+			rp := s.residual(t, xp, k, tac)
+			for i := 0; i < 4; i++ {
+				jac[i][j] = (rp[i] - r[i]) / step
+			}
+		}
+
+		var jtj [3][3]float64
+		var jtr [3]float64
+		for a := 0; a < 3; a++ {
+			for b := 0; b < 3; b++ {
+				for i := 0; i < 4; i++ {
+					jtj[a][b] += jac[i][a] * jac[i][b]
+				}
+			}
+			for i := 0; i < 4; i++ {
+				jtr[a] += jac[i][a] * r[i]
+			}
+		}
+
+		improved := false
+		for lambda < 1e8 {
+			d, ok := s.step(jtj, jtr, lambda, x)
+			if !ok {
+				lambda *= 10
+				continue
+			}
+			var xn [3]float64
+			for a := 0; a < 3; a++ {
+				xn[a] = clamp01(x[a] + d[a])
+			}
+			if c := s.cost(t, xn, k, tac); c < cur {
+				done := cur-c < 1e-7
+				x, cur = xn, c
+				lambda /= 3
+				improved = !done
+				break
+			}
+			lambda *= 4
+		}
+		if !improved {
+			break
+		}
+	}
+
+	lab := s.model.Lab(x[0], x[1], x[2], k)
+	return x, DeltaE76(lab, t)
+}
+
+// step solves the damped normal equations. Coverages pinned at 0 or 1 whose
+// step would leave the box are frozen so the remaining inks can still move.
+func (s *separator) step(jtj [3][3]float64, jtr [3]float64, lambda float64, x [3]float64) ([3]float64, bool) {
+	var frozen [3]bool
+	for pass := 0; pass < 3; pass++ {
+		m := jtj
+		v := [3]float64{-jtr[0], -jtr[1], -jtr[2]}
+		for a := 0; a < 3; a++ {
+			m[a][a] += lambda * (jtj[a][a] + 1e-6)
+			if frozen[a] {
+				for b := 0; b < 3; b++ {
+					m[a][b], m[b][a] = 0, 0
+				}
+				m[a][a], v[a] = 1, 0
+			}
+		}
+		d, ok := solve3(m, v)
+		if !ok {
+			return d, false
+		}
+		changed := false
+		for a := 0; a < 3; a++ {
+			if !frozen[a] && ((x[a] <= 0 && d[a] < 0) || (x[a] >= 1 && d[a] > 0)) {
+				frozen[a] = true
+				changed = true
+			}
+		}
+		if !changed {
+			return d, true
+		}
+	}
+	return [3]float64{}, false
+}
+
+// separate converts a media-relative Lab colour to CMYK coverages. start is
+// the C, M, Y solution of a neighbouring colour, used to keep adjacent grid
+// points on the same solution branch.
+func (s *separator) separate(t Lab, start [3]float64) [4]float64 {
+	tac := s.sep.TAC / 100
+	maxK := s.sep.MaxK / 100
+	bs := s.sep.BlackStart / 100
+
+	// Gray component of the CMY-only solution drives black generation.
+	cmy0, _ := s.solveCMY(t, 0, start, math.Inf(1))
+	g := math.Min(cmy0[0], math.Min(cmy0[1], cmy0[2]))
+	k := 0.0
+	if g > bs && bs < 1 {
+		k = s.sep.GCR / 100 * maxK * (g - bs) / (1 - bs)
+	}
+	cmy, e := s.solveCMY(t, k, cmy0, tac)
+
+	// Shadows beyond the CMY gamut, or colours squeezed by the TAC limit,
+	// need more black than GCR alone asks for.
+	for k < maxK {
+		k2 := math.Min(maxK, k+0.05)
+		cmy2, e2 := s.solveCMY(t, k2, cmy, tac)
+		if e2 > e-0.25 {
+			break
+		}
+		k, cmy, e = k2, cmy2, e2
+	}
+
+	// The penalty keeps the solver close to the limit; enforce it exactly.
+	if sum := cmy[0] + cmy[1] + cmy[2] + k; sum > tac && sum > k {
+		f := (tac - k) / (sum - k)
+		for i := range cmy {
+			cmy[i] *= f
+		}
+	}
+	return [4]float64{cmy[0], cmy[1], cmy[2], k}
+}
+
+// solve3 solves the 3x3 linear system m·x = v by Cramer's rule.
+func solve3(m [3][3]float64, v [3]float64) ([3]float64, bool) {
+	det := m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+		m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+		m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+	if math.Abs(det) < 1e-18 {
+		return [3]float64{}, false
+	}
+	var x [3]float64
+	for c := 0; c < 3; c++ {
+		mc := m
+		for r := 0; r < 3; r++ {
+			mc[r][c] = v[r]
+		}
+		x[c] = (mc[0][0]*(mc[1][1]*mc[2][2]-mc[1][2]*mc[2][1]) -
+			mc[0][1]*(mc[1][0]*mc[2][2]-mc[1][2]*mc[2][0]) +
+			mc[0][2]*(mc[1][0]*mc[2][1]-mc[1][1]*mc[2][0])) / det
+	}
+	return x, true
+}
@@ -0,0 +1,178 @@
+package tiff
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Additional tags read by DecodeFloatRGB and Resolution.
+const (
+	tagSampleFormat = 339
+)
+
+// Field types beyond those the writer emits.
+const (
+	typeByte = 1
+)
+
+// DecodeFloatRGB reads an uncompressed, strip-organised, chunky TIFF with
+// 32-bit IEEE float samples and 3 (RGB) or 4 (RGBA, alpha dropped) samples
+// per pixel. It returns 3 float32 values per pixel, top row first.
+func DecodeFloatRGB(data []byte) (width, height int, pixels []float32, err error) {
+	bo, tags, err := firstIFD(data)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	first := func(tag uint16, def uint32) uint32 {
+		if v, ok := tags[tag]; ok && len(v) > 0 {
+			return v[0]
+		}
+		return def
+	}
+
+	width = int(first(tagImageWidth, 0))
+	height = int(first(tagImageLength, 0))
+	spp := int(first(tagSamplesPerPixel, 1))
+	switch {
+	case width <= 0 || height <= 0:
+		return 0, 0, nil, errors.New("TIFF has no image dimensions")
+	case first(tagCompression, 1) != 1:
+		return 0, 0, nil, errors.New("compressed TIFF is not supported")
+	case first(tagBitsPerSample, 1) != 32 || first(tagSampleFormat, 1) != 3:
+		return 0, 0, nil, errors.New("TIFF is not 32-bit floating point")
+	case spp != 3 && spp != 4:
+		return 0, 0, nil, fmt.Errorf("TIFF has %d samples per pixel, want 3 or 4", spp)
+	case spp > 1 && first(tagPlanarConfiguration, 1) != 1:
+		return 0, 0, nil, errors.New("planar TIFF is not supported")
+	}
+
+	offsets, counts := tags[tagStripOffsets], tags[tagStripByteCounts]
+	if len(offsets) == 0 || len(offsets) != len(counts) {
+		return 0, 0, nil, errors.New("TIFF strip tables are missing or inconsistent")
+	}
+	raw := make([]byte, 0, width*height*spp*4)
+	for i, off := range offsets {
+		end := uint64(off) + uint64(counts[i])
+		if end > uint64(len(data)) {
+			return 0, 0, nil, fmt.Errorf("TIFF strip %d out of bounds", i)
+		}
+		raw = append(raw, data[off:end]...)
+	}
+	if len(raw) < width*height*spp*4 {
+		return 0, 0, nil, fmt.Errorf("TIFF pixel data truncated: %d of %d bytes", len(raw), width*height*spp*4)
+	}
+
+	pixels = make([]float32, width*height*3)
+	for p := 0; p < width*height; p++ {
+		for c := 0; c < 3; c++ {
+			pixels[p*3+c] = math.Float32frombits(bo.Uint32(raw[(p*spp+c)*4:]))
+		}
+	}
+	return width, height, pixels, nil
+}
+
+// Resolution returns the print resolution of a TIFF in pixels per inch,
+// from its XResolution and ResolutionUnit tags, or 0 if it has none.
+func Resolution(data []byte) float64 {
+	_, tags, err := firstIFD(data)
+	if err != nil {
+		return 0
+	}
+	x := tags[tagXResolution]
+	if len(x) < 2 || x[1] == 0 {
+		return 0
+	}
+	dpi := float64(x[0]) / float64(x[1])
+	unit := uint32(2)
+	if u := tags[tagResolutionUnit]; len(u) > 0 {
+		unit = u[0]
+	}
+	switch unit {
+	case 2:
+		return dpi
+	case 3:
+		return dpi * 2.54
+	}
+	return 0
+}
+
+// firstIFD checks the TIFF header and reads the first IFD.
+func firstIFD(data []byte) (binary.ByteOrder, map[uint16][]uint32, error) {
+	if len(data) < 8 {
+		return nil, nil, errors.New("TIFF data too short")
+	}
+	var bo binary.ByteOrder
+	switch string(data[:2]) {
+	case "II":
+		bo = binary.LittleEndian
+	case "MM":
+		bo = binary.BigEndian
+	default:
+		return nil, nil, errors.New("not a TIFF file")
+	}
+	if bo.Uint16(data[2:]) != 42 {
+		return nil, nil, errors.New("not a classic TIFF file")
+	}
+	tags, err := readIFD(data, bo, bo.Uint32(data[4:]))
+	return bo, tags, err
+}
+
+// readIFD returns the SHORT, LONG and BYTE values of each tag in the IFD at
+// off, and RATIONAL values as numerator, denominator pairs. Other types are
+// skipped.
+func readIFD(data []byte, bo binary.ByteOrder, off uint32) (map[uint16][]uint32, error) {
+	if uint64(off)+2 > uint64(len(data)) {
+		return nil, errors.New("TIFF IFD offset out of bounds")
+	}
+	n := int(bo.Uint16(data[off:]))
+	if uint64(off)+2+12*uint64(n) > uint64(len(data)) {
+		return nil, errors.New("TIFF IFD truncated")
+	}
+	tags := make(map[uint16][]uint32, n)
+	for i := 0; i < n; i++ {
+		e := data[int(off)+2+12*i:]
+		tag, typ, count := bo.Uint16(e), bo.Uint16(e[2:]), bo.Uint32(e[4:])
+		var size uint32
+		switch typ {
+		case typeByte:
+			size = 1
+		case typeShort:
+			size = 2
+		case typeLong:
+			size = 4
+		case typeRational:
+			size = 8
+		default:
+			continue
+		}
+		if uint64(count)*uint64(size) > uint64(len(data)) {
+			return nil, fmt.Errorf("TIFF tag %d count out of range", tag)
+		}
+		src := e[8:12]
+		if count*size > 4 {
+			vo := bo.Uint32(e[8:])
+			if uint64(vo)+uint64(count*size) > uint64(len(data)) {
+				return nil, fmt.Errorf("TIFF tag %d values out of bounds", tag)
+			}
+			src = data[vo : vo+count*size]
+		}
+		vals := make([]uint32, count)
+		if typ == typeRational {
+			vals = make([]uint32, 2*count)
+		}
+		for j := range vals {
+			switch typ {
+			case typeByte:
+				vals[j] = uint32(src[j])
+			case typeShort:
+				vals[j] = uint32(bo.Uint16(src[2*j:]))
+			case typeLong, typeRational:
+				vals[j] = bo.Uint32(src[4*j:])
+			}
+		}
+		tags[tag] = vals
+	}
+	return tags, nil
+}
@@ -0,0 +1,122 @@
+package tiff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// floatTIFF builds a little-endian 32-bit float TIFF with spp samples per
+// pixel, split into one strip per row.
+func floatTIFF(width, height, spp int, values []float32) []byte {
+	le := binary.LittleEndian
+	var pix bytes.Buffer
+	for _, v := range values {
+		binary.Write(&pix, le, math.Float32bits(v))
+	}
+
+	const n = 10
+	ifdLen := 2 + 12*n + 4
+	bpsOff := 8 + ifdLen
+	stripTables := bpsOff + 2*spp
+	countsOff := stripTables + 4*height
+	dataOff := countsOff + 4*height
+
+	var b bytes.Buffer
+	b.WriteString("II")
+	binary.Write(&b, le, uint16(42))
+	binary.Write(&b, le, uint32(8))
+	binary.Write(&b, le, uint16(n))
+	put := func(tag, typ uint16, count, value uint32) {
+		binary.Write(&b, le, tag)
+		binary.Write(&b, le, typ)
+		binary.Write(&b, le, count)
+		binary.Write(&b, le, value)
+	}
+	put(tagImageWidth, typeLong, 1, uint32(width))
+	put(tagImageLength, typeLong, 1, uint32(height))
+	put(tagBitsPerSample, typeShort, uint32(spp), uint32(bpsOff))
+	put(tagCompression, typeShort, 1, 1)
+	put(tagPhotometricInterpretation, typeShort, 1, 2)
+	put(tagStripOffsets, typeLong, uint32(height), uint32(stripTables))
+	put(tagSamplesPerPixel, typeShort, 1, uint32(spp))
+	put(tagStripByteCounts, typeLong, uint32(height), uint32(countsOff))
+	put(tagPlanarConfiguration, typeShort, 1, 1)
+	put(tagSampleFormat, typeShort, 1, 3)
+	binary.Write(&b, le, uint32(0))
+	for i := 0; i < spp; i++ {
+		binary.Write(&b, le, uint16(32))
+	}
+	row := width * spp * 4
+	for y := 0; y < height; y++ {
+		binary.Write(&b, le, uint32(dataOff+y*row))
+	}
+	for y := 0; y < height; y++ {
+		binary.Write(&b, le, uint32(row))
+	}
+	b.Write(pix.Bytes())
+	return b.Bytes()
+}
+
+func TestDecodeFloatRGB(t *testing.T) {
+	// 2x2 RGBA; alpha must be dropped.
+	values := []float32{
+		0, 0.5, 1, 1, 2, 3, 4, 1,
+		-1, 0.25, 8, 1, 1, 1, 1, 0,
+	}
+	w, h, pix, err := DecodeFloatRGB(floatTIFF(2, 2, 4, values))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []float32{0, 0.5, 1, 2, 3, 4, -1, 0.25, 8, 1, 1, 1}
+	if w != 2 || h != 2 || len(pix) != len(want) {
+		t.Fatalf("got %dx%d with %d values", w, h, len(pix))
+	}
+	for i := range want {
+		if pix[i] != want[i] {
+			t.Errorf("value %d = %v, want %v", i, pix[i], want[i])
+		}
+	}
+}
+
+func TestDecodeFloatRGBRejects8Bit(t *testing.T) {
+	data, err := EncodeCMYK(make([]byte, 4), 1, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := DecodeFloatRGB(data); err == nil {
+		t.Error("expected error for 8-bit TIFF")
+	}
+}
+
+func TestResolution(t *testing.T) {
+	data, err := EncodeCMYK(make([]byte, 4), 1, 1, 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dpi := Resolution(data); dpi != 300 {
+		t.Errorf("resolution %g, want 300", dpi)
+	}
+	if dpi := Resolution(floatTIFF(1, 1, 3, []float32{0, 0, 0})); dpi != 0 {
+		t.Errorf("resolution %g without the tags, want 0", dpi)
+	}
+}
+
+func TestEncodeCMYK16(t *testing.T) {
+	data, err := EncodeCMYK16([]uint16{0, 0x1234, 0xffff, 1}, 1, 1, 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags, err := readIFD(data, binary.LittleEndian, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bps := tags[tagBitsPerSample]; len(bps) != 4 || bps[0] != 16 {
+		t.Errorf("BitsPerSample = %v", bps)
+	}
+	off := tags[tagStripOffsets][0]
+	if got := binary.LittleEndian.Uint16(data[off+2:]); got != 0x1234 {
+		t.Errorf("second sample = %#x", got)
+	}
+}
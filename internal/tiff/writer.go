@@ -0,0 +1,162 @@
+// Package tiff reads and writes the small subset of baseline TIFF the tool
+// needs: uncompressed, single-image, interleaved files.
+package tiff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// TIFF tag numbers used by this package.
+const (
+	tagImageWidth                = 256
+	tagImageLength               = 257
+	tagBitsPerSample             = 258
+	tagCompression               = 259
+	tagPhotometricInterpretation = 262
+	tagStripOffsets              = 273
+	tagSamplesPerPixel           = 277
+	tagRowsPerStrip              = 278
+	tagStripByteCounts           = 279
+	tagXResolution               = 282
+	tagYResolution               = 283
+	tagPlanarConfiguration       = 284
+	tagResolutionUnit            = 296
+	tagInkSet                    = 332
+)
+
+// TIFF field types.
+const (
+	typeShort    = 3
+	typeLong     = 4
+	typeRational = 5
+)
+
+type entry struct {
+	tag, typ uint16
+	values   []uint32 // SHORT/LONG values, or numerator/denominator pairs
+}
+
+// EncodeCMYK writes 8-bit interleaved CMYK pixels as an uncompressed TIFF
+// with the given resolution in pixels per inch (0 omits it).
+func EncodeCMYK(pixels []byte, width, height int, dpi float64) ([]byte, error) {
+	if expected := width * height * 4; len(pixels) != expected {
+		return nil, fmt.Errorf("expected %d CMYK bytes, got %d", expected, len(pixels))
+	}
+	return encodeCMYK(pixels, 8, width, height, dpi), nil
+}
+
+// EncodeCMYK16 writes 16-bit interleaved CMYK samples as an uncompressed
+// TIFF, like EncodeCMYK.
+func EncodeCMYK16(samples []uint16, width, height int, dpi float64) ([]byte, error) {
+	if expected := width * height * 4; len(samples) != expected {
+		return nil, fmt.Errorf("expected %d CMYK samples, got %d", expected, len(samples))
+	}
+	pixels := make([]byte, 2*len(samples))
+	for i, v := range samples {
+		binary.LittleEndian.PutUint16(pixels[2*i:], v)
+	}
+	return encodeCMYK(pixels, 16, width, height, dpi), nil
+}
+
+// encodeCMYK writes little-endian pixel data with the given bits per sample.
+func encodeCMYK(pixels []byte, bits uint32, width, height int, dpi float64) []byte {
+	const headerLen = 8
+	entries := []entry{
+		{tagImageWidth, typeLong, []uint32{uint32(width)}},
+		{tagImageLength, typeLong, []uint32{uint32(height)}},
+		{tagBitsPerSample, typeShort, []uint32{bits, bits, bits, bits}},
+		{tagCompression, typeShort, []uint32{1}},
+		{tagPhotometricInterpretation, typeShort, []uint32{5}}, // separated
+		{tagStripOffsets, typeLong, []uint32{0}},               // patched below
+		{tagSamplesPerPixel, typeShort, []uint32{4}},
+		{tagRowsPerStrip, typeLong, []uint32{uint32(height)}},
+		{tagStripByteCounts, typeLong, []uint32{uint32(len(pixels))}},
+		{tagPlanarConfiguration, typeShort, []uint32{1}},
+		{tagInkSet, typeShort, []uint32{1}}, // CMYK
+	}
+	if dpi > 0 {
+		r := []uint32{uint32(dpi*100 + 0.5), 100}
+		entries = append(entries,
+			entry{tagXResolution, typeRational, r},
+			entry{tagYResolution, typeRational, r},
+			entry{tagResolutionUnit, typeShort, []uint32{2}},
+		)
+	}
+	sortEntries(entries)
+
+	// Layout: header, IFD, out-of-line values, pixel data.
+	ifdLen := 2 + 12*len(entries) + 4
+	extraOff := headerLen + ifdLen
+	var extra bytes.Buffer
+	valueOffsets := make([]int, len(entries))
+	for i, e := range entries {
+		if size := valueSize(e); size > 4 {
+			valueOffsets[i] = extraOff + extra.Len()
+			writeValues(&extra, e)
+		}
+	}
+	dataOff := extraOff + extra.Len()
+	for i := range entries {
+		if entries[i].tag == tagStripOffsets {
+			entries[i].values[0] = uint32(dataOff)
+		}
+	}
+
+	le := binary.LittleEndian
+	var out bytes.Buffer
+	out.Grow(dataOff + len(pixels))
+	out.WriteString("II")
+	binary.Write(&out, le, uint16(42))
+	binary.Write(&out, le, uint32(headerLen))
+	binary.Write(&out, le, uint16(len(entries)))
+	for i, e := range entries {
+		count := len(e.values)
+		if e.typ == typeRational {
+			count /= 2
+		}
+		binary.Write(&out, le, e.tag)
+		binary.Write(&out, le, e.typ)
+		binary.Write(&out, le, uint32(count))
+		if valueSize(e) > 4 {
+			binary.Write(&out, le, uint32(valueOffsets[i]))
+		} else {
+			var inline bytes.Buffer
+			writeValues(&inline, e)
+			for inline.Len() < 4 {
+				inline.WriteByte(0)
+			}
+			out.Write(inline.Bytes())
+		}
+	}
+	binary.Write(&out, le, uint32(0)) // no next IFD
+	out.Write(extra.Bytes())
+	out.Write(pixels)
+	return out.Bytes()
+}
+
+func valueSize(e entry) int {
+	if e.typ == typeShort {
+		return 2 * len(e.values)
+	}
+	return 4 * len(e.values)
+}
+
+func writeValues(b *bytes.Buffer, e entry) {
+	for _, v := range e.values {
+		if e.typ == typeShort {
+			binary.Write(b, binary.LittleEndian, uint16(v))
+		} else {
+			binary.Write(b, binary.LittleEndian, v)
+		}
+	}
+}
+
+func sortEntries(entries []entry) {
+	for i := 1; i < len(entries); i++ {
+		for j := i; j > 0 && entries[j].tag < entries[j-1].tag; j-- {
+			entries[j], entries[j-1] = entries[j-1], entries[j]
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package rgbtocmyk
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/davesmith10/RGBtoCMYK/internal/color"
+	"github.com/davesmith10/RGBtoCMYK/internal/jpeg"
+	"github.com/davesmith10/RGBtoCMYK/internal/pipeline"
+)
+
+// Result describes a conversion.
+type Result struct {
+	Data          []byte // the CMYK JPEG; nil from Transform
+	CMYK          []byte // interleaved CMYK pixels, 0 = no ink
+	Width, Height int
+	SourceProfile []byte // the RGB profile the input was converted from
+	SourceReason  string // how SourceProfile was chosen
+	Intent        Intent // rendering intent used, after any fallback
+	Quality       int    // JPEG quality used (Convert)
+	CMYReduction  int    // CMY quality reduction used (Convert)
+	Warnings      []string
+}
+
+// Convert converts an RGB, YCbCr or grayscale JPEG to a CMYK JPEG. It uses
+// Profile, SourceProfile, AssumeProfile, RequireSourceProfile, Intent,
+// Quality, CMYReduction, Progressive, Convention, DPI, StripMetadata,
+// StripGPS and Software.
+func Convert(src []byte, opts ...Option) (*Result, error) {
+	o, err := resolve(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSource(src, o); err != nil {
+		return nil, err
+	}
+	dst, err := destination(o)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := pipeline.Run(src, pipeline.Options{
+		SrcProfileOverride: o.SourceProfile,
+		AssumeProfile:      o.AssumeProfile,
+		DstProfile:         dst,
+		Quality:            o.Quality,
+		CMYReduction:       o.CMYReduction,
+		Intent:             int(o.Intent),
+		Progressive:        o.Progressive,
+		Convention:         jpeg.Convention(o.Convention),
+		StripMetadata:      o.StripMetadata,
+		StripGPS:           o.StripGPS,
+		DPI:                o.DPI,
+		Software:           o.Software,
+	})
+	if err != nil {
+		return nil, wrapStage(err)
+	}
+	return &Result{
+		Data:          r.Data,
+		CMYK:          r.CMYK,
+		Width:         r.SrcWidth,
+		Height:        r.SrcHeight,
+		SourceProfile: r.SrcICC,
+		SourceReason:  r.SrcReason,
+		Intent:        Intent(r.Intent),
+		Quality:       r.Quality,
+		CMYReduction:  r.CMYReduction,
+		Warnings:      r.Warnings,
+	}, nil
+}
+
+// ConvertReader converts the JPEG read from r and writes the CMYK JPEG to
+// w.
+func ConvertReader(r io.Reader, w io.Writer, opts ...Option) (*Result, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, wrap(ErrInvalidInput, err)
+	}
+	res, err := Convert(src, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(res.Data); err != nil {
+		return nil, fmt.Errorf("rgbtocmyk: writing output: %w", err)
+	}
+	return res, nil
+}
+
+// Transform separates an RGB, YCbCr or grayscale JPEG into CMYK pixels
+// without encoding them, in Result.CMYK. It uses Profile, SourceProfile,
+// AssumeProfile, RequireSourceProfile and Intent.
+func Transform(src []byte, opts ...Option) (*Result, error) {
+	o, err := resolve(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSource(src, o); err != nil {
+		return nil, err
+	}
+	dst, err := destination(o)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded, err := jpeg.DecodeRGB(src)
+	if err != nil {
+		return nil, wrap(ErrInvalidInput, err)
+	}
+	srcICC, reason := pipeline.SourceProfile(decoded, o.SourceProfile, o.AssumeProfile)
+	var warnings []string
+	if ignored := pipeline.IgnoredProfile(decoded); ignored != "" && o.SourceProfile == nil {
+		warnings = append(warnings, ignored)
+	}
+
+	xform, err := color.NewTransform(srcICC, dst, int(o.Intent))
+	if err != nil {
+		return nil, wrap(ErrInvalidProfile, err)
+	}
+	defer xform.Close()
+	cmyk, err := xform.TransformPixels(decoded.Pixels, decoded.Width, decoded.Height)
+	if err != nil {
+		return nil, wrap(ErrTransform, err)
+	}
+	return &Result{
+		CMYK:          cmyk,
+		Width:         decoded.Width,
+		Height:        decoded.Height,
+		SourceProfile: srcICC,
+		SourceReason:  reason,
+		Intent:        Intent(xform.Intent()),
+		Warnings:      warnings,
+	}, nil
+}
+
+// TransformReader separates the JPEG read from r and writes the raw CMYK
+// pixels to w.
+func TransformReader(r io.Reader, w io.Writer, opts ...Option) (*Result, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, wrap(ErrInvalidInput, err)
+	}
+	res, err := Transform(src, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(res.CMYK); err != nil {
+		return nil, fmt.Errorf("rgbtocmyk: writing output: %w", err)
+	}
+	return res, nil
+}
+
+// checkSource rejects input that is not a JPEG, is not in an RGB-based
+// colour space, or has no source profile when one is required.
+func checkSource(src []byte, o Options) error {
+	info, err := jpeg.GetInfo(src)
+	if err != nil {
+		return wrap(ErrInvalidInput, err)
+	}
+	switch info.ColorSpace {
+	case "RGB", "YCbCr", "Grayscale":
+	default:
+		return fmt.Errorf("%w: input is %s, want RGB, YCbCr or grayscale", ErrUnsupportedColorSpace, info.ColorSpace)
+	}
+	if o.RequireSourceProfile && o.SourceProfile == nil && o.AssumeProfile == nil {
+		decoded := &jpeg.DecodedRGB{ICC: info.ICC}
+		if info.ICC == nil {
+			return fmt.Errorf("%w: input has no embedded profile", ErrNoSourceProfile)
+		}
+		if ignored := pipeline.IgnoredProfile(decoded); ignored != "" {
+			return fmt.Errorf("%w: %s", ErrNoSourceProfile, ignored)
+		}
+	}
+	return nil
+}
+
+// destination returns the destination profile, DefaultProfile if none is
+// set.
+func destination(o Options) ([]byte, error) {
+	if o.Profile != nil {
+		return o.Profile, nil
+	}
+	return LoadProfile(DefaultProfile)
+}
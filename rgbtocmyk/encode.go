@@ -0,0 +1,49 @@
+package rgbtocmyk
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/davesmith10/RGBtoCMYK/internal/jpeg"
+)
+
+// Encode writes width×height interleaved CMYK pixels (0 = no ink, as in
+// Result.CMYK) as a CMYK JPEG. It uses Profile, embedded if set, Quality,
+// CMYReduction, Progressive, Convention and DPI.
+func Encode(cmyk []byte, width, height int, opts ...Option) ([]byte, error) {
+	o, err := resolve(opts)
+	if err != nil {
+		return nil, err
+	}
+	if width <= 0 || height <= 0 || len(cmyk) != width*height*4 {
+		return nil, fmt.Errorf("%w: %d bytes for %dx%d CMYK, want %d", ErrInvalidInput, len(cmyk), width, height, width*height*4)
+	}
+	data, err := jpeg.EncodeCMYK(cmyk, width, height, o.Profile, jpeg.EncoderOptions{
+		Quality:      o.Quality,
+		CMYReduction: o.CMYReduction,
+		Progressive:  o.Progressive,
+		Convention:   jpeg.Convention(o.Convention),
+		Density:      jpeg.Density{X: o.DPI, Y: o.DPI},
+	})
+	if err != nil {
+		return nil, wrap(ErrEncode, err)
+	}
+	return data, nil
+}
+
+// EncodeReader reads width×height CMYK pixels from r and writes the JPEG to
+// w.
+func EncodeReader(r io.Reader, w io.Writer, width, height int, opts ...Option) error {
+	cmyk, err := io.ReadAll(r)
+	if err != nil {
+		return wrap(ErrInvalidInput, err)
+	}
+	data, err := Encode(cmyk, width, height, opts...)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("rgbtocmyk: writing output: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package rgbtocmyk
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/davesmith10/RGBtoCMYK/internal/pipeline"
+)
+
+// Errors returned by this package wrap one of these, so callers can test
+// for them with errors.Is; the message keeps the underlying cause.
+var (
+	// ErrInvalidInput means the input is not a readable JPEG, or raw CMYK
+	// data does not match its dimensions.
+	ErrInvalidInput = errors.New("rgbtocmyk: invalid input")
+	// ErrInvalidOption means an option is out of range or unknown, or
+	// options that cannot be combined were given together.
+	ErrInvalidOption = errors.New("rgbtocmyk: invalid option")
+	// ErrUnsupportedColorSpace means the input's colour space cannot be
+	// converted, such as a CMYK JPEG given to Convert.
+	ErrUnsupportedColorSpace = errors.New("rgbtocmyk: unsupported color space")
+	// ErrNoSourceProfile means the input has no usable embedded profile and
+	// Options.RequireSourceProfile is set.
+	ErrNoSourceProfile = errors.New("rgbtocmyk: no source profile")
+	// ErrInvalidProfile means a profile cannot be read, or the profiles
+	// cannot make an RGB to CMYK transform.
+	ErrInvalidProfile = errors.New("rgbtocmyk: invalid profile")
+	// ErrTransform means the colour transform failed.
+	ErrTransform = errors.New("rgbtocmyk: color transform failed")
+	// ErrEncode means the CMYK JPEG could not be encoded.
+	ErrEncode = errors.New("rgbtocmyk: encode failed")
+)
+
+// wrap marks err with sentinel.
+func wrap(sentinel, err error) error {
+	return fmt.Errorf("%w: %w", sentinel, err)
+}
+
+// wrapStage marks an error from the pipeline with the sentinel for the
+// stage it failed in.
+func wrapStage(err error) error {
+	var se *pipeline.StageError
+	if !errors.As(err, &se) {
+		return err
+	}
+	switch se.Stage {
+	case pipeline.StageDecode:
+		return wrap(ErrInvalidInput, err)
+	case pipeline.StageOptions:
+		return wrap(ErrInvalidOption, err)
+	case pipeline.StageProfile:
+		return wrap(ErrInvalidProfile, err)
+	case pipeline.StageTransform:
+		return wrap(ErrTransform, err)
+	case pipeline.StageEncode:
+		return wrap(ErrEncode, err)
+	}
+	return err
+}
@@ -0,0 +1,68 @@
+package rgbtocmyk
+
+import (
+	"io"
+
+	"github.com/davesmith10/RGBtoCMYK/internal/jpeg"
+	"github.com/davesmith10/RGBtoCMYK/internal/pipeline"
+)
+
+// Info describes a JPEG file without decoding its pixels.
+type Info struct {
+	Width, Height int
+	Components    int
+	ColorSpace    string     // "RGB", "YCbCr", "CMYK", "YCCK" or "Grayscale"
+	Convention    Convention // CMYK and YCCK only
+	Coding        string     // e.g. "baseline" or "progressive"
+	Progressive   bool
+	ICC           []byte       // embedded ICC profile, nil if none
+	Profile       *ProfileInfo // description of ICC, nil if none or unreadable
+	XDPI, YDPI    float64      // print resolution from JFIF or EXIF, 0 if unknown
+	Quality       []Quality    // estimated quality of each component
+}
+
+// Quality is the estimated IJG quality of one component's quantization
+// table.
+type Quality struct {
+	Quality int
+	Base    string // base table it matched, e.g. "annex-k"
+	Exact   bool   // the table is exactly that base at that quality
+}
+
+// Identify reads the header, profile and metadata of a JPEG. Errors match
+// ErrInvalidInput.
+func Identify(src []byte) (*Info, error) {
+	info, err := jpeg.GetInfo(src)
+	if err != nil {
+		return nil, wrap(ErrInvalidInput, err)
+	}
+	density, _ := pipeline.SourceDensity(info.JFIF, info.APP1)
+	out := &Info{
+		Width:       info.Width,
+		Height:      info.Height,
+		Components:  info.NumComponents,
+		ColorSpace:  info.ColorSpace,
+		Convention:  Convention(info.Convention),
+		Coding:      info.Coding(),
+		Progressive: info.Progressive,
+		ICC:         info.ICC,
+		XDPI:        density.X,
+		YDPI:        density.Y,
+	}
+	if info.ICC != nil {
+		out.Profile, _ = DescribeProfile(info.ICC)
+	}
+	for _, c := range info.Components {
+		out.Quality = append(out.Quality, Quality{Quality: c.Quality.Quality, Base: c.Quality.Base, Exact: c.Quality.Exact})
+	}
+	return out, nil
+}
+
+// IdentifyReader reads a JPEG from r and describes it.
+func IdentifyReader(r io.Reader) (*Info, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, wrap(ErrInvalidInput, err)
+	}
+	return Identify(src)
+}
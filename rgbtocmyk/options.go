@@ -0,0 +1,217 @@
+// Package rgbtocmyk converts RGB JPEG images to CMYK JPEGs for print, as
+// the rgbtocmyk command does: ICC colour management with lcms2 (or the
+// pure-Go engine when built with -tags nolcms2), and JPEG encoding with
+// libjpeg-turbo using channel-aware quantization.
+//
+// Each function takes functional options, starting from DefaultOptions:
+//
+//	out, err := rgbtocmyk.Convert(data,
+//		rgbtocmyk.WithProfile(icc),
+//		rgbtocmyk.WithQuality(90))
+//
+// WithOptions sets every field at once from an Options value.
+package rgbtocmyk
+
+import (
+	"fmt"
+
+	"github.com/davesmith10/RGBtoCMYK/internal/color"
+	"github.com/davesmith10/RGBtoCMYK/internal/jpeg"
+)
+
+// Intent is an ICC rendering intent.
+type Intent int
+
+const (
+	Perceptual           Intent = color.IntentPerceptual
+	RelativeColorimetric Intent = color.IntentRelativeColorimetric
+	Saturation           Intent = color.IntentSaturation
+	AbsoluteColorimetric Intent = color.IntentAbsoluteColorimetric
+)
+
+// String returns the intent's command-line name, e.g. "relative".
+func (i Intent) String() string { return color.IntentName(int(i)) }
+
+// ParseIntent parses "perceptual", "relative", "saturation" or "absolute".
+// Errors match ErrInvalidOption.
+func ParseIntent(s string) (Intent, error) {
+	i, err := color.ParseIntent(s)
+	if err != nil {
+		return 0, wrap(ErrInvalidOption, err)
+	}
+	return Intent(i), nil
+}
+
+// Convention is how CMYK samples are stored in the output JPEG.
+type Convention int
+
+const (
+	// AdobeInverted stores inverted samples with an Adobe APP14 marker, as
+	// Photoshop does. It is the default and what most readers expect.
+	AdobeInverted Convention = Convention(jpeg.AdobeInverted)
+	// Plain stores samples as they are, without the marker.
+	Plain Convention = Convention(jpeg.Plain)
+)
+
+// String returns the convention's command-line name.
+func (c Convention) String() string { return jpeg.Convention(c).String() }
+
+// Options holds the settings of Convert, Transform and Encode. Each field
+// says which functions use it.
+type Options struct {
+	// Profile is the destination CMYK ICC profile, or an RGB to CMYK
+	// DeviceLink, for Convert and Transform; nil selects DefaultProfile.
+	// Encode embeds it in the output and embeds none if it is nil.
+	Profile []byte
+	// SourceProfile replaces the input's embedded profile (Convert,
+	// Transform).
+	SourceProfile []byte
+	// AssumeProfile is used for input without an embedded profile, instead
+	// of EXIF/XMP colour-space hints and sRGB (Convert, Transform).
+	AssumeProfile []byte
+	// RequireSourceProfile makes untagged input fail with
+	// ErrNoSourceProfile unless SourceProfile or AssumeProfile is set,
+	// rather than guessing its colour space (Convert, Transform).
+	RequireSourceProfile bool
+	// Intent is the rendering intent; a profile without tables for it
+	// falls back to another, reported in Result.Intent (Convert,
+	// Transform).
+	Intent Intent
+	// Quality is the JPEG quality of the K channel, 1-100 (Convert,
+	// Encode).
+	Quality int
+	// CMYReduction lowers the quality of the C, M and Y channels by this
+	// much, 0-99 (Convert, Encode).
+	CMYReduction int
+	// Progressive writes a progressive JPEG (Convert, Encode).
+	Progressive bool
+	// Convention is the CMYK sample storage (Convert, Encode).
+	Convention Convention
+	// DPI is the print resolution to record, not negative; 0 keeps the
+	// input's for Convert and records none for Encode.
+	DPI float64
+	// StripMetadata drops the input's EXIF, XMP and IPTC metadata, and
+	// StripGPS only its GPS data (Convert).
+	StripMetadata bool
+	StripGPS      bool
+	// Software names the calling application in the XMP conversion record;
+	// "" writes no record (Convert).
+	Software string
+}
+
+// DefaultOptions returns the settings the rgbtocmyk command uses by
+// default: perceptual intent, quality 85 with the CMY channels 15 lower,
+// baseline Adobe-inverted output.
+func DefaultOptions() Options {
+	return Options{Intent: Perceptual, Quality: 85, CMYReduction: 15, Convention: AdobeInverted}
+}
+
+// Option changes one setting.
+type Option func(*Options)
+
+// WithOptions replaces all settings with o; later options still apply.
+func WithOptions(o Options) Option { return func(opts *Options) { *opts = o } }
+
+// WithProfile sets the destination profile, or for Encode the profile to
+// embed. See LoadProfile for built-in profiles and files.
+func WithProfile(icc []byte) Option { return func(o *Options) { o.Profile = icc } }
+
+// WithSourceProfile overrides the input's embedded profile.
+func WithSourceProfile(icc []byte) Option { return func(o *Options) { o.SourceProfile = icc } }
+
+// WithAssumedProfile sets the source profile for untagged input.
+func WithAssumedProfile(icc []byte) Option { return func(o *Options) { o.AssumeProfile = icc } }
+
+// WithRequiredSourceProfile makes untagged input an error.
+func WithRequiredSourceProfile() Option { return func(o *Options) { o.RequireSourceProfile = true } }
+
+// WithIntent sets the rendering intent.
+func WithIntent(i Intent) Option { return func(o *Options) { o.Intent = i } }
+
+// WithQuality sets the JPEG quality, 1-100.
+func WithQuality(q int) Option { return func(o *Options) { o.Quality = q } }
+
+// WithCMYReduction sets how much lower the C, M and Y quality is.
+func WithCMYReduction(r int) Option { return func(o *Options) { o.CMYReduction = r } }
+
+// WithProgressive writes a progressive JPEG.
+func WithProgressive() Option { return func(o *Options) { o.Progressive = true } }
+
+// WithConvention sets the CMYK sample storage.
+func WithConvention(c Convention) Option { return func(o *Options) { o.Convention = c } }
+
+// WithDPI sets the print resolution in pixels per inch.
+func WithDPI(dpi float64) Option { return func(o *Options) { o.DPI = dpi } }
+
+// WithoutMetadata drops the input's EXIF, XMP and IPTC metadata.
+func WithoutMetadata() Option { return func(o *Options) { o.StripMetadata = true } }
+
+// WithoutGPS drops GPS data from the carried-over metadata.
+func WithoutGPS() Option { return func(o *Options) { o.StripGPS = true } }
+
+// WithSoftware names the calling application in the conversion record.
+func WithSoftware(name string) Option { return func(o *Options) { o.Software = name } }
+
+// resolve applies opts to the defaults and checks the result.
+func resolve(opts []Option) (Options, error) {
+	o := DefaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.Quality < 1 || o.Quality > 100 {
+		return o, fmt.Errorf("%w: quality must be 1-100, got %d", ErrInvalidOption, o.Quality)
+	}
+	if o.CMYReduction < 0 || o.CMYReduction > 99 {
+		return o, fmt.Errorf("%w: CMY reduction must be 0-99, got %d", ErrInvalidOption, o.CMYReduction)
+	}
+	if o.Intent < Perceptual || o.Intent > AbsoluteColorimetric {
+		return o, fmt.Errorf("%w: unknown rendering intent %d", ErrInvalidOption, o.Intent)
+	}
+	if o.DPI < 0 {
+		return o, fmt.Errorf("%w: DPI must not be negative, got %g", ErrInvalidOption, o.DPI)
+	}
+	if o.Convention != AdobeInverted && o.Convention != Plain {
+		return o, fmt.Errorf("%w: unknown CMYK convention %d", ErrInvalidOption, o.Convention)
+	}
+	return o, nil
+}
+
+// DefaultProfile is the built-in destination profile used when
+// Options.Profile is nil.
+const DefaultProfile = color.DefaultCMYKProfile
+
+// BuiltinProfiles lists the names LoadProfile accepts besides file paths.
+func BuiltinProfiles() []string { return color.BuiltinProfileNames() }
+
+// LoadProfile returns the built-in profile called nameOrPath, or reads and
+// validates the ICC file at that path. Errors match ErrInvalidProfile.
+func LoadProfile(nameOrPath string) ([]byte, error) {
+	data, err := color.ResolveProfile(nameOrPath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidProfile, err)
+	}
+	return data, nil
+}
+
+// ProfileInfo describes an ICC profile.
+type ProfileInfo struct {
+	Description string // the 'desc' tag, "" if missing
+	ColorSpace  string // e.g. "RGB", "CMYK"
+	Class       string // e.g. "Output", "Display", "DeviceLink"
+	Version     string // e.g. "4.3.0"
+}
+
+// DescribeProfile reads the header and description of an ICC profile.
+// Errors match ErrInvalidProfile.
+func DescribeProfile(icc []byte) (*ProfileInfo, error) {
+	pi, err := color.ParseProfileInfo(icc)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidProfile, err)
+	}
+	return &ProfileInfo{
+		Description: pi.Description,
+		ColorSpace:  color.ColorSpaceName(pi.ColorSpace),
+		Class:       color.ProfileClassName(pi.Class),
+		Version:     pi.Version,
+	}, nil
+}
@@ -0,0 +1,163 @@
+package rgbtocmyk
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	stdjpeg "image/jpeg"
+	"testing"
+)
+
+// testJPEG returns an untagged RGB JPEG with a colour gradient.
+func testJPEG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := img.PixOffset(x, y)
+			img.Pix[i] = uint8(x * 255 / w)
+			img.Pix[i+1] = uint8(y * 255 / h)
+			img.Pix[i+2] = 128
+			img.Pix[i+3] = 255
+		}
+	}
+	var buf bytes.Buffer
+	if err := stdjpeg.Encode(&buf, img, &stdjpeg.Options{Quality: 90}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestConvert(t *testing.T) {
+	src := testJPEG(t, 40, 24)
+	res, err := Convert(src, WithQuality(90), WithDPI(300))
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	if res.Width != 40 || res.Height != 24 || len(res.CMYK) != 40*24*4 {
+		t.Errorf("got %dx%d with %d CMYK bytes", res.Width, res.Height, len(res.CMYK))
+	}
+	if res.Quality != 90 || res.Intent != Perceptual {
+		t.Errorf("quality %d intent %v, want 90 perceptual", res.Quality, res.Intent)
+	}
+
+	info, err := Identify(res.Data)
+	if err != nil {
+		t.Fatalf("Identify: %v", err)
+	}
+	if info.ColorSpace != "CMYK" || info.Components != 4 || info.Width != 40 || info.Height != 24 {
+		t.Errorf("output is %s, %d components, %dx%d", info.ColorSpace, info.Components, info.Width, info.Height)
+	}
+	if info.Convention != AdobeInverted {
+		t.Errorf("convention %v, want %v", info.Convention, AdobeInverted)
+	}
+	if info.ICC == nil || info.Profile == nil || info.Profile.ColorSpace != "CMYK" {
+		t.Errorf("output profile %+v, want an embedded CMYK profile", info.Profile)
+	}
+	if info.XDPI != 300 || info.YDPI != 300 {
+		t.Errorf("density %gx%g, want 300", info.XDPI, info.YDPI)
+	}
+	if len(info.Quality) != 4 {
+		t.Errorf("%d quality estimates, want 4", len(info.Quality))
+	}
+
+	// Readers and writers give the same output.
+	var out bytes.Buffer
+	if _, err := ConvertReader(bytes.NewReader(src), &out, WithQuality(90), WithDPI(300)); err != nil {
+		t.Fatalf("ConvertReader: %v", err)
+	}
+	if !bytes.Equal(out.Bytes(), res.Data) {
+		t.Error("ConvertReader output differs from Convert")
+	}
+}
+
+func TestTransformEncode(t *testing.T) {
+	src := testJPEG(t, 16, 16)
+	var raw bytes.Buffer
+	res, err := TransformReader(bytes.NewReader(src), &raw)
+	if err != nil {
+		t.Fatalf("TransformReader: %v", err)
+	}
+	if res.Data != nil || raw.Len() != 16*16*4 {
+		t.Fatalf("got %d raw bytes and %d JPEG bytes", raw.Len(), len(res.Data))
+	}
+
+	var out bytes.Buffer
+	if err := EncodeReader(&raw, &out, 16, 16, WithConvention(Plain), WithProgressive()); err != nil {
+		t.Fatalf("EncodeReader: %v", err)
+	}
+	info, err := Identify(out.Bytes())
+	if err != nil {
+		t.Fatalf("Identify: %v", err)
+	}
+	if info.ColorSpace != "CMYK" || info.Convention != Plain || !info.Progressive {
+		t.Errorf("got %s %v progressive=%v", info.ColorSpace, info.Convention, info.Progressive)
+	}
+	if info.ICC != nil {
+		t.Error("Encode without a profile embedded one")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	src := testJPEG(t, 8, 8)
+	cmyk, err := Convert(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		run  func() error
+		want error
+	}{
+		{"not a JPEG", func() error { _, err := Convert([]byte("not a jpeg")); return err }, ErrInvalidInput},
+		{"CMYK input", func() error { _, err := Convert(cmyk.Data); return err }, ErrUnsupportedColorSpace},
+		{"CMYK transform", func() error { _, err := Transform(cmyk.Data); return err }, ErrUnsupportedColorSpace},
+		{"untagged input", func() error { _, err := Convert(src, WithRequiredSourceProfile()); return err }, ErrNoSourceProfile},
+		{"bad profile", func() error { _, err := Convert(src, WithProfile([]byte("junk"))); return err }, ErrInvalidProfile},
+		{"missing profile", func() error { _, err := LoadProfile("/nonexistent.icc"); return err }, ErrInvalidProfile},
+		{"short pixels", func() error { _, err := Encode(make([]byte, 10), 8, 8); return err }, ErrInvalidInput},
+		{"identify", func() error { _, err := Identify(nil); return err }, ErrInvalidInput},
+		{"quality", func() error { _, err := Convert(src, WithQuality(101)); return err }, ErrInvalidOption},
+		{"encode quality", func() error { _, err := Encode(cmyk.CMYK, 8, 8, WithQuality(0)); return err }, ErrInvalidOption},
+		{"convention", func() error { _, err := Convert(src, WithConvention(Convention(9))); return err }, ErrInvalidOption},
+		{"intent", func() error { _, err := Convert(src, WithIntent(Intent(9))); return err }, ErrInvalidOption},
+		{"negative CMY reduction", func() error { _, err := Convert(src, WithCMYReduction(-50)); return err }, ErrInvalidOption},
+		{"CMY reduction", func() error { _, err := Encode(cmyk.CMYK, 8, 8, WithCMYReduction(500)); return err }, ErrInvalidOption},
+		{"DPI", func() error { _, err := Convert(src, WithDPI(-72)); return err }, ErrInvalidOption},
+		{"intent name", func() error { _, err := ParseIntent("vivid"); return err }, ErrInvalidOption},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.run()
+			if !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	// An assumed profile satisfies RequireSourceProfile.
+	srgb, err := LoadProfile("srgb")
+	if err != nil {
+		t.Skipf("no built-in sRGB profile: %v", err)
+	}
+	if _, err := Convert(src, WithRequiredSourceProfile(), WithAssumedProfile(srgb)); err != nil {
+		t.Errorf("Convert with assumed profile: %v", err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	if _, err := resolve([]Option{WithQuality(0)}); err == nil {
+		t.Error("quality 0 accepted")
+	}
+	o, err := resolve([]Option{WithOptions(Options{Quality: 70, Convention: Plain}), WithCMYReduction(5)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Quality != 70 || o.CMYReduction != 5 || o.Convention != Plain || o.Intent != Perceptual {
+		t.Errorf("resolved %+v", o)
+	}
+	if i, err := ParseIntent(RelativeColorimetric.String()); err != nil || i != RelativeColorimetric {
+		t.Errorf("ParseIntent round trip: %v, %v", i, err)
+	}
+}